@@ -11,7 +11,10 @@ import (
 	"syscall"
 	"time"
 	"wayra/internal/adapter/config"
+	"wayra/internal/adapter/httpserver/handlers"
+	"wayra/internal/adapter/mqttclient"
 	"wayra/internal/adapter/repository"
+	"wayra/internal/core/service"
 	"wayra/internal/digcontainer"
 
 	"github.com/gin-gonic/gin"
@@ -49,13 +52,34 @@ func main() {
 		log.Fatalf("Failed to invoke DB migration: %s", err)
 	}
 
-	err = container.Invoke(func(router *gin.Engine, cfg *config.Config) {
+	err = container.Invoke(func(
+		router *gin.Engine,
+		cfg *config.Config,
+		mqttClient *mqttclient.Client,
+		connectivityService *service.ConnectivityService,
+		retentionService *service.RetentionService,
+		alertService *service.AlertService,
+		streamHandler *handlers.StreamHandler,
+	) {
 		log.Println("Starting server")
 
+		if err := mqttClient.Start(); err != nil {
+			log.Fatalf("MQTT client failed: %s", err)
+		}
+		defer mqttClient.Stop()
+
+		jobsCtx, stopJobs := context.WithCancel(context.Background())
+		defer stopJobs()
+		go connectivityService.RunOfflineChecker(jobsCtx, time.Minute)
+		go retentionService.RunRetention(jobsCtx, cfg.Retention.Interval)
+		go alertService.RunAlertEvaluation(jobsCtx, cfg.Alerts.Interval)
+
 		srv := &http.Server{
 			Addr:    "localhost:" + strconv.Itoa(cfg.Http.Port),
 			Handler: router,
 		}
+		// The streams never end on their own, they are closed so the shutdown does not wait for them
+		srv.RegisterOnShutdown(streamHandler.Shutdown)
 
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -0,0 +1,97 @@
+// Package main is the entry point of the device simulator.
+// It runs virtual devices that follow the protocol of the ESP32 firmware against a running wayra server.
+//
+// Example:
+//
+//	go run ./cmd/simulator -server http://localhost:8081 -token $JWT -devices 5 -route-id 1 \
+//		-scenarios calm,storm,sensor_fault -speed 60 -readings 24 -seed 42
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"wayra/internal/simulator"
+)
+
+func main() {
+	var (
+		serverURL       = flag.String("server", "http://localhost:8081", "address of the wayra server")
+		userToken       = flag.String("token", os.Getenv("WAYRA_TOKEN"), "JWT of a company manager, defaults to $WAYRA_TOKEN")
+		deviceTokens    = flag.String("device-tokens", "", "JSON file mapping device serials to device tokens")
+		devices         = flag.Int("devices", 1, "number of virtual devices")
+		serialPrefix    = flag.String("serial-prefix", "device_serial_", "serial of the n-th device is the prefix followed by n")
+		scenarios       = flag.String("scenarios", "calm", "comma separated scenarios assigned round-robin: calm, storm, freezing, sensor_fault")
+		seed            = flag.Int64("seed", 1, "seed of the weather and the sensor faults")
+		start           = flag.String("start", "", "virtual start time in RFC 3339, defaults to now")
+		speed           = flag.Float64("speed", 1, "virtual seconds per real second, 0 runs as fast as possible")
+		readings        = flag.Int("readings", 0, "readings each device sends before stopping, 0 for no limit")
+		routeID         = flag.Uint("route-id", 0, "route to create the waypoints of unknown serials on")
+		pushCoordinates = flag.Bool("push-coordinates", true, "push random coordinates on start like the firmware")
+		clockOffset     = flag.Duration("clock-offset", 0, "offset added to the sent dates, 3h reproduces the firmware's local time labelled as UTC")
+		timeout         = flag.Duration("timeout", 10*time.Second, "timeout of a request to the server")
+		verbose         = flag.Bool("v", false, "log every reading")
+	)
+	flag.Parse()
+
+	if *verbose {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+
+	parsedScenarios, err := simulator.ParseScenarios(*scenarios)
+	if err != nil {
+		log.Fatalf("Invalid scenarios: %s", err)
+	}
+
+	startTime := time.Now().UTC().Truncate(time.Second)
+	if *start != "" {
+		if startTime, err = time.Parse(time.RFC3339, *start); err != nil {
+			log.Fatalf("Invalid start time: %s", err)
+		}
+	}
+
+	tokens := map[string]string{}
+	if *deviceTokens != "" {
+		content, err := os.ReadFile(*deviceTokens)
+		if err != nil {
+			log.Fatalf("Failed to read device tokens: %s", err)
+		}
+		if err := json.Unmarshal(content, &tokens); err != nil {
+			log.Fatalf("Invalid device tokens file: %s", err)
+		}
+	}
+
+	if *devices < 1 {
+		log.Fatalf("At least one device is required")
+	}
+
+	sim := simulator.New(simulator.Config{
+		ServerURL:       *serverURL,
+		UserToken:       *userToken,
+		DeviceTokens:    tokens,
+		Devices:         *devices,
+		SerialPrefix:    *serialPrefix,
+		Scenarios:       parsedScenarios,
+		Seed:            *seed,
+		Start:           startTime,
+		Speed:           *speed,
+		Readings:        *readings,
+		RouteID:         uint(*routeID),
+		PushCoordinates: *pushCoordinates,
+		ClockOffset:     *clockOffset,
+		Timeout:         *timeout,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Starting %d virtual devices against %s", *devices, *serverURL)
+	stats := sim.Run(ctx)
+	log.Printf("Simulation finished: %s", stats)
+}
@@ -14,11 +14,17 @@ import (
 // This structure includes storage paths, HTTP server configuration,
 // authentication settings, and database credentials.
 type Config struct {
-	StoragePath   string     `yaml:"storage_path" env-required:"true"` // Path to the storage directory.
-	Http          HttpConfig `yaml:"http"`                             // HTTP server configuration.
-	AuthConfig    AuthConfig `yaml:"auth"`                             // Authentication configuration.
-	DBPassword    string     `yaml:"db_password" env-required:"true"`  // Database password.
-	EncryptionKey string     `yaml:"encryption_key"`                   // Encryption key for sensitive data.
+	StoragePath   string              `yaml:"storage_path" env-required:"true"` // Path to the storage directory.
+	Http          HttpConfig          `yaml:"http"`                             // HTTP server configuration.
+	AuthConfig    AuthConfig          `yaml:"auth"`                             // Authentication configuration.
+	DBPassword    string              `yaml:"db_password" env-required:"true"`  // Database password.
+	EncryptionKey string              `yaml:"encryption_key"`                   // Encryption key for sensitive data.
+	MQTT          MQTTConfig          `yaml:"mqtt"`                             // MQTT broker configuration.
+	Retention     RetentionConfig     `yaml:"retention"`                        // Retention of the sensor data.
+	Stream        StreamConfig        `yaml:"stream"`                           // Real-time streaming of the changes.
+	Regression    RegressionConfig    `yaml:"regression"`                       // Regression of the delivery speed.
+	Alerts        AlertsConfig        `yaml:"alerts"`                           // Evaluation of the alert rules.
+	Notifications NotificationsConfig `yaml:"notifications"`                    // Notification of the alerts.
 }
 
 // HttpConfig defines the HTTP server configuration.
@@ -35,6 +41,79 @@ type AuthConfig struct {
 	TokenExpiry time.Duration `yaml:"token_ttl"` // Token expiration duration.
 }
 
+// MQTTConfig defines the connection to the MQTT broker the devices publish their telemetry to.
+// The MQTT adapter is disabled when no broker URL is set.
+type MQTTConfig struct {
+	BrokerURL   string `yaml:"broker_url"`                           // URL of the broker, e.g. tcp://localhost:1883.
+	ClientID    string `yaml:"client_id" env-default:"wayra-server"` // Client ID of the server.
+	Username    string `yaml:"username"`                             // Username for the broker.
+	Password    string `yaml:"password"`                             // Password for the broker.
+	TopicPrefix string `yaml:"topic_prefix" env-default:"wayra"`     // First level of every topic.
+	QoS         byte   `yaml:"qos" env-default:"1"`                  // Quality of service of the subscriptions and messages.
+}
+
+// RetentionConfig defines how long the sensor data is kept.
+// Raw readings older than RawAge are rolled up into hourly and daily aggregates and purged,
+// hourly aggregates older than HourlyAge are purged and daily aggregates are kept.
+// Companies can override the ages by their ID, an age left at zero falls back to the default.
+type RetentionConfig struct {
+	RawAge    time.Duration                   `yaml:"raw_age" env-default:"720h"`     // Age after which raw readings are rolled up.
+	HourlyAge time.Duration                   `yaml:"hourly_age" env-default:"2160h"` // Age after which hourly aggregates are purged.
+	Interval  time.Duration                   `yaml:"interval" env-default:"1h"`      // Time between two runs of the retention job.
+	Companies map[uint]CompanyRetentionConfig `yaml:"companies"`                      // Overrides by company ID.
+}
+
+// CompanyRetentionConfig overrides the retention of the sensor data of a company.
+type CompanyRetentionConfig struct {
+	RawAge    time.Duration `yaml:"raw_age"`    // Age after which raw readings are rolled up.
+	HourlyAge time.Duration `yaml:"hourly_age"` // Age after which hourly aggregates are purged.
+}
+
+// StreamConfig defines the real-time streaming of the ingested readings, status changes and alerts.
+// A subscriber falling more than BufferSize events behind misses the events that do not fit,
+// and is told how many it missed.
+type StreamConfig struct {
+	BufferSize int           `yaml:"buffer_size" env-default:"64"` // Events a subscriber may fall behind.
+	Heartbeat  time.Duration `yaml:"heartbeat" env-default:"15s"`  // Time between two keep-alive messages.
+}
+
+// RegressionConfig defines the regression of the delivery speed on the weather of the routes.
+// Features are any of temperature, humidity, wind_speed, dew_point, heat_index and wind_chill,
+// the total weight of the delivery is always a feature.
+type RegressionConfig struct {
+	Features []string `yaml:"features" env-default:"temperature,humidity,wind_speed"` // Weather features, in the order of their coefficients.
+}
+
+// AlertsConfig defines how often the alert rules are evaluated at every route,
+// opening the alerts whose conditions started to hold and resolving the ones whose conditions cleared.
+type AlertsConfig struct {
+	Interval time.Duration `yaml:"interval" env-default:"1m"` // Time between two evaluations of the alert rules.
+}
+
+// NotificationsConfig defines how the alerts are sent to the notification channels of the companies.
+// A failed notification is sent again after InitialBackoff, the delay doubling up to MaxBackoff,
+// until MaxAttempts attempts failed.
+// Webhooks may not point to loopback, private or link-local addresses unless their host is in AllowedWebhookHosts.
+type NotificationsConfig struct {
+	MaxAttempts         int           `yaml:"max_attempts" env-default:"5"`     // Attempts before a delivery fails.
+	InitialBackoff      time.Duration `yaml:"initial_backoff" env-default:"2s"` // Delay before the second attempt.
+	MaxBackoff          time.Duration `yaml:"max_backoff" env-default:"5m"`     // Longest delay between two attempts.
+	Timeout             time.Duration `yaml:"timeout" env-default:"10s"`        // Time an attempt may take.
+	AllowedWebhookHosts []string      `yaml:"allowed_webhook_hosts"`            // Host names, addresses and CIDR networks webhooks may reach although they are not public.
+	SMTP                SMTPConfig    `yaml:"smtp"`                             // SMTP server of the email channels.
+}
+
+// SMTPConfig defines the SMTP server the email notifications are sent through.
+// Email channels are unavailable when no host is set. The server is used without authentication
+// when no username is set, like a local stand-in such as MailHog on localhost:1025.
+type SMTPConfig struct {
+	Host     string `yaml:"host"`                               // Host of the server.
+	Port     int    `yaml:"port" env-default:"25"`              // Port of the server.
+	Username string `yaml:"username"`                           // Username for the server.
+	Password string `yaml:"password"`                           // Password for the server.
+	From     string `yaml:"from" env-default:"wayra@localhost"` // Sender address of the emails.
+}
+
 // MustLoad loads the configuration file specified by the CONFIG_PATH
 // environment variable or the --config flag and panics if any error occurs.
 // This function ensures the configuration is properly loaded or terminates the application.
@@ -0,0 +1,346 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"wayra/internal/core/domain/dtos"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	dtoMapper "github.com/dranikpg/dto-mapper"
+	"github.com/gin-gonic/gin"
+)
+
+// AlertHandler is a handler for the alerts raised by the alert rules
+type AlertHandler struct {
+	alertService       services.AlertService       // service to handle alerts
+	companyService     services.CompanyService     // service to handle companies
+	routeService       services.RouteService       // service to handle routes
+	userCompanyService services.UserCompanyService // service to handle user-company relationships
+}
+
+// NewAlertHandler creates a new AlertHandler
+// alertService: service to handle alerts
+// companyService: service to handle companies
+// routeService: service to handle routes
+// userCompanyService: service to handle user-company relationships
+// returns: a new AlertHandler
+func NewAlertHandler(
+	alertService services.AlertService,
+	companyService services.CompanyService,
+	routeService services.RouteService,
+	userCompanyService services.UserCompanyService,
+) *AlertHandler {
+	return &AlertHandler{
+		alertService:       alertService,
+		companyService:     companyService,
+		routeService:       routeService,
+		userCompanyService: userCompanyService,
+	}
+}
+
+// AlertActionRequest is a struct to handle the request to acknowledge or resolve an alert
+type AlertActionRequest struct {
+	// Comment of the user, may be empty
+	// Example: Gritting truck dispatched.
+	Comment string `json:"comment" example:"Gritting truck dispatched."`
+}
+
+// GetCompanyAlerts godoc
+// @Summary      List the alerts of a company
+// @Description  Retrieves the alerts raised for the routes of the company, newest first, without their readings
+// @Tags         alerts
+// @Produce      json
+// @Param        company_id path int true "Company ID"
+// @Param        route_id query int false "Route ID"
+// @Param        waypoint_id query int false "Waypoint ID"
+// @Param        status query string false "open, acknowledged or resolved"
+// @Param        severity query string false "info, warning or critical"
+// @Param        from query string false "Alerts opened at or after, RFC 3339"
+// @Param        to query string false "Alerts opened before, RFC 3339"
+// @Param        limit query int false "Maximum number of alerts"
+// @Security     BearerAuth
+// @Router       /company/{company_id}/alerts [get]
+func (h *AlertHandler) GetCompanyAlerts(c *gin.Context) {
+	companyID, err := strconv.Atoi(c.Param("company_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid company ID format"})
+		return
+	}
+
+	if _, err := h.companyService.GetByID(context.Background(), uint(companyID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, uint(companyID)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's alerts"})
+		return
+	}
+
+	query, ok := parseAlertQuery(c)
+	if !ok {
+		return
+	}
+	query.CompanyID = uint(companyID)
+
+	if value := c.Query("route_id"); value != "" {
+		routeID, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID format"})
+			return
+		}
+		query.RouteID = uint(routeID)
+	}
+
+	h.writeAlerts(c, query)
+}
+
+// GetRouteAlerts godoc
+// @Summary      List the alerts of a route
+// @Description  Retrieves the alerts raised for the route, newest first, without their readings
+// @Tags         alerts
+// @Produce      json
+// @Param        route_id path int true "Route ID"
+// @Param        waypoint_id query int false "Waypoint ID"
+// @Param        status query string false "open, acknowledged or resolved"
+// @Param        severity query string false "info, warning or critical"
+// @Param        from query string false "Alerts opened at or after, RFC 3339"
+// @Param        to query string false "Alerts opened before, RFC 3339"
+// @Param        limit query int false "Maximum number of alerts"
+// @Security     BearerAuth
+// @Router       /routes/{route_id}/alerts [get]
+func (h *AlertHandler) GetRouteAlerts(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("route_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID format"})
+		return
+	}
+
+	route, err := h.routeService.GetByID(context.Background(), uint(routeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to access this route"})
+		return
+	}
+
+	query, ok := parseAlertQuery(c)
+	if !ok {
+		return
+	}
+	query.CompanyID = route.CompanyID
+	query.RouteID = route.ID
+
+	h.writeAlerts(c, query)
+}
+
+// GetAlert godoc
+// @Summary      Get an alert
+// @Description  Retrieves an alert with the readings that raised it
+// @Tags         alerts
+// @Produce      json
+// @Param        alert_id path int true "Alert ID"
+// @Security     BearerAuth
+// @Router       /alerts/{alert_id} [get]
+func (h *AlertHandler) GetAlert(c *gin.Context) {
+	alert, ok := h.getAlert(c)
+	if !ok {
+		return
+	}
+
+	writeAlert(c, alert)
+}
+
+// AcknowledgeAlert godoc
+// @Summary      Acknowledge an alert
+// @Description  Records that the user is dealing with an open alert
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Param        alert_id path int true "Alert ID"
+// @Param        request body AlertActionRequest false "Comment"
+// @Security     BearerAuth
+// @Router       /alerts/{alert_id}/acknowledge [post]
+func (h *AlertHandler) AcknowledgeAlert(c *gin.Context) {
+	h.changeAlert(c, h.alertService.Acknowledge)
+}
+
+// ResolveAlert godoc
+// @Summary      Resolve an alert
+// @Description  Closes an open or acknowledged alert. Should its conditions still hold, a new alert is opened
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Param        alert_id path int true "Alert ID"
+// @Param        request body AlertActionRequest false "Comment"
+// @Security     BearerAuth
+// @Router       /alerts/{alert_id}/resolve [post]
+func (h *AlertHandler) ResolveAlert(c *gin.Context) {
+	h.changeAlert(c, h.alertService.Resolve)
+}
+
+// changeAlert acknowledges or resolves the alert from the alert_id path parameter on behalf of the user
+// c: The gin context
+// change: method of the alert service changing the status of the alert
+func (h *AlertHandler) changeAlert(
+	c *gin.Context,
+	change func(ctx context.Context, alert *models.Alert, userID uint, comment string) error,
+) {
+	alert, ok := h.getAlert(c)
+	if !ok {
+		return
+	}
+
+	// The comment is optional, so is the body
+	var actionRequest AlertActionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&actionRequest); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := change(context.Background(), alert, *userID, actionRequest.Comment); err != nil {
+		if errors.Is(err, services.ErrAlertNotAcknowledgable) || errors.Is(err, services.ErrAlertResolved) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeAlert(c, alert)
+}
+
+// getAlert loads the alert from the alert_id path parameter, for a member of its company
+// c: The gin context
+// Returns: The alert with its readings and false if a response has already been written
+func (h *AlertHandler) getAlert(c *gin.Context) (*models.Alert, bool) {
+	alertID, err := strconv.Atoi(c.Param("alert_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID format"})
+		return nil, false
+	}
+
+	alert, err := h.alertService.GetByID(context.Background(), uint(alertID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return nil, false
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, alert.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to access this alert"})
+		return nil, false
+	}
+
+	return alert, true
+}
+
+// writeAlerts writes the alerts selected by the query as AlertDTOs
+// c: The gin context
+// query: query selecting the alerts
+func (h *AlertHandler) writeAlerts(c *gin.Context, query models.AlertQuery) {
+	alerts, err := h.alertService.GetAlerts(context.Background(), query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAlertQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	alertDTOs := []dtos.AlertDTO{}
+	if err = dtoMapper.Map(&alertDTOs, alerts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, alertDTOs)
+}
+
+// parseAlertQuery reads the waypoint_id, status, severity, from, to and limit query parameters
+// Unlike the readings, the alerts are not limited to the last 24 hours unless from or to is given.
+// c: The gin context
+// returns: the query without its company and false if a response has already been written
+func parseAlertQuery(c *gin.Context) (models.AlertQuery, bool) {
+	query := models.AlertQuery{
+		Status:   c.Query("status"),
+		Severity: c.Query("severity"),
+	}
+
+	if value := c.Query("waypoint_id"); value != "" {
+		waypointID, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+			return query, false
+		}
+		query.WaypointID = uint(waypointID)
+	}
+
+	if c.Query("from") != "" || c.Query("to") != "" {
+		from, to, ok := parseTimeRange(c)
+		if !ok {
+			return query, false
+		}
+		query.From, query.To = from, to
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return query, false
+	}
+	query.Limit = limit
+
+	return query, true
+}
+
+// writeAlert writes the alert as an AlertDTO, with the metrics derived from its readings
+// c: The gin context
+// alert: alert to write
+func writeAlert(c *gin.Context, alert *models.Alert) {
+	alertDTO := &dtos.AlertDTO{}
+	if err := dtoMapper.Map(alertDTO, alert); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range alertDTO.Readings {
+		deriveMetrics(&alertDTO.Readings[i])
+	}
+
+	c.JSON(http.StatusOK, alertDTO)
+}
@@ -0,0 +1,296 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"wayra/internal/core/domain/dtos"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	dtoMapper "github.com/dranikpg/dto-mapper"
+	"github.com/gin-gonic/gin"
+)
+
+// AlertRuleHandler is a handler for the weather alert rules of the companies
+type AlertRuleHandler struct {
+	alertRuleService   services.AlertRuleService   // service to handle alert rules
+	companyService     services.CompanyService     // service to handle companies
+	userCompanyService services.UserCompanyService // service to handle user-company relationships
+}
+
+// NewAlertRuleHandler creates a new AlertRuleHandler
+// alertRuleService: service to handle alert rules
+// companyService: service to handle companies
+// userCompanyService: service to handle user-company relationships
+// returns: a new AlertRuleHandler
+func NewAlertRuleHandler(
+	alertRuleService services.AlertRuleService,
+	companyService services.CompanyService,
+	userCompanyService services.UserCompanyService,
+) *AlertRuleHandler {
+	return &AlertRuleHandler{
+		alertRuleService:   alertRuleService,
+		companyService:     companyService,
+		userCompanyService: userCompanyService,
+	}
+}
+
+// AlertRuleRequest is a struct to handle the request to add or replace an alert rule
+type AlertRuleRequest struct {
+	// Type of the alert the rule raises
+	// Example: Storm Alert
+	Type string `json:"type" example:"Storm Alert"`
+
+	// Message of the alert the rule raises
+	// Example: High wind speed detected, potential storm risk.
+	Message string `json:"message" example:"High wind speed detected, potential storm risk."`
+
+	// Severity of the alert: info, warning or critical
+	// Example: warning
+	Severity string `json:"severity" example:"warning"`
+
+	// How the conditions are combined: and or or, and when omitted
+	// Example: and
+	Logic string `json:"logic" example:"and"`
+
+	// Minutes the conditions must hold before the alert is raised, 0 raises it on the first matching reading
+	// Example: 15
+	Duration int `json:"duration" example:"15"`
+
+	// Whether the rule is evaluated, true when omitted
+	// Example: true
+	Enabled *bool `json:"enabled" example:"true"`
+
+	// Conditions of the rule, on temperature, humidity, wind_speed, mean_pressure, dew_point, heat_index,
+	// wind_chill or dew_point_spread
+	Conditions []dtos.AlertConditionDTO `json:"conditions"`
+}
+
+// GetCompanyAlertRules godoc
+// @Summary      List the alert rules of a company
+// @Description  Retrieves the weather alert rules of the company. A company without rules gets the default rules
+// @Tags         alert-rules
+// @Produce      json
+// @Param        company_id path int true "Company ID"
+// @Security     BearerAuth
+// @Router       /company/{company_id}/alert-rules [get]
+func (h *AlertRuleHandler) GetCompanyAlertRules(c *gin.Context) {
+	companyID, userID, ok := h.getCompany(c)
+	if !ok {
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(userID, companyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's alert rules"})
+		return
+	}
+
+	rules, err := h.alertRuleService.GetByCompany(context.Background(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ruleDTOs := []dtos.AlertRuleDTO{}
+	if err = dtoMapper.Map(&ruleDTOs, rules); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ruleDTOs)
+}
+
+// CreateAlertRule godoc
+// @Summary      Add an alert rule to a company
+// @Description  Adds a weather alert rule evaluated for every route of the company
+// @Tags         alert-rules
+// @Accept       json
+// @Produce      json
+// @Param        company_id path int true "Company ID"
+// @Param        rule body AlertRuleRequest true "Alert rule"
+// @Security     BearerAuth
+// @Router       /company/{company_id}/alert-rules [post]
+func (h *AlertRuleHandler) CreateAlertRule(c *gin.Context) {
+	companyID, userID, ok := h.getCompany(c)
+	if !ok {
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, userID, companyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	rule := &models.AlertRule{CompanyID: companyID}
+	if !bindAlertRule(c, rule) {
+		return
+	}
+
+	if err := h.alertRuleService.Create(context.Background(), rule); err != nil {
+		writeAlertRuleError(c, err)
+		return
+	}
+
+	writeAlertRule(c, http.StatusCreated, rule)
+}
+
+// UpdateAlertRule godoc
+// @Summary      Replace an alert rule
+// @Description  Replaces every field and the conditions of a weather alert rule
+// @Tags         alert-rules
+// @Accept       json
+// @Produce      json
+// @Param        rule_id path int true "Alert rule ID"
+// @Param        rule body AlertRuleRequest true "Alert rule"
+// @Security     BearerAuth
+// @Router       /alert-rules/{rule_id} [put]
+func (h *AlertRuleHandler) UpdateAlertRule(c *gin.Context) {
+	rule, ok := h.getRule(c)
+	if !ok {
+		return
+	}
+
+	if !bindAlertRule(c, rule) {
+		return
+	}
+
+	if err := h.alertRuleService.Update(context.Background(), rule); err != nil {
+		writeAlertRuleError(c, err)
+		return
+	}
+
+	writeAlertRule(c, http.StatusOK, rule)
+}
+
+// DeleteAlertRule godoc
+// @Summary      Delete an alert rule
+// @Description  Deletes a weather alert rule. Disable the rules instead of deleting every one of them, a company without rules gets the default rules back
+// @Tags         alert-rules
+// @Produce      json
+// @Param        rule_id path int true "Alert rule ID"
+// @Security     BearerAuth
+// @Router       /alert-rules/{rule_id} [delete]
+func (h *AlertRuleHandler) DeleteAlertRule(c *gin.Context) {
+	rule, ok := h.getRule(c)
+	if !ok {
+		return
+	}
+
+	if err := h.alertRuleService.Delete(context.Background(), rule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted successfully"})
+}
+
+// getCompany reads the company_id path parameter and the user from the token
+// c: The gin context
+// Returns: The ID of the company, the ID of the user and false if a response has already been written
+func (h *AlertRuleHandler) getCompany(c *gin.Context) (uint, uint, bool) {
+	companyID, err := strconv.Atoi(c.Param("company_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid company ID format"})
+		return 0, 0, false
+	}
+
+	if _, err := h.companyService.GetByID(context.Background(), uint(companyID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return 0, 0, false
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, 0, false
+	}
+
+	return uint(companyID), *userID, true
+}
+
+// getRule loads the rule from the rule_id path parameter, for a manager of its company
+// c: The gin context
+// Returns: The rule and false if a response has already been written
+func (h *AlertRuleHandler) getRule(c *gin.Context) (*models.AlertRule, bool) {
+	ruleID, err := strconv.Atoi(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule ID format"})
+		return nil, false
+	}
+
+	rule, err := h.alertRuleService.GetByID(context.Background(), uint(ruleID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return nil, false
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	if !isCompanyManager(h.userCompanyService, *userID, rule.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return nil, false
+	}
+
+	return rule, true
+}
+
+// bindAlertRule reads the rule from the request body into the rule
+// c: The gin context
+// rule: rule to fill, keeping its ID and its company
+// Returns: false if a response has already been written
+func bindAlertRule(c *gin.Context, rule *models.AlertRule) bool {
+	var ruleRequest AlertRuleRequest
+	if err := c.ShouldBindJSON(&ruleRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return false
+	}
+
+	rule.Type = ruleRequest.Type
+	rule.Message = ruleRequest.Message
+	rule.Severity = ruleRequest.Severity
+	rule.Logic = ruleRequest.Logic
+	rule.Duration = ruleRequest.Duration
+	rule.Enabled = ruleRequest.Enabled == nil || *ruleRequest.Enabled
+	rule.Conditions = []models.AlertCondition{}
+	for _, condition := range ruleRequest.Conditions {
+		rule.Conditions = append(rule.Conditions, models.AlertCondition{
+			Metric:    condition.Metric,
+			Operator:  condition.Operator,
+			Threshold: condition.Threshold,
+		})
+	}
+
+	return true
+}
+
+// writeAlertRule writes the rule as an AlertRuleDTO
+// c: The gin context
+// status: HTTP status of the response
+// rule: rule to write
+func writeAlertRule(c *gin.Context, status int, rule *models.AlertRule) {
+	ruleDTO := &dtos.AlertRuleDTO{}
+	if err := dtoMapper.Map(ruleDTO, rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(status, ruleDTO)
+}
+
+// writeAlertRuleError writes the response for an error returned by the alert rule service
+// c: The gin context
+// err: error returned by the service
+func writeAlertRuleError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidAlertRule) || errors.Is(err, services.ErrInvalidAlertCondition) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
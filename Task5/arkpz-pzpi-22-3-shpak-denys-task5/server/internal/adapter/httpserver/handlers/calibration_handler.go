@@ -0,0 +1,434 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"wayra/internal/core/domain/dtos"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	dtoMapper "github.com/dranikpg/dto-mapper"
+	"github.com/gin-gonic/gin"
+)
+
+// CalibrationHandler is a handler for the calibration profiles of the devices and the waypoints
+type CalibrationHandler struct {
+	calibrationService services.CalibrationService // service to handle calibration profiles
+	deviceService      services.DeviceService      // service to handle devices
+	waypointService    services.WaypointService    // service to handle waypoints
+	userCompanyService services.UserCompanyService // service to handle user-company relationships
+}
+
+// NewCalibrationHandler creates a new CalibrationHandler
+// calibrationService: service to handle calibration profiles
+// deviceService: service to handle devices
+// waypointService: service to handle waypoints
+// userCompanyService: service to handle user-company relationships
+// returns: a new CalibrationHandler
+func NewCalibrationHandler(
+	calibrationService services.CalibrationService,
+	deviceService services.DeviceService,
+	waypointService services.WaypointService,
+	userCompanyService services.UserCompanyService,
+) *CalibrationHandler {
+	return &CalibrationHandler{
+		calibrationService: calibrationService,
+		deviceService:      deviceService,
+		waypointService:    waypointService,
+		userCompanyService: userCompanyService,
+	}
+}
+
+// CreateCalibrationRequest is a struct to handle the request to add a calibration profile
+// The raw value is mapped through the points, when there are any, then multiplied by the gain and added to the offset.
+type CreateCalibrationRequest struct {
+	// Measurement to correct: temperature, humidity, wind_speed or mean_pressure
+	// Example: humidity
+	Metric string `json:"metric" example:"humidity"`
+
+	// Date of the first reading to correct, now when omitted
+	// Example: 2024-12-01T00:00:00Z
+	ValidFrom string `json:"valid_from" example:"2024-12-01T00:00:00Z"`
+
+	// Gain multiplying the value, 1 when omitted
+	// Example: 1.02
+	Gain *float64 `json:"gain" example:"1.02"`
+
+	// Offset added to the value after the gain
+	// Example: -1.5
+	Offset float64 `json:"offset" example:"-1.5"`
+
+	// Correction table of raw and reference values, at least 2 points when given
+	Points []dtos.CalibrationPointDTO `json:"points"`
+
+	// Where the calibration comes from
+	// Example: Lab certificate 2024-117
+	Notes string `json:"notes" example:"Lab certificate 2024-117"`
+}
+
+// CreateDeviceCalibration godoc
+// @Summary      Add a calibration profile to a device
+// @Description  Adds a profile correcting a measurement of the readings the device records from valid_from on. Stored readings are corrected by the recalibrate endpoint
+// @Tags         calibration
+// @Accept       json
+// @Produce      json
+// @Param        device_id path int true "Device ID"
+// @Param        calibration body CreateCalibrationRequest true "Calibration profile"
+// @Security     BearerAuth
+// @Router       /devices/{device_id}/calibrations [post]
+func (h *CalibrationHandler) CreateDeviceCalibration(c *gin.Context) {
+	device, userID, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, userID, device.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	h.createCalibration(c, &models.CalibrationProfile{DeviceID: &device.ID, CreatedByID: &userID})
+}
+
+// CreateWaypointCalibration godoc
+// @Summary      Add a calibration profile to a waypoint
+// @Description  Adds a profile correcting a measurement of the readings recorded at the waypoint from valid_from on, unless the device has its own profile. Stored readings are corrected by the recalibrate endpoint
+// @Tags         calibration
+// @Accept       json
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Param        calibration body CreateCalibrationRequest true "Calibration profile"
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/calibrations [post]
+func (h *CalibrationHandler) CreateWaypointCalibration(c *gin.Context) {
+	waypoint, userID, ok := h.getWaypoint(c)
+	if !ok {
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	h.createCalibration(c, &models.CalibrationProfile{WaypointID: &waypoint.ID, CreatedByID: &userID})
+}
+
+// GetDeviceCalibrations godoc
+// @Summary      List the calibration profiles of a device
+// @Description  Retrieves the calibration profiles of the device, oldest first
+// @Tags         calibration
+// @Produce      json
+// @Param        device_id path int true "Device ID"
+// @Security     BearerAuth
+// @Router       /devices/{device_id}/calibrations [get]
+func (h *CalibrationHandler) GetDeviceCalibrations(c *gin.Context) {
+	device, userID, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(userID, device.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this device's calibrations"})
+		return
+	}
+
+	profiles, err := h.calibrationService.GetByDevice(context.Background(), device.ID)
+	writeCalibrations(c, profiles, err)
+}
+
+// GetWaypointCalibrations godoc
+// @Summary      List the calibration profiles of a waypoint
+// @Description  Retrieves the calibration profiles of the waypoint, oldest first
+// @Tags         calibration
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/calibrations [get]
+func (h *CalibrationHandler) GetWaypointCalibrations(c *gin.Context) {
+	waypoint, userID, ok := h.getWaypoint(c)
+	if !ok {
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this waypoint's calibrations"})
+		return
+	}
+
+	profiles, err := h.calibrationService.GetByWaypoint(context.Background(), waypoint.ID)
+	writeCalibrations(c, profiles, err)
+}
+
+// DeleteCalibration godoc
+// @Summary      Delete a calibration profile
+// @Description  Deletes a calibration profile. Stored readings keep their correction until they are recalibrated
+// @Tags         calibration
+// @Produce      json
+// @Param        calibration_id path int true "Calibration profile ID"
+// @Security     BearerAuth
+// @Router       /calibrations/{calibration_id} [delete]
+func (h *CalibrationHandler) DeleteCalibration(c *gin.Context) {
+	calibrationID, err := strconv.Atoi(c.Param("calibration_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calibration ID format"})
+		return
+	}
+
+	profile, err := h.calibrationService.GetByID(context.Background(), uint(calibrationID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calibration not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	companyID, err := h.companyOf(profile)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calibration not found"})
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, *userID, companyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := h.calibrationService.Delete(context.Background(), profile.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calibration deleted successfully"})
+}
+
+// RecalibrateDevice godoc
+// @Summary      Recalibrate the stored readings of a device
+// @Description  Corrects the readings the device recorded in the range again with the current profiles and flags them again. Readings already rolled up are not recalibrated
+// @Tags         calibration
+// @Produce      json
+// @Param        device_id path int true "Device ID"
+// @Param        from query string false "Start of the range, RFC 3339, defaults to 24 hours before to"
+// @Param        to query string false "End of the range, RFC 3339, defaults to now"
+// @Success      200 {object} models.RecalibrationResult
+// @Security     BearerAuth
+// @Router       /devices/{device_id}/recalibrate [post]
+func (h *CalibrationHandler) RecalibrateDevice(c *gin.Context) {
+	device, userID, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, userID, device.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	from, to, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	h.recalibrate(c, models.RecalibrationQuery{DeviceID: device.ID, From: from, To: to})
+}
+
+// RecalibrateWaypoint godoc
+// @Summary      Recalibrate the stored readings of a waypoint
+// @Description  Corrects the readings recorded at the waypoint in the range again with the current profiles and flags them again. Readings already rolled up are not recalibrated
+// @Tags         calibration
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Param        from query string false "Start of the range, RFC 3339, defaults to 24 hours before to"
+// @Param        to query string false "End of the range, RFC 3339, defaults to now"
+// @Success      200 {object} models.RecalibrationResult
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/recalibrate [post]
+func (h *CalibrationHandler) RecalibrateWaypoint(c *gin.Context) {
+	waypoint, userID, ok := h.getWaypoint(c)
+	if !ok {
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	from, to, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	h.recalibrate(c, models.RecalibrationQuery{WaypointID: waypoint.ID, From: from, To: to})
+}
+
+// createCalibration reads the profile from the request body and adds it
+// c: The gin context
+// profile: profile with its device or waypoint and its author
+func (h *CalibrationHandler) createCalibration(c *gin.Context, profile *models.CalibrationProfile) {
+	var calibrationRequest CreateCalibrationRequest
+	if err := c.ShouldBindJSON(&calibrationRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	validFrom, err := parseOptionalTime(calibrationRequest.ValidFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid valid_from format"})
+		return
+	}
+	if validFrom != nil {
+		profile.ValidFrom = validFrom.UTC()
+	}
+
+	profile.Metric = calibrationRequest.Metric
+	profile.Gain = 1
+	if calibrationRequest.Gain != nil {
+		profile.Gain = *calibrationRequest.Gain
+	}
+	profile.Offset = calibrationRequest.Offset
+	profile.Notes = calibrationRequest.Notes
+	for _, point := range calibrationRequest.Points {
+		profile.Points = append(profile.Points, models.CalibrationPoint{Raw: point.Raw, Reference: point.Reference})
+	}
+
+	if err := h.calibrationService.Create(context.Background(), profile); err != nil {
+		writeCalibrationError(c, err)
+		return
+	}
+
+	profileDTO := &dtos.CalibrationProfileDTO{}
+	if err := dtoMapper.Map(profileDTO, profile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, profileDTO)
+}
+
+// recalibrate runs the recalibration and writes its result
+// c: The gin context
+// query: device or waypoint and time range of the readings
+func (h *CalibrationHandler) recalibrate(c *gin.Context, query models.RecalibrationQuery) {
+	result, err := h.calibrationService.Recalibrate(context.Background(), query)
+	if err != nil {
+		writeCalibrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// getDevice loads the device from the device_id path parameter and the user from the token
+// c: The gin context
+// Returns: The device, the ID of the user and false if a response has already been written
+func (h *CalibrationHandler) getDevice(c *gin.Context) (*models.Device, uint, bool) {
+	deviceID, err := strconv.Atoi(c.Param("device_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID format"})
+		return nil, 0, false
+	}
+
+	device, err := h.deviceService.GetByID(context.Background(), uint(deviceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return nil, 0, false
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	return device, *userID, true
+}
+
+// getWaypoint loads the waypoint from the waypoint_id path parameter and the user from the token
+// c: The gin context
+// Returns: The waypoint with its route, the ID of the user and false if a response has already been written
+func (h *CalibrationHandler) getWaypoint(c *gin.Context) (*models.Waypoint, uint, bool) {
+	waypointID, err := strconv.Atoi(c.Param("waypoint_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+		return nil, 0, false
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), uint(waypointID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return nil, 0, false
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	return waypoint, *userID, true
+}
+
+// companyOf resolves the company owning the device or the waypoint of a profile
+// profile: profile to look at
+// Returns: The ID of the company and an error if the device or the waypoint is gone
+func (h *CalibrationHandler) companyOf(profile *models.CalibrationProfile) (uint, error) {
+	if profile.DeviceID != nil {
+		device, err := h.deviceService.GetByID(context.Background(), *profile.DeviceID)
+		if err != nil {
+			return 0, err
+		}
+		return device.CompanyID, nil
+	}
+
+	if profile.WaypointID == nil {
+		return 0, services.ErrInvalidCalibrationScope
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), *profile.WaypointID)
+	if err != nil {
+		return 0, err
+	}
+	return waypoint.Route.CompanyID, nil
+}
+
+// writeCalibrations writes the profiles as CalibrationProfileDTOs
+// c: The gin context
+// profiles: profiles to write
+// err: error returned when loading the profiles
+func writeCalibrations(c *gin.Context, profiles []models.CalibrationProfile, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	profileDTOs := []dtos.CalibrationProfileDTO{}
+	if err = dtoMapper.Map(&profileDTOs, profiles); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profileDTOs)
+}
+
+// writeCalibrationError writes the response for an error returned by the calibration service
+// c: The gin context
+// err: error returned by the service
+func writeCalibrationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCalibrationMetric),
+		errors.Is(err, services.ErrInvalidCalibrationScope),
+		errors.Is(err, services.ErrInvalidCalibrationGain),
+		errors.Is(err, services.ErrInvalidCalibrationTable),
+		errors.Is(err, services.ErrInvalidRecalibrationQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
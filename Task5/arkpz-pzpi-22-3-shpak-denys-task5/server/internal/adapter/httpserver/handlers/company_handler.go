@@ -134,7 +134,7 @@ func (h *CompanyHandler) RegisterCompany(c *gin.Context) {
 
 // GetCompany godoc
 // @Summary      Get company details
-// @Description  Retrieves the details of a company by its ID
+// @Description  Retrieves the details of a company by its ID, the waypoints of its routes come without their readings
 // @Tags         company
 // @Produce      json
 // @Param        company_id path int true "Company ID"
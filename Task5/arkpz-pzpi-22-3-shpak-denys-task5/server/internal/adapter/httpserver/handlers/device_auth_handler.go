@@ -0,0 +1,163 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"wayra/internal/core/domain/dtos"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	dtoMapper "github.com/dranikpg/dto-mapper"
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceAuthHandler is a handler for the credentials of devices
+type DeviceAuthHandler struct {
+	deviceAuthService  services.DeviceAuthService  // service to handle device credentials
+	waypointService    services.WaypointService    // service to handle waypoints
+	userCompanyService services.UserCompanyService // service to handle user-company relationships
+}
+
+// NewDeviceAuthHandler creates a new DeviceAuthHandler
+// deviceAuthService: service to handle device credentials
+// waypointService: service to handle waypoints
+// userCompanyService: service to handle user-company relationships
+// returns: a new DeviceAuthHandler
+func NewDeviceAuthHandler(
+	deviceAuthService services.DeviceAuthService,
+	waypointService services.WaypointService,
+	userCompanyService services.UserCompanyService,
+) *DeviceAuthHandler {
+	return &DeviceAuthHandler{
+		deviceAuthService:  deviceAuthService,
+		waypointService:    waypointService,
+		userCompanyService: userCompanyService,
+	}
+}
+
+// IssueDeviceCredentialResponse is the response to issuing a device credential
+type IssueDeviceCredentialResponse struct {
+	// Token the device sends in the "Authorization: Device {token}" header, it is only shown once
+	// Example: wd_3f9a1c...
+	Token string `json:"token" example:"wd_3f9a1c"`
+
+	// Credential is the issued credential
+	Credential dtos.DeviceCredentialDTO `json:"credential"`
+}
+
+// IssueDeviceCredential godoc
+// @Summary      Issue device credential
+// @Description  Issues a new token for the device of the waypoint. Previous tokens of the device are revoked
+// @Tags         device-auth
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/device-credentials [post]
+func (h *DeviceAuthHandler) IssueDeviceCredential(c *gin.Context) {
+	waypoint, userID, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+
+	token, credential, err := h.deviceAuthService.IssueCredential(context.Background(), *waypoint, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceSerialMissing) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := IssueDeviceCredentialResponse{Token: token}
+	if err = dtoMapper.Map(&response.Credential, credential); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetDeviceCredentials godoc
+// @Summary      List device credentials
+// @Description  Lists the credentials issued for the device of the waypoint, newest first. Tokens are never returned
+// @Tags         device-auth
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/device-credentials [get]
+func (h *DeviceAuthHandler) GetDeviceCredentials(c *gin.Context) {
+	waypoint, _, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+
+	credentials, err := h.deviceAuthService.ListCredentials(context.Background(), waypoint.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	credentialDTOs := []dtos.DeviceCredentialDTO{}
+	if err = dtoMapper.Map(&credentialDTOs, credentials); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, credentialDTOs)
+}
+
+// RevokeDeviceCredentials godoc
+// @Summary      Revoke device credentials
+// @Description  Revokes every active credential of the device of the waypoint
+// @Tags         device-auth
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/device-credentials [delete]
+func (h *DeviceAuthHandler) RevokeDeviceCredentials(c *gin.Context) {
+	waypoint, _, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+
+	if err := h.deviceAuthService.RevokeCredentials(context.Background(), waypoint.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Device credentials revoked successfully"})
+}
+
+// authorize checks that the user is an admin of the company owning the waypoint
+// c: The gin context
+// Returns: The waypoint, the ID of the user and false if a response has already been written
+func (h *DeviceAuthHandler) authorize(c *gin.Context) (*models.Waypoint, uint, bool) {
+	waypointID, err := strconv.Atoi(c.Param("waypoint_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+		return nil, 0, false
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), uint(waypointID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return nil, 0, false
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	if !isCompanyAdmin(h.userCompanyService, *userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return nil, 0, false
+	}
+
+	return waypoint, *userID, true
+}
@@ -0,0 +1,336 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"wayra/internal/core/domain/dtos"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	dtoMapper "github.com/dranikpg/dto-mapper"
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceConfigHandler is a handler for device configuration requests
+type DeviceConfigHandler struct {
+	deviceConfigService services.DeviceConfigService // service to handle device configs
+	waypointService     services.WaypointService     // service to handle waypoints
+	companyService      services.CompanyService      // service to handle companies
+	userCompanyService  services.UserCompanyService  // service to handle user-company relationships
+}
+
+// NewDeviceConfigHandler creates a new DeviceConfigHandler
+// deviceConfigService: service to handle device configs
+// waypointService: service to handle waypoints
+// companyService: service to handle companies
+// userCompanyService: service to handle user-company relationships
+// returns: a new DeviceConfigHandler
+func NewDeviceConfigHandler(
+	deviceConfigService services.DeviceConfigService,
+	waypointService services.WaypointService,
+	companyService services.CompanyService,
+	userCompanyService services.UserCompanyService,
+) *DeviceConfigHandler {
+	return &DeviceConfigHandler{
+		deviceConfigService: deviceConfigService,
+		waypointService:     waypointService,
+		companyService:      companyService,
+		userCompanyService:  userCompanyService,
+	}
+}
+
+// DeviceConfigRequest is a struct to handle the request to change a device config
+// Omitted fields keep their current value.
+type DeviceConfigRequest struct {
+	// Interval in minutes between two readings sent by the device
+	// Example: 20
+	SendDataFrequency *int `json:"send_data_frequency" example:"20"`
+
+	// Whether the device analyzes the weather on its own
+	// Example: true
+	GetWeatherAlerts *bool `json:"get_weather_alerts" example:"true"`
+}
+
+// GetDeviceConfig godoc
+// @Summary      Get device config
+// @Description  Returns the config the device of the waypoint should apply. Send the version in If-None-Match to get 304 when nothing changed
+// @Tags         device-config
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Param        If-None-Match header string false "Version of the config the device already applied"
+// @Security     BearerAuth
+// @Router       /device-config/{waypoint_id} [get]
+func (h *DeviceConfigHandler) GetDeviceConfig(c *gin.Context) {
+	waypoint, ok := h.getWaypoint(c)
+	if !ok {
+		return
+	}
+
+	if device := getDeviceFromContext(c); device != nil {
+		if device.WaypointID != waypoint.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Devices can only get the config of their own waypoint"})
+			return
+		}
+
+		h.writeEffectiveConfig(c, *waypoint)
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this waypoint's config"})
+		return
+	}
+
+	h.writeEffectiveConfig(c, *waypoint)
+}
+
+// UpdateDeviceConfig godoc
+// @Summary      Override device config of a waypoint
+// @Description  Overrides the company defaults for the device of the waypoint
+// @Tags         device-config
+// @Accept       json
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Param        config body DeviceConfigRequest true "Config values"
+// @Security     BearerAuth
+// @Router       /device-config/{waypoint_id} [put]
+func (h *DeviceConfigHandler) UpdateDeviceConfig(c *gin.Context) {
+	waypoint, ok := h.getWaypoint(c)
+	if !ok {
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, *userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	var configRequest DeviceConfigRequest
+	if err := c.ShouldBindJSON(&configRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	config, err := h.deviceConfigService.SaveWaypointOverride(context.Background(), *waypoint, models.DeviceConfig{
+		SendDataFrequency: configRequest.SendDataFrequency,
+		GetWeatherAlerts:  configRequest.GetWeatherAlerts,
+	})
+	if err != nil {
+		writeDeviceConfigError(c, err)
+		return
+	}
+
+	configDTO := &dtos.DeviceConfigDTO{}
+	if err = dtoMapper.Map(configDTO, config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, configDTO)
+}
+
+// DeleteDeviceConfig godoc
+// @Summary      Remove device config override of a waypoint
+// @Description  Removes the override so the device of the waypoint falls back to the company defaults
+// @Tags         device-config
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Security     BearerAuth
+// @Router       /device-config/{waypoint_id} [delete]
+func (h *DeviceConfigHandler) DeleteDeviceConfig(c *gin.Context) {
+	waypoint, ok := h.getWaypoint(c)
+	if !ok {
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, *userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := h.deviceConfigService.DeleteWaypointOverride(context.Background(), waypoint.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Device config override deleted successfully"})
+}
+
+// GetCompanyDeviceConfig godoc
+// @Summary      Get company device config defaults
+// @Description  Returns the device config defaults of the company
+// @Tags         device-config
+// @Produce      json
+// @Param        company_id path int true "Company ID"
+// @Security     BearerAuth
+// @Router       /company/{company_id}/device-config [get]
+func (h *DeviceConfigHandler) GetCompanyDeviceConfig(c *gin.Context) {
+	companyID, err := strconv.Atoi(c.Param("company_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid company ID format"})
+		return
+	}
+
+	if _, err := h.companyService.GetByID(context.Background(), uint(companyID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, uint(companyID)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's device config"})
+		return
+	}
+
+	config, err := h.deviceConfigService.GetCompanyDefaults(context.Background(), uint(companyID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if config == nil {
+		config = &models.DeviceConfig{CompanyID: uint(companyID)}
+	}
+
+	configDTO := &dtos.DeviceConfigDTO{}
+	if err = dtoMapper.Map(configDTO, config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, configDTO)
+}
+
+// UpdateCompanyDeviceConfig godoc
+// @Summary      Update company device config defaults
+// @Description  Changes the device config defaults applied to every waypoint of the company
+// @Tags         device-config
+// @Accept       json
+// @Produce      json
+// @Param        company_id path int true "Company ID"
+// @Param        config body DeviceConfigRequest true "Config values"
+// @Security     BearerAuth
+// @Router       /company/{company_id}/device-config [put]
+func (h *DeviceConfigHandler) UpdateCompanyDeviceConfig(c *gin.Context) {
+	companyID, err := strconv.Atoi(c.Param("company_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid company ID format"})
+		return
+	}
+
+	if _, err := h.companyService.GetByID(context.Background(), uint(companyID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, *userID, uint(companyID)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	var configRequest DeviceConfigRequest
+	if err := c.ShouldBindJSON(&configRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	config, err := h.deviceConfigService.SaveCompanyDefaults(context.Background(), uint(companyID), models.DeviceConfig{
+		SendDataFrequency: configRequest.SendDataFrequency,
+		GetWeatherAlerts:  configRequest.GetWeatherAlerts,
+	})
+	if err != nil {
+		writeDeviceConfigError(c, err)
+		return
+	}
+
+	configDTO := &dtos.DeviceConfigDTO{}
+	if err = dtoMapper.Map(configDTO, config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, configDTO)
+}
+
+// getWaypoint loads the waypoint from the waypoint_id path parameter
+// c: The gin context
+// Returns: The waypoint and false if a response has already been written
+func (h *DeviceConfigHandler) getWaypoint(c *gin.Context) (*models.Waypoint, bool) {
+	waypointID, err := strconv.Atoi(c.Param("waypoint_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+		return nil, false
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), uint(waypointID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return nil, false
+	}
+
+	return waypoint, true
+}
+
+// writeEffectiveConfig writes the config the device should apply, or 304 if the device already has it
+// c: The gin context
+// waypoint: waypoint to resolve the config for
+func (h *DeviceConfigHandler) writeEffectiveConfig(c *gin.Context, waypoint models.Waypoint) {
+	config, err := h.deviceConfigService.GetEffectiveConfig(context.Background(), waypoint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	etag := strconv.Quote(config.Version)
+	c.Header("ETag", etag)
+
+	if match := c.GetHeader("If-None-Match"); match == etag || match == config.Version {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, config)
+}
+
+// writeDeviceConfigError writes the response for an error returned while saving a device config
+// c: The gin context
+// err: error returned by the service
+func writeDeviceConfigError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidDeviceConfig) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,466 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"wayra/internal/core/domain/dtos"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	dtoMapper "github.com/dranikpg/dto-mapper"
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceHandler is a handler for the registry of devices
+type DeviceHandler struct {
+	deviceService      services.DeviceService      // service to handle devices
+	waypointService    services.WaypointService    // service to handle waypoints
+	companyService     services.CompanyService     // service to handle companies
+	userCompanyService services.UserCompanyService // service to handle user-company relationships
+}
+
+// NewDeviceHandler creates a new DeviceHandler
+// deviceService: service to handle devices
+// waypointService: service to handle waypoints
+// companyService: service to handle companies
+// userCompanyService: service to handle user-company relationships
+// returns: a new DeviceHandler
+func NewDeviceHandler(
+	deviceService services.DeviceService,
+	waypointService services.WaypointService,
+	companyService services.CompanyService,
+	userCompanyService services.UserCompanyService,
+) *DeviceHandler {
+	return &DeviceHandler{
+		deviceService:      deviceService,
+		waypointService:    waypointService,
+		companyService:     companyService,
+		userCompanyService: userCompanyService,
+	}
+}
+
+// CreateDeviceRequest is a struct to handle the request to register a device
+type CreateDeviceRequest struct {
+	// Serial number of the device
+	// Example: 123456789
+	Serial string `json:"serial" example:"123456789"`
+
+	// Hardware model of the device
+	// Example: ESP32-DHT22
+	Model string `json:"model" example:"ESP32-DHT22"`
+
+	// Version of the firmware running on the device
+	// Example: 1.2.0
+	FirmwareVersion string `json:"firmware_version" example:"1.2.0"`
+
+	// Date the device was put into service
+	// Example: 2024-12-01T12:00:00Z
+	InstalledAt string `json:"installed_at" example:"2024-12-01T12:00:00Z"`
+
+	// ID of the company owning the device
+	// Example: 1
+	CompanyID uint `json:"company_id" example:"1"`
+}
+
+// UpdateDeviceRequest is a struct to handle the request to update a device
+// Omitted fields keep their current value.
+type UpdateDeviceRequest struct {
+	// Hardware model of the device
+	// Example: ESP32-DHT22
+	Model string `json:"model" example:"ESP32-DHT22"`
+
+	// Version of the firmware running on the device
+	// Example: 1.2.0
+	FirmwareVersion string `json:"firmware_version" example:"1.2.0"`
+
+	// Date the device was put into service
+	// Example: 2024-12-01T12:00:00Z
+	InstalledAt string `json:"installed_at" example:"2024-12-01T12:00:00Z"`
+
+	// Status of the device: active, maintenance or retired. Retiring a device removes it from its waypoint
+	// Example: maintenance
+	Status string `json:"status" example:"maintenance"`
+}
+
+// AssignDeviceRequest is a struct to handle the request to install a device at a waypoint
+type AssignDeviceRequest struct {
+	// ID of the waypoint
+	// Example: 1
+	WaypointID uint `json:"waypoint_id" example:"1"`
+}
+
+// CreateDevice godoc
+// @Summary      Register a device
+// @Description  Registers a device of the company, it is installed at a waypoint with the assign endpoint
+// @Tags         device
+// @Accept       json
+// @Produce      json
+// @Param        device body CreateDeviceRequest true "Device details"
+// @Security     BearerAuth
+// @Router       /devices [post]
+func (h *DeviceHandler) CreateDevice(c *gin.Context) {
+	var deviceRequest CreateDeviceRequest
+	if err := c.ShouldBindJSON(&deviceRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if _, err := h.companyService.GetByID(context.Background(), deviceRequest.CompanyID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, *userID, deviceRequest.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	installedAt, err := parseOptionalTime(deviceRequest.InstalledAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid installed_at format"})
+		return
+	}
+
+	device := &models.Device{
+		Serial:          deviceRequest.Serial,
+		Model:           deviceRequest.Model,
+		FirmwareVersion: deviceRequest.FirmwareVersion,
+		InstalledAt:     installedAt,
+		CompanyID:       deviceRequest.CompanyID,
+	}
+
+	if err := h.deviceService.Create(context.Background(), device); err != nil {
+		writeDeviceError(c, err)
+		return
+	}
+
+	writeDevice(c, device)
+}
+
+// GetDevice godoc
+// @Summary      Get device details
+// @Description  Retrieves the details of a device
+// @Tags         device
+// @Produce      json
+// @Param        device_id path int true "Device ID"
+// @Security     BearerAuth
+// @Router       /devices/{device_id} [get]
+func (h *DeviceHandler) GetDevice(c *gin.Context) {
+	device, userID, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(userID, device.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this device"})
+		return
+	}
+
+	writeDevice(c, device)
+}
+
+// GetCompanyDevices godoc
+// @Summary      List the devices of a company
+// @Description  Retrieves every device registered by the company
+// @Tags         device
+// @Produce      json
+// @Param        company_id path int true "Company ID"
+// @Security     BearerAuth
+// @Router       /company/{company_id}/devices [get]
+func (h *DeviceHandler) GetCompanyDevices(c *gin.Context) {
+	companyID, err := strconv.Atoi(c.Param("company_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid company ID format"})
+		return
+	}
+
+	if _, err := h.companyService.GetByID(context.Background(), uint(companyID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, uint(companyID)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's devices"})
+		return
+	}
+
+	devices, err := h.deviceService.GetByCompany(context.Background(), uint(companyID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	deviceDTOs := []dtos.DeviceDTO{}
+	if err = dtoMapper.Map(&deviceDTOs, devices); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deviceDTOs)
+}
+
+// UpdateDevice godoc
+// @Summary      Update device details
+// @Description  Updates the details of a device
+// @Tags         device
+// @Accept       json
+// @Produce      json
+// @Param        device_id path int true "Device ID"
+// @Param        device body UpdateDeviceRequest true "Device details"
+// @Security     BearerAuth
+// @Router       /devices/{device_id} [put]
+func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
+	device, userID, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, userID, device.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	var deviceRequest UpdateDeviceRequest
+	if err := c.ShouldBindJSON(&deviceRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	installedAt, err := parseOptionalTime(deviceRequest.InstalledAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid installed_at format"})
+		return
+	}
+
+	changes := &models.Device{
+		ID:              device.ID,
+		Model:           deviceRequest.Model,
+		FirmwareVersion: deviceRequest.FirmwareVersion,
+		InstalledAt:     installedAt,
+		Status:          deviceRequest.Status,
+	}
+
+	if err := h.deviceService.Update(context.Background(), changes); err != nil {
+		writeDeviceError(c, err)
+		return
+	}
+
+	writeDevice(c, changes)
+}
+
+// DeleteDevice godoc
+// @Summary      Delete a device
+// @Description  Deletes a device that has never been installed. Devices with a history have to be retired
+// @Tags         device
+// @Produce      json
+// @Param        device_id path int true "Device ID"
+// @Security     BearerAuth
+// @Router       /devices/{device_id} [delete]
+func (h *DeviceHandler) DeleteDevice(c *gin.Context) {
+	device, userID, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, userID, device.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := h.deviceService.Delete(context.Background(), device.ID); err != nil {
+		writeDeviceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Device deleted successfully"})
+}
+
+// AssignDevice godoc
+// @Summary      Install a device at a waypoint
+// @Description  Installs the device at the waypoint. The device leaves its previous waypoint and replaces the device installed at the waypoint
+// @Tags         device
+// @Accept       json
+// @Produce      json
+// @Param        device_id path int true "Device ID"
+// @Param        assignment body AssignDeviceRequest true "Waypoint to install the device at"
+// @Security     BearerAuth
+// @Router       /devices/{device_id}/assign [post]
+func (h *DeviceHandler) AssignDevice(c *gin.Context) {
+	device, userID, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, userID, device.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	var assignRequest AssignDeviceRequest
+	if err := c.ShouldBindJSON(&assignRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), assignRequest.WaypointID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return
+	}
+
+	if err := h.deviceService.Assign(context.Background(), device, *waypoint, userID); err != nil {
+		writeDeviceError(c, err)
+		return
+	}
+
+	writeDevice(c, device)
+}
+
+// UnassignDevice godoc
+// @Summary      Remove a device from its waypoint
+// @Description  Removes the device from the waypoint it is installed at
+// @Tags         device
+// @Produce      json
+// @Param        device_id path int true "Device ID"
+// @Security     BearerAuth
+// @Router       /devices/{device_id}/unassign [post]
+func (h *DeviceHandler) UnassignDevice(c *gin.Context) {
+	device, userID, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, userID, device.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := h.deviceService.Unassign(context.Background(), device); err != nil {
+		writeDeviceError(c, err)
+		return
+	}
+
+	writeDevice(c, device)
+}
+
+// GetDeviceAssignments godoc
+// @Summary      Get the installation history of a device
+// @Description  Retrieves the waypoints the device has been installed at, newest first
+// @Tags         device
+// @Produce      json
+// @Param        device_id path int true "Device ID"
+// @Security     BearerAuth
+// @Router       /devices/{device_id}/assignments [get]
+func (h *DeviceHandler) GetDeviceAssignments(c *gin.Context) {
+	device, userID, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(userID, device.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this device"})
+		return
+	}
+
+	assignments, err := h.deviceService.GetAssignments(context.Background(), device.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	assignmentDTOs := []dtos.DeviceAssignmentDTO{}
+	if err = dtoMapper.Map(&assignmentDTOs, assignments); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, assignmentDTOs)
+}
+
+// getDevice loads the device from the device_id path parameter and the user from the token
+// c: The gin context
+// Returns: The device, the ID of the user and false if a response has already been written
+func (h *DeviceHandler) getDevice(c *gin.Context) (*models.Device, uint, bool) {
+	deviceID, err := strconv.Atoi(c.Param("device_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID format"})
+		return nil, 0, false
+	}
+
+	device, err := h.deviceService.GetByID(context.Background(), uint(deviceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return nil, 0, false
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	return device, *userID, true
+}
+
+// writeDevice writes the device as a DeviceDTO
+// c: The gin context
+// device: device to write
+func writeDevice(c *gin.Context, device *models.Device) {
+	deviceDTO := &dtos.DeviceDTO{}
+	if err := dtoMapper.Map(deviceDTO, device); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deviceDTO)
+}
+
+// writeDeviceError writes the response for an error returned by the device service
+// c: The gin context
+// err: error returned by the service
+func writeDeviceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDeviceSerialRequired),
+		errors.Is(err, services.ErrInvalidDeviceStatus),
+		errors.Is(err, services.ErrDeviceOtherCompany):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDeviceSerialTaken),
+		errors.Is(err, services.ErrDeviceRetired),
+		errors.Is(err, services.ErrDeviceNotAssigned),
+		errors.Is(err, services.ErrDeviceHasHistory):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// parseOptionalTime parses an RFC 3339 time, an empty string gives nil
+// value: time to parse
+// returns: the time and an error if the format is invalid
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
@@ -5,6 +5,23 @@
 // The handlers are responsible for handling the http requests and responses
 package handlers // import "wayra/internal/adapter/httpserver/handlers"
 
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"wayra/internal/core/domain/dtos"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/analysis"
+	utilsMeteo "wayra/internal/core/domain/utils/meteo"
+	utilsTime "wayra/internal/core/domain/utils/time"
+	"wayra/internal/core/port/services"
+
+	"github.com/gin-gonic/gin"
+)
+
 // The role of user is one of these constants
 const (
 	AdminRole = iota + 1
@@ -27,3 +44,144 @@ const (
 	InProgress = "in_progress"
 	Completed  = "completed"
 )
+
+// isCompanyAdmin checks if the user is an admin of the company
+// userCompanyService: service to handle user-company relationships
+// userID: ID of the user
+// companyID: ID of the company
+// returns: true if the user is an admin of the company
+func isCompanyAdmin(userCompanyService services.UserCompanyService, userID, companyID uint) bool {
+	userCompany, err := userCompanyService.Where(context.Background(), &models.UserCompany{
+		UserID:    userID,
+		CompanyID: companyID,
+	})
+	if err != nil || len(userCompany) == 0 {
+		return false
+	}
+
+	return userCompany[0].Role == string(RoleAdmin)
+}
+
+// getDeviceFromContext returns the credential of the device that sent the request
+// c: The gin context
+// returns: the credential, nil if the request was sent by a user
+func getDeviceFromContext(c *gin.Context) *models.DeviceCredential {
+	device, ok := c.Get("device")
+	if !ok {
+		return nil
+	}
+
+	credential, _ := device.(*models.DeviceCredential)
+	return credential
+}
+
+// isCompanyManager checks if the user is an admin or a manager of the company
+// userCompanyService: service to handle user-company relationships
+// userID: ID of the user
+// companyID: ID of the company
+// returns: true if the user can manage the company's resources
+func isCompanyManager(userCompanyService services.UserCompanyService, userID, companyID uint) bool {
+	userCompany, err := userCompanyService.Where(context.Background(), &models.UserCompany{
+		UserID:    userID,
+		CompanyID: companyID,
+	})
+	if err != nil || len(userCompany) == 0 {
+		return false
+	}
+
+	return userCompany[0].Role == string(RoleAdmin) || userCompany[0].Role == string(RoleManager)
+}
+
+// parseTimeRange reads the from and to query parameters
+// The range defaults to the last 24 hours.
+// c: The gin context
+// returns: the start and end of the range and false if a response has already been written
+func parseTimeRange(c *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, expected RFC 3339"})
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed.UTC()
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, expected RFC 3339"})
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed.UTC()
+	}
+
+	return from, to, true
+}
+
+// parseIncludeFlagged reads the include_flagged query parameter, readings flagged as suspect are left out by default
+// c: The gin context
+// returns: whether the suspect readings are included and false if a response has already been written
+func parseIncludeFlagged(c *gin.Context) (bool, bool) {
+	includeFlagged, err := strconv.ParseBool(c.DefaultQuery("include_flagged", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_flagged value"})
+		return false, false
+	}
+
+	return includeFlagged, true
+}
+
+// parseBucketQuery reads the from, to, bucket and include_flagged query parameters of a bucket query
+// The range defaults to the last 24 hours and the bucket to 1h.
+// c: The gin context
+// returns: the query and false if a response has already been written
+func parseBucketQuery(c *gin.Context) (models.BucketQuery, bool) {
+	var query models.BucketQuery
+
+	from, to, ok := parseTimeRange(c)
+	if !ok {
+		return query, false
+	}
+	query.From, query.To = from, to
+
+	bucket, err := utilsTime.ParseInterval(c.DefaultQuery("bucket", "1h"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bucket, expected e.g. 5m, 1h or 1d"})
+		return query, false
+	}
+	query.Bucket = bucket
+
+	if query.IncludeFlagged, ok = parseIncludeFlagged(c); !ok {
+		return query, false
+	}
+
+	return query, true
+}
+
+// deriveMetrics fills the derived meteorological metrics of a reading from its measured ones
+// reading: The reading to fill
+func deriveMetrics(reading *dtos.SensorDataDTO) {
+	reading.DewPoint = utilsMeteo.DewPoint(reading.Temperature, reading.Humidity)
+	reading.HeatIndex = utilsMeteo.HeatIndex(reading.Temperature, reading.Humidity)
+	reading.WindChill = utilsMeteo.WindChill(reading.Temperature, reading.WindSpeed)
+}
+
+// regressionEquation formats the equation of the regression of the delivery speed
+// features: The weather features of the regression
+// coeffs: The coefficients of the regression, the free term followed by the betas of the features and the total weight
+// includeWeight: Whether the total weight is part of the equation
+// returns: The equation, e.g. y = 1.000000 + 0.500000 * Temperature
+func regressionEquation(features []analysis.RegressionFeature, coeffs []float64, includeWeight bool) string {
+	var equation strings.Builder
+	fmt.Fprintf(&equation, "y = %f", coeffs[0])
+	for i, feature := range features {
+		fmt.Fprintf(&equation, " + %f * %s", coeffs[i+1], feature.Name())
+	}
+	if includeWeight {
+		fmt.Fprintf(&equation, " + %f * TotalWeight", coeffs[len(features)+1])
+	}
+
+	return equation.String()
+}
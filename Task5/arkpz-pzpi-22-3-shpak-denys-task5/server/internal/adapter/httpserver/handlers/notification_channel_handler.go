@@ -0,0 +1,417 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"wayra/internal/core/domain/dtos"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	dtoMapper "github.com/dranikpg/dto-mapper"
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationChannelHandler is a handler for the notification channels the alerts of the companies are sent to
+type NotificationChannelHandler struct {
+	notificationService services.NotificationService // service to handle notification channels
+	companyService      services.CompanyService      // service to handle companies
+	userCompanyService  services.UserCompanyService  // service to handle user-company relationships
+}
+
+// NewNotificationChannelHandler creates a new NotificationChannelHandler
+// notificationService: service to handle notification channels
+// companyService: service to handle companies
+// userCompanyService: service to handle user-company relationships
+// returns: a new NotificationChannelHandler
+func NewNotificationChannelHandler(
+	notificationService services.NotificationService,
+	companyService services.CompanyService,
+	userCompanyService services.UserCompanyService,
+) *NotificationChannelHandler {
+	return &NotificationChannelHandler{
+		notificationService: notificationService,
+		companyService:      companyService,
+		userCompanyService:  userCompanyService,
+	}
+}
+
+// NotificationChannelRequest is a struct to handle the request to add or replace a notification channel
+type NotificationChannelRequest struct {
+	// Name of the channel
+	// Example: Dispatch on-call
+	Name string `json:"name" example:"Dispatch on-call"`
+
+	// Kind of the channel: webhook or email
+	// Example: webhook
+	Kind string `json:"kind" example:"webhook"`
+
+	// Whether notifications are sent to the channel, true when omitted
+	// Example: true
+	Enabled *bool `json:"enabled" example:"true"`
+
+	// URL the webhook notifications are posted to
+	// Example: https://hooks.example.com/wayra
+	URL string `json:"url" example:"https://hooks.example.com/wayra"`
+
+	// Comma-separated addresses the email notifications are sent to
+	// Example: dispatch@example.com, ops@example.com
+	Recipients string `json:"recipients" example:"dispatch@example.com, ops@example.com"`
+
+	// Lowest severity of the alerts sent: info, warning or critical, info when omitted
+	// Example: warning
+	MinSeverity string `json:"min_severity" example:"warning"`
+
+	// Routes whose alerts are sent, every route of the company when empty
+	// Example: [1, 2]
+	RouteIDs []uint `json:"route_ids"`
+
+	// text/template of the subject, with the fields of the notification, the default one when empty
+	// Example: [{{.Severity}}] {{.Type}} on {{.RouteName}}
+	SubjectTemplate string `json:"subject_template" example:"[{{.Severity}}] {{.Type}} on {{.RouteName}}"`
+
+	// text/template of the body, with the fields of the notification, the default one when empty
+	// Example: {{.Message}} {{.Details}}
+	BodyTemplate string `json:"body_template" example:"{{.Message}} {{.Details}}"`
+
+	// Whether a new secret is generated for the webhook, only on replace
+	// Example: false
+	RotateSecret bool `json:"rotate_secret" example:"false"`
+}
+
+// NotificationChannelResponse is the response to adding or replacing a notification channel
+type NotificationChannelResponse struct {
+	// Secret the webhook notifications are signed with, it is only shown when it is generated
+	// Example: whsec_3f1c...
+	Secret string `json:"secret,omitempty" example:"whsec_3f1c"`
+
+	// Channel is the added or replaced channel
+	Channel dtos.NotificationChannelDTO `json:"channel"`
+}
+
+// GetCompanyNotificationChannels godoc
+// @Summary      List the notification channels of a company
+// @Description  Retrieves the webhooks and email address lists the alerts of the company are sent to
+// @Tags         notification-channels
+// @Produce      json
+// @Param        company_id path int true "Company ID"
+// @Security     BearerAuth
+// @Router       /company/{company_id}/notification-channels [get]
+func (h *NotificationChannelHandler) GetCompanyNotificationChannels(c *gin.Context) {
+	companyID, ok := h.getCompany(c)
+	if !ok {
+		return
+	}
+
+	channels, err := h.notificationService.GetByCompany(context.Background(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	channelDTOs := make([]dtos.NotificationChannelDTO, len(channels))
+	for i := range channels {
+		if err := mapNotificationChannel(&channelDTOs[i], &channels[i]); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, channelDTOs)
+}
+
+// CreateNotificationChannel godoc
+// @Summary      Add a notification channel to a company
+// @Description  Adds a webhook or an email address list the alerts opened for the routes of the company are sent to.
+// @Description  Webhooks receive a JSON POST signed in the X-Wayra-Signature header with HMAC-SHA256 of the
+// @Description  X-Wayra-Timestamp header, a dot and the body, keyed with the secret returned once here.
+// @Description  The URL of a webhook must not resolve to a loopback, private or link-local address
+// @Tags         notification-channels
+// @Accept       json
+// @Produce      json
+// @Param        company_id path int true "Company ID"
+// @Param        channel body NotificationChannelRequest true "Notification channel"
+// @Security     BearerAuth
+// @Router       /company/{company_id}/notification-channels [post]
+func (h *NotificationChannelHandler) CreateNotificationChannel(c *gin.Context) {
+	companyID, ok := h.getCompany(c)
+	if !ok {
+		return
+	}
+
+	channel := &models.NotificationChannel{CompanyID: companyID}
+	if !bindNotificationChannel(c, channel) {
+		return
+	}
+
+	if err := h.notificationService.Create(context.Background(), channel); err != nil {
+		writeNotificationChannelError(c, err)
+		return
+	}
+
+	writeNotificationChannel(c, http.StatusCreated, channel, channel.Secret)
+}
+
+// UpdateNotificationChannel godoc
+// @Summary      Replace a notification channel
+// @Description  Replaces every field and the routes of a notification channel. The secret of a webhook is kept
+// @Description  unless rotate_secret is set, a new secret is returned once
+// @Tags         notification-channels
+// @Accept       json
+// @Produce      json
+// @Param        channel_id path int true "Notification channel ID"
+// @Param        channel body NotificationChannelRequest true "Notification channel"
+// @Security     BearerAuth
+// @Router       /notification-channels/{channel_id} [put]
+func (h *NotificationChannelHandler) UpdateNotificationChannel(c *gin.Context) {
+	channel, ok := h.getChannel(c)
+	if !ok {
+		return
+	}
+
+	secret := channel.Secret
+	if !bindNotificationChannel(c, channel) {
+		return
+	}
+
+	if err := h.notificationService.Update(context.Background(), channel); err != nil {
+		writeNotificationChannelError(c, err)
+		return
+	}
+
+	if channel.Secret == secret {
+		secret = ""
+	} else {
+		secret = channel.Secret
+	}
+	writeNotificationChannel(c, http.StatusOK, channel, secret)
+}
+
+// DeleteNotificationChannel godoc
+// @Summary      Delete a notification channel
+// @Description  Deletes a notification channel with its delivery log
+// @Tags         notification-channels
+// @Produce      json
+// @Param        channel_id path int true "Notification channel ID"
+// @Security     BearerAuth
+// @Router       /notification-channels/{channel_id} [delete]
+func (h *NotificationChannelHandler) DeleteNotificationChannel(c *gin.Context) {
+	channel, ok := h.getChannel(c)
+	if !ok {
+		return
+	}
+
+	if err := h.notificationService.Delete(context.Background(), channel.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification channel deleted successfully"})
+}
+
+// GetNotificationDeliveries godoc
+// @Summary      List the deliveries of a notification channel
+// @Description  Retrieves the log of the notifications sent to the channel, newest first
+// @Tags         notification-channels
+// @Produce      json
+// @Param        channel_id path int true "Notification channel ID"
+// @Param        limit query int false "Maximum number of deliveries, 50 when omitted"
+// @Security     BearerAuth
+// @Router       /notification-channels/{channel_id}/deliveries [get]
+func (h *NotificationChannelHandler) GetNotificationDeliveries(c *gin.Context) {
+	channel, ok := h.getChannel(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	deliveries, err := h.notificationService.GetDeliveries(context.Background(), channel.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	deliveryDTOs := []dtos.NotificationDeliveryDTO{}
+	if err = dtoMapper.Map(&deliveryDTOs, deliveries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveryDTOs)
+}
+
+// TestNotificationChannel godoc
+// @Summary      Send a test notification
+// @Description  Sends a test notification to the channel once, even if it is disabled, and returns its delivery.
+// @Description  A delivery the channel did not accept has the failed status and the error
+// @Tags         notification-channels
+// @Produce      json
+// @Param        channel_id path int true "Notification channel ID"
+// @Security     BearerAuth
+// @Router       /notification-channels/{channel_id}/test [post]
+func (h *NotificationChannelHandler) TestNotificationChannel(c *gin.Context) {
+	channel, ok := h.getChannel(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.notificationService.SendTest(context.Background(), *channel)
+	if err != nil {
+		writeNotificationChannelError(c, err)
+		return
+	}
+
+	deliveryDTO := &dtos.NotificationDeliveryDTO{}
+	if err = dtoMapper.Map(deliveryDTO, delivery); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveryDTO)
+}
+
+// getCompany reads the company_id path parameter, for a manager of the company
+// c: The gin context
+// Returns: The ID of the company and false if a response has already been written
+func (h *NotificationChannelHandler) getCompany(c *gin.Context) (uint, bool) {
+	companyID, err := strconv.Atoi(c.Param("company_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid company ID format"})
+		return 0, false
+	}
+
+	if _, err := h.companyService.GetByID(context.Background(), uint(companyID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return 0, false
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, false
+	}
+
+	if !isCompanyManager(h.userCompanyService, *userID, uint(companyID)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return 0, false
+	}
+
+	return uint(companyID), true
+}
+
+// getChannel loads the channel from the channel_id path parameter, for a manager of its company
+// c: The gin context
+// Returns: The channel with its routes and false if a response has already been written
+func (h *NotificationChannelHandler) getChannel(c *gin.Context) (*models.NotificationChannel, bool) {
+	channelID, err := strconv.Atoi(c.Param("channel_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification channel ID format"})
+		return nil, false
+	}
+
+	channel, err := h.notificationService.GetByID(context.Background(), uint(channelID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification channel not found"})
+		return nil, false
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	if !isCompanyManager(h.userCompanyService, *userID, channel.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return nil, false
+	}
+
+	return channel, true
+}
+
+// bindNotificationChannel reads the channel from the request body into the channel
+// The secret of a webhook is kept unless it is rotated, the other kinds have none.
+// c: The gin context
+// channel: channel to fill, keeping its ID, its company and its secret
+// Returns: false if a response has already been written
+func bindNotificationChannel(c *gin.Context, channel *models.NotificationChannel) bool {
+	var channelRequest NotificationChannelRequest
+	if err := c.ShouldBindJSON(&channelRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return false
+	}
+
+	channel.Name = channelRequest.Name
+	channel.Kind = channelRequest.Kind
+	channel.Enabled = channelRequest.Enabled == nil || *channelRequest.Enabled
+	channel.URL = channelRequest.URL
+	channel.Recipients = channelRequest.Recipients
+	channel.MinSeverity = channelRequest.MinSeverity
+	channel.SubjectTemplate = channelRequest.SubjectTemplate
+	channel.BodyTemplate = channelRequest.BodyTemplate
+	if channelRequest.RotateSecret || channel.Kind != models.NotificationChannelWebhook {
+		channel.Secret = ""
+	}
+	channel.Routes = []models.Route{}
+	for _, routeID := range channelRequest.RouteIDs {
+		channel.Routes = append(channel.Routes, models.Route{ID: routeID})
+	}
+
+	return true
+}
+
+// mapNotificationChannel maps a channel to a NotificationChannelDTO, with the IDs of its routes
+// channelDTO: DTO to fill
+// channel: channel to map
+// Returns: an error
+func mapNotificationChannel(channelDTO *dtos.NotificationChannelDTO, channel *models.NotificationChannel) error {
+	if err := dtoMapper.Map(channelDTO, channel); err != nil {
+		return err
+	}
+
+	channelDTO.RouteIDs = make([]uint, 0, len(channel.Routes))
+	for _, route := range channel.Routes {
+		channelDTO.RouteIDs = append(channelDTO.RouteIDs, route.ID)
+	}
+
+	return nil
+}
+
+// writeNotificationChannel writes the channel as a NotificationChannelResponse
+// c: The gin context
+// status: HTTP status of the response
+// channel: channel to write
+// secret: secret of the webhook to show, empty to hide it
+func writeNotificationChannel(c *gin.Context, status int, channel *models.NotificationChannel, secret string) {
+	response := NotificationChannelResponse{Secret: secret}
+	if err := mapNotificationChannel(&response.Channel, channel); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(status, response)
+}
+
+// writeNotificationChannelError writes the response for an error returned by the notification service
+// c: The gin context
+// err: error returned by the service
+func writeNotificationChannelError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidNotificationChannel) ||
+		errors.Is(err, services.ErrNotificationKindUnavailable) ||
+		errors.Is(err, services.ErrInvalidWebhookURL) ||
+		errors.Is(err, services.ErrWebhookHostNotAllowed) ||
+		errors.Is(err, services.ErrInvalidEmailRecipients) ||
+		errors.Is(err, services.ErrInvalidNotificationTemplate) ||
+		errors.Is(err, services.ErrNotificationRouteOtherCompany) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -2,11 +2,13 @@ package handlers // import "wayra/internal/adapter/httpserver/handlers"
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 	"wayra/internal/core/domain/dtos"
 	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/analysis"
 	"wayra/internal/core/port/services"
 
 	dtoMapper "github.com/dranikpg/dto-mapper"
@@ -19,6 +21,7 @@ type RouteHandler struct {
 	companyService     services.CompanyService // service to handle company related operations
 	userCompanyService services.UserCompanyService // service to handle user-company related operations
 	deliveryService    services.DeliveryService // service to handle delivery related operations
+	qualityService     services.QualityService // service to flag the data-quality problems of the readings
 }
 
 // NewRoutesHandler creates a new RouteHandler
@@ -26,18 +29,21 @@ type RouteHandler struct {
 // companyService: service to handle company related operations
 // userCompanyService: service to handle user-company related operations
 // deliveryService: service to handle delivery related operations
+// qualityService: service to flag the data-quality problems of the readings
 // returns: a new RouteHandler
 func NewRoutesHandler(
 	routeService services.RouteService,
 	companyService services.CompanyService,
 	userCompanyService services.UserCompanyService,
 	deliveryService services.DeliveryService,
+	qualityService services.QualityService,
 ) *RouteHandler {
 	return &RouteHandler{
 		routeService:       routeService,
 		companyService:     companyService,
 		userCompanyService: userCompanyService,
 		deliveryService:    deliveryService,
+		qualityService:     qualityService,
 	}
 }
 
@@ -57,6 +63,14 @@ type UpdateRouteRequest struct {
 	// Name of the route
 	// example: Route 1
 	Name string `json:"name" example:"Route 1"`
+
+	// Weather condition reported for the route, recorded in the condition history
+	// example: bad_weather_detected
+	Status string `json:"status" example:"bad_weather_detected"`
+
+	// Explanation of the reported condition
+	// example: CV indicates abnormal weather conditions across multiple waypoints.
+	Details string `json:"details"`
 }
 
 // CreateRoute godoc
@@ -121,7 +135,8 @@ func (h *RouteHandler) CreateRoute(c *gin.Context) {
 
 // GetRoute godoc
 // @Summary      Get a route
-// @Description  Retrieves a route with the given ID
+// @Description  Retrieves a route with the given ID, its waypoints come without their readings:
+// @Description  they are read from /routes/{route_id}/get-sensor-data or /waypoints/{waypoint_id}/sensor-data
 // @Tags         route
 // @Produce      json
 // @Param        route_id path int true "Route ID"
@@ -162,7 +177,7 @@ func (h *RouteHandler) GetRoute(c *gin.Context) {
 
 // UpdateRoute godoc
 // @Summary      Update an existing route
-// @Description  Updates an existing route with the given ID
+// @Description  Updates an existing route with the given ID. A device may only report the condition of the route of its waypoint
 // @Tags         route
 // @Accept       json
 // @Produce      json
@@ -183,24 +198,24 @@ func (h *RouteHandler) UpdateRoute(c *gin.Context) {
 		return
 	}
 
-	userID, err := getUserIDFromToken(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
+	var userID *uint
+	device := getDeviceFromContext(c)
+	if device != nil {
+		if device.Waypoint.RouteID != route.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Devices can only update the route of their own waypoint"})
+			return
+		}
+	} else {
+		userID, err = getUserIDFromToken(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
 
-	userCompany, err := h.userCompanyService.Where(context.Background(), &models.UserCompany{
-		UserID:    *userID,
-		CompanyID: route.CompanyID,
-	})
-	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-		return
-	}
-
-	if userCompany[0].Role != string(RoleAdmin) && userCompany[0].Role != string(RoleManager) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
-		return
+		if !isCompanyManager(h.userCompanyService, *userID, route.CompanyID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
 	}
 
 	var routeRequest UpdateRouteRequest
@@ -209,7 +224,28 @@ func (h *RouteHandler) UpdateRoute(c *gin.Context) {
 		return
 	}
 
-	if routeRequest.Name != "" {
+	if routeRequest.Status != "" {
+		event := &models.RouteConditionEvent{
+			Status:       routeRequest.Status,
+			Details:      routeRequest.Details,
+			ReportedByID: userID,
+		}
+		if device != nil {
+			event.SourceDevice = device.DeviceSerial
+		}
+
+		err := h.routeService.ReportCondition(context.Background(), route, event)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidRouteCondition) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if routeRequest.Name != "" && device == nil {
 		route.Name = routeRequest.Name
 	}
 
@@ -230,6 +266,60 @@ func (h *RouteHandler) UpdateRoute(c *gin.Context) {
 	c.JSON(http.StatusOK, routeDTO)
 }
 
+// GetRouteConditionHistory godoc
+// @Summary      Get route condition history
+// @Description  Retrieves the weather conditions reported for the route, newest first
+// @Tags         route
+// @Produce      json
+// @Param        route_id path int true "Route ID"
+// @Param        limit query int false "Maximum number of events"
+// @Security     BearerAuth
+// @Router       /routes/{route_id}/condition-history [get]
+func (h *RouteHandler) GetRouteConditionHistory(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("route_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID format"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	route, err := h.routeService.GetByID(context.Background(), uint(routeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to access this route"})
+		return
+	}
+
+	events, err := h.routeService.GetConditionHistory(context.Background(), route.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	eventDTOs := []dtos.StatusEventDTO{}
+	if err = dtoMapper.Map(&eventDTOs, events); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, eventDTOs)
+}
+
 // DeleteRoute godoc
 // @Summary      Delete a route
 // @Description  Deletes a route with the given ID
@@ -282,6 +372,7 @@ func (h *RouteHandler) DeleteRoute(c *gin.Context) {
 // GetOptimalRoute godoc
 // @Summary      Get optimal route
 // @Description  Retrieves the optimal route for the given route ID
+// @Description  404 when the company has no completed delivery to predict the delivery speed from.
 // @Tags         analytics
 // @Produce      json
 // @Param        delivery_id path int true "delivery_id"
@@ -313,6 +404,10 @@ func (h *RouteHandler) GetOptimalRoute(c *gin.Context) {
 
 	message, predictData, coeffs, route, err := h.routeService.GetOptimalRoute(context.Background(), delivery, true, true)
 	if err != nil {
+		if errors.Is(err, services.ErrNoDeliveryHistory) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -328,14 +423,7 @@ func (h *RouteHandler) GetOptimalRoute(c *gin.Context) {
 		return
 	}
 
-	equation := fmt.Sprintf(
-		"y = %f + %f * Temperature + %f * Humidity + %f * WindSpeed + %f * TotalWeight",
-		coeffs[0],
-		coeffs[1],
-		coeffs[2],
-		coeffs[3],
-		coeffs[4],
-	)
+	equation := regressionEquation(h.routeService.RegressionFeatures(), coeffs, true)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":      message,
@@ -386,9 +474,110 @@ func (h *RouteHandler) GetWeatherAlert(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
 }
 
+// RouteSensorDataResponse is the response of GetRouteSensorData when statistics are requested
+type RouteSensorDataResponse struct {
+	// Readings are the latest readings of every waypoint of the route
+	Readings []dtos.SensorDataDTO `json:"readings"`
+
+	// Statistics are the statistics of every waypoint followed by the statistics of the whole route
+	Statistics []models.SensorDataStatistics `json:"statistics"`
+}
+
+// GetRouteSensorData godoc
+// @Summary      Get latest sensor data of a route
+// @Description  Retrieves the latest readings of every waypoint of the route. With include_stats the readings are returned together with mean, standard deviation and coefficient of variation
+// @Tags         route
+// @Produce      json
+// @Param        route_id path int true "Route ID"
+// @Param        limit query int false "Readings per waypoint (1-100, default 1)"
+// @Param        include_stats query bool false "Include summary statistics"
+// @Param        include_flagged query bool false "Include the readings flagged as outliers, spikes or frozen sensors"
+// @Security     BearerAuth
+// @Router       /routes/{route_id}/get-sensor-data [get]
+func (h *RouteHandler) GetRouteSensorData(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("route_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID format"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "1"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number between 1 and 100"})
+		return
+	}
+
+	includeStats, err := strconv.ParseBool(c.DefaultQuery("include_stats", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_stats value"})
+		return
+	}
+
+	includeFlagged, ok := parseIncludeFlagged(c)
+	if !ok {
+		return
+	}
+
+	route, err := h.routeService.GetByID(context.Background(), uint(routeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	if device := getDeviceFromContext(c); device != nil {
+		if device.Waypoint.RouteID != route.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Devices can only get the sensor data of the route of their own waypoint"})
+			return
+		}
+	} else {
+		userID, err := getUserIDFromToken(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if !h.userCompanyService.UserBelongsToCompany(*userID, route.CompanyID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to access this route"})
+			return
+		}
+	}
+
+	readings, err := h.routeService.GetLatestSensorData(context.Background(), route.ID, limit, includeFlagged)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	readingDTOs := []dtos.SensorDataDTO{}
+	if err = dtoMapper.Map(&readingDTOs, readings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range readingDTOs {
+		deriveMetrics(&readingDTOs[i])
+	}
+
+	if !includeStats {
+		c.JSON(http.StatusOK, readingDTOs)
+		return
+	}
+
+	statistics, err := h.routeService.GetSensorDataStatistics(context.Background(), route.ID, limit, includeFlagged)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, RouteSensorDataResponse{
+		Readings:   readingDTOs,
+		Statistics: statistics,
+	})
+}
+
 // GetOptimalBackRoute godoc
 // @Summary      Get optimal back route
 // @Description  Retrieves the optimal back route for the given route ID
+// @Description  404 when the company has no completed delivery to predict the delivery speed from.
 // @Tags         analytics
 // @Produce      json
 // @Param        delivery_id path int true "delivery_id"
@@ -420,6 +609,10 @@ func (h *RouteHandler) GetOptimalBackRoute(c *gin.Context) {
 
 	message, predictData, coeffs, route, err := h.routeService.GetOptimalRoute(context.Background(), delivery, false, false)
 	if err != nil {
+		if errors.Is(err, services.ErrNoDeliveryHistory) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -435,13 +628,7 @@ func (h *RouteHandler) GetOptimalBackRoute(c *gin.Context) {
 		return
 	}
 
-	equation := fmt.Sprintf(
-		"y = %f + %f * Temperature + %f * Humidity + %f * WindSpeed",
-		coeffs[0],
-		coeffs[1],
-		coeffs[2],
-		coeffs[3],
-	)
+	equation := regressionEquation(h.routeService.RegressionFeatures(), coeffs, false)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":      message,
@@ -450,3 +637,225 @@ func (h *RouteHandler) GetOptimalBackRoute(c *gin.Context) {
 		"equation":     equation,
 	})
 }
+
+// RouteClassificationResponse is the response of GetRouteClassification
+type RouteClassificationResponse struct {
+	// From is the start of the analyzed window
+	// example: 2024-12-01T11:00:00Z
+	From time.Time `json:"from"`
+
+	// To is the end of the analyzed window
+	// example: 2024-12-01T12:00:00Z
+	To time.Time `json:"to"`
+
+	// Config holds the thresholds the waypoints were classified with
+	Config analysis.ClassifierConfig `json:"config"`
+
+	// Waypoints holds the classification of the device of every waypoint
+	Waypoints []analysis.WaypointClassification `json:"waypoints"`
+}
+
+// GetRouteClassification godoc
+// @Summary      Classify route devices
+// @Description  Tells sensor malfunctions from bad weather by comparing the variation of the readings of every waypoint with the rest of the route, like the firmware does on the devices
+// @Tags         route
+// @Produce      json
+// @Param        route_id path int true "Route ID"
+// @Param        window query string false "Analyzed window ending at until, e.g. 1h (default)"
+// @Param        until query string false "End of the window in RFC 3339, defaults to now"
+// @Param        anomaly_factor query number false "How many times the CV of a device may exceed the CV of its peers (default 1.5)"
+// @Param        stable_cv query number false "Peers with every CV below this value are stable (default 0.1)"
+// @Param        min_readings query int false "Readings a device and its peers need to be classified (default 3)"
+// @Param        include_flagged query bool false "Include the readings flagged as outliers, spikes or frozen sensors"
+// @Security     BearerAuth
+// @Router       /routes/{route_id}/classification [get]
+func (h *RouteHandler) GetRouteClassification(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("route_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID format"})
+		return
+	}
+
+	window, err := time.ParseDuration(c.DefaultQuery("window", "1h"))
+	if err != nil || window <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window"})
+		return
+	}
+
+	to := time.Now().UTC()
+	if until := c.Query("until"); until != "" {
+		if to, err = time.Parse(time.RFC3339, until); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until, expected RFC 3339"})
+			return
+		}
+	}
+
+	config := analysis.DefaultClassifierConfig()
+	if config.AnomalyFactor, err = strconv.ParseFloat(c.DefaultQuery("anomaly_factor", "1.5"), 64); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid anomaly_factor"})
+		return
+	}
+	if config.StableCV, err = strconv.ParseFloat(c.DefaultQuery("stable_cv", "0.1"), 64); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stable_cv"})
+		return
+	}
+	if config.MinReadings, err = strconv.Atoi(c.DefaultQuery("min_readings", "3")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_readings"})
+		return
+	}
+
+	includeFlagged, ok := parseIncludeFlagged(c)
+	if !ok {
+		return
+	}
+
+	route, err := h.routeService.GetByID(context.Background(), uint(routeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to access this route"})
+		return
+	}
+
+	from := to.Add(-window)
+	classifications, err := h.routeService.ClassifyWaypoints(context.Background(), *route, from, to, config, includeFlagged)
+	if err != nil {
+		if errors.Is(err, analysis.ErrInvalidClassifierConfig) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, RouteClassificationResponse{
+		From:      from,
+		To:        to,
+		Config:    config,
+		Waypoints: classifications,
+	})
+}
+
+// GetRouteSensorDataBuckets godoc
+// @Summary      Get sensor data of a route over time
+// @Description  Returns the min, max, average and count of every measurement of the route per time bucket in [from, to). Buckets without readings are left out
+// @Tags         route
+// @Produce      json
+// @Param        route_id path int true "Route ID"
+// @Param        from query string false "Start of the range in RFC 3339, defaults to 24 hours before to"
+// @Param        to query string false "End of the range in RFC 3339, defaults to now"
+// @Param        bucket query string false "Size of a bucket, e.g. 5m, 1h (default) or 1d"
+// @Param        per_waypoint query bool false "Give every waypoint its own buckets instead of aggregating the whole route"
+// @Param        include_flagged query bool false "Include the readings flagged as outliers, spikes or frozen sensors that are not purged yet"
+// @Security     BearerAuth
+// @Router       /routes/{route_id}/sensor-data [get]
+func (h *RouteHandler) GetRouteSensorDataBuckets(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("route_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID format"})
+		return
+	}
+
+	query, ok := parseBucketQuery(c)
+	if !ok {
+		return
+	}
+
+	perWaypoint, err := strconv.ParseBool(c.DefaultQuery("per_waypoint", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid per_waypoint value"})
+		return
+	}
+
+	route, err := h.routeService.GetByID(context.Background(), uint(routeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to access this route"})
+		return
+	}
+
+	buckets, err := h.routeService.GetSensorDataBuckets(context.Background(), route.ID, query, perWaypoint)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBucketQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, buckets)
+}
+
+// AssessRouteSensorDataQuality godoc
+// @Summary      Reassess the data quality of the sensor data of a route
+// @Description  Recomputes the outlier, spike, stuck and gap flags of the readings of every waypoint of the route
+// @Description  recorded in the time range, e.g. for the readings stored before they were flagged. Only for managers.
+// @Tags         route
+// @Produce      json
+// @Param        route_id path int true "Route ID"
+// @Param        from query string false "Start of the range in RFC 3339, defaults to 24 hours before to"
+// @Param        to query string false "End of the range in RFC 3339, defaults to now"
+// @Success      200 {object} models.QualityAssessment
+// @Security     BearerAuth
+// @Router       /routes/{route_id}/sensor-data/assess-quality [post]
+func (h *RouteHandler) AssessRouteSensorDataQuality(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("route_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID format"})
+		return
+	}
+
+	from, to, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+
+	route, err := h.routeService.GetByID(context.Background(), uint(routeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !isCompanyManager(h.userCompanyService, *userID, route.CompanyID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	assessment, err := h.qualityService.AssessRoute(context.Background(), *route, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQualityRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, assessment)
+}
@@ -0,0 +1,133 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"wayra/internal/core/port/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EstimateWaypointSensorData godoc
+// @Summary      Estimate the sensor data of a waypoint
+// @Description  Returns the measurements of the waypoint at the given time. A reading recorded close enough to the time is returned as it is,
+// @Description  otherwise the readings of the waypoint around the time are interpolated, or, without them, the values of the neighbouring
+// @Description  waypoints are weighted by inverse distance. Estimated values are marked with "estimated" and the method used.
+// @Tags         sensor
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Param        at query string false "Point in time in RFC 3339, defaults to now"
+// @Success      200 {object} analysis.WaypointEstimate
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/sensor-data/estimate [get]
+func (h *SensorDataHandler) EstimateWaypointSensorData(c *gin.Context) {
+	waypointID, err := strconv.Atoi(c.Param("waypoint_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+		return
+	}
+
+	at, ok := parseEstimateTime(c)
+	if !ok {
+		return
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), uint(waypointID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's sensor data"})
+		return
+	}
+
+	estimate, err := h.interpolationService.EstimateWaypoint(context.Background(), *waypoint, at)
+	if err != nil {
+		if errors.Is(err, services.ErrNoEstimate) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
+
+// EstimateRouteSensorData godoc
+// @Summary      Estimate the sensor data of a route
+// @Description  Returns the measurements of every waypoint of the route at the given time, measured or estimated like for a single waypoint.
+// @Description  Waypoints that can be estimated neither from their own readings nor from their neighbours are left out.
+// @Tags         route
+// @Produce      json
+// @Param        route_id path int true "Route ID"
+// @Param        at query string false "Point in time in RFC 3339, defaults to now"
+// @Success      200 {array} analysis.WaypointEstimate
+// @Security     BearerAuth
+// @Router       /routes/{route_id}/sensor-data/estimate [get]
+func (h *SensorDataHandler) EstimateRouteSensorData(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("route_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Route ID format"})
+		return
+	}
+
+	at, ok := parseEstimateTime(c)
+	if !ok {
+		return
+	}
+
+	route, err := h.routeService.GetByID(context.Background(), uint(routeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's sensor data"})
+		return
+	}
+
+	estimates, err := h.interpolationService.EstimateRoute(context.Background(), *route, at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, estimates)
+}
+
+// parseEstimateTime reads the at query parameter
+// c: The gin context
+// returns: the point in time, now by default, and false if a response has already been written
+func parseEstimateTime(c *gin.Context) (time.Time, bool) {
+	value := c.Query("at")
+	if value == "" {
+		return time.Now().UTC(), true
+	}
+
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid at, expected RFC 3339"})
+		return time.Time{}, false
+	}
+
+	return at.UTC(), true
+}
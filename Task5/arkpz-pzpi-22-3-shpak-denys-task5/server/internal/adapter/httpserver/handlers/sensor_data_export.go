@@ -0,0 +1,273 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushRows is the number of exported rows after which the response is flushed to the client
+const exportFlushRows = 500
+
+// exportFormats are the content types of the export formats
+var exportFormats = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// exportCSVHeader is the header row of a CSV export, in the order of exportCSVRecord
+var exportCSVHeader = []string{
+	"id", "date", "route_id", "waypoint_id", "waypoint_name", "latitude", "longitude",
+	"temperature", "humidity", "wind_speed", "mean_pressure", "device_id", "timestamp_flag", "quality_flags",
+}
+
+// ExportWaypointSensorData godoc
+// @Summary      Export the sensor data of a waypoint
+// @Description  Streams every reading of the waypoint recorded in [from, to), oldest first, as CSV or NDJSON.
+// @Description  Readings that were rolled up and purged by the retention policy are not exported.
+// @Description  Readings flagged by the data-quality pass are exported with their quality_flags.
+// @Tags         sensor
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Param        from query string false "Start of the range in RFC 3339, defaults to 24 hours before to"
+// @Param        to query string false "End of the range in RFC 3339, defaults to now"
+// @Param        format query string false "csv (default) or ndjson"
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/sensor-data/export [get]
+func (h *SensorDataHandler) ExportWaypointSensorData(c *gin.Context) {
+	waypointID, err := strconv.Atoi(c.Param("waypoint_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+		return
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), uint(waypointID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's sensor data"})
+		return
+	}
+
+	h.exportSensorData(c, models.SensorDataExportQuery{WaypointID: waypoint.ID}, fmt.Sprintf("waypoint-%d", waypoint.ID))
+}
+
+// ExportRouteSensorData godoc
+// @Summary      Export the sensor data of a route
+// @Description  Streams every reading of the waypoints of the route recorded in [from, to), oldest first, as CSV or NDJSON.
+// @Description  Readings that were rolled up and purged by the retention policy are not exported.
+// @Description  Readings flagged by the data-quality pass are exported with their quality_flags.
+// @Tags         route
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        route_id path int true "Route ID"
+// @Param        from query string false "Start of the range in RFC 3339, defaults to 24 hours before to"
+// @Param        to query string false "End of the range in RFC 3339, defaults to now"
+// @Param        format query string false "csv (default) or ndjson"
+// @Security     BearerAuth
+// @Router       /routes/{route_id}/sensor-data/export [get]
+func (h *SensorDataHandler) ExportRouteSensorData(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("route_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Route ID format"})
+		return
+	}
+
+	route, err := h.routeService.GetByID(context.Background(), uint(routeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's sensor data"})
+		return
+	}
+
+	h.exportSensorData(c, models.SensorDataExportQuery{RouteID: route.ID}, fmt.Sprintf("route-%d", route.ID))
+}
+
+// ExportCompanySensorData godoc
+// @Summary      Export the sensor data of a company
+// @Description  Streams every reading of the routes of the company recorded in [from, to), oldest first, as CSV or NDJSON.
+// @Description  Readings that were rolled up and purged by the retention policy are not exported.
+// @Description  Readings flagged by the data-quality pass are exported with their quality_flags.
+// @Tags         company
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        company_id path int true "Company ID"
+// @Param        from query string false "Start of the range in RFC 3339, defaults to 24 hours before to"
+// @Param        to query string false "End of the range in RFC 3339, defaults to now"
+// @Param        format query string false "csv (default) or ndjson"
+// @Security     BearerAuth
+// @Router       /company/{company_id}/sensor-data/export [get]
+func (h *SensorDataHandler) ExportCompanySensorData(c *gin.Context) {
+	companyID, err := strconv.Atoi(c.Param("company_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid company ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, uint(companyID)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's sensor data"})
+		return
+	}
+
+	h.exportSensorData(c, models.SensorDataExportQuery{CompanyID: uint(companyID)}, fmt.Sprintf("company-%d", companyID))
+}
+
+// exportSensorData streams the readings selected by the query in the format requested by the format query parameter
+// The response is only started with the first reading, so errors of the query are still sent as JSON.
+// An error in the middle of the stream can only end it early and is recorded on the context.
+// c: The gin context
+// query: scope of the readings, the range is read from the query parameters
+// name: name of the exported file, without extension
+func (h *SensorDataHandler) exportSensorData(c *gin.Context, query models.SensorDataExportQuery, name string) {
+	format := c.DefaultQuery("format", "csv")
+	contentType, ok := exportFormats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected csv or ndjson"})
+		return
+	}
+
+	from, to, ok := parseTimeRange(c)
+	if !ok {
+		return
+	}
+	query.From, query.To = from, to
+
+	buffer := bufio.NewWriter(c.Writer)
+	csvWriter := csv.NewWriter(buffer)
+	encoder := json.NewEncoder(buffer)
+
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="sensor-data-%s.%s"`, name, format))
+		c.Status(http.StatusOK)
+
+		if format == "csv" {
+			return csvWriter.Write(exportCSVHeader)
+		}
+		return nil
+	}
+
+	flush := func() error {
+		if format == "csv" {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		if err := buffer.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	rows := 0
+	err := h.sensorDataService.Export(c.Request.Context(), query, func(row models.SensorDataExportRow) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if format == "csv" {
+			err = csvWriter.Write(exportCSVRecord(row))
+		} else {
+			err = encoder.Encode(row)
+		}
+		if err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushRows == 0 {
+			return flush()
+		}
+		return nil
+	})
+
+	if err == nil && !started {
+		err = start()
+	}
+
+	if err != nil && !started {
+		if errors.Is(err, services.ErrInvalidExportQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// exportCSVRecord formats a reading as a CSV record, in the order of exportCSVHeader
+// row: reading to format
+// returns: the fields of the record
+func exportCSVRecord(row models.SensorDataExportRow) []string {
+	deviceID := ""
+	if row.DeviceID != nil {
+		deviceID = strconv.FormatUint(uint64(*row.DeviceID), 10)
+	}
+
+	return []string{
+		strconv.FormatUint(uint64(row.ID), 10),
+		row.Date.Format(time.RFC3339Nano),
+		strconv.FormatUint(uint64(row.RouteID), 10),
+		strconv.FormatUint(uint64(row.WaypointID), 10),
+		row.WaypointName,
+		strconv.FormatFloat(row.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(row.Longitude, 'f', -1, 64),
+		strconv.FormatFloat(row.Temperature, 'f', -1, 64),
+		strconv.FormatFloat(row.Humidity, 'f', -1, 64),
+		strconv.FormatFloat(row.WindSpeed, 'f', -1, 64),
+		strconv.FormatFloat(row.MeanPressure, 'f', -1, 64),
+		deviceID,
+		row.TimestampFlag,
+		row.QualityFlags.String(),
+	}
+}
@@ -1,12 +1,20 @@
 package handlers // import "wayra/internal/adapter/httpserver/handlers"
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 	"wayra/internal/core/domain/dtos"
 	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/ingest"
 	"wayra/internal/core/port/services"
 
 	dtoMapper "github.com/dranikpg/dto-mapper"
@@ -15,134 +23,357 @@ import (
 
 // SensorDataHandler represents the handler for managing SensorData
 type SensorDataHandler struct {
-	sensorDataService  services.SensorDataService  // is the service for managing SensorData
-	waypointService    services.WaypointService    // is the service for managing Waypoints
-	userCompanyService services.UserCompanyService // is the service for managing UserCompany
+	sensorDataService    services.SensorDataService    // is the service for managing SensorData
+	waypointService      services.WaypointService      // is the service for managing Waypoints
+	routeService         services.RouteService         // is the service for managing Routes
+	userCompanyService   services.UserCompanyService   // is the service for managing UserCompany
+	interpolationService services.InterpolationService // is the service for estimating missing SensorData
 }
 
 // NewSensorDataHandler creates a new SensorDataHandler
 // sensorDataService: is the service for managing SensorData
 // waypointService: is the service for managing Waypoints
+// routeService: is the service for managing Routes
 // userCompanyService: is the service for managing UserCompany
+// interpolationService: is the service for estimating missing SensorData
 // returns a new SensorDataHandler
 func NewSensorDataHandler(
 	sensorDataService services.SensorDataService,
 	waypointService services.WaypointService,
+	routeService services.RouteService,
 	userCompanyService services.UserCompanyService,
+	interpolationService services.InterpolationService,
 ) *SensorDataHandler {
 	return &SensorDataHandler{
-		sensorDataService:  sensorDataService,
-		waypointService:    waypointService,
-		userCompanyService: userCompanyService,
+		sensorDataService:    sensorDataService,
+		waypointService:      waypointService,
+		routeService:         routeService,
+		userCompanyService:   userCompanyService,
+		interpolationService: interpolationService,
 	}
 }
 
-// CreateSensorDataRequest represents the request for creating a SensorData
-type CreateSensorDataRequest struct {
-	// Date is the date and time when the sensor data was recorded
-	// example: 2021-09-01T12:00:00Z
-	Date string `json:"date" example:"2021-09-01T12:00:00Z"`
-
-	// Temperature is the temperature recorded by the sensor
-	// example: 25.5
-	Temperature float64 `json:"temperature" example:"25.5"`
-
-	// Humidity is the humidity recorded by the sensor
-	// example: 50.0
-	Humidity float64 `json:"humidity" example:"50.0"`
-
-	// WaypointID is the ID of the waypoint where the sensor data was recorded
-	// example: 1
-	WaypointID uint `json:"waypoint_id"`
-}
-
-// UpdateSensorDataRequest represents the request for updating a SensorData
-type UpdateSensorDataRequest struct {
-	// Date is the date and time when the sensor data was recorded
-	// example: 2021-09-01T12:00:00Z
-	Date string `json:"date" example:"2021-09-01T12:00:00Z"`
-
-	// Temperature is the temperature recorded by the sensor
-	// example: 25.5
-	Temperature float64 `json:"temperature" example:"25.5"`
-
-	// Humidity is the humidity recorded by the sensor
-	// example: 50.0
-	Humidity float64 `json:"humidity" example:"50.0"`
-}
-
 // AddSensorData godoc
 // @Summary      Add sensor data to a SensorData
-// @Description  Adds new sensor data to the specified SensorData
+// @Description  Adds new sensor data to the specified SensorData. Every measurement is required, fields may be sent under their aliases
+// @Description  and, with schema_version 2, in the units declared in "units". The values are stored in °C, %, m/s and hPa.
+// @Description  The date is corrected for the clock skew of the device, without a date the time the reading was received is used.
+// @Description  409 when the waypoint already has a reading recorded at the same date.
 // @Tags         sensor
 // @Accept       json
 // @Produce      json
-// @Param        sensor_data body CreateSensorDataRequest true "Sensor data details"
+// @Param        sensor_data body ingest.Reading true "Sensor data details"
 // @Security     BearerAuth
 // @Router       /sensor-data [post]
 func (h *SensorDataHandler) AddSensorData(c *gin.Context) {
-	var sensorDataRequest CreateSensorDataRequest
+	var sensorDataRequest ingest.Reading
 	if err := c.ShouldBindJSON(&sensorDataRequest); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	userID, err := getUserIDFromToken(c)
+	sensorData, err := sensorDataRequest.ToSensorData()
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	waypoint, err := h.waypointService.GetByID(context.Background(), sensorDataRequest.WaypointID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+	if device := getDeviceFromContext(c); device != nil {
+		if sensorDataRequest.WaypointID == 0 {
+			sensorDataRequest.WaypointID = device.WaypointID
+		}
 
-	userCompany, err := h.userCompanyService.Where(context.Background(), &models.UserCompany{
-		UserID:    *userID,
-		CompanyID: waypoint.Route.CompanyID,
-	})
-	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-		return
-	}
+		if sensorDataRequest.WaypointID != device.WaypointID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Devices can only send data for their own waypoint"})
+			return
+		}
+	} else {
+		userID, err := getUserIDFromToken(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
 
-	if userCompany[0].Role != string(RoleAdmin) && userCompany[0].Role != string(RoleManager) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
-		return
+		waypoint, err := h.waypointService.GetByID(context.Background(), sensorDataRequest.WaypointID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		userCompany, err := h.userCompanyService.Where(context.Background(), &models.UserCompany{
+			UserID:    *userID,
+			CompanyID: waypoint.Route.CompanyID,
+		})
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		if userCompany[0].Role != string(RoleAdmin) && userCompany[0].Role != string(RoleManager) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
 	}
 
-	var date time.Time
+	// Without a date the service falls back to the time the reading was received
 	if sensorDataRequest.Date != "" {
-		date, err = time.Parse(time.RFC3339, sensorDataRequest.Date)
+		date, err := time.Parse(time.RFC3339, sensorDataRequest.Date)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
 			return
 		}
+		sensorData.DeviceDate = &date
 	}
 
-	sensorData := &models.SensorData{
-		Date:        date,
-		Temperature: sensorDataRequest.Temperature,
-		Humidity:    sensorDataRequest.Humidity,
-		WaypointID:  sensorDataRequest.WaypointID,
-	}
+	sensorData.WaypointID = sensorDataRequest.WaypointID
 
-	if err := h.sensorDataService.Create(context.Background(), sensorData); err != nil {
+	if err := h.sensorDataService.Create(context.Background(), &sensorData); err != nil {
+		if errors.Is(err, services.ErrDuplicateReading) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	sensorDataDTO := &dtos.SensorDataDTO{}
-	if err = dtoMapper.Map(sensorDataDTO, sensorData); err != nil {
+	if err = dtoMapper.Map(sensorDataDTO, &sensorData); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	deriveMetrics(sensorDataDTO)
 
 	c.JSON(http.StatusOK, sensorDataDTO)
 }
 
+// maxBatchSize is the maximum number of readings accepted in one batch
+const maxBatchSize = 1000
+
+// maxBatchBodySize is the maximum size in bytes of a batch request body
+const maxBatchBodySize = 8 << 20
+
+// SensorDataBatchResult is the outcome of one reading of a batch
+type SensorDataBatchResult struct {
+	// Index is the position of the reading in the batch, starting at 0
+	// Example: 0
+	Index int `json:"index" example:"0"`
+
+	// Status is one of created, duplicate or rejected
+	// Example: created
+	Status models.IngestStatus `json:"status" example:"created"`
+
+	// ID is the ID of the stored reading, only set when it was created
+	// Example: 1
+	ID uint `json:"id,omitempty" example:"1"`
+
+	// Error explains why the reading was rejected
+	// Example: Invalid date format
+	Error string `json:"error,omitempty"`
+}
+
+// SensorDataBatchResponse is the response to a batch of readings
+type SensorDataBatchResponse struct {
+	// Received is the number of readings in the batch
+	// Example: 3
+	Received int `json:"received" example:"3"`
+
+	// Created is the number of readings stored
+	// Example: 1
+	Created int `json:"created" example:"1"`
+
+	// Duplicates is the number of readings that were already stored
+	// Example: 1
+	Duplicates int `json:"duplicates" example:"1"`
+
+	// Rejected is the number of invalid readings
+	// Example: 1
+	Rejected int `json:"rejected" example:"1"`
+
+	// Results holds the outcome of every reading in the order of the batch
+	Results []SensorDataBatchResult `json:"results"`
+}
+
+// AddSensorDataBatch godoc
+// @Summary      Add a batch of sensor data
+// @Description  Stores many readings, of one or more waypoints, at once. The body is a JSON array or an NDJSON stream (Content-Type: application/x-ndjson).
+// @Description  Readings are identified by waypoint and date, so a backlog sent again is not stored twice. Every reading gets its own result.
+// @Tags         sensor
+// @Accept       json
+// @Accept       x-ndjson
+// @Produce      json
+// @Param        sensor_data body []ingest.Reading true "Sensor data readings"
+// @Security     BearerAuth
+// @Router       /sensor-data/batch [post]
+func (h *SensorDataHandler) AddSensorDataBatch(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchBodySize)
+
+	var rows []json.RawMessage
+	var err error
+	if strings.Contains(c.ContentType(), "ndjson") {
+		rows, err = readNDJSONRows(c.Request.Body)
+	} else {
+		err = json.NewDecoder(c.Request.Body).Decode(&rows)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The batch is empty"})
+		return
+	}
+
+	if len(rows) > maxBatchSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("A batch can hold at most %d readings", maxBatchSize),
+		})
+		return
+	}
+
+	response := SensorDataBatchResponse{
+		Received: len(rows),
+		Results:  make([]SensorDataBatchResult, len(rows)),
+	}
+
+	authorizer := h.newWaypointAuthorizer(c)
+	readings := make([]models.SensorData, 0, len(rows))
+	positions := make([]int, 0, len(rows))
+
+	for i, row := range rows {
+		response.Results[i] = SensorDataBatchResult{Index: i, Status: models.IngestStatusRejected}
+
+		var sensorDataRequest ingest.Reading
+		if err := json.Unmarshal(row, &sensorDataRequest); err != nil {
+			response.Results[i].Error = "Invalid input"
+			continue
+		}
+
+		reading, err := sensorDataRequest.ToSensorData()
+		if err != nil {
+			response.Results[i].Error = err.Error()
+			continue
+		}
+
+		// Every reading of a backlog would get the same receive time, so the date can not be left out
+		if sensorDataRequest.Date == "" {
+			response.Results[i].Error = "date is required"
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, sensorDataRequest.Date)
+		if err != nil {
+			response.Results[i].Error = "Invalid date format"
+			continue
+		}
+
+		waypointID, reason := authorizer(sensorDataRequest.WaypointID)
+		if reason != "" {
+			response.Results[i].Error = reason
+			continue
+		}
+
+		reading.DeviceDate = &date
+		reading.WaypointID = waypointID
+
+		readings = append(readings, reading)
+		positions = append(positions, i)
+	}
+
+	statuses, err := h.sensorDataService.IngestBatch(context.Background(), readings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i, position := range positions {
+		response.Results[position].Status = statuses[i]
+		if statuses[i] == models.IngestStatusCreated {
+			response.Results[position].ID = readings[i].ID
+		}
+	}
+
+	for _, result := range response.Results {
+		switch result.Status {
+		case models.IngestStatusCreated:
+			response.Created++
+		case models.IngestStatusDuplicate:
+			response.Duplicates++
+		case models.IngestStatusRejected:
+			response.Rejected++
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// newWaypointAuthorizer returns a function that checks if the sender of the request may add data to a waypoint
+// Devices may only add data to their own waypoint, users must manage the company owning the waypoint.
+// The result is remembered for every waypoint, so a batch does not load the same waypoint twice.
+// c: The gin context
+// returns: a function that returns the waypoint ID to store the data for and the reason it is not allowed
+func (h *SensorDataHandler) newWaypointAuthorizer(c *gin.Context) func(waypointID uint) (uint, string) {
+	device := getDeviceFromContext(c)
+	userID, userErr := getUserIDFromToken(c)
+	reasons := make(map[uint]string)
+
+	return func(waypointID uint) (uint, string) {
+		if device != nil {
+			if waypointID == 0 {
+				waypointID = device.WaypointID
+			}
+
+			if waypointID != device.WaypointID {
+				return 0, "Devices can only send data for their own waypoint"
+			}
+
+			return waypointID, ""
+		}
+
+		if userErr != nil {
+			return 0, "Unauthorized"
+		}
+
+		if reason, ok := reasons[waypointID]; ok {
+			return waypointID, reason
+		}
+
+		reason := ""
+		waypoint, err := h.waypointService.GetByID(context.Background(), waypointID)
+		if err != nil {
+			reason = "Waypoint not found"
+		} else if !isCompanyManager(h.userCompanyService, *userID, waypoint.Route.CompanyID) {
+			reason = "Forbidden"
+		}
+
+		reasons[waypointID] = reason
+		return waypointID, reason
+	}
+}
+
+// readNDJSONRows splits a newline delimited JSON stream into its rows, skipping blank lines
+// body: the stream to read
+// returns: the rows and an error if the stream could not be read
+func readNDJSONRows(body io.Reader) ([]json.RawMessage, error) {
+	var rows []json.RawMessage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchBodySize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		rows = append(rows, json.RawMessage(bytes.Clone(line)))
+		if len(rows) > maxBatchSize {
+			break
+		}
+	}
+
+	return rows, scanner.Err()
+}
+
 // GetSensorData godoc
 // @Summary      Get sensor data by ID
 // @Description  Retrieves sensor data with the given ID
@@ -180,18 +411,20 @@ func (h *SensorDataHandler) GetSensorData(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	deriveMetrics(sensorDataDTO)
 
 	c.JSON(http.StatusOK, sensorDataDTO)
 }
 
 // UpdateSensorData godoc
 // @Summary      Update sensor data by ID
-// @Description  Updates sensor data with the given ID
+// @Description  Updates sensor data with the given ID, 409 when the waypoint already has a reading at the new date
+// @Description  The measurements sent are raw values, they are corrected by the calibration in effect at the date of the reading.
 // @Tags         sensor
 // @Accept       json
 // @Produce      json
 // @Param        sensor_data_id path int true "Sensor Data ID"
-// @Param        sensor_data body UpdateSensorDataRequest true "Sensor data details"
+// @Param        sensor_data body ingest.Reading true "Sensor data details, omitted measurements keep their value"
 // @Security     BearerAuth
 // @Router       /sensor-data/{sensor_data_id} [put]
 func (h *SensorDataHandler) UpdateSensorData(c *gin.Context) {
@@ -226,27 +459,47 @@ func (h *SensorDataHandler) UpdateSensorData(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
 		return
 	}
-	var sensorDataRequest UpdateSensorDataRequest
+	var sensorDataRequest ingest.Reading
 	if err := c.ShouldBindJSON(&sensorDataRequest); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	var date time.Time
+	normalized, err := sensorDataRequest.Normalize()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if sensorDataRequest.Date != "" {
-		date, err = time.Parse(time.RFC3339, sensorDataRequest.Date)
+		date, err := time.Parse(time.RFC3339, sensorDataRequest.Date)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
 			return
 		}
+		sensorData.Date = date.UTC()
 	}
 
-	sensorData.Date = date
-	sensorData.Temperature = sensorDataRequest.Temperature
-	sensorData.Humidity = sensorDataRequest.Humidity
+	// The values sent are raw measurements, the service corrects them by the calibration like ingested ones
+	if normalized.Temperature != nil {
+		sensorData.Temperature, sensorData.RawTemperature = *normalized.Temperature, normalized.Temperature
+	}
+	if normalized.Humidity != nil {
+		sensorData.Humidity, sensorData.RawHumidity = *normalized.Humidity, normalized.Humidity
+	}
+	if normalized.WindSpeed != nil {
+		sensorData.WindSpeed, sensorData.RawWindSpeed = *normalized.WindSpeed, normalized.WindSpeed
+	}
+	if normalized.MeanPressure != nil {
+		sensorData.MeanPressure, sensorData.RawMeanPressure = *normalized.MeanPressure, normalized.MeanPressure
+	}
 	sensorData.Waypoint = models.Waypoint{}
 
 	if err := h.sensorDataService.Update(context.Background(), sensorData); err != nil {
+		if errors.Is(err, services.ErrDuplicateReading) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -256,6 +509,7 @@ func (h *SensorDataHandler) UpdateSensorData(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	deriveMetrics(sensorDataDTO)
 
 	c.JSON(http.StatusOK, sensorDataDTO)
 }
@@ -308,3 +562,57 @@ func (h *SensorDataHandler) DeleteSensorData(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Sensor Data deleted successfully"})
 }
+
+// GetWaypointSensorData godoc
+// @Summary      Get sensor data of a waypoint over time
+// @Description  Returns the min, max, average and count of every measurement of the waypoint per time bucket in [from, to). Buckets without readings are left out
+// @Tags         sensor
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Param        from query string false "Start of the range in RFC 3339, defaults to 24 hours before to"
+// @Param        to query string false "End of the range in RFC 3339, defaults to now"
+// @Param        bucket query string false "Size of a bucket, e.g. 5m, 1h (default) or 1d"
+// @Param        include_flagged query bool false "Include the readings flagged as outliers, spikes or frozen sensors that are not purged yet"
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/sensor-data [get]
+func (h *SensorDataHandler) GetWaypointSensorData(c *gin.Context) {
+	waypointID, err := strconv.Atoi(c.Param("waypoint_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+		return
+	}
+
+	query, ok := parseBucketQuery(c)
+	if !ok {
+		return
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), uint(waypointID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's waypoints"})
+		return
+	}
+
+	buckets, err := h.sensorDataService.GetWaypointBuckets(context.Background(), waypoint.ID, query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBucketQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, buckets)
+}
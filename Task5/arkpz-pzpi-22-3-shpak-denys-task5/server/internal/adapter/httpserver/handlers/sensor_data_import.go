@@ -0,0 +1,303 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/ingest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBodySize is the maximum size in bytes of an import request body, file included
+const maxImportBodySize = 32 << 20
+
+// maxImportLines is the maximum number of readings accepted in one import
+const maxImportLines = 100000
+
+// SensorDataImportIssue is a line of an import that is not stored
+type SensorDataImportIssue struct {
+	// Line is the line number in the file, the header is line 1
+	// Example: 42
+	Line int `json:"line" example:"42"`
+
+	// Status is duplicate or rejected
+	// Example: rejected
+	Status models.IngestStatus `json:"status" example:"rejected"`
+
+	// Error explains why the line was rejected
+	// Example: invalid date
+	Error string `json:"error,omitempty" example:"invalid date"`
+}
+
+// SensorDataImportResponse is the response to an import of readings
+type SensorDataImportResponse struct {
+	// DryRun tells that nothing was stored and the counts are what the import would do
+	// Example: true
+	DryRun bool `json:"dry_run" example:"true"`
+
+	// Lines is the number of readings in the file
+	// Example: 1440
+	Lines int `json:"lines" example:"1440"`
+
+	// Created is the number of readings stored, or that would be stored on a dry run
+	// Example: 1438
+	Created int `json:"created" example:"1438"`
+
+	// Duplicates is the number of readings that are already stored or repeated in the file
+	// Example: 1
+	Duplicates int `json:"duplicates" example:"1"`
+
+	// Rejected is the number of invalid readings
+	// Example: 1
+	Rejected int `json:"rejected" example:"1"`
+
+	// Issues holds the lines that are not stored, ordered by line
+	Issues []SensorDataImportIssue `json:"issues"`
+}
+
+// ImportSensorData godoc
+// @Summary      Import historical sensor data from a CSV file
+// @Description  Imports the readings of a logger export. The header names the columns, which are matched to date, temperature, humidity,
+// @Description  wind_speed, mean_pressure, waypoint_id and device_serial by name or alias, or mapped in "columns".
+// @Description  The waypoint of a line is its waypoint_id, else the waypoint of its device_serial, else the form defaults.
+// @Description  Readings are identified by waypoint and date, so a file imported again is not stored twice.
+// @Description  A dry run validates every line and stores nothing. Otherwise the readings are stored in a single transaction,
+// @Description  and only when no line is rejected: a file with an invalid line is answered with 422 and its issues.
+// @Tags         sensor
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file formData file true "CSV file with a header line"
+// @Param        dry_run formData bool false "Only validate the file"
+// @Param        waypoint_id formData int false "Waypoint of the lines without waypoint_id or device_serial"
+// @Param        device_serial formData string false "Device of the lines without waypoint_id or device_serial"
+// @Param        columns formData string false "JSON object mapping fields to column headers, e.g. {\"date\":\"Time (UTC)\"}"
+// @Param        units formData string false "JSON object declaring the units of the measurements, e.g. {\"temperature\":\"F\"}"
+// @Param        date_format formData string false "Go layout of the dates, RFC 3339 by default"
+// @Param        timezone formData string false "IANA time zone of the dates without an offset, UTC by default"
+// @Param        delimiter formData string false "Field delimiter, a comma by default"
+// @Security     BearerAuth
+// @Router       /sensor-data/import [post]
+func (h *SensorDataHandler) ImportSensorData(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBodySize)
+
+	if _, err := getUserIDFromToken(c); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV file is required"})
+		return
+	}
+
+	dryRun, err := strconv.ParseBool(c.DefaultPostForm("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run, expected true or false"})
+		return
+	}
+
+	var defaultWaypointID uint
+	if value := c.PostForm("waypoint_id"); value != "" {
+		waypointID, err := strconv.Atoi(value)
+		if err != nil || waypointID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+			return
+		}
+		defaultWaypointID = uint(waypointID)
+	}
+	defaultSerial := c.PostForm("device_serial")
+
+	options, ok := parseImportOptions(c)
+	if !ok {
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file"})
+		return
+	}
+	defer file.Close()
+
+	lines, err := ingest.ReadCSV(file, options)
+	if errors.Is(err, ingest.ErrTooManyLines) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(lines) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The file has no readings"})
+		return
+	}
+
+	response := SensorDataImportResponse{DryRun: dryRun, Lines: len(lines), Issues: []SensorDataImportIssue{}}
+	reject := func(line int, reason string) {
+		response.Rejected++
+		response.Issues = append(response.Issues, SensorDataImportIssue{
+			Line:   line,
+			Status: models.IngestStatusRejected,
+			Error:  reason,
+		})
+	}
+
+	authorizer := h.newWaypointAuthorizer(c)
+	resolveSerial := h.newSerialResolver()
+	readings := make([]models.SensorData, 0, len(lines))
+	lineNumbers := make([]int, 0, len(lines))
+
+	for _, line := range lines {
+		if line.Err != nil {
+			reject(line.Line, line.Err.Error())
+			continue
+		}
+
+		waypointID := line.Reading.WaypointID
+		if waypointID == 0 {
+			serial := line.DeviceSerial
+			if serial == "" {
+				serial = defaultSerial
+			}
+
+			if serial != "" {
+				var reason string
+				if waypointID, reason = resolveSerial(serial); reason != "" {
+					reject(line.Line, reason)
+					continue
+				}
+			} else {
+				waypointID = defaultWaypointID
+			}
+		}
+
+		if waypointID == 0 {
+			reject(line.Line, "waypoint_id or device_serial is required")
+			continue
+		}
+
+		if _, reason := authorizer(waypointID); reason != "" {
+			reject(line.Line, reason)
+			continue
+		}
+
+		reading := line.Reading
+		reading.WaypointID = waypointID
+		readings = append(readings, reading)
+		lineNumbers = append(lineNumbers, line.Line)
+	}
+
+	// An import is stored whole or not at all, so a rejected line stops it before anything is stored
+	if response.Rejected > 0 && !dryRun {
+		c.JSON(http.StatusUnprocessableEntity, response)
+		return
+	}
+
+	statuses, err := h.sensorDataService.Import(context.Background(), readings, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i, status := range statuses {
+		switch status {
+		case models.IngestStatusCreated:
+			response.Created++
+		case models.IngestStatusDuplicate:
+			response.Duplicates++
+			response.Issues = append(response.Issues, SensorDataImportIssue{Line: lineNumbers[i], Status: status})
+		}
+	}
+
+	sort.SliceStable(response.Issues, func(i, j int) bool {
+		return response.Issues[i].Line < response.Issues[j].Line
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// parseImportOptions reads how to read the CSV file from the form of an import
+// If the form is invalid, it responds with 400.
+// c: The gin context
+// returns: the options and true if the form is valid
+func parseImportOptions(c *gin.Context) (ingest.CSVOptions, bool) {
+	options := ingest.CSVOptions{
+		DateLayout: c.PostForm("date_format"),
+		MaxLines:   maxImportLines,
+	}
+
+	if value := c.PostForm("columns"); value != "" {
+		if err := json.Unmarshal([]byte(value), &options.Columns); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid columns, expected a JSON object of column headers by field"})
+			return ingest.CSVOptions{}, false
+		}
+	}
+
+	if value := c.PostForm("units"); value != "" {
+		var units ingest.Units
+		if err := json.Unmarshal([]byte(value), &units); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid units, expected a JSON object of units by measurement"})
+			return ingest.CSVOptions{}, false
+		}
+		options.Units = &units
+	}
+
+	if value := c.PostForm("timezone"); value != "" {
+		location, err := time.LoadLocation(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown timezone %q", value)})
+			return ingest.CSVOptions{}, false
+		}
+		options.Location = location
+	}
+
+	if value := c.PostForm("delimiter"); value != "" {
+		delimiter, size := utf8.DecodeRuneInString(value)
+		if size != len(value) || delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delimiter, expected a single character"})
+			return ingest.CSVOptions{}, false
+		}
+		options.Delimiter = delimiter
+	}
+
+	return options, true
+}
+
+// newSerialResolver returns a function that finds the waypoint a device is installed at by its serial
+// The result is remembered for every serial, so an import does not load the same device twice.
+// returns: a function that returns the ID of the waypoint and the reason it was not found
+func (h *SensorDataHandler) newSerialResolver() func(deviceSerial string) (uint, string) {
+	type resolved struct {
+		waypointID uint
+		reason     string
+	}
+	cache := make(map[string]resolved)
+
+	return func(deviceSerial string) (uint, string) {
+		if result, ok := cache[deviceSerial]; ok {
+			return result.waypointID, result.reason
+		}
+
+		result := resolved{}
+		waypoint, err := h.waypointService.GetByDeviceSerial(context.Background(), deviceSerial)
+		if err != nil {
+			result.reason = err.Error()
+		} else {
+			result.waypointID = waypoint.ID
+		}
+
+		cache[deviceSerial] = result
+		return result.waypointID, result.reason
+	}
+}
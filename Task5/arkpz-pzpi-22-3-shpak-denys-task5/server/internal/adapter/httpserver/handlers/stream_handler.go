@@ -0,0 +1,292 @@
+package handlers // import "wayra/internal/adapter/httpserver/handlers"
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"wayra/internal/core/domain/dtos"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	dtoMapper "github.com/dranikpg/dto-mapper"
+	"github.com/gin-gonic/gin"
+)
+
+// StreamHandler is a handler streaming the ingested readings, status changes and alerts as Server-Sent Events
+type StreamHandler struct {
+	eventHub           services.EventHub           // hub the events are received from
+	routeService       services.RouteService       // service to handle route related operations
+	waypointService    services.WaypointService    // service to handle waypoint related operations
+	userCompanyService services.UserCompanyService // service to handle user-company related operations
+	heartbeat          time.Duration               // time between two keep-alive messages
+
+	shutdownOnce sync.Once     // closes shutdown once
+	shutdown     chan struct{} // closed when the server shuts down
+}
+
+// NewStreamHandler creates a new StreamHandler
+// eventHub: hub the events are received from
+// routeService: service to handle route related operations
+// waypointService: service to handle waypoint related operations
+// userCompanyService: service to handle user-company related operations
+// heartbeat: time between two keep-alive messages
+// returns: a new StreamHandler
+func NewStreamHandler(
+	eventHub services.EventHub,
+	routeService services.RouteService,
+	waypointService services.WaypointService,
+	userCompanyService services.UserCompanyService,
+	heartbeat time.Duration,
+) *StreamHandler {
+	return &StreamHandler{
+		eventHub:           eventHub,
+		routeService:       routeService,
+		waypointService:    waypointService,
+		userCompanyService: userCompanyService,
+		heartbeat:          heartbeat,
+		shutdown:           make(chan struct{}),
+	}
+}
+
+// StreamMessage is the data of a Server-Sent Event, the event name is its type
+type StreamMessage struct {
+	// Type is the kind of change: sensor_data, waypoint_status, route_condition, connectivity or weather_alert
+	// Example: sensor_data
+	Type models.StreamEventType `json:"type"`
+
+	// CompanyID is the company the change belongs to
+	// Example: 1
+	CompanyID uint `json:"company_id"`
+
+	// RouteID is the route the change belongs to
+	// Example: 1
+	RouteID uint `json:"route_id"`
+
+	// WaypointID is the waypoint the change belongs to, left out for changes of a whole route
+	// Example: 1
+	WaypointID uint `json:"waypoint_id,omitempty"`
+
+	// Date is the time of the change
+	// Example: 2024-12-01T12:00:00Z
+	Date time.Time `json:"date"`
+
+	// Data is the change: a reading, a status event, a connectivity change or the list of the weather alerts
+	Data interface{} `json:"data"`
+}
+
+// Shutdown ends every open stream, so the server can shut down without waiting for the clients to disconnect
+func (h *StreamHandler) Shutdown() {
+	h.shutdownOnce.Do(func() { close(h.shutdown) })
+}
+
+// StreamRoute godoc
+// @Summary      Stream the changes of a route
+// @Description  Streams as Server-Sent Events the readings ingested at the waypoints of the route, their status and connectivity changes,
+// @Description  the condition changes of the route and its weather alerts whenever they change. Every event is named after its type.
+// @Description  A "ping" event is sent periodically. A client too slow to keep up misses events and receives a "dropped" event with their count.
+// @Tags         route
+// @Produce      text/event-stream
+// @Param        route_id path int true "Route ID"
+// @Success      200 {object} StreamMessage
+// @Security     BearerAuth
+// @Router       /routes/{route_id}/stream [get]
+func (h *StreamHandler) StreamRoute(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("route_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Route ID format"})
+		return
+	}
+
+	route, err := h.routeService.GetByID(context.Background(), uint(routeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to access this route"})
+		return
+	}
+
+	h.stream(c, *userID, models.StreamScope{CompanyID: route.CompanyID, RouteID: route.ID})
+}
+
+// StreamWaypoint godoc
+// @Summary      Stream the changes of a waypoint
+// @Description  Streams as Server-Sent Events the readings ingested at the waypoint, its status and connectivity changes,
+// @Description  and the condition changes and weather alerts of its route. Every event is named after its type.
+// @Description  A "ping" event is sent periodically. A client too slow to keep up misses events and receives a "dropped" event with their count.
+// @Tags         waypoint
+// @Produce      text/event-stream
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Success      200 {object} StreamMessage
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/stream [get]
+func (h *StreamHandler) StreamWaypoint(c *gin.Context) {
+	waypointID, err := strconv.Atoi(c.Param("waypoint_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+		return
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), uint(waypointID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's waypoints"})
+		return
+	}
+
+	h.stream(c, *userID, models.StreamScope{
+		CompanyID:  waypoint.Route.CompanyID,
+		RouteID:    waypoint.RouteID,
+		WaypointID: waypoint.ID,
+	})
+}
+
+// StreamCompany godoc
+// @Summary      Stream the changes of a company
+// @Description  Streams as Server-Sent Events every change of the routes of the company, like for a single route.
+// @Description  A "ping" event is sent periodically. A client too slow to keep up misses events and receives a "dropped" event with their count.
+// @Tags         company
+// @Produce      text/event-stream
+// @Param        company_id path int true "Company ID"
+// @Success      200 {object} StreamMessage
+// @Security     BearerAuth
+// @Router       /company/{company_id}/stream [get]
+func (h *StreamHandler) StreamCompany(c *gin.Context) {
+	companyID, err := strconv.Atoi(c.Param("company_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid company ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, uint(companyID)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to access this company"})
+		return
+	}
+
+	h.stream(c, *userID, models.StreamScope{CompanyID: uint(companyID)})
+}
+
+// stream subscribes to the events in the scope and writes them until the client disconnects
+// The membership of the user is checked again at every heartbeat, so a user removed from the company
+// stops receiving its events.
+// c: The gin context
+// userID: ID of the subscribed user
+// scope: events to stream
+func (h *StreamHandler) stream(c *gin.Context, userID uint, scope models.StreamScope) {
+	subscription := h.eventHub.Subscribe(scope)
+	defer subscription.Close()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-h.shutdown:
+			return false
+		case event := <-subscription.Events():
+			writeDropped(c, subscription)
+
+			message, err := streamMessageOf(event)
+			if err != nil {
+				slog.Error("streaming an event failed", slog.String("type", string(event.Type)), slog.String("error", err.Error()))
+				return true
+			}
+			c.SSEvent(string(event.Type), message)
+			return true
+		case now := <-heartbeat.C:
+			if !h.userCompanyService.UserBelongsToCompany(userID, scope.CompanyID) {
+				c.SSEvent("error", gin.H{"error": "You are not authorized to access this company"})
+				return false
+			}
+
+			writeDropped(c, subscription)
+			c.SSEvent("ping", gin.H{"date": now.UTC()})
+			return true
+		}
+	})
+}
+
+// writeDropped tells the client how many events it missed since it was last told
+// c: The gin context
+// subscription: subscription of the client
+func writeDropped(c *gin.Context, subscription services.Subscription) {
+	if dropped := subscription.TakeDropped(); dropped > 0 {
+		c.SSEvent("dropped", gin.H{"dropped": dropped})
+	}
+}
+
+// streamMessageOf converts an event to the message sent to the client, the models are mapped to their DTOs
+// event: event to convert
+// returns: the message and an error if the data can not be mapped
+func streamMessageOf(event models.StreamEvent) (StreamMessage, error) {
+	message := StreamMessage{
+		Type:       event.Type,
+		CompanyID:  event.CompanyID,
+		RouteID:    event.RouteID,
+		WaypointID: event.WaypointID,
+		Date:       event.Date,
+		Data:       event.Data,
+	}
+
+	switch data := event.Data.(type) {
+	case models.SensorData:
+		sensorDataDTO := &dtos.SensorDataDTO{}
+		if err := dtoMapper.Map(sensorDataDTO, data); err != nil {
+			return message, err
+		}
+		deriveMetrics(sensorDataDTO)
+		message.Data = sensorDataDTO
+	case models.WaypointStatusEvent, models.RouteConditionEvent:
+		eventDTO := &dtos.StatusEventDTO{}
+		if err := dtoMapper.Map(eventDTO, data); err != nil {
+			return message, err
+		}
+		message.Data = eventDTO
+	case models.Alert:
+		alertDTO := &dtos.AlertDTO{}
+		if err := dtoMapper.Map(alertDTO, data); err != nil {
+			return message, err
+		}
+		for i := range alertDTO.Readings {
+			deriveMetrics(&alertDTO.Readings[i])
+		}
+		message.Data = alertDTO
+	}
+
+	return message, nil
+}
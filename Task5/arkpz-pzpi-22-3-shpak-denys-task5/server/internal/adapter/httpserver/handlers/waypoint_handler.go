@@ -2,6 +2,7 @@ package handlers // import "wayra/internal/adapter/httpserver/handlers"
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"wayra/internal/core/domain/dtos"
@@ -14,10 +15,11 @@ import (
 
 // WaypointHandler is
 type WaypointHandler struct {
-	waypointService    services.WaypointService
-	routeService       services.RouteService
-	companyService     services.CompanyService
-	userCompanyService services.UserCompanyService
+	waypointService     services.WaypointService
+	routeService        services.RouteService
+	companyService      services.CompanyService
+	userCompanyService  services.UserCompanyService
+	connectivityService services.ConnectivityService
 }
 
 // NewWaypointHandler is a constructor for WaypointHandler
@@ -25,18 +27,21 @@ type WaypointHandler struct {
 // routeService: service to handle routes
 // companyService: service to handle companies
 // userCompany: service to handle user-company relationships
+// connectivityService: service to track the last-seen times of the devices
 // returns: a new WaypointHandler
 func NewWaypointHandler(
 	waypointService services.WaypointService,
 	routeService services.RouteService,
 	companyService services.CompanyService,
 	userCompany services.UserCompanyService,
+	connectivityService services.ConnectivityService,
 ) *WaypointHandler {
 	return &WaypointHandler{
-		waypointService:    waypointService,
-		routeService:       routeService,
-		companyService:     companyService,
-		userCompanyService: userCompany,
+		waypointService:     waypointService,
+		routeService:        routeService,
+		companyService:      companyService,
+		userCompanyService:  userCompany,
+		connectivityService: connectivityService,
 	}
 }
 
@@ -80,6 +85,14 @@ type UpdateWaypointRequest struct {
 	// Device serial number
 	// Example: "1234567890"
 	DeviceSerial string  `json:"device_serial"`
+
+	// Health status reported for the waypoint's device, recorded in the status history
+	// Example: "anomaly_detected"
+	Status string `json:"status" example:"anomaly_detected"`
+
+	// Explanation of the reported status
+	// Example: "Device CV exceeds normal values. Possible sensor malfunction."
+	Details string `json:"details"`
 }
 
 // AddWaypoint godoc
@@ -133,6 +146,10 @@ func (h *WaypointHandler) AddWaypoint(c *gin.Context) {
 	}
 
 	if err := h.waypointService.Create(context.Background(), waypoint); err != nil {
+		if errors.Is(err, services.ErrDeviceSerialInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -148,7 +165,7 @@ func (h *WaypointHandler) AddWaypoint(c *gin.Context) {
 
 // GetWaypoint godoc
 // @Summary      Get waypoint details
-// @Description  Retrieves the details of a waypoint
+// @Description  Retrieves the details of a waypoint, without its readings: they are read from /waypoints/{waypoint_id}/sensor-data
 // @Tags         waypoint
 // @Produce      json
 // @Param        waypoint_id path int true "Waypoint ID"
@@ -187,9 +204,82 @@ func (h *WaypointHandler) GetWaypoint(c *gin.Context) {
 	c.JSON(http.StatusOK, waypointDTO)
 }
 
+// DeviceWaypointResponse is a struct to return the waypoint a device is bound to
+type DeviceWaypointResponse struct {
+	// ID of the waypoint the device is bound to
+	// Example: 1
+	WaypointID uint `json:"waypoint_id"`
+
+	// ID of the route the waypoint belongs to
+	// Example: 1
+	RouteID uint `json:"route_id"`
+
+	// Name of the waypoint
+	// Example: "Waypoint 1"
+	Name string `json:"name"`
+}
+
+// GetWaypointByDeviceSerial godoc
+// @Summary      Resolve waypoint by device serial
+// @Description  Returns the waypoint and route the device with the given serial is bound to
+// @Description  The serials bound to the waypoints of other companies are answered as not found
+// @Tags         waypoint
+// @Produce      json
+// @Param        device_serial query string true "Device serial number"
+// @Security     BearerAuth
+// @Router       /waypoints/ [get]
+func (h *WaypointHandler) GetWaypointByDeviceSerial(c *gin.Context) {
+	deviceSerial := c.Query("device_serial")
+	if deviceSerial == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_serial is required"})
+		return
+	}
+
+	device := getDeviceFromContext(c)
+	if device != nil && device.DeviceSerial != deviceSerial {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Devices can only resolve their own serial"})
+		return
+	}
+
+	var userID *uint
+	if device == nil {
+		id, err := getUserIDFromToken(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID = id
+	}
+
+	waypoint, err := h.waypointService.GetByDeviceSerial(context.Background(), deviceSerial)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDeviceSerialNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDeviceSerialDuplicate):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// The serials of other companies are not found, so users can not learn which serials are registered
+	if userID != nil && !h.userCompanyService.UserBelongsToCompany(*userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrDeviceSerialNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, DeviceWaypointResponse{
+		WaypointID: waypoint.ID,
+		RouteID:    waypoint.RouteID,
+		Name:       waypoint.Name,
+	})
+}
+
 // UpdateWaypoint godoc
 // @Summary      Update waypoint details
-// @Description  Updates the details of a waypoint
+// @Description  Updates the details of a waypoint. A device may only report the status and the coordinates of its own waypoint
 // @Tags         waypoint
 // @Accept       json
 // @Produce      json
@@ -210,24 +300,24 @@ func (h *WaypointHandler) UpdateWaypoint(c *gin.Context) {
 		return
 	}
 
-	userID, err := getUserIDFromToken(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	userCompany, err := h.userCompanyService.Where(context.Background(), &models.UserCompany{
-		UserID:    *userID,
-		CompanyID: waypoint.Route.CompanyID,
-	})
-	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-		return
-	}
-
-	if userCompany[0].Role != string(RoleAdmin) && userCompany[0].Role != string(RoleManager) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
-		return
+	var userID *uint
+	device := getDeviceFromContext(c)
+	if device != nil {
+		if device.WaypointID != waypoint.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Devices can only update their own waypoint"})
+			return
+		}
+	} else {
+		userID, err = getUserIDFromToken(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if !isCompanyManager(h.userCompanyService, *userID, waypoint.Route.CompanyID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
 	}
 
 	var waypointRequest UpdateWaypointRequest
@@ -236,14 +326,44 @@ func (h *WaypointHandler) UpdateWaypoint(c *gin.Context) {
 		return
 	}
 
-	waypoint.Name = waypointRequest.Name
-	waypoint.DeviceSerial = waypointRequest.DeviceSerial
-	waypoint.Latitude = waypointRequest.Latitude
-	waypoint.Longitude = waypointRequest.Longitude
+	if waypointRequest.Status != "" {
+		event := &models.WaypointStatusEvent{
+			Status:       waypointRequest.Status,
+			Details:      waypointRequest.Details,
+			ReportedByID: userID,
+		}
+		if device != nil {
+			event.SourceDevice = device.DeviceSerial
+		}
+
+		err := h.waypointService.ReportStatus(context.Background(), waypoint, event)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidWaypointStatus) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// A device can not rename its waypoint or bind it to another serial, it only reports where it is
+	if device == nil {
+		waypoint.Name = waypointRequest.Name
+		waypoint.DeviceSerial = waypointRequest.DeviceSerial
+	}
+	if device == nil || waypointRequest.Latitude != 0 || waypointRequest.Longitude != 0 {
+		waypoint.Latitude = waypointRequest.Latitude
+		waypoint.Longitude = waypointRequest.Longitude
+	}
 	waypoint.Route = models.Route{}
 	waypoint.SensorData = nil
 
 	if err := h.waypointService.Update(context.Background(), waypoint); err != nil {
+		if errors.Is(err, services.ErrDeviceSerialInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -257,6 +377,106 @@ func (h *WaypointHandler) UpdateWaypoint(c *gin.Context) {
 	c.JSON(http.StatusOK, waypointDTO)
 }
 
+// GetWaypointStatusHistory godoc
+// @Summary      Get waypoint status history
+// @Description  Retrieves the status events reported for the waypoint, newest first
+// @Tags         waypoint
+// @Produce      json
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Param        limit query int false "Maximum number of events"
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/status-history [get]
+func (h *WaypointHandler) GetWaypointStatusHistory(c *gin.Context) {
+	waypointID, err := strconv.Atoi(c.Param("waypoint_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), uint(waypointID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return
+	}
+
+	userID, err := getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !h.userCompanyService.UserBelongsToCompany(*userID, waypoint.Route.CompanyID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to get this company's waypoints"})
+		return
+	}
+
+	events, err := h.waypointService.GetStatusHistory(context.Background(), waypoint.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	eventDTOs := []dtos.StatusEventDTO{}
+	if err = dtoMapper.Map(&eventDTOs, events); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, eventDTOs)
+}
+
+// WaypointHeartbeat godoc
+// @Summary      Send waypoint heartbeat
+// @Description  Marks the device of the waypoint as online without sending a reading
+// @Tags         waypoint
+// @Param        waypoint_id path int true "Waypoint ID"
+// @Security     BearerAuth
+// @Router       /waypoints/{waypoint_id}/heartbeat [post]
+func (h *WaypointHandler) WaypointHeartbeat(c *gin.Context) {
+	waypointID, err := strconv.Atoi(c.Param("waypoint_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid waypoint ID format"})
+		return
+	}
+
+	waypoint, err := h.waypointService.GetByID(context.Background(), uint(waypointID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waypoint not found"})
+		return
+	}
+
+	if device := getDeviceFromContext(c); device != nil {
+		if device.WaypointID != waypoint.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Devices can only send heartbeats for their own waypoint"})
+			return
+		}
+	} else {
+		userID, err := getUserIDFromToken(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if !isCompanyManager(h.userCompanyService, *userID, waypoint.Route.CompanyID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+	}
+
+	if err := h.connectivityService.RecordActivity(context.Background(), waypoint.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 // DeleteWaypoint godoc
 // @Summary      Delete waypoint
 // @Description  Deletes a waypoint
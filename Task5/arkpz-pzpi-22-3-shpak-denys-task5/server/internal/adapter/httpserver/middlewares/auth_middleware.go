@@ -2,6 +2,7 @@
 package middlewares // import "wayra/internal/adapter/httpserver/middlewares"
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -12,13 +13,24 @@ import (
 )
 
 // AuthMiddleware is a middleware that checks if the request has a valid token
+// Users send "Bearer {token}", devices send "Device {token}".
+// Devices are only let through to the routes listed in deviceRoutes.
 // log: logger
 // authService: service to validate the token
+// deviceAuthService: service to validate the device token
+// deviceRoutes: routes devices may call, in the format "METHOD /path/:param"
 // returns: gin.HandlerFunc
 func AuthMiddleware(
 	log *slog.Logger,
 	authService services.AuthService,
+	deviceAuthService services.DeviceAuthService,
+	deviceRoutes []string,
 ) gin.HandlerFunc {
+	allowedDeviceRoutes := make(map[string]bool, len(deviceRoutes))
+	for _, route := range deviceRoutes {
+		allowedDeviceRoutes[route] = true
+	}
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -27,6 +39,26 @@ func AuthMiddleware(
 			return
 		}
 
+		if deviceToken := strings.TrimPrefix(authHeader, "Device "); deviceToken != authHeader {
+			credential, err := deviceAuthService.AuthenticateDevice(context.Background(), deviceToken)
+			if err != nil {
+				log.Warn("device authentication failed", slog.String("error", err.Error()))
+				c.JSON(http.StatusUnauthorized, gin.H{"message": services.ErrInvalidDeviceToken.Error()})
+				c.Abort()
+				return
+			}
+
+			if !allowedDeviceRoutes[c.Request.Method+" "+c.FullPath()] {
+				c.JSON(http.StatusForbidden, gin.H{"message": "Devices are not allowed to call this endpoint"})
+				c.Abort()
+				return
+			}
+
+			c.Set("device", credential)
+			c.Next()
+			return
+		}
+
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
 			c.JSON(http.StatusUnauthorized, gin.H{"message": "Bearer token missing"})
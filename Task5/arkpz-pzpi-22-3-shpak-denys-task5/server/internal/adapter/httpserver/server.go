@@ -28,6 +28,15 @@ import (
 // deliveryHandler: handler for the delivery routes
 // productHandler: handler for the product routes
 // adminHandler: handler for the admin routes
+// deviceConfigHandler: handler for the device config routes
+// deviceAuthService: service to validate the device tokens
+// deviceAuthHandler: handler for the device credential routes
+// deviceHandler: handler for the device registry routes
+// streamHandler: handler for the real-time streams
+// calibrationHandler: handler for the calibration profile routes
+// alertRuleHandler: handler for the alert rule routes
+// alertHandler: handler for the alert routes
+// notificationChannelHandler: handler for the notification channel routes
 // returns: *gin.Engine
 func NewRouter(
 	log *slog.Logger,
@@ -42,6 +51,15 @@ func NewRouter(
 	deliveryHandler *handlers.DeliveryHandler,
 	productHandler *handlers.ProductHandler,
 	adminHandler *handlers.AdminHandler,
+	deviceConfigHandler *handlers.DeviceConfigHandler,
+	deviceAuthService services.DeviceAuthService,
+	deviceAuthHandler *handlers.DeviceAuthHandler,
+	deviceHandler *handlers.DeviceHandler,
+	streamHandler *handlers.StreamHandler,
+	calibrationHandler *handlers.CalibrationHandler,
+	alertRuleHandler *handlers.AlertRuleHandler,
+	alertHandler *handlers.AlertHandler,
+	notificationChannelHandler *handlers.NotificationChannelHandler,
 ) *gin.Engine {
 	r := gin.Default()
 
@@ -53,7 +71,20 @@ func NewRouter(
 		auth.POST("/login", authHandler.LoginUser)
 	}
 
-	r.Use(middlewares.AuthMiddleware(log, authService))
+	// Routes devices may call with their own token, everything else is for users only
+	// The handlers limit a device to its own waypoint and the route of it.
+	deviceRoutes := []string{
+		"POST /sensor-data/",
+		"POST /sensor-data/batch",
+		"GET /waypoints/",
+		"PUT /waypoints/:waypoint_id",
+		"GET /device-config/:waypoint_id",
+		"POST /waypoints/:waypoint_id/heartbeat",
+		"PUT /routes/:route_id",
+		"GET /routes/:route_id/get-sensor-data",
+	}
+
+	r.Use(middlewares.AuthMiddleware(log, authService, deviceAuthService, deviceRoutes))
 
 	r.POST("/auth/logout", authHandler.LogoutUser)
 
@@ -76,6 +107,21 @@ func NewRouter(
 		company.POST("/:company_id/add-user", companyHandler.AddUserToCompany)
 		company.PUT("/:company_id/update-user", companyHandler.UpdateUserInCompany)
 		company.DELETE("/:company_id/remove-user", companyHandler.RemoveUserFromCompany)
+
+		company.GET("/:company_id/device-config", deviceConfigHandler.GetCompanyDeviceConfig)
+		company.PUT("/:company_id/device-config", deviceConfigHandler.UpdateCompanyDeviceConfig)
+
+		company.GET("/:company_id/devices", deviceHandler.GetCompanyDevices)
+
+		company.GET("/:company_id/sensor-data/export", sensorDataHandler.ExportCompanySensorData)
+		company.GET("/:company_id/stream", streamHandler.StreamCompany)
+
+		company.GET("/:company_id/alert-rules", alertRuleHandler.GetCompanyAlertRules)
+		company.POST("/:company_id/alert-rules", alertRuleHandler.CreateAlertRule)
+		company.GET("/:company_id/alerts", alertHandler.GetCompanyAlerts)
+
+		company.GET("/:company_id/notification-channels", notificationChannelHandler.GetCompanyNotificationChannels)
+		company.POST("/:company_id/notification-channels", notificationChannelHandler.CreateNotificationChannel)
 	}
 
 	deliveries := r.Group("/delivery")
@@ -102,6 +148,15 @@ func NewRouter(
 		routes.DELETE("/:route_id", routeHanler.DeleteRoute)
 
 		routes.GET("/:route_id/weather-alert", routeHanler.GetWeatherAlert)
+		routes.GET("/:route_id/alerts", alertHandler.GetRouteAlerts)
+		routes.GET("/:route_id/get-sensor-data", routeHanler.GetRouteSensorData)
+		routes.GET("/:route_id/condition-history", routeHanler.GetRouteConditionHistory)
+		routes.GET("/:route_id/classification", routeHanler.GetRouteClassification)
+		routes.GET("/:route_id/sensor-data", routeHanler.GetRouteSensorDataBuckets)
+		routes.GET("/:route_id/sensor-data/export", sensorDataHandler.ExportRouteSensorData)
+		routes.GET("/:route_id/sensor-data/estimate", sensorDataHandler.EstimateRouteSensorData)
+		routes.GET("/:route_id/stream", streamHandler.StreamRoute)
+		routes.POST("/:route_id/sensor-data/assess-quality", routeHanler.AssessRouteSensorDataQuality)
 	}
 
 	analytics := r.Group("/analytics")
@@ -113,19 +168,86 @@ func NewRouter(
 	waypoints := r.Group("/waypoints")
 	{
 		waypoints.POST("/", waypointHandler.AddWaypoint)
+		waypoints.GET("/", waypointHandler.GetWaypointByDeviceSerial)
 		waypoints.GET("/:waypoint_id", waypointHandler.GetWaypoint)
 		waypoints.PUT("/:waypoint_id", waypointHandler.UpdateWaypoint)
 		waypoints.DELETE("/:waypoint_id", waypointHandler.DeleteWaypoint)
+
+		waypoints.GET("/:waypoint_id/status-history", waypointHandler.GetWaypointStatusHistory)
+		waypoints.POST("/:waypoint_id/heartbeat", waypointHandler.WaypointHeartbeat)
+		waypoints.GET("/:waypoint_id/sensor-data", sensorDataHandler.GetWaypointSensorData)
+		waypoints.GET("/:waypoint_id/sensor-data/export", sensorDataHandler.ExportWaypointSensorData)
+		waypoints.GET("/:waypoint_id/sensor-data/estimate", sensorDataHandler.EstimateWaypointSensorData)
+		waypoints.GET("/:waypoint_id/stream", streamHandler.StreamWaypoint)
+
+		waypoints.POST("/:waypoint_id/device-credentials", deviceAuthHandler.IssueDeviceCredential)
+		waypoints.GET("/:waypoint_id/device-credentials", deviceAuthHandler.GetDeviceCredentials)
+		waypoints.DELETE("/:waypoint_id/device-credentials", deviceAuthHandler.RevokeDeviceCredentials)
+
+		waypoints.POST("/:waypoint_id/calibrations", calibrationHandler.CreateWaypointCalibration)
+		waypoints.GET("/:waypoint_id/calibrations", calibrationHandler.GetWaypointCalibrations)
+		waypoints.POST("/:waypoint_id/recalibrate", calibrationHandler.RecalibrateWaypoint)
 	}
 
 	sensorData := r.Group("/sensor-data")
 	{
 		sensorData.POST("/", sensorDataHandler.AddSensorData)
+		sensorData.POST("/batch", sensorDataHandler.AddSensorDataBatch)
+		sensorData.POST("/import", sensorDataHandler.ImportSensorData)
 		sensorData.GET("/:sensor_data_id", sensorDataHandler.GetSensorData)
 		sensorData.PUT("/:sensor_data_id", sensorDataHandler.UpdateSensorData)
 		sensorData.DELETE("/:sensor_data_id", sensorDataHandler.DeleteSensorData)
 	}
 
+	deviceConfig := r.Group("/device-config")
+	{
+		deviceConfig.GET("/:waypoint_id", deviceConfigHandler.GetDeviceConfig)
+		deviceConfig.PUT("/:waypoint_id", deviceConfigHandler.UpdateDeviceConfig)
+		deviceConfig.DELETE("/:waypoint_id", deviceConfigHandler.DeleteDeviceConfig)
+	}
+
+	devices := r.Group("/devices")
+	{
+		devices.POST("/", deviceHandler.CreateDevice)
+		devices.GET("/:device_id", deviceHandler.GetDevice)
+		devices.PUT("/:device_id", deviceHandler.UpdateDevice)
+		devices.DELETE("/:device_id", deviceHandler.DeleteDevice)
+
+		devices.POST("/:device_id/assign", deviceHandler.AssignDevice)
+		devices.POST("/:device_id/unassign", deviceHandler.UnassignDevice)
+		devices.GET("/:device_id/assignments", deviceHandler.GetDeviceAssignments)
+
+		devices.POST("/:device_id/calibrations", calibrationHandler.CreateDeviceCalibration)
+		devices.GET("/:device_id/calibrations", calibrationHandler.GetDeviceCalibrations)
+		devices.POST("/:device_id/recalibrate", calibrationHandler.RecalibrateDevice)
+	}
+
+	calibrations := r.Group("/calibrations")
+	{
+		calibrations.DELETE("/:calibration_id", calibrationHandler.DeleteCalibration)
+	}
+
+	alertRules := r.Group("/alert-rules")
+	{
+		alertRules.PUT("/:rule_id", alertRuleHandler.UpdateAlertRule)
+		alertRules.DELETE("/:rule_id", alertRuleHandler.DeleteAlertRule)
+	}
+
+	alerts := r.Group("/alerts")
+	{
+		alerts.GET("/:alert_id", alertHandler.GetAlert)
+		alerts.POST("/:alert_id/acknowledge", alertHandler.AcknowledgeAlert)
+		alerts.POST("/:alert_id/resolve", alertHandler.ResolveAlert)
+	}
+
+	notificationChannels := r.Group("/notification-channels")
+	{
+		notificationChannels.PUT("/:channel_id", notificationChannelHandler.UpdateNotificationChannel)
+		notificationChannels.DELETE("/:channel_id", notificationChannelHandler.DeleteNotificationChannel)
+		notificationChannels.GET("/:channel_id/deliveries", notificationChannelHandler.GetNotificationDeliveries)
+		notificationChannels.POST("/:channel_id/test", notificationChannelHandler.TestNotificationChannel)
+	}
+
 	admin := r.Group("/admin")
 	{
 		admin.POST("/backup", adminHandler.BackupDatabase)
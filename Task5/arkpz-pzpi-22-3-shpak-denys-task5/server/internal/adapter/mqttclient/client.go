@@ -0,0 +1,412 @@
+// Package mqttclient provides the MQTT adapter the devices use instead of the http server
+// on links where HTTP is too expensive. It ingests the telemetry of the devices and
+// publishes their config and alerts back to them.
+package mqttclient // import "wayra/internal/adapter/mqttclient"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"wayra/internal/adapter/config"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/ingest"
+	"wayra/internal/core/port/services"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// connectTimeout is how long Start waits for the first connection to the broker
+const connectTimeout = 10 * time.Second
+
+// disconnectQuiesce is how many milliseconds Stop waits for the pending work to finish
+const disconnectQuiesce = 250
+
+// Client is the MQTT adapter connected to the broker of the devices
+type Client struct {
+	log                 *slog.Logger                 // logger
+	cfg                 config.MQTTConfig            // broker configuration
+	client              paho.Client                  // connection to the broker, nil when MQTT is disabled
+	sensorDataService   services.SensorDataService   // service to store the telemetry
+	waypointService     services.WaypointService     // service to resolve the devices
+	deviceConfigService services.DeviceConfigService // service to resolve the config of the devices
+
+	mu               sync.Mutex        // guards publishedConfigs
+	publishedConfigs map[string]string // version of the config last published to every device serial
+}
+
+// NewClient creates a new MQTT adapter, it does not connect until Start is called
+// log: logger
+// cfg: config
+// sensorDataService: service to store the telemetry
+// waypointService: service to resolve the devices
+// deviceConfigService: service to resolve the config of the devices
+// returns: *Client
+func NewClient(
+	log *slog.Logger,
+	cfg *config.Config,
+	sensorDataService services.SensorDataService,
+	waypointService services.WaypointService,
+	deviceConfigService services.DeviceConfigService,
+) *Client {
+	c := &Client{
+		log:                 log.With(slog.String("adapter", "mqtt")),
+		cfg:                 cfg.MQTT,
+		sensorDataService:   sensorDataService,
+		waypointService:     waypointService,
+		deviceConfigService: deviceConfigService,
+		publishedConfigs:    make(map[string]string),
+	}
+
+	if c.cfg.BrokerURL == "" {
+		return c
+	}
+
+	options := paho.NewClientOptions().
+		AddBroker(c.cfg.BrokerURL).
+		SetClientID(c.cfg.ClientID).
+		SetUsername(c.cfg.Username).
+		SetPassword(c.cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(c.subscribe).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			c.log.Warn("connection to the broker lost", slog.String("error", err.Error()))
+		})
+
+	c.client = paho.NewClient(options)
+	return c
+}
+
+// Enabled reports whether a broker is configured
+// returns: true if the adapter connects to a broker
+func (c *Client) Enabled() bool {
+	return c.client != nil
+}
+
+// Start connects to the broker, the subscriptions are made on every (re)connection
+// Start keeps retrying in the background if the broker is not reachable within the connect timeout.
+// returns: an error if the connection was refused
+func (c *Client) Start() error {
+	if !c.Enabled() {
+		c.log.Info("no broker configured, MQTT is disabled")
+		return nil
+	}
+
+	token := c.client.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		c.log.Warn("broker not reachable yet, retrying in the background", slog.String("broker", c.cfg.BrokerURL))
+		return nil
+	}
+
+	return token.Error()
+}
+
+// Stop disconnects from the broker
+func (c *Client) Stop() {
+	if c.Enabled() {
+		c.client.Disconnect(disconnectQuiesce)
+	}
+}
+
+// PublishConfig publishes the effective config of the device of the waypoint as a retained message,
+// so the device gets it as soon as it subscribes
+// ctx: context
+// waypoint: waypoint the device is bound to, its route must be loaded
+// returns: an error if the config could not be resolved or published
+func (c *Client) PublishConfig(ctx context.Context, waypoint models.Waypoint) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if waypoint.DeviceSerial == "" {
+		return services.ErrDeviceSerialMissing
+	}
+
+	effective, err := c.deviceConfigService.GetEffectiveConfig(ctx, waypoint)
+	if err != nil {
+		return err
+	}
+
+	topic := buildTopic(c.cfg.TopicPrefix, waypoint.Route.CompanyID, waypoint.DeviceSerial, topicConfig)
+	if err := c.publish(topic, true, effective); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.publishedConfigs[waypoint.DeviceSerial] = effective.Version
+	c.mu.Unlock()
+
+	return nil
+}
+
+// PublishWaypointConfig publishes the effective config of the device of the waypoint after it changed
+// Waypoints without a device are skipped.
+// ctx: context
+// waypointID: ID of the waypoint
+// returns: an error if the config could not be resolved or published
+func (c *Client) PublishWaypointConfig(ctx context.Context, waypointID uint) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	waypoint, err := c.waypointService.GetByID(ctx, waypointID)
+	if err != nil {
+		return err
+	}
+	if waypoint.DeviceSerial == "" {
+		return nil
+	}
+
+	return c.PublishConfig(ctx, *waypoint)
+}
+
+// PublishCompanyConfigs publishes the effective config of every device of the company after its defaults changed
+// Every device is tried, the first error is returned.
+// ctx: context
+// companyID: ID of the company
+// returns: an error if a config could not be resolved or published
+func (c *Client) PublishCompanyConfigs(ctx context.Context, companyID uint) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	waypoints, err := c.waypointService.GetWithDevicesByCompany(ctx, companyID)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, waypoint := range waypoints {
+		if err := c.PublishConfig(ctx, waypoint); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// PublishAlert publishes an alert to the device of the waypoint, if the config of the device asks for alerts
+// The readings of the alert are left out, the devices only show it.
+// ctx: context
+// waypoint: waypoint the device is bound to, its route must be loaded
+// alert: alert to send, encoded as JSON
+// returns: an error if the config could not be resolved or the alert could not be published
+func (c *Client) PublishAlert(ctx context.Context, waypoint models.Waypoint, alert models.Alert) error {
+	if !c.Enabled() || waypoint.DeviceSerial == "" {
+		return nil
+	}
+
+	effective, err := c.deviceConfigService.GetEffectiveConfig(ctx, waypoint)
+	if err != nil {
+		return err
+	}
+	if !effective.GetWeatherAlerts {
+		return nil
+	}
+
+	alert.Readings = nil
+	topic := buildTopic(c.cfg.TopicPrefix, waypoint.Route.CompanyID, waypoint.DeviceSerial, topicAlert)
+	return c.publish(topic, false, alert)
+}
+
+// subscribe subscribes to the topics of the devices, it is called on every connection
+// client: connection to the broker
+func (c *Client) subscribe(client paho.Client) {
+	filters := map[string]byte{
+		subscription(c.cfg.TopicPrefix, topicTelemetry):     c.cfg.QoS,
+		subscription(c.cfg.TopicPrefix, topicConfigRequest): c.cfg.QoS,
+	}
+
+	token := client.SubscribeMultiple(filters, c.route)
+	if token.Wait() && token.Error() != nil {
+		c.log.Error("failed to subscribe", slog.String("error", token.Error().Error()))
+		return
+	}
+
+	c.log.Info("subscribed to the device topics", slog.String("broker", c.cfg.BrokerURL))
+}
+
+// route passes a message to the handler of its topic
+// client: connection to the broker
+// msg: received message
+func (c *Client) route(_ paho.Client, msg paho.Message) {
+	if device, err := parseTopic(c.cfg.TopicPrefix, msg.Topic(), topicTelemetry); err == nil {
+		c.handleTelemetry(device, msg.Payload())
+		return
+	}
+
+	if device, err := parseTopic(c.cfg.TopicPrefix, msg.Topic(), topicConfigRequest); err == nil {
+		c.handleConfigRequest(device)
+		return
+	}
+
+	c.log.Warn("message on an unknown topic", slog.String("topic", msg.Topic()))
+}
+
+// handleTelemetry stores the readings published by a device
+// The payload is a single reading or an array of readings, in the same schema as the readings sent over HTTP.
+// device: device the readings were published for
+// payload: payload of the message
+func (c *Client) handleTelemetry(device deviceTopic, payload []byte) {
+	ctx := context.Background()
+	log := c.log.With(slog.String("device_serial", device.deviceSerial))
+
+	waypoint, ok := c.resolveDevice(ctx, device)
+	if !ok {
+		return
+	}
+
+	var messages []ingest.Reading
+	payload = bytes.TrimSpace(payload)
+	if bytes.HasPrefix(payload, []byte("[")) {
+		if err := json.Unmarshal(payload, &messages); err != nil {
+			log.Warn("invalid telemetry payload", slog.String("error", err.Error()))
+			return
+		}
+	} else {
+		var message ingest.Reading
+		if err := json.Unmarshal(payload, &message); err != nil {
+			log.Warn("invalid telemetry payload", slog.String("error", err.Error()))
+			return
+		}
+		messages = append(messages, message)
+	}
+
+	receivedAt := time.Now().UTC()
+	readings := make([]models.SensorData, 0, len(messages))
+	for _, message := range messages {
+		reading, err := message.ToSensorData()
+		if err != nil {
+			log.Warn("invalid telemetry reading", slog.String("error", err.Error()))
+			continue
+		}
+
+		if message.Date != "" {
+			date, err := time.Parse(time.RFC3339, message.Date)
+			if err != nil {
+				log.Warn("invalid telemetry date", slog.String("date", message.Date))
+				continue
+			}
+			reading.DeviceDate = &date
+		}
+
+		reading.ReceivedAt = &receivedAt
+		reading.WaypointID = waypoint.ID
+		readings = append(readings, reading)
+	}
+
+	statuses, err := c.sensorDataService.IngestBatch(ctx, readings)
+	if err != nil {
+		log.Error("failed to store telemetry", slog.String("error", err.Error()))
+		return
+	}
+
+	created := 0
+	for _, status := range statuses {
+		if status == models.IngestStatusCreated {
+			created++
+		}
+	}
+	log.Debug("telemetry stored", slog.Int("received", len(messages)), slog.Int("created", created))
+
+	c.publishConfigIfChanged(ctx, *waypoint)
+}
+
+// handleConfigRequest publishes the config of a device that asked for it
+// device: device that asked for its config
+func (c *Client) handleConfigRequest(device deviceTopic) {
+	ctx := context.Background()
+
+	waypoint, ok := c.resolveDevice(ctx, device)
+	if !ok {
+		return
+	}
+
+	if err := c.PublishConfig(ctx, *waypoint); err != nil {
+		c.log.Error("failed to publish config",
+			slog.String("device_serial", device.deviceSerial),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// publishConfigIfChanged publishes the config of the device when it changed since it was last published
+// ctx: context
+// waypoint: waypoint the device is bound to
+func (c *Client) publishConfigIfChanged(ctx context.Context, waypoint models.Waypoint) {
+	effective, err := c.deviceConfigService.GetEffectiveConfig(ctx, waypoint)
+	if err != nil {
+		c.log.Error("failed to resolve config", slog.String("error", err.Error()))
+		return
+	}
+
+	c.mu.Lock()
+	published := c.publishedConfigs[waypoint.DeviceSerial]
+	c.mu.Unlock()
+
+	if published == effective.Version {
+		return
+	}
+
+	if err := c.PublishConfig(ctx, waypoint); err != nil {
+		c.log.Error("failed to publish config",
+			slog.String("device_serial", waypoint.DeviceSerial),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// resolveDevice returns the waypoint the device is bound to
+// Messages of unknown devices, or published under another company, are dropped.
+// ctx: context
+// device: device from the topic
+// returns: the waypoint and false if the message has to be dropped
+func (c *Client) resolveDevice(ctx context.Context, device deviceTopic) (*models.Waypoint, bool) {
+	waypoint, err := c.waypointService.GetByDeviceSerial(ctx, device.deviceSerial)
+	if err != nil {
+		level := slog.LevelError
+		if errors.Is(err, services.ErrDeviceSerialNotFound) || errors.Is(err, services.ErrDeviceSerialDuplicate) {
+			level = slog.LevelWarn
+		}
+		c.log.Log(ctx, level, "message from an unresolved device",
+			slog.String("device_serial", device.deviceSerial),
+			slog.String("error", err.Error()),
+		)
+		return nil, false
+	}
+
+	if waypoint.Route.CompanyID != device.companyID {
+		c.log.Warn("device published under another company",
+			slog.String("device_serial", device.deviceSerial),
+			slog.Uint64("company_id", uint64(device.companyID)),
+		)
+		return nil, false
+	}
+
+	return waypoint, true
+}
+
+// publish encodes the payload as JSON and publishes it
+// topic: topic to publish to
+// retained: whether the broker keeps the message for new subscribers
+// payload: payload to encode
+// returns: an error if the message could not be published
+func (c *Client) publish(topic string, retained bool, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	token := c.client.Publish(topic, c.cfg.QoS, retained, data)
+	if !token.WaitTimeout(connectTimeout) {
+		return fmt.Errorf("timed out publishing to %s", topic)
+	}
+
+	return token.Error()
+}
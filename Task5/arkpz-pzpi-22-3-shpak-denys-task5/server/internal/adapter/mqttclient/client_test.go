@@ -0,0 +1,344 @@
+package mqttclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+	"wayra/internal/adapter/config"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// waitTimeout is how long a test waits for a message to go through the broker
+const waitTimeout = 5 * time.Second
+
+// testWaypoint is the waypoint the device of the tests is bound to
+var testWaypoint = models.Waypoint{
+	ID:           7,
+	DeviceSerial: "dev-1",
+	RouteID:      2,
+	Route:        models.Route{ID: 2, CompanyID: 3},
+}
+
+// fakeSensorDataService records the ingested batches
+type fakeSensorDataService struct {
+	services.SensorDataService
+	batches chan []models.SensorData
+}
+
+func (f *fakeSensorDataService) IngestBatch(_ context.Context, readings []models.SensorData) ([]models.IngestStatus, error) {
+	f.batches <- readings
+
+	statuses := make([]models.IngestStatus, len(readings))
+	for i := range statuses {
+		statuses[i] = models.IngestStatusCreated
+	}
+	return statuses, nil
+}
+
+// fakeWaypointService resolves the device of testWaypoint only
+type fakeWaypointService struct {
+	services.WaypointService
+}
+
+func (f *fakeWaypointService) GetByDeviceSerial(_ context.Context, deviceSerial string) (*models.Waypoint, error) {
+	if deviceSerial != testWaypoint.DeviceSerial {
+		return nil, services.ErrDeviceSerialNotFound
+	}
+	waypoint := testWaypoint
+	return &waypoint, nil
+}
+
+func (f *fakeWaypointService) GetByID(_ context.Context, id uint) (*models.Waypoint, error) {
+	if id != testWaypoint.ID {
+		return nil, fmt.Errorf("waypoint %d not found", id)
+	}
+	waypoint := testWaypoint
+	return &waypoint, nil
+}
+
+func (f *fakeWaypointService) GetWithDevicesByCompany(_ context.Context, companyID uint) ([]models.Waypoint, error) {
+	if companyID != testWaypoint.Route.CompanyID {
+		return nil, nil
+	}
+	return []models.Waypoint{testWaypoint}, nil
+}
+
+// fakeDeviceConfigService returns a config the tests can change
+type fakeDeviceConfigService struct {
+	services.DeviceConfigService
+	mu     sync.Mutex
+	config models.EffectiveDeviceConfig
+}
+
+func (f *fakeDeviceConfigService) GetEffectiveConfig(_ context.Context, waypoint models.Waypoint) (*models.EffectiveDeviceConfig, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	config := f.config
+	config.WaypointID = waypoint.ID
+	return &config, nil
+}
+
+func (f *fakeDeviceConfigService) set(config models.EffectiveDeviceConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = config
+}
+
+// testSetup is an in-process broker with a client connected to it
+type testSetup struct {
+	broker        *mqtt.Server
+	client        *Client
+	sensorData    *fakeSensorDataService
+	deviceConfigs *fakeDeviceConfigService
+}
+
+// newTestSetup starts an in-process broker on a free port and connects a client to it
+func newTestSetup(t *testing.T) *testSetup {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	broker := mqtt.New(&mqtt.Options{InlineClient: true})
+	if err := broker.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.AddListener(listeners.NewTCP(listeners.Config{ID: "test", Address: address})); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+
+	setup := &testSetup{
+		broker:        broker,
+		sensorData:    &fakeSensorDataService{batches: make(chan []models.SensorData, 16)},
+		deviceConfigs: &fakeDeviceConfigService{config: models.EffectiveDeviceConfig{SendDataFrequency: 5, Version: "v1"}},
+	}
+
+	cfg := &config.Config{MQTT: config.MQTTConfig{
+		BrokerURL:   "tcp://" + address,
+		ClientID:    "wayra-test",
+		TopicPrefix: "wayra",
+		QoS:         1,
+	}}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	setup.client = NewClient(log, cfg, setup.sensorData, &fakeWaypointService{}, setup.deviceConfigs)
+	if err := setup.client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(setup.client.Stop)
+
+	return setup
+}
+
+// subscribe returns the messages the broker delivers on the topic
+func (s *testSetup) subscribe(t *testing.T, topic string, id int) <-chan packets.Packet {
+	t.Helper()
+
+	messages := make(chan packets.Packet, 16)
+	err := s.broker.Subscribe(topic, id, func(_ *mqtt.Client, _ packets.Subscription, pk packets.Packet) {
+		messages <- pk
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return messages
+}
+
+// receive waits for the next message
+func receive(t *testing.T, messages <-chan packets.Packet) packets.Packet {
+	t.Helper()
+
+	select {
+	case pk := <-messages:
+		return pk
+	case <-time.After(waitTimeout):
+		t.Fatal("no message received")
+		return packets.Packet{}
+	}
+}
+
+// receiveConfig waits for the next config and decodes it
+func receiveConfig(t *testing.T, messages <-chan packets.Packet) models.EffectiveDeviceConfig {
+	t.Helper()
+
+	var config models.EffectiveDeviceConfig
+	if err := json.Unmarshal(receive(t, messages).Payload, &config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestTelemetryIsIngestedAndAnsweredWithTheConfig(t *testing.T) {
+	setup := newTestSetup(t)
+	configs := setup.subscribe(t, buildTopic("wayra", 3, "dev-1", topicConfig), 1)
+
+	telemetry := buildTopic("wayra", 3, "dev-1", topicTelemetry)
+	payload := []byte(`[
+		{"date": "2024-12-01T12:00:00Z", "temperature": 21.5, "humidity": 40, "wind_speed": 3, "mean_pressure": 1012},
+		{"date": "2024-12-01T12:05:00Z", "temperature": 22, "humidity": 41, "wind_speed": 2, "mean_pressure": 1013}
+	]`)
+
+	// The client subscribes once connected, so the telemetry is resent until it gets through
+	var batch []models.SensorData
+	deadline := time.After(waitTimeout)
+	for batch == nil {
+		if err := setup.broker.Publish(telemetry, payload, false, 1); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case batch = <-setup.sensorData.batches:
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("telemetry was not ingested")
+		}
+	}
+
+	if len(batch) != 2 {
+		t.Fatalf("ingested %d readings, want 2", len(batch))
+	}
+	for i, want := range []float64{21.5, 22} {
+		if batch[i].WaypointID != testWaypoint.ID {
+			t.Errorf("reading %d: waypoint %d, want %d", i, batch[i].WaypointID, testWaypoint.ID)
+		}
+		if batch[i].Temperature != want {
+			t.Errorf("reading %d: temperature %v, want %v", i, batch[i].Temperature, want)
+		}
+		if batch[i].DeviceDate == nil || batch[i].ReceivedAt == nil {
+			t.Errorf("reading %d: device date and received at must be set", i)
+		}
+	}
+
+	if config := receiveConfig(t, configs); config.Version != "v1" || config.WaypointID != testWaypoint.ID {
+		t.Errorf("config %+v, want version v1 of waypoint %d", config, testWaypoint.ID)
+	}
+}
+
+func TestTelemetryOfUnknownDevicesIsDropped(t *testing.T) {
+	setup := newTestSetup(t)
+
+	tests := []struct {
+		name  string
+		topic string
+	}{
+		{"unknown serial", buildTopic("wayra", 3, "dev-2", topicTelemetry)},
+		{"other company", buildTopic("wayra", 4, "dev-1", topicTelemetry)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := setup.broker.Publish(tt.topic, []byte(`{"temperature": 20}`), false, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case batch := <-setup.sensorData.batches:
+				t.Errorf("ingested %d readings, want none", len(batch))
+			case <-time.After(300 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestChangedConfigsArePublished(t *testing.T) {
+	setup := newTestSetup(t)
+	configs := setup.subscribe(t, buildTopic("wayra", 3, "dev-1", topicConfig), 1)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		version string
+		publish func() error
+	}{
+		{"waypoint override", "v2", func() error { return setup.client.PublishWaypointConfig(ctx, testWaypoint.ID) }},
+		{"company defaults", "v3", func() error { return setup.client.PublishCompanyConfigs(ctx, testWaypoint.Route.CompanyID) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup.deviceConfigs.set(models.EffectiveDeviceConfig{SendDataFrequency: 10, Version: tt.version})
+			if err := tt.publish(); err != nil {
+				t.Fatal(err)
+			}
+
+			pk := receive(t, configs)
+			var config models.EffectiveDeviceConfig
+			if err := json.Unmarshal(pk.Payload, &config); err != nil {
+				t.Fatal(err)
+			}
+			if config.Version != tt.version || config.SendDataFrequency != 10 {
+				t.Errorf("config %+v, want version %s every 10 minutes", config, tt.version)
+			}
+		})
+	}
+}
+
+func TestAlertsArePublishedToDevicesThatAskForThem(t *testing.T) {
+	setup := newTestSetup(t)
+	alerts := setup.subscribe(t, buildTopic("wayra", 3, "dev-1", topicAlert), 1)
+	ctx := context.Background()
+
+	tests := []struct {
+		name             string
+		getWeatherAlerts bool
+		want             bool
+	}{
+		{"alerts enabled", true, true},
+		{"alerts disabled", false, false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup.deviceConfigs.set(models.EffectiveDeviceConfig{GetWeatherAlerts: tt.getWeatherAlerts, Version: "v1"})
+
+			alert := models.Alert{
+				ID:         uint(i + 1),
+				WaypointID: testWaypoint.ID,
+				Type:       "storm",
+				Severity:   models.AlertSeverityCritical,
+				Readings:   []models.AlertReading{{ID: 1}},
+			}
+			if err := setup.client.PublishAlert(ctx, testWaypoint, alert); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case pk := <-alerts:
+				if !tt.want {
+					t.Fatal("alert published to a device that does not ask for alerts")
+				}
+				var published models.Alert
+				if err := json.Unmarshal(pk.Payload, &published); err != nil {
+					t.Fatal(err)
+				}
+				if published.ID != alert.ID || published.Type != "storm" || len(published.Readings) != 0 {
+					t.Errorf("alert %+v, want alert %d without readings", published, alert.ID)
+				}
+			case <-time.After(300 * time.Millisecond):
+				if tt.want {
+					t.Fatal("alert was not published")
+				}
+			}
+		})
+	}
+}
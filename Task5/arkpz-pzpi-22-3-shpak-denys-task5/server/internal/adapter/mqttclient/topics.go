@@ -0,0 +1,68 @@
+package mqttclient // import "wayra/internal/adapter/mqttclient"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Last levels of the device topics, the full topic is {prefix}/{company}/{device_serial}/{kind}
+const (
+	topicTelemetry     = "telemetry"  // readings published by the device
+	topicConfig        = "config"     // config published to the device, retained
+	topicConfigRequest = "config/get" // published by the device to ask for its config
+	topicAlert         = "alert"      // alerts published to the device
+)
+
+// deviceTopic identifies the device a topic belongs to
+type deviceTopic struct {
+	companyID    uint   // ID of the company from the second level
+	deviceSerial string // serial of the device from the third level
+}
+
+// buildTopic builds the topic of a device
+// prefix: first level of the topic
+// companyID: ID of the company
+// deviceSerial: serial of the device
+// kind: last levels of the topic
+// returns: the topic
+func buildTopic(prefix string, companyID uint, deviceSerial, kind string) string {
+	return fmt.Sprintf("%s/%d/%s/%s", prefix, companyID, deviceSerial, kind)
+}
+
+// subscription returns the topic filter matching the given kind of topic of every device
+// prefix: first level of the topic
+// kind: last levels of the topic
+// returns: the topic filter
+func subscription(prefix, kind string) string {
+	return prefix + "/+/+/" + kind
+}
+
+// parseTopic reads the company and device serial from a device topic
+// prefix: first level of the topic
+// topic: topic the message was received on
+// kind: expected last levels of the topic
+// returns: the device the topic belongs to and an error if the topic does not match
+func parseTopic(prefix, topic, kind string) (deviceTopic, error) {
+	rest, ok := strings.CutPrefix(topic, prefix+"/")
+	if !ok {
+		return deviceTopic{}, fmt.Errorf("topic %q does not start with %q", topic, prefix)
+	}
+
+	rest, ok = strings.CutSuffix(rest, "/"+kind)
+	if !ok {
+		return deviceTopic{}, fmt.Errorf("topic %q does not end with %q", topic, kind)
+	}
+
+	company, deviceSerial, ok := strings.Cut(rest, "/")
+	if !ok || deviceSerial == "" || strings.Contains(deviceSerial, "/") {
+		return deviceTopic{}, fmt.Errorf("topic %q is not a device topic", topic)
+	}
+
+	companyID, err := strconv.ParseUint(company, 10, 64)
+	if err != nil {
+		return deviceTopic{}, fmt.Errorf("topic %q has an invalid company ID", topic)
+	}
+
+	return deviceTopic{companyID: uint(companyID), deviceSerial: deviceSerial}, nil
+}
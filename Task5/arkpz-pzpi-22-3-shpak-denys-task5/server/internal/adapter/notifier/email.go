@@ -0,0 +1,150 @@
+package notifier // import "wayra/internal/adapter/notifier"
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+	"wayra/internal/adapter/config"
+	"wayra/internal/core/domain/models"
+)
+
+// EmailSender sends the notifications as plain-text emails to the recipients of the email channels
+type EmailSender struct {
+	cfg config.SMTPConfig // SMTP server the emails are sent through
+}
+
+// NewEmailSender creates a new EmailSender
+// cfg: config
+// returns: *EmailSender
+func NewEmailSender(cfg *config.Config) *EmailSender {
+	return &EmailSender{cfg: cfg.Notifications.SMTP}
+}
+
+// Send sends the subject and the text of the notification to the recipients of the channel
+// STARTTLS is used when the server offers it.
+// ctx: context bounding the connection to the server
+// channel: email channel
+// notification: notification to send
+// returns: 0 and an error
+func (s *EmailSender) Send(
+	ctx context.Context,
+	channel models.NotificationChannel,
+	notification models.Notification,
+) (int, error) {
+	from, err := mail.ParseAddress(s.cfg.From)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sender address: %s", s.cfg.From)
+	}
+
+	recipients, err := mail.ParseAddressList(channel.Recipients)
+	if err != nil {
+		return 0, err
+	}
+
+	message, err := buildMessage(from, recipients, notification)
+	if err != nil {
+		return 0, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port)))
+	if err != nil {
+		return 0, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return 0, err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return 0, err
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return 0, err
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient.Address); err != nil {
+			return 0, err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := writer.Write(message); err != nil {
+		writer.Close()
+		return 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+
+	return 0, client.Quit()
+}
+
+// buildMessage builds the email of a notification, its text encoded as quoted-printable UTF-8
+// from: sender of the email
+// recipients: recipients of the email
+// notification: notification to send
+// returns: the message with its headers and an error
+func buildMessage(from *mail.Address, recipients []*mail.Address, notification models.Notification) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	to := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		to = append(to, recipient.String())
+	}
+
+	var message bytes.Buffer
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", notification.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=UTF-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", header[0], header[1])
+	}
+	message.WriteString("\r\n")
+
+	writer := quotedprintable.NewWriter(&message)
+	text := strings.ReplaceAll(strings.ReplaceAll(notification.Text, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := writer.Write([]byte(text)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return message.Bytes(), nil
+}
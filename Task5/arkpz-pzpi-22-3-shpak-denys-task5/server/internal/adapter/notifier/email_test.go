@@ -0,0 +1,193 @@
+package notifier
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+	"wayra/internal/adapter/config"
+	"wayra/internal/core/domain/models"
+)
+
+// receivedEmail is an email a test SMTP server received
+type receivedEmail struct {
+	from       string
+	recipients []string
+	data       string
+}
+
+// newTestSMTPServer starts an SMTP server on a free port answering the recipients it rejects with 550
+// returns: the port and the emails it receives
+func newTestSMTPServer(t *testing.T, rejected string) (int, <-chan receivedEmail) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	emails := make(chan receivedEmail, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, rejected, emails)
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, emails
+}
+
+// serveSMTP speaks the part of SMTP the EmailSender uses, without STARTTLS and authentication
+func serveSMTP(conn net.Conn, rejected string, emails chan<- receivedEmail) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	var email receivedEmail
+	reply := func(format string, args ...any) { _ = text.PrintfLine(format, args...) }
+
+	reply("220 test ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-test")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			from, _, _ := strings.Cut(strings.TrimSpace(line[len("MAIL FROM:"):]), " ")
+			email = receivedEmail{from: strings.Trim(from, "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			recipient := strings.Trim(line[len("RCPT TO:"):], "<> ")
+			if recipient == rejected {
+				reply("550 no such user")
+				continue
+			}
+			email.recipients = append(email.recipients, recipient)
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			email.data = string(data)
+			emails <- email
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestEmailSend(t *testing.T) {
+	tests := []struct {
+		name       string
+		from       string
+		recipients string
+		rejected   string
+		wantErr    bool
+	}{
+		{"delivered", "Wayra <alerts@wayra.test>", "ops@company.test, Duty <duty@company.test>", "", false},
+		{"recipient rejected", "alerts@wayra.test", "ops@company.test, gone@company.test", "gone@company.test", true},
+		{"invalid sender", "not an address", "ops@company.test", "", true},
+	}
+
+	notification := models.Notification{
+		Subject: "[critical] Шторм on North",
+		Text:    "Wind speed above 20 m/s\nWaypoint: Pass = 2",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, emails := newTestSMTPServer(t, tt.rejected)
+			sender := NewEmailSender(&config.Config{Notifications: config.NotificationsConfig{
+				SMTP: config.SMTPConfig{Host: "127.0.0.1", Port: port, From: tt.from},
+			}})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			channel := models.NotificationChannel{Kind: models.NotificationChannelEmail, Recipients: tt.recipients}
+			_, err := sender.Send(ctx, channel, notification)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error %v, want an error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				select {
+				case <-emails:
+					t.Error("email was sent")
+				default:
+				}
+				return
+			}
+
+			var email receivedEmail
+			select {
+			case email = <-emails:
+			case <-ctx.Done():
+				t.Fatal("email was not received")
+			}
+
+			if email.from != "alerts@wayra.test" {
+				t.Errorf("sender %s, want alerts@wayra.test", email.from)
+			}
+			if got := strings.Join(email.recipients, ","); got != "ops@company.test,duty@company.test" {
+				t.Errorf("recipients %s, want ops@company.test,duty@company.test", got)
+			}
+
+			message, err := mail.ReadMessage(strings.NewReader(email.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+			if err != nil || subject != notification.Subject {
+				t.Errorf("subject %q, want %q", subject, notification.Subject)
+			}
+			body, err := io.ReadAll(quotedprintable.NewReader(message.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The dot reader of the server turns the line endings back into \n and keeps the closing one
+			if want := notification.Text + "\n"; string(body) != want {
+				t.Errorf("body %q, want %q", body, want)
+			}
+			if got := message.Header.Get("Content-Type"); got != "text/plain; charset=UTF-8" {
+				t.Errorf("content type %q", got)
+			}
+		})
+	}
+}
+
+func TestEmailSendFailsWithoutServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	sender := NewEmailSender(&config.Config{Notifications: config.NotificationsConfig{
+		SMTP: config.SMTPConfig{Host: "127.0.0.1", Port: port, From: "alerts@wayra.test"},
+	}})
+	channel := models.NotificationChannel{Kind: models.NotificationChannelEmail, Recipients: "ops@company.test"}
+	if _, err := sender.Send(context.Background(), channel, models.Notification{Subject: "test"}); err == nil {
+		t.Fatalf("Send() to 127.0.0.1:%d succeeded without a server", port)
+	}
+}
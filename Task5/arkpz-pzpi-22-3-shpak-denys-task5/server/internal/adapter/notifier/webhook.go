@@ -0,0 +1,114 @@
+// Package notifier provides the adapters sending the alert notifications to the channels of the companies:
+// signed JSON webhooks and SMTP email.
+package notifier // import "wayra/internal/adapter/notifier"
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/netguard"
+)
+
+// Headers of the webhook requests
+const (
+	SignatureHeader = "X-Wayra-Signature" // sha256= followed by the hex HMAC of the timestamp, a dot and the body
+	TimestampHeader = "X-Wayra-Timestamp" // Unix time the request was signed at
+	EventHeader     = "X-Wayra-Event"     // event the notification is sent for
+	DeliveryHeader  = "X-Wayra-Delivery"  // ID of the delivery, the same for every retry
+)
+
+// maxResponseBody is how much of the response of a webhook is read before the connection is reused
+const maxResponseBody = 64 << 10
+
+// WebhookSender posts the notifications as JSON to the URLs of the webhook channels
+type WebhookSender struct {
+	client *http.Client // client sending the requests, the timeout comes from the context
+}
+
+// NewWebhookSender creates a new WebhookSender
+// Redirects are not followed, a webhook answering with one has to be fixed instead.
+// The connections are checked once their host is resolved and no proxy is used,
+// so a webhook can not reach the addresses the guard refuses, even by changing what its host resolves to.
+// guard: hosts the webhooks may reach
+// returns: *WebhookSender
+func NewWebhookSender(guard *netguard.Guard) *WebhookSender {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = guard.DialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+
+	return &WebhookSender{
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send posts the notification to the URL of the channel, signed with the secret of the channel
+// ctx: context bounding the request
+// channel: webhook channel
+// notification: notification to send
+// returns: the HTTP status of the response and an error unless it is a 2xx
+func (s *WebhookSender) Send(
+	ctx context.Context,
+	channel models.NotificationChannel,
+	notification models.Notification,
+) (int, error) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wayra-webhook")
+	req.Header.Set(SignatureHeader, "sha256="+Sign(channel.Secret, timestamp, body))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(EventHeader, notification.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(notification.DeliveryID), 10))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign computes the signature of a webhook request
+// The receiver recomputes it from the X-Wayra-Timestamp header and the raw body to check the request
+// comes from the server, and rejects old timestamps to stop replays.
+// secret: secret of the channel
+// timestamp: value of the X-Wayra-Timestamp header
+// body: body of the request
+// returns: the hex HMAC-SHA256 of the timestamp, a dot and the body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,147 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/netguard"
+)
+
+// receivedRequest is a request a test webhook received
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newTestWebhook starts a webhook answering with the status and returns the requests it receives
+func newTestWebhook(t *testing.T, status int) (*httptest.Server, <-chan receivedRequest) {
+	t.Helper()
+
+	requests := make(chan receivedRequest, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- receivedRequest{header: r.Header.Clone(), body: body}
+		if status == http.StatusFound {
+			http.Redirect(w, r, "/elsewhere", status)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{"body", "whsec_abc", "1700000000", `{"event":"test"}`, "88e7be1da5ab370406fbc9c9f4f50141393c84bb7025e65248b94e444bb93450"},
+		{"empty secret and body", "", "1700000000", "", "c1da1b6c6b8e9da7f4bbb90f7cab0820f271ad19ccbf80c88479c4e14f37d1c6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"accepted", http.StatusOK, false},
+		{"accepted without content", http.StatusNoContent, false},
+		{"server error", http.StatusInternalServerError, true},
+		{"redirect is not followed", http.StatusFound, true},
+	}
+
+	sender := NewWebhookSender(netguard.New([]string{"127.0.0.1"}))
+	notification := models.Notification{
+		DeliveryID: 42,
+		Event:      models.NotificationEventAlertOpened,
+		AlertID:    5,
+		Type:       "storm",
+		Severity:   models.AlertSeverityCritical,
+		OpenedAt:   time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newTestWebhook(t, tt.status)
+			channel := models.NotificationChannel{Kind: models.NotificationChannelWebhook, URL: server.URL + "/hook", Secret: "whsec_test"}
+
+			status, err := sender.Send(context.Background(), channel, notification)
+			if status != tt.status || (err != nil) != tt.wantErr {
+				t.Fatalf("Send() = %d, %v, want %d and an error: %v", status, err, tt.status, tt.wantErr)
+			}
+
+			request := <-requests
+			select {
+			case <-requests:
+				t.Fatal("webhook received more than one request")
+			default:
+			}
+
+			timestamp := request.header.Get(TimestampHeader)
+			if unix, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(unix, 0)) > time.Minute {
+				t.Errorf("timestamp %q, want the current Unix time", timestamp)
+			}
+			if got, want := request.header.Get(SignatureHeader), "sha256="+Sign(channel.Secret, timestamp, request.body); got != want {
+				t.Errorf("signature %s, want %s", got, want)
+			}
+
+			headers := map[string]string{
+				EventHeader:    models.NotificationEventAlertOpened,
+				DeliveryHeader: "42",
+				"Content-Type": "application/json",
+				"User-Agent":   "wayra-webhook",
+			}
+			for name, want := range headers {
+				if got := request.header.Get(name); got != want {
+					t.Errorf("header %s = %q, want %q", name, got, want)
+				}
+			}
+
+			var received models.Notification
+			if err := json.Unmarshal(request.body, &received); err != nil {
+				t.Fatal(err)
+			}
+			if received.AlertID != notification.AlertID || received.Type != notification.Type {
+				t.Errorf("notification %+v, want alert %d of type %s", received, notification.AlertID, notification.Type)
+			}
+		})
+	}
+}
+
+func TestWebhookSendRefusesAddressesNotAllowed(t *testing.T) {
+	server, requests := newTestWebhook(t, http.StatusOK)
+	channel := models.NotificationChannel{Kind: models.NotificationChannelWebhook, URL: server.URL, Secret: "whsec_test"}
+
+	_, err := NewWebhookSender(netguard.New(nil)).Send(context.Background(), channel, models.Notification{})
+	if !errors.Is(err, netguard.ErrAddressNotAllowed) {
+		t.Fatalf("error %v, want %v", err, netguard.ErrAddressNotAllowed)
+	}
+
+	select {
+	case <-requests:
+		t.Error("webhook on a loopback address received the request")
+	default:
+	}
+}
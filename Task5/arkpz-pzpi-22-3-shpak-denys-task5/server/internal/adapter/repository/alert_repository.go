@@ -0,0 +1,130 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+)
+
+// AlertRepository is a repository for the alerts raised by the alert rules
+type AlertRepository struct {
+	*GenericRepository[models.Alert] // Embedding the generic repository
+}
+
+// NewAlertRepository creates a new AlertRepository
+// db: database connection
+// returns: *AlertRepository
+func NewAlertRepository(db *gorm.DB) *AlertRepository {
+	return &AlertRepository{
+		GenericRepository: NewRepository[models.Alert](db),
+	}
+}
+
+// Add adds the alert with the copies of its readings
+// ctx: context
+// alert: alert to add, with the readings that raised it
+// returns: error
+func (r *AlertRepository) Add(ctx context.Context, alert *models.Alert) error {
+	return r.db.WithContext(ctx).Create(alert).Error
+}
+
+// Active returns the alerts of the route that are not resolved yet, without their readings
+// ctx: context
+// routeID: id of the route
+// returns: []models.Alert ordered by id, error
+func (r *AlertRepository) Active(ctx context.Context, routeID uint) ([]models.Alert, error) {
+	var alerts []models.Alert
+
+	err := r.db.WithContext(ctx).
+		Omit("readings").
+		Where("route_id = ? AND status <> ?", routeID, models.AlertStatusResolved).
+		Order("id").
+		Find(&alerts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+// Filter returns the alerts selected by the query, without their readings
+// ctx: context
+// query: company of the alerts and the filters to apply
+// returns: []models.Alert newest first, error
+func (r *AlertRepository) Filter(ctx context.Context, query models.AlertQuery) ([]models.Alert, error) {
+	var alerts []models.Alert
+
+	tx := r.db.WithContext(ctx).Omit("readings").Where("company_id = ?", query.CompanyID)
+	if query.RouteID != 0 {
+		tx = tx.Where("route_id = ?", query.RouteID)
+	}
+	if query.WaypointID != 0 {
+		tx = tx.Where("waypoint_id = ?", query.WaypointID)
+	}
+	if query.Status != "" {
+		tx = tx.Where("status = ?", query.Status)
+	}
+	if query.Severity != "" {
+		tx = tx.Where("severity = ?", query.Severity)
+	}
+	if !query.From.IsZero() {
+		tx = tx.Where("opened_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("opened_at < ?", query.To)
+	}
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+
+	if err := tx.Order("opened_at DESC, id DESC").Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+// Touch records that the conditions of the alerts still hold
+// ctx: context
+// ids: ids of the alerts
+// at: time the conditions were found to hold
+// returns: error
+func (r *AlertRepository) Touch(ctx context.Context, ids []uint, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).
+		Model(&models.Alert{}).
+		Where("id IN ?", ids).
+		Update("last_triggered_at", at).Error
+}
+
+// UpdateStatus stores the status of the alert and the acknowledgement and resolution that come with it,
+// provided the stored alert is still in one of the given statuses
+// Checking the status in the same statement keeps a user and the evaluation of the rules from both changing it.
+// ctx: context
+// alert: alert with its new status
+// from: statuses the alert may change from
+// returns: false if the alert is no longer in one of the statuses, error
+func (r *AlertRepository) UpdateStatus(ctx context.Context, alert *models.Alert, from ...string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(alert).
+		Where("status IN ?", from).
+		Select(
+			"status",
+			"acknowledged_at", "acknowledged_by_id", "acknowledgement_comment",
+			"resolved_at", "resolved_by_id", "resolution_comment",
+		).
+		Updates(alert)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	return true, alert.LoadRelations(r.db.WithContext(ctx)).First(alert).Error
+}
@@ -0,0 +1,107 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AlertRuleRepository is a repository for the alert rules of the companies
+type AlertRuleRepository struct {
+	*GenericRepository[models.AlertRule] // Embedding the generic repository
+}
+
+// NewAlertRuleRepository creates a new AlertRuleRepository
+// db: database connection
+// returns: *AlertRuleRepository
+func NewAlertRuleRepository(db *gorm.DB) *AlertRuleRepository {
+	return &AlertRuleRepository{
+		GenericRepository: NewRepository[models.AlertRule](db),
+	}
+}
+
+// ByCompany returns the rules of the company with their conditions
+// ctx: context
+// companyID: id of the company
+// returns: []models.AlertRule ordered by id, error
+func (r *AlertRuleRepository) ByCompany(ctx context.Context, companyID uint) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+
+	err := (&models.AlertRule{}).LoadRelations(r.db.WithContext(ctx)).
+		Where("company_id = ?", companyID).
+		Order("id").
+		Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Update replaces every field of the rule and its conditions
+// Unlike the generic update, zero values like a disabled rule or a threshold of 0 are stored too.
+// ctx: context
+// rule: rule to update, with the conditions it should have
+// returns: error
+func (r *AlertRuleRepository) Update(ctx context.Context, rule *models.AlertRule) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(rule).
+			Select("type", "message", "severity", "logic", "duration", "enabled", "updated_at").
+			Updates(rule).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("rule_id = ?", rule.ID).Delete(&models.AlertCondition{}).Error; err != nil {
+			return err
+		}
+		if len(rule.Conditions) == 0 {
+			return nil
+		}
+
+		for i := range rule.Conditions {
+			rule.Conditions[i].ID = 0
+			rule.Conditions[i].RuleID = rule.ID
+		}
+		return tx.Create(&rule.Conditions).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return rule.LoadRelations(r.db.WithContext(ctx)).First(rule).Error
+}
+
+// AddDefaults adds the rules to the company unless it already has rules
+// The company is locked meanwhile, so concurrent calls seed the rules only once.
+// ctx: context
+// companyID: id of the company
+// rules: rules to add, with their conditions
+// returns: error
+func (r *AlertRuleRepository) AddDefaults(ctx context.Context, companyID uint, rules []models.AlertRule) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var company models.Company
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", companyID).
+			First(&company).Error
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.AlertRule{}).Where("company_id = ?", companyID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 || len(rules) == 0 {
+			return nil
+		}
+
+		for i := range rules {
+			rules[i].CompanyID = companyID
+		}
+		return tx.Create(&rules).Error
+	})
+}
@@ -0,0 +1,60 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+)
+
+// CalibrationRepository is a repository for the calibration profiles of the devices and the waypoints
+type CalibrationRepository struct {
+	*GenericRepository[models.CalibrationProfile] // Embedding the generic repository
+}
+
+// NewCalibrationRepository creates a new CalibrationRepository
+// db: database connection
+// returns: *CalibrationRepository
+func NewCalibrationRepository(db *gorm.DB) *CalibrationRepository {
+	return &CalibrationRepository{
+		GenericRepository: NewRepository[models.CalibrationProfile](db),
+	}
+}
+
+// ForScopes returns the profiles of the devices and the waypoints valid from the given time or earlier,
+// with their tables
+// ctx: context
+// deviceIDs: ids of the devices
+// waypointIDs: ids of the waypoints
+// until: profiles valid from a later time are left out
+// returns: []models.CalibrationProfile ordered by valid_from, error
+func (r *CalibrationRepository) ForScopes(
+	ctx context.Context,
+	deviceIDs, waypointIDs []uint,
+	until time.Time,
+) ([]models.CalibrationProfile, error) {
+	var profiles []models.CalibrationProfile
+	if len(deviceIDs) == 0 && len(waypointIDs) == 0 {
+		return profiles, nil
+	}
+
+	query := r.db.WithContext(ctx).Where("valid_from <= ?", until)
+	switch {
+	case len(deviceIDs) == 0:
+		query = query.Where("waypoint_id IN ?", waypointIDs)
+	case len(waypointIDs) == 0:
+		query = query.Where("device_id IN ?", deviceIDs)
+	default:
+		query = query.Where("device_id IN ? OR waypoint_id IN ?", deviceIDs, waypointIDs)
+	}
+
+	err := (&models.CalibrationProfile{}).LoadRelations(query).
+		Order("valid_from, id").
+		Find(&profiles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
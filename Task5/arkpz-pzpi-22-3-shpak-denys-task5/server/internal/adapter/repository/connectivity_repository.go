@@ -0,0 +1,123 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConnectivityRepository is a repository for the last-seen tracking of the devices of the waypoints
+type ConnectivityRepository struct {
+	db *gorm.DB // db is the database connection
+}
+
+// NewConnectivityRepository creates a new ConnectivityRepository
+// db: database connection
+// returns: *ConnectivityRepository
+func NewConnectivityRepository(db *gorm.DB) *ConnectivityRepository {
+	return &ConnectivityRepository{db: db}
+}
+
+// TouchLastSeen marks the devices of the waypoints as online and seen at the given time
+// The devices installed at the waypoints are updated as well.
+// ctx: context
+// waypointIDs: ids of the waypoints
+// at: time the devices were seen
+// returns: the waypoints whose device was offline, with their route and previous last-seen time, error
+func (r *ConnectivityRepository) TouchLastSeen(
+	ctx context.Context,
+	waypointIDs []uint,
+	at time.Time,
+) ([]models.Waypoint, error) {
+	if len(waypointIDs) == 0 {
+		return nil, nil
+	}
+
+	var backOnline []models.Waypoint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The rows are locked, so a concurrent report of the same device does not see it offline too
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "route_id", "device_serial", "connectivity", "last_seen_at").
+			Where("id IN ? AND connectivity = ?", waypointIDs, models.ConnectivityOffline).
+			Find(&backOnline).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Waypoint{}).
+			Where("id IN ?", waypointIDs).
+			Updates(map[string]interface{}{
+				"connectivity": models.ConnectivityOnline,
+				"last_seen_at": at,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Device{}).
+			Where("waypoint_id IN ?", waypointIDs).
+			Update("last_seen_at", at).Error
+	})
+	if err != nil || len(backOnline) == 0 {
+		return nil, err
+	}
+
+	routeIDs := make([]uint, 0, len(backOnline))
+	for _, waypoint := range backOnline {
+		routeIDs = append(routeIDs, waypoint.RouteID)
+	}
+
+	var routes []models.Route
+	if err := r.db.WithContext(ctx).Where("id IN ?", routeIDs).Find(&routes).Error; err != nil {
+		return nil, err
+	}
+
+	routesByID := make(map[uint]models.Route, len(routes))
+	for _, route := range routes {
+		routesByID[route.ID] = route
+	}
+	for i := range backOnline {
+		backOnline[i].Route = routesByID[backOnline[i].RouteID]
+	}
+
+	return backOnline, nil
+}
+
+// OnlineWaypoints returns the waypoints whose device is online, with their route
+// Only the columns needed to check the connectivity are loaded.
+// ctx: context
+// returns: []models.Waypoint, error
+func (r *ConnectivityRepository) OnlineWaypoints(ctx context.Context) ([]models.Waypoint, error) {
+	var waypoints []models.Waypoint
+
+	err := r.db.WithContext(ctx).
+		Select("id", "route_id", "device_serial", "connectivity", "last_seen_at").
+		Preload("Route").
+		Where("connectivity = ?", models.ConnectivityOnline).
+		Find(&waypoints).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return waypoints, nil
+}
+
+// MarkOffline marks the device of the waypoint as offline if it has not been seen since the given time
+// ctx: context
+// waypointID: id of the waypoint
+// seenBefore: the device is offline when it was last seen before this time
+// returns: true if the waypoint was marked offline, error
+func (r *ConnectivityRepository) MarkOffline(ctx context.Context, waypointID uint, seenBefore time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Waypoint{}).
+		Where("id = ? AND connectivity = ? AND last_seen_at < ?", waypointID, models.ConnectivityOnline, seenBefore).
+		Update("connectivity", models.ConnectivityOffline)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -2,6 +2,8 @@
 package repository // import "wayra/internal/adapter/repository"
 
 import (
+	"fmt"
+	"strings"
 	"wayra/internal/core/domain/models"
 
 	"gorm.io/driver/postgres"
@@ -9,16 +11,27 @@ import (
 )
 
 // NewGORMDB creates a new GORM database connection
+// The violations of the unique indexes are translated to gorm.ErrDuplicatedKey.
 // connectionString: connection string to the database
 // returns: *gorm.DB, error
 func NewGORMDB(connectionString string) (*gorm.DB, error) {
-	return gorm.Open(postgres.Open(connectionString), &gorm.Config{})
+	return gorm.Open(postgres.Open(connectionString), &gorm.Config{TranslateError: true})
 }
 
 // AutoMigrate runs the auto migration for the models
 // db: database connection
 // returns: error
 func AutoMigrate(db *gorm.DB) error {
+	if err := migrateSensorDataDatesToUTC(db); err != nil {
+		return err
+	}
+	if err := migrateWaypointDeviceSerialsToUnique(db); err != nil {
+		return err
+	}
+	if err := migrateSensorDataDatesToUnique(db); err != nil {
+		return err
+	}
+
 	return db.AutoMigrate(
 		&models.Company{},
 		&models.Delivery{},
@@ -30,5 +43,104 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.UserCompany{},
 		&models.Product{},
 		&models.ProductCategory{},
+		&models.DeviceConfig{},
+		&models.WaypointStatusEvent{},
+		&models.RouteConditionEvent{},
+		&models.DeviceCredential{},
+		&models.Device{},
+		&models.DeviceAssignment{},
+		&models.DeviceClock{},
+		&models.HourlySensorData{},
+		&models.DailySensorData{},
+		&models.CalibrationProfile{},
+		&models.CalibrationPoint{},
+		&models.AlertRule{},
+		&models.AlertCondition{},
+		&models.Alert{},
+		&models.NotificationChannel{},
+		&models.NotificationDelivery{},
 	)
 }
+
+// migrateSensorDataDatesToUTC turns the date of the sensor data into a timezone-aware column
+// The stored dates are UTC, so they are converted explicitly instead of in the time zone of the session,
+// as AutoMigrate would do.
+// db: database connection
+// returns: error
+func migrateSensorDataDatesToUTC(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.SensorData{}) {
+		return nil
+	}
+
+	columnTypes, err := db.Migrator().ColumnTypes(&models.SensorData{})
+	if err != nil {
+		return err
+	}
+
+	for _, columnType := range columnTypes {
+		if columnType.Name() == "date" && strings.EqualFold(columnType.DatabaseTypeName(), "timestamp") {
+			return db.Exec(`ALTER TABLE sensor_data ALTER COLUMN date TYPE timestamptz USING date AT TIME ZONE 'UTC'`).Error
+		}
+	}
+
+	return nil
+}
+
+// migrateWaypointDeviceSerialsToUnique checks that no device serial is shared by waypoints,
+// so AutoMigrate can add the unique index on them
+// Waypoints sharing a serial have to be given distinct serials first, otherwise the unique index can not be built.
+// The plain index on the serials, if any, is dropped, the unique one replaces it.
+// db: database connection
+// returns: an error naming the shared serials, error
+func migrateWaypointDeviceSerialsToUnique(db *gorm.DB) error {
+	const plainIndex = "idx_waypoints_device_serial"
+	if !db.Migrator().HasTable(&models.Waypoint{}) ||
+		db.Migrator().HasIndex(&models.Waypoint{}, "idx_waypoints_device_serial_unique") {
+		return nil
+	}
+
+	var shared []string
+	err := db.Model(&models.Waypoint{}).
+		Where("device_serial <> ''").
+		Group("device_serial").
+		Having("COUNT(*) > 1").
+		Pluck("device_serial", &shared).Error
+	if err != nil {
+		return err
+	}
+	if len(shared) > 0 {
+		return fmt.Errorf("device serials %s are bound to more than one waypoint", strings.Join(shared, ", "))
+	}
+
+	if !db.Migrator().HasIndex(&models.Waypoint{}, plainIndex) {
+		return nil
+	}
+	return db.Migrator().DropIndex(&models.Waypoint{}, plainIndex)
+}
+
+// migrateSensorDataDatesToUnique keeps only the first stored reading of every waypoint and date,
+// so AutoMigrate can add the unique index on them
+// The plain index on them, if any, is dropped, the unique one replaces it.
+// db: database connection
+// returns: error
+func migrateSensorDataDatesToUnique(db *gorm.DB) error {
+	const plainIndex = "idx_sensor_data_waypoint_date"
+	if !db.Migrator().HasTable(&models.SensorData{}) ||
+		db.Migrator().HasIndex(&models.SensorData{}, "idx_sensor_data_waypoint_date_unique") {
+		return nil
+	}
+
+	err := db.Exec(`DELETE FROM sensor_data AS later
+		USING sensor_data AS first
+		WHERE later.waypoint_id = first.waypoint_id
+		AND later.date = first.date
+		AND later.id > first.id`).Error
+	if err != nil {
+		return err
+	}
+
+	if !db.Migrator().HasIndex(&models.SensorData{}, plainIndex) {
+		return nil
+	}
+	return db.Migrator().DropIndex(&models.SensorData{}, plainIndex)
+}
@@ -0,0 +1,109 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"fmt"
+	"strings"
+	"wayra/internal/core/domain/models"
+	utilsMeteo "wayra/internal/core/domain/utils/meteo"
+)
+
+// derivedMetric is a metric computed from the measured metrics of a reading
+type derivedMetric struct {
+	column     string // prefix of its _min, _max and _avg columns
+	expression string // SQL expression computing it from a row of sensor_data
+}
+
+// derivedMetrics are the metrics of the meteo package aggregated by the bucket queries and the rollups,
+// their expressions compute the same values as its functions
+var derivedMetrics = []derivedMetric{
+	{"dew_point", dewPointExpression("sensor_data.temperature", "sensor_data.humidity")},
+	{"heat_index", heatIndexExpression("sensor_data.temperature", "sensor_data.humidity")},
+	{"wind_chill", windChillExpression("sensor_data.temperature", "sensor_data.wind_speed")},
+}
+
+// dewPointExpression builds the SQL expression of meteo.DewPoint
+// temperature: SQL expression of the temperature
+// humidity: SQL expression of the relative humidity
+// returns: the expression
+func dewPointExpression(temperature, humidity string) string {
+	gamma := fmt.Sprintf("(ln(GREATEST(LEAST(%[2]s, 100), %[3]v) / 100) + %[4]v * %[1]s / (%[5]v + %[1]s))",
+		temperature, humidity, utilsMeteo.MinHumidity, utilsMeteo.MagnusA, utilsMeteo.MagnusB)
+
+	return fmt.Sprintf("(%[2]v * %[1]s / (%[3]v - %[1]s))", gamma, utilsMeteo.MagnusB, utilsMeteo.MagnusA)
+}
+
+// heatIndexExpression builds the SQL expression of meteo.HeatIndex
+// temperature: SQL expression of the temperature
+// humidity: SQL expression of the relative humidity
+// returns: the expression
+func heatIndexExpression(temperature, humidity string) string {
+	t := fmt.Sprintf("(%s * 1.8 + 32)", temperature)
+	rh := humidity
+
+	fahrenheit := fmt.Sprintf("(-42.379 + 2.04901523 * %[1]s + 10.14333127 * %[2]s"+
+		" - 0.22475541 * %[1]s * %[2]s - 0.00683783 * %[1]s * %[1]s - 0.05481717 * %[2]s * %[2]s"+
+		" + 0.00122874 * %[1]s * %[1]s * %[2]s + 0.00085282 * %[1]s * %[2]s * %[2]s"+
+		" - 0.00000199 * %[1]s * %[1]s * %[2]s * %[2]s)", t, rh)
+
+	return fmt.Sprintf("(CASE WHEN %[1]s < %[2]v THEN %[1]s ELSE (%[3]s - 32) / 1.8 END)",
+		temperature, utilsMeteo.HeatIndexMin, fahrenheit)
+}
+
+// windChillExpression builds the SQL expression of meteo.WindChill
+// temperature: SQL expression of the temperature
+// windSpeed: SQL expression of the wind speed
+// returns: the expression
+func windChillExpression(temperature, windSpeed string) string {
+	kmh := fmt.Sprintf("(%s * 3.6)", windSpeed)
+
+	return fmt.Sprintf("(CASE WHEN %[1]s > %[3]v OR %[2]s <= %[4]v THEN %[1]s"+
+		" ELSE 13.12 + 0.6215 * %[1]s - 11.37 * power(%[2]s, 0.16) + 0.3965 * %[1]s * power(%[2]s, 0.16) END)",
+		temperature, kmh, utilsMeteo.WindChillMaxTemp, utilsMeteo.WindChillMinWindSpeed)
+}
+
+// derivedBucketAggregates builds the aggregates of the derived metrics computed over raw readings,
+// named like the columns of a bucketRow
+// returns: the aggregates, to be appended to bucketAggregates
+func derivedBucketAggregates() string {
+	aggregates := make([]string, 0, 3*len(derivedMetrics))
+	for _, metric := range derivedMetrics {
+		aggregates = append(aggregates,
+			fmt.Sprintf("MIN(%s) AS %s_min", metric.expression, metric.column),
+			fmt.Sprintf("MAX(%s) AS %s_max", metric.expression, metric.column),
+			fmt.Sprintf("AVG(%s) AS %s_avg", metric.expression, metric.column),
+		)
+	}
+
+	return strings.Join(aggregates, ",\n\t")
+}
+
+// derivedSeriesAggregates builds the aggregates of the derived metrics computed over the rows of sensorDataSeries,
+// named like the columns of a bucketRow
+// Rollups created before the derived metrics were added hold no values for them and are left out.
+// returns: the aggregates, to be appended to seriesAggregates
+func derivedSeriesAggregates() string {
+	aggregates := make([]string, 0, 3*len(derivedMetrics))
+	for _, metric := range derivedMetrics {
+		aggregates = append(aggregates,
+			fmt.Sprintf("MIN(sensor_data.%[1]s_min) AS %[1]s_min", metric.column),
+			fmt.Sprintf("MAX(sensor_data.%[1]s_max) AS %[1]s_max", metric.column),
+			fmt.Sprintf("SUM(sensor_data.%[1]s_avg * sensor_data.count) / "+
+				"SUM(sensor_data.count) FILTER (WHERE sensor_data.%[1]s_avg IS NOT NULL) AS %[1]s_avg", metric.column),
+		)
+	}
+
+	return strings.Join(aggregates, ",\n\t")
+}
+
+// derivedAggregate converts the aggregates of a derived metric into a models.MetricAggregate
+// min: lowest value, nil when the bucket holds no value of the metric
+// max: highest value
+// avg: arithmetic mean of the values
+// returns: *models.MetricAggregate, nil when the bucket holds no value of the metric
+func derivedAggregate(min, max, avg *float64) *models.MetricAggregate {
+	if min == nil || max == nil || avg == nil {
+		return nil
+	}
+
+	return &models.MetricAggregate{Min: *min, Max: *max, Avg: *avg}
+}
@@ -0,0 +1,56 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeviceClockRepository is a repository for the clock skew of the devices of the waypoints
+type DeviceClockRepository struct {
+	db *gorm.DB // db is the database connection
+}
+
+// NewDeviceClockRepository creates a new DeviceClockRepository
+// db: database connection
+// returns: *DeviceClockRepository
+func NewDeviceClockRepository(db *gorm.DB) *DeviceClockRepository {
+	return &DeviceClockRepository{db: db}
+}
+
+// ForWaypoints returns the clocks of the devices of the waypoints, waypoints without a clock are left out
+// ctx: context
+// waypointIDs: ids of the waypoints
+// returns: []models.DeviceClock, error
+func (r *DeviceClockRepository) ForWaypoints(ctx context.Context, waypointIDs []uint) ([]models.DeviceClock, error) {
+	var clocks []models.DeviceClock
+	if len(waypointIDs) == 0 {
+		return clocks, nil
+	}
+
+	if err := r.db.WithContext(ctx).Where("waypoint_id IN ?", waypointIDs).Find(&clocks).Error; err != nil {
+		return nil, err
+	}
+
+	return clocks, nil
+}
+
+// Save inserts the clocks or overwrites the stored ones, zero values included
+// ctx: context
+// clocks: clocks to save
+// returns: error
+func (r *DeviceClockRepository) Save(ctx context.Context, clocks []models.DeviceClock) error {
+	if len(clocks) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).
+		Omit(clause.Associations).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "waypoint_id"}},
+			UpdateAll: true,
+		}).
+		Create(&clocks).Error
+}
@@ -0,0 +1,126 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+)
+
+// DeviceRepository is a repository for the devices that keeps their assignments and the waypoints in sync
+type DeviceRepository struct {
+	*GenericRepository[models.Device] // Embedding the generic repository
+}
+
+// NewDeviceRepository creates a new DeviceRepository
+// db: database connection
+// returns: *DeviceRepository
+func NewDeviceRepository(db *gorm.DB) *DeviceRepository {
+	return &DeviceRepository{
+		GenericRepository: NewRepository[models.Device](db),
+	}
+}
+
+// Assign installs the device at the waypoint in a single transaction
+// The device leaves its previous waypoint, the device previously installed at the waypoint is removed from it,
+// and the waypoint takes the serial of the device so the device keeps resolving its waypoint by serial.
+// ctx: context
+// device: device to install, reloaded after the assignment
+// waypoint: waypoint to install the device at
+// assignedByID: ID of the user installing the device
+// at: time of the installation
+// returns: error
+func (r *DeviceRepository) Assign(
+	ctx context.Context,
+	device *models.Device,
+	waypoint models.Waypoint,
+	assignedByID uint,
+	at time.Time,
+) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.DeviceAssignment{}).
+			Where("unassigned_at IS NULL AND (device_id = ? OR waypoint_id = ?)", device.ID, waypoint.ID).
+			Update("unassigned_at", at).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Device{}).
+			Where("waypoint_id = ? AND id <> ?", waypoint.ID, device.ID).
+			Update("waypoint_id", nil).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Waypoint{}).
+			Where("device_serial = ? AND id <> ?", device.Serial, waypoint.ID).
+			Update("device_serial", "").Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&models.DeviceAssignment{
+			DeviceID:     device.ID,
+			WaypointID:   waypoint.ID,
+			AssignedAt:   at,
+			AssignedByID: &assignedByID,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Device{}).
+			Where("id = ?", device.ID).
+			Update("waypoint_id", waypoint.ID).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Waypoint{}).
+			Where("id = ?", waypoint.ID).
+			Update("device_serial", device.Serial).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	*device = models.Device{ID: device.ID}
+	return device.LoadRelations(r.db.WithContext(ctx)).First(device).Error
+}
+
+// Unassign removes the device from its waypoint in a single transaction
+// The waypoint loses the serial of the device, so the device no longer resolves it.
+// ctx: context
+// device: device to remove, reloaded after the removal
+// at: time of the removal
+// returns: error
+func (r *DeviceRepository) Unassign(ctx context.Context, device *models.Device, at time.Time) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.DeviceAssignment{}).
+			Where("unassigned_at IS NULL AND device_id = ?", device.ID).
+			Update("unassigned_at", at).Error
+		if err != nil {
+			return err
+		}
+
+		if device.WaypointID != nil {
+			err = tx.Model(&models.Waypoint{}).
+				Where("id = ? AND device_serial = ?", *device.WaypointID, device.Serial).
+				Update("device_serial", "").Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.Device{}).
+			Where("id = ?", device.ID).
+			Update("waypoint_id", nil).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	*device = models.Device{ID: device.ID}
+	return device.LoadRelations(r.db.WithContext(ctx)).First(device).Error
+}
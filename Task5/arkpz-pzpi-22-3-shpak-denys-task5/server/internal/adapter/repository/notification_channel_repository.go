@@ -0,0 +1,106 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+)
+
+// NotificationChannelRepository is a repository for the notification channels of the companies
+type NotificationChannelRepository struct {
+	*GenericRepository[models.NotificationChannel] // Embedding the generic repository
+}
+
+// NewNotificationChannelRepository creates a new NotificationChannelRepository
+// db: database connection
+// returns: *NotificationChannelRepository
+func NewNotificationChannelRepository(db *gorm.DB) *NotificationChannelRepository {
+	return &NotificationChannelRepository{
+		GenericRepository: NewRepository[models.NotificationChannel](db),
+	}
+}
+
+// Add adds the channel and links it to its routes
+// The routes are stored already, only the links are added.
+// ctx: context
+// channel: channel to add, with the routes whose alerts it receives
+// returns: error
+func (r *NotificationChannelRepository) Add(ctx context.Context, channel *models.NotificationChannel) error {
+	if err := r.db.WithContext(ctx).Omit("Routes.*").Create(channel).Error; err != nil {
+		return err
+	}
+
+	return channel.LoadRelations(r.db.WithContext(ctx)).First(channel).Error
+}
+
+// ByCompany returns the channels of the company with their routes
+// ctx: context
+// companyID: id of the company
+// returns: []models.NotificationChannel ordered by id, error
+func (r *NotificationChannelRepository) ByCompany(
+	ctx context.Context,
+	companyID uint,
+) ([]models.NotificationChannel, error) {
+	var channels []models.NotificationChannel
+
+	err := (&models.NotificationChannel{}).LoadRelations(r.db.WithContext(ctx)).
+		Where("company_id = ?", companyID).
+		Order("id").
+		Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return channels, nil
+}
+
+// Update replaces every field of the channel and its routes
+// Unlike the generic update, zero values like a disabled channel or an empty template are stored too.
+// ctx: context
+// channel: channel to update, with the routes it should have
+// returns: error
+func (r *NotificationChannelRepository) Update(ctx context.Context, channel *models.NotificationChannel) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(channel).
+			Select(
+				"name", "kind", "enabled", "url", "secret", "recipients", "min_severity", "all_routes",
+				"subject_template", "body_template", "updated_at",
+			).
+			Updates(channel).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(channel).Omit("Routes.*").Association("Routes").Replace(channel.Routes)
+	})
+	if err != nil {
+		return err
+	}
+
+	return channel.LoadRelations(r.db.WithContext(ctx)).First(channel).Error
+}
+
+// Deliveries returns the latest deliveries of the notifications sent to the channel
+// ctx: context
+// channelID: id of the channel
+// limit: maximum number of deliveries, 0 for all
+// returns: []models.NotificationDelivery newest first, error
+func (r *NotificationChannelRepository) Deliveries(
+	ctx context.Context,
+	channelID uint,
+	limit int,
+) ([]models.NotificationDelivery, error) {
+	var deliveries []models.NotificationDelivery
+
+	tx := r.db.WithContext(ctx).Where("channel_id = ?", channelID)
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+
+	if err := tx.Order("created_at DESC, id DESC").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,631 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// insertBatchSize is the number of readings sent to the database in one INSERT statement
+const insertBatchSize = 500
+
+// SensorDataRepository is a repository for the sensor data that runs the aggregating queries in the database
+type SensorDataRepository struct {
+	*GenericRepository[models.SensorData] // Embedding the generic repository
+}
+
+// NewSensorDataRepository creates a new SensorDataRepository
+// db: database connection
+// returns: *SensorDataRepository
+func NewSensorDataRepository(db *gorm.DB) *SensorDataRepository {
+	return &SensorDataRepository{
+		GenericRepository: NewRepository[models.SensorData](db),
+	}
+}
+
+// LatestByRoute returns the latest readings of every waypoint of the route
+// ctx: context
+// routeID: id of the route
+// limit: number of readings to return per waypoint
+// includeFlagged: whether the suspect readings are returned too
+// returns: []models.SensorData ordered by waypoint and newest first, error
+func (r *SensorDataRepository) LatestByRoute(
+	ctx context.Context,
+	routeID uint,
+	limit int,
+	includeFlagged bool,
+) ([]models.SensorData, error) {
+	var readings []models.SensorData
+
+	err := r.db.WithContext(ctx).
+		Table("(?) AS latest", r.latestByRouteQuery(ctx, routeID, includeFlagged)).
+		Where("latest.rn <= ?", limit).
+		Order("latest.waypoint_id, latest.date DESC, latest.id DESC").
+		Find(&readings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return readings, nil
+}
+
+// statisticsRow is a row of the StatisticsByRoute query
+type statisticsRow struct {
+	WaypointID        *uint
+	Count             int
+	TemperatureMean   float64
+	TemperatureStdDev float64
+	HumidityMean      float64
+	HumidityStdDev    float64
+	WindSpeedMean     float64
+	WindSpeedStdDev   float64
+	PressureMean      float64
+	PressureStdDev    float64
+}
+
+// StatisticsByRoute returns the mean and standard deviation of the latest readings
+// of every waypoint of the route, followed by the same statistics for the whole route
+// ctx: context
+// routeID: id of the route
+// limit: number of readings to take per waypoint
+// includeFlagged: whether the suspect readings are taken too
+// returns: []models.SensorDataStatistics, error
+func (r *SensorDataRepository) StatisticsByRoute(
+	ctx context.Context,
+	routeID uint,
+	limit int,
+	includeFlagged bool,
+) ([]models.SensorDataStatistics, error) {
+	var rows []statisticsRow
+
+	err := r.db.WithContext(ctx).
+		Table("(?) AS latest", r.latestByRouteQuery(ctx, routeID, includeFlagged)).
+		Select(`latest.waypoint_id AS waypoint_id,
+			COUNT(*) AS count,
+			COALESCE(AVG(latest.temperature), 0) AS temperature_mean,
+			COALESCE(STDDEV_POP(latest.temperature), 0) AS temperature_std_dev,
+			COALESCE(AVG(latest.humidity), 0) AS humidity_mean,
+			COALESCE(STDDEV_POP(latest.humidity), 0) AS humidity_std_dev,
+			COALESCE(AVG(latest.wind_speed), 0) AS wind_speed_mean,
+			COALESCE(STDDEV_POP(latest.wind_speed), 0) AS wind_speed_std_dev,
+			COALESCE(AVG(latest.mean_pressure), 0) AS pressure_mean,
+			COALESCE(STDDEV_POP(latest.mean_pressure), 0) AS pressure_std_dev`).
+		Where("latest.rn <= ?", limit).
+		Group("GROUPING SETS ((latest.waypoint_id), ())").
+		Order("latest.waypoint_id NULLS LAST").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	statistics := make([]models.SensorDataStatistics, 0, len(rows))
+	for _, row := range rows {
+		statistics = append(statistics, models.SensorDataStatistics{
+			WaypointID:   row.WaypointID,
+			Count:        row.Count,
+			Temperature:  models.MetricStatistics{Mean: row.TemperatureMean, StdDev: row.TemperatureStdDev},
+			Humidity:     models.MetricStatistics{Mean: row.HumidityMean, StdDev: row.HumidityStdDev},
+			WindSpeed:    models.MetricStatistics{Mean: row.WindSpeedMean, StdDev: row.WindSpeedStdDev},
+			MeanPressure: models.MetricStatistics{Mean: row.PressureMean, StdDev: row.PressureStdDev},
+		})
+	}
+
+	return statistics, nil
+}
+
+// BetweenByRoute returns the readings of every waypoint of the route recorded in the time range
+// ctx: context
+// routeID: id of the route
+// from: start of the range, inclusive
+// to: end of the range, inclusive
+// includeFlagged: whether the suspect readings are returned too
+// returns: []models.SensorData ordered by waypoint and date, error
+func (r *SensorDataRepository) BetweenByRoute(
+	ctx context.Context,
+	routeID uint,
+	from, to time.Time,
+	includeFlagged bool,
+) ([]models.SensorData, error) {
+	var readings []models.SensorData
+
+	err := withoutSuspect(r.db.WithContext(ctx), includeFlagged).
+		Select("sensor_data.*").
+		Joins("JOIN waypoints ON waypoints.id = sensor_data.waypoint_id").
+		Where("waypoints.route_id = ? AND sensor_data.date BETWEEN ? AND ?", routeID, from, to).
+		Order("sensor_data.waypoint_id, sensor_data.date, sensor_data.id").
+		Find(&readings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return readings, nil
+}
+
+// bucketRow is a row of the bucket queries
+type bucketRow struct {
+	WaypointID     *uint
+	BucketStart    time.Time
+	Count          int
+	TemperatureMin float64
+	TemperatureMax float64
+	TemperatureAvg float64
+	HumidityMin    float64
+	HumidityMax    float64
+	HumidityAvg    float64
+	WindSpeedMin   float64
+	WindSpeedMax   float64
+	WindSpeedAvg   float64
+	PressureMin    float64
+	PressureMax    float64
+	PressureAvg    float64
+	DewPointMin    *float64
+	DewPointMax    *float64
+	DewPointAvg    *float64
+	HeatIndexMin   *float64
+	HeatIndexMax   *float64
+	HeatIndexAvg   *float64
+	WindChillMin   *float64
+	WindChillMax   *float64
+	WindChillAvg   *float64
+}
+
+// bucketAggregates are the aggregates computed over raw readings for every bucket
+var bucketAggregates = `COUNT(*) AS count,
+	MIN(sensor_data.temperature) AS temperature_min,
+	MAX(sensor_data.temperature) AS temperature_max,
+	AVG(sensor_data.temperature) AS temperature_avg,
+	MIN(sensor_data.humidity) AS humidity_min,
+	MAX(sensor_data.humidity) AS humidity_max,
+	AVG(sensor_data.humidity) AS humidity_avg,
+	MIN(sensor_data.wind_speed) AS wind_speed_min,
+	MAX(sensor_data.wind_speed) AS wind_speed_max,
+	AVG(sensor_data.wind_speed) AS wind_speed_avg,
+	MIN(sensor_data.mean_pressure) AS pressure_min,
+	MAX(sensor_data.mean_pressure) AS pressure_max,
+	AVG(sensor_data.mean_pressure) AS pressure_avg,
+	` + derivedBucketAggregates()
+
+// BucketsByWaypoint returns the aggregates of the readings of the waypoint grouped in time buckets
+// Readings that were already purged are read from the rollups.
+// ctx: context
+// waypointID: id of the waypoint
+// query: time range and size of the buckets
+// returns: []models.SensorDataBucket ordered by time, empty buckets are left out, error
+func (r *SensorDataRepository) BucketsByWaypoint(
+	ctx context.Context,
+	waypointID uint,
+	query models.BucketQuery,
+) ([]models.SensorDataBucket, error) {
+	var rows []bucketRow
+
+	err := r.db.WithContext(ctx).
+		Table("(?) AS sensor_data", sensorDataSeries(r.db, query.From, query.To, query.IncludeFlagged)).
+		Select("sensor_data.waypoint_id AS waypoint_id, "+bucketStartExpression+" AS bucket_start, "+seriesAggregates,
+			query.Bucket.Seconds(), query.Bucket.Seconds()).
+		Where("sensor_data.waypoint_id = ?", waypointID).
+		Group("sensor_data.waypoint_id, bucket_start").
+		Order("bucket_start").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return bucketsOf(rows), nil
+}
+
+// BucketsByRoute returns the aggregates of the readings of the route grouped in time buckets
+// Readings that were already purged are read from the rollups.
+// ctx: context
+// routeID: id of the route
+// query: time range and size of the buckets
+// perWaypoint: whether every waypoint gets its own buckets instead of aggregating the whole route
+// returns: []models.SensorDataBucket ordered by waypoint and time, empty buckets are left out, error
+func (r *SensorDataRepository) BucketsByRoute(
+	ctx context.Context,
+	routeID uint,
+	query models.BucketQuery,
+	perWaypoint bool,
+) ([]models.SensorDataBucket, error) {
+	var rows []bucketRow
+
+	columns, group, order := "", "bucket_start", "bucket_start"
+	if perWaypoint {
+		columns = "sensor_data.waypoint_id AS waypoint_id, "
+		group = "sensor_data.waypoint_id, bucket_start"
+		order = "sensor_data.waypoint_id, bucket_start"
+	}
+
+	err := r.db.WithContext(ctx).
+		Table("(?) AS sensor_data", sensorDataSeries(r.db, query.From, query.To, query.IncludeFlagged)).
+		Select(columns+bucketStartExpression+" AS bucket_start, "+seriesAggregates,
+			query.Bucket.Seconds(), query.Bucket.Seconds()).
+		Joins("JOIN waypoints ON waypoints.id = sensor_data.waypoint_id").
+		Where("waypoints.route_id = ?", routeID).
+		Group(group).
+		Order(order).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return bucketsOf(rows), nil
+}
+
+// bucketStartExpression truncates the date of a reading to the start of its bucket,
+// buckets are aligned to the Unix epoch so daily buckets start at midnight UTC
+// The bucket size in seconds is bound twice.
+const bucketStartExpression = "to_timestamp(floor(extract(epoch FROM sensor_data.date) / ?) * ?)"
+
+// bucketsOf converts the rows of a bucket query into buckets
+// rows: rows of the query
+// returns: []models.SensorDataBucket
+func bucketsOf(rows []bucketRow) []models.SensorDataBucket {
+	buckets := make([]models.SensorDataBucket, 0, len(rows))
+	for _, row := range rows {
+		buckets = append(buckets, models.SensorDataBucket{
+			WaypointID: row.WaypointID,
+			Start:      row.BucketStart.UTC(),
+			Count:      row.Count,
+			Temperature: models.MetricAggregate{
+				Min: row.TemperatureMin, Max: row.TemperatureMax, Avg: row.TemperatureAvg,
+			},
+			Humidity: models.MetricAggregate{
+				Min: row.HumidityMin, Max: row.HumidityMax, Avg: row.HumidityAvg,
+			},
+			WindSpeed: models.MetricAggregate{
+				Min: row.WindSpeedMin, Max: row.WindSpeedMax, Avg: row.WindSpeedAvg,
+			},
+			MeanPressure: models.MetricAggregate{
+				Min: row.PressureMin, Max: row.PressureMax, Avg: row.PressureAvg,
+			},
+			DewPoint:  derivedAggregate(row.DewPointMin, row.DewPointMax, row.DewPointAvg),
+			HeatIndex: derivedAggregate(row.HeatIndexMin, row.HeatIndexMax, row.HeatIndexAvg),
+			WindChill: derivedAggregate(row.WindChillMin, row.WindChillMax, row.WindChillAvg),
+		})
+	}
+
+	return buckets
+}
+
+// Export streams the readings selected by the query to fn, ordered by date
+// The rows are scanned one by one from the cursor of the query.
+// ctx: context
+// query: scope and time range of the readings
+// fn: function called with every reading, an error stops the export and is returned
+// returns: error
+func (r *SensorDataRepository) Export(
+	ctx context.Context,
+	query models.SensorDataExportQuery,
+	fn func(models.SensorDataExportRow) error,
+) error {
+	tx := r.db.WithContext(ctx).
+		Model(&models.SensorData{}).
+		Select(`sensor_data.id, sensor_data.date, waypoints.route_id, sensor_data.waypoint_id,
+			waypoints.name AS waypoint_name, waypoints.latitude, waypoints.longitude,
+			sensor_data.temperature, sensor_data.humidity, sensor_data.wind_speed, sensor_data.mean_pressure,
+			sensor_data.device_id, sensor_data.timestamp_flag, sensor_data.quality_flags`).
+		Joins("JOIN waypoints ON waypoints.id = sensor_data.waypoint_id").
+		Where("sensor_data.date >= ? AND sensor_data.date < ?", query.From, query.To)
+
+	switch {
+	case query.WaypointID != 0:
+		tx = tx.Where("sensor_data.waypoint_id = ?", query.WaypointID)
+	case query.RouteID != 0:
+		tx = tx.Where("waypoints.route_id = ?", query.RouteID)
+	default:
+		tx = tx.Joins("JOIN routes ON routes.id = waypoints.route_id").
+			Where("routes.company_id = ?", query.CompanyID)
+	}
+
+	rows, err := tx.Order("sensor_data.date, sensor_data.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.SensorDataExportRow
+		if err := r.db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		row.Date = row.Date.UTC()
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// FindExisting returns the stored readings that have the same waypoint and date as one of the given readings
+// Only the id, waypoint_id and date columns are loaded. The readings are looked up by chunks of insertBatchSize,
+// so an import of any size stays below the limit of bind parameters of a statement.
+// ctx: context
+// readings: readings to look up
+// returns: []models.SensorData, error
+func (r *SensorDataRepository) FindExisting(ctx context.Context, readings []models.SensorData) ([]models.SensorData, error) {
+	var existing []models.SensorData
+
+	for start := 0; start < len(readings); start += insertBatchSize {
+		end := min(start+insertBatchSize, len(readings))
+
+		keys := make([][]interface{}, 0, end-start)
+		for _, reading := range readings[start:end] {
+			keys = append(keys, []interface{}{reading.WaypointID, reading.Date})
+		}
+
+		var chunk []models.SensorData
+		err := r.db.WithContext(ctx).
+			Select("id", "waypoint_id", "date").
+			Where("(waypoint_id, date) IN ?", keys).
+			Find(&chunk).Error
+		if err != nil {
+			return nil, err
+		}
+
+		existing = append(existing, chunk...)
+	}
+
+	return existing, nil
+}
+
+// AddBatch inserts the readings with bulk INSERT statements in a single transaction
+// A reading whose waypoint already has one recorded at the same date is skipped by the database,
+// so concurrent sends of the same backlog never store it twice. The relations of the readings are not saved.
+// ctx: context
+// readings: readings to insert, the IDs of the inserted ones are filled in and the skipped ones keep 0
+// returns: error
+func (r *SensorDataRepository) AddBatch(ctx context.Context, readings []models.SensorData) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(readings); start += insertBatchSize {
+			if err := insertSkippingDuplicates(tx, readings[start:min(start+insertBatchSize, len(readings))]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// insertSkippingDuplicates inserts the readings with ON CONFLICT DO NOTHING in one INSERT statement
+// GORM fills the returned IDs in the order of the slice, which is wrong once the database skips a reading,
+// so the statement is only built by GORM and the returned rows are matched to the readings by waypoint and date.
+// tx: database transaction
+// readings: readings to insert, the IDs of the inserted ones are filled in
+// returns: error
+func insertSkippingDuplicates(tx *gorm.DB, readings []models.SensorData) error {
+	statement := tx.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true}).
+		Omit(clause.Associations).
+		Clauses(
+			clause.OnConflict{
+				Columns:   []clause.Column{{Name: "waypoint_id"}, {Name: "date"}},
+				DoNothing: true,
+			},
+			clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "waypoint_id"}, {Name: "date"}}},
+		).
+		Create(&readings).Statement
+
+	var inserted []models.SensorData
+	if err := tx.Raw(statement.SQL.String(), statement.Vars...).Scan(&inserted).Error; err != nil {
+		return err
+	}
+
+	type readingKey struct {
+		waypointID uint
+		date       time.Time
+	}
+	ids := make(map[readingKey]uint, len(inserted))
+	for _, reading := range inserted {
+		ids[readingKey{reading.WaypointID, reading.Date.UTC()}] = reading.ID
+	}
+	for i := range readings {
+		readings[i].ID = ids[readingKey{readings[i].WaypointID, readings[i].Date.UTC()}]
+	}
+
+	return nil
+}
+
+// QualityWindow returns the readings of the waypoint recorded in the time range, preceded and followed by
+// up to contextReadings readings recorded outside of it, so the data-quality pass sees their neighbours
+// ctx: context
+// waypointID: id of the waypoint
+// from: start of the range, inclusive
+// to: end of the range, inclusive
+// contextReadings: number of readings to add on every side of the range
+// returns: []models.SensorData ordered by date, error
+func (r *SensorDataRepository) QualityWindow(
+	ctx context.Context,
+	waypointID uint,
+	from, to time.Time,
+	contextReadings int,
+) ([]models.SensorData, error) {
+	var readings []models.SensorData
+
+	err := r.db.WithContext(ctx).
+		Raw(`(SELECT * FROM sensor_data
+				WHERE waypoint_id = @waypoint AND date < @from
+				ORDER BY date DESC, id DESC LIMIT @context)
+			UNION ALL
+			(SELECT * FROM sensor_data
+				WHERE waypoint_id = @waypoint AND date >= @from AND date <= @to)
+			UNION ALL
+			(SELECT * FROM sensor_data
+				WHERE waypoint_id = @waypoint AND date > @to
+				ORDER BY date, id LIMIT @context)
+			ORDER BY date, id`,
+			map[string]interface{}{"waypoint": waypointID, "from": from, "to": to, "context": contextReadings},
+		).
+		Scan(&readings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return readings, nil
+}
+
+// UpdateQualityFlags stores the data-quality flags of the readings in a single transaction
+// ctx: context
+// flags: flags of every reading to update, by reading ID
+// returns: error
+func (r *SensorDataRepository) UpdateQualityFlags(ctx context.Context, flags map[uint]models.QualityFlags) error {
+	if len(flags) == 0 {
+		return nil
+	}
+
+	idsByFlags := make(map[models.QualityFlags][]uint)
+	for id, flag := range flags {
+		idsByFlags[flag] = append(idsByFlags[flag], id)
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for flag, ids := range idsByFlags {
+			for start := 0; start < len(ids); start += insertBatchSize {
+				end := min(start+insertBatchSize, len(ids))
+
+				err := tx.Model(&models.SensorData{}).
+					Where("id IN ?", ids[start:end]).
+					Update("quality_flags", flag).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// AroundByWaypoints returns, for every waypoint, the latest reading at or before the time and the earliest reading after it
+// Readings flagged as suspect are left out.
+// ctx: context
+// waypointIDs: ids of the waypoints
+// at: point in time
+// window: how far from the time the readings are looked up
+// returns: []models.SensorData, error
+func (r *SensorDataRepository) AroundByWaypoints(
+	ctx context.Context,
+	waypointIDs []uint,
+	at time.Time,
+	window time.Duration,
+) ([]models.SensorData, error) {
+	var readings []models.SensorData
+	if len(waypointIDs) == 0 {
+		return readings, nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Raw(`(SELECT DISTINCT ON (waypoint_id) * FROM sensor_data
+				WHERE waypoint_id IN @waypoints AND date >= @from AND date <= @at AND quality_flags & @suspect = 0
+				ORDER BY waypoint_id, date DESC, id DESC)
+			UNION ALL
+			(SELECT DISTINCT ON (waypoint_id) * FROM sensor_data
+				WHERE waypoint_id IN @waypoints AND date > @at AND date <= @to AND quality_flags & @suspect = 0
+				ORDER BY waypoint_id, date, id)`,
+			map[string]interface{}{
+				"waypoints": waypointIDs,
+				"at":        at,
+				"from":      at.Add(-window),
+				"to":        at.Add(window),
+				"suspect":   int(models.QualityFlagsSuspect),
+			},
+		).
+		Scan(&readings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return readings, nil
+}
+
+// ForRecalibration returns a page of the readings selected by the recalibration query, by ascending id
+// ctx: context
+// query: device or waypoint and time range of the readings
+// afterID: only readings with a greater id are returned, 0 for the first page
+// limit: size of the page
+// returns: []models.SensorData, error
+func (r *SensorDataRepository) ForRecalibration(
+	ctx context.Context,
+	query models.RecalibrationQuery,
+	afterID uint,
+	limit int,
+) ([]models.SensorData, error) {
+	var readings []models.SensorData
+
+	db := r.db.WithContext(ctx).
+		Where("date >= ? AND date < ? AND id > ?", query.From, query.To, afterID)
+	if query.DeviceID != 0 {
+		db = db.Where("device_id = ?", query.DeviceID)
+	}
+	if query.WaypointID != 0 {
+		db = db.Where("waypoint_id = ?", query.WaypointID)
+	}
+
+	err := db.Order("id").Limit(limit).Find(&readings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return readings, nil
+}
+
+// UpdateMeasurements stores the calibrated and the raw measurements of the readings in a single transaction
+// ctx: context
+// readings: readings to update, identified by their ID
+// returns: error
+func (r *SensorDataRepository) UpdateMeasurements(ctx context.Context, readings []models.SensorData) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range readings {
+			err := tx.Model(&models.SensorData{ID: readings[i].ID}).
+				Select(
+					"temperature", "humidity", "wind_speed", "mean_pressure",
+					"raw_temperature", "raw_humidity", "raw_wind_speed", "raw_mean_pressure",
+				).
+				Updates(&readings[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// latestByRouteQuery builds the query that numbers the readings of every waypoint of the route, newest first
+// ctx: context
+// routeID: id of the route
+// includeFlagged: whether the suspect readings are numbered too
+// returns: *gorm.DB to be used as a subquery
+func (r *SensorDataRepository) latestByRouteQuery(ctx context.Context, routeID uint, includeFlagged bool) *gorm.DB {
+	return withoutSuspect(r.db.WithContext(ctx), includeFlagged).
+		Model(&models.SensorData{}).
+		Select(`sensor_data.*, ROW_NUMBER() OVER (
+			PARTITION BY sensor_data.waypoint_id
+			ORDER BY sensor_data.date DESC, sensor_data.id DESC
+		) AS rn`).
+		Joins("JOIN waypoints ON waypoints.id = sensor_data.waypoint_id").
+		Where("waypoints.route_id = ?", routeID)
+}
+
+// withoutSuspect leaves the readings flagged as suspect by the data-quality pass out of a query on sensor_data
+// db: query to filter
+// includeFlagged: whether the suspect readings are kept
+// returns: *gorm.DB
+func withoutSuspect(db *gorm.DB, includeFlagged bool) *gorm.DB {
+	if includeFlagged {
+		return db
+	}
+
+	return db.Where("sensor_data.quality_flags & ? = 0", int(models.QualityFlagsSuspect))
+}
@@ -0,0 +1,248 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+)
+
+// rollupMetrics are the measured metrics, every one of them has a _min, _max and _avg column in the rollup tables
+var rollupMetrics = []string{"temperature", "humidity", "wind_speed", "mean_pressure"}
+
+// SensorDataRollupRepository is a repository for the hourly and daily rollups of the sensor data
+type SensorDataRollupRepository struct {
+	db *gorm.DB // db is the database connection
+}
+
+// NewSensorDataRollupRepository creates a new SensorDataRollupRepository
+// db: database connection
+// returns: *SensorDataRollupRepository
+func NewSensorDataRollupRepository(db *gorm.DB) *SensorDataRollupRepository {
+	return &SensorDataRollupRepository{db: db}
+}
+
+// CompanyIDs returns the IDs of the companies that have routes, and so may have readings
+// ctx: context
+// returns: []uint, error
+func (r *SensorDataRollupRepository) CompanyIDs(ctx context.Context) ([]uint, error) {
+	var ids []uint
+
+	err := r.db.WithContext(ctx).
+		Model(&models.Route{}).
+		Distinct().
+		Order("company_id").
+		Pluck("company_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// RollUp moves the readings of the company recorded before the given time into the hourly and daily rollups
+// The readings are deleted and aggregated by a single statement, so a reading arriving meanwhile is either
+// rolled up or kept. Readings falling into an existing bucket are merged into it.
+// Suspect readings are purged without being rolled up.
+// ctx: context
+// companyID: id of the company
+// before: readings recorded before this time are rolled up
+// returns: number of readings rolled up, error
+func (r *SensorDataRollupRepository) RollUp(ctx context.Context, companyID uint, before time.Time) (int64, error) {
+	var rolledUp int64
+
+	hourly := models.HourlySensorData{}.TableName()
+	daily := models.DailySensorData{}.TableName()
+
+	query := `WITH purged AS (
+			DELETE FROM sensor_data USING waypoints, routes
+			WHERE waypoints.id = sensor_data.waypoint_id
+				AND routes.id = waypoints.route_id
+				AND routes.company_id = ?
+				AND sensor_data.date < ?
+			RETURNING sensor_data.*
+		), hourly AS (` + rollupInsert(hourly) + `
+		), daily AS (` + rollupInsert(daily) + `
+		)
+		SELECT COUNT(*) FROM purged`
+
+	err := r.db.WithContext(ctx).
+		Raw(query,
+			companyID, before,
+			models.HourlyRollupSize.Seconds(), models.HourlyRollupSize.Seconds(),
+			models.DailyRollupSize.Seconds(), models.DailyRollupSize.Seconds(),
+		).
+		Scan(&rolledUp).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return rolledUp, nil
+}
+
+// rollupInsert builds the statement that aggregates the purged readings into a rollup table
+// The bucket size in seconds is bound twice.
+// table: name of the rollup table
+// returns: the INSERT statement
+func rollupInsert(table string) string {
+	columns := []string{"waypoint_id", "bucket_start", "count"}
+	updates := []string{"count = existing.count + EXCLUDED.count"}
+	for _, metric := range rollupMetrics {
+		columns = append(columns, metric+"_min", metric+"_max", metric+"_avg")
+		updates = append(updates,
+			fmt.Sprintf("%[1]s_min = LEAST(existing.%[1]s_min, EXCLUDED.%[1]s_min)", metric),
+			fmt.Sprintf("%[1]s_max = GREATEST(existing.%[1]s_max, EXCLUDED.%[1]s_max)", metric),
+			fmt.Sprintf(
+				"%[1]s_avg = (existing.%[1]s_avg * existing.count + EXCLUDED.%[1]s_avg * EXCLUDED.count) / (existing.count + EXCLUDED.count)",
+				metric,
+			),
+		)
+	}
+	// Rollups created before the derived metrics were added hold no values for them and take the new ones as they are
+	for _, metric := range derivedMetrics {
+		columns = append(columns, metric.column+"_min", metric.column+"_max", metric.column+"_avg")
+		updates = append(updates,
+			fmt.Sprintf("%[1]s_min = LEAST(existing.%[1]s_min, EXCLUDED.%[1]s_min)", metric.column),
+			fmt.Sprintf("%[1]s_max = GREATEST(existing.%[1]s_max, EXCLUDED.%[1]s_max)", metric.column),
+			fmt.Sprintf(
+				"%[1]s_avg = COALESCE((existing.%[1]s_avg * existing.count + EXCLUDED.%[1]s_avg * EXCLUDED.count) / (existing.count + EXCLUDED.count), EXCLUDED.%[1]s_avg)",
+				metric.column,
+			),
+		)
+	}
+
+	return fmt.Sprintf(`INSERT INTO %s AS existing (%s)
+			SELECT sensor_data.waypoint_id, %s, %s
+			FROM purged AS sensor_data
+			WHERE sensor_data.quality_flags & %d = 0
+			GROUP BY sensor_data.waypoint_id, 2
+			ON CONFLICT (waypoint_id, bucket_start) DO UPDATE SET %s`,
+		table,
+		strings.Join(columns, ", "),
+		bucketStartExpression,
+		bucketAggregates,
+		models.QualityFlagsSuspect,
+		strings.Join(updates, ", "),
+	)
+}
+
+// PurgeHourly deletes the hourly rollups of the company that start before the given time
+// ctx: context
+// companyID: id of the company
+// before: rollups starting before this time are deleted
+// returns: number of rollups deleted, error
+func (r *SensorDataRollupRepository) PurgeHourly(ctx context.Context, companyID uint, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("bucket_start < ?", before).
+		Where("waypoint_id IN (?)", r.db.
+			Model(&models.Waypoint{}).
+			Select("waypoints.id").
+			Joins("JOIN routes ON routes.id = waypoints.route_id").
+			Where("routes.company_id = ?", companyID),
+		).
+		Delete(&models.HourlySensorData{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+// SummaryByRoute returns the aggregates of the readings of the route recorded in the time range
+// Readings that were already purged are read from the rollups overlapping the range, suspect readings are left out.
+// ctx: context
+// routeID: id of the route
+// from: start of the range, inclusive
+// to: end of the range, exclusive
+// returns: the aggregates with a zero Count when there are no readings, error
+func (r *SensorDataRollupRepository) SummaryByRoute(
+	ctx context.Context,
+	routeID uint,
+	from, to time.Time,
+) (models.SensorDataBucket, error) {
+	var row bucketRow
+
+	err := r.db.WithContext(ctx).
+		Table("(?) AS sensor_data", sensorDataSeries(r.db, from, to, false)).
+		Select(seriesAggregates).
+		Joins("JOIN waypoints ON waypoints.id = sensor_data.waypoint_id").
+		Where("waypoints.route_id = ?", routeID).
+		Scan(&row).Error
+	if err != nil {
+		return models.SensorDataBucket{}, err
+	}
+
+	row.BucketStart = from
+	return bucketsOf([]bucketRow{row})[0], nil
+}
+
+// sensorDataSeries builds the subquery returning the readings recorded in the time range followed by the rollups
+// of the purged readings overlapping it, as rows with a date, a count and the min, max and avg of every metric.
+// The hourly rollups are used while they are kept and the daily rollups afterwards.
+// Rollups starting before the range are dated at its start. The rollups never hold suspect readings.
+// db: database connection
+// from: start of the range, inclusive
+// to: end of the range, exclusive
+// includeFlagged: whether the suspect readings that are not purged yet are returned too
+// returns: *gorm.DB to be used as a subquery
+func sensorDataSeries(db *gorm.DB, from, to time.Time, includeFlagged bool) *gorm.DB {
+	rawColumns := []string{"waypoint_id", "date", "1 AS count"}
+	rollupColumns := []string{"waypoint_id", "GREATEST(bucket_start, @from) AS date", "count"}
+	for _, metric := range rollupMetrics {
+		rawColumns = append(rawColumns,
+			metric+" AS "+metric+"_min", metric+" AS "+metric+"_max", metric+" AS "+metric+"_avg")
+		rollupColumns = append(rollupColumns, metric+"_min", metric+"_max", metric+"_avg")
+	}
+	for _, metric := range derivedMetrics {
+		rawColumns = append(rawColumns, metric.expression+" AS "+metric.column+"_min",
+			metric.expression+" AS "+metric.column+"_max", metric.expression+" AS "+metric.column+"_avg")
+		rollupColumns = append(rollupColumns, metric.column+"_min", metric.column+"_max", metric.column+"_avg")
+	}
+
+	query := fmt.Sprintf(`SELECT %[1]s FROM sensor_data
+		WHERE date >= @from AND date < @to AND (@include OR quality_flags & @suspect = 0)
+		UNION ALL
+		SELECT %[2]s FROM %[3]s
+		WHERE bucket_start < @to AND bucket_start + interval '1 hour' > @from
+		UNION ALL
+		SELECT %[2]s FROM %[4]s AS daily
+		WHERE bucket_start < @to AND bucket_start + interval '1 day' > @from
+			AND NOT EXISTS (
+				SELECT 1 FROM %[3]s AS hourly
+				WHERE hourly.waypoint_id = daily.waypoint_id
+					AND hourly.bucket_start >= daily.bucket_start
+					AND hourly.bucket_start < daily.bucket_start + interval '1 day'
+			)`,
+		strings.Join(rawColumns, ", "),
+		strings.Join(rollupColumns, ", "),
+		models.HourlySensorData{}.TableName(),
+		models.DailySensorData{}.TableName(),
+	)
+
+	return db.Raw(query, map[string]interface{}{
+		"from":    from,
+		"to":      to,
+		"include": includeFlagged,
+		"suspect": int(models.QualityFlagsSuspect),
+	})
+}
+
+// seriesAggregates are the aggregates computed over the rows of sensorDataSeries,
+// named like the columns of a bucketRow
+var seriesAggregates = `SUM(sensor_data.count) AS count,
+	MIN(sensor_data.temperature_min) AS temperature_min,
+	MAX(sensor_data.temperature_max) AS temperature_max,
+	SUM(sensor_data.temperature_avg * sensor_data.count) / SUM(sensor_data.count) AS temperature_avg,
+	MIN(sensor_data.humidity_min) AS humidity_min,
+	MAX(sensor_data.humidity_max) AS humidity_max,
+	SUM(sensor_data.humidity_avg * sensor_data.count) / SUM(sensor_data.count) AS humidity_avg,
+	MIN(sensor_data.wind_speed_min) AS wind_speed_min,
+	MAX(sensor_data.wind_speed_max) AS wind_speed_max,
+	SUM(sensor_data.wind_speed_avg * sensor_data.count) / SUM(sensor_data.count) AS wind_speed_avg,
+	MIN(sensor_data.mean_pressure_min) AS pressure_min,
+	MAX(sensor_data.mean_pressure_max) AS pressure_max,
+	SUM(sensor_data.mean_pressure_avg * sensor_data.count) / SUM(sensor_data.count) AS pressure_avg,
+	` + derivedSeriesAggregates()
@@ -0,0 +1,89 @@
+package repository // import "wayra/internal/adapter/repository"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+
+	"gorm.io/gorm"
+)
+
+// WaypointStatusEventRepository is a repository for the status events of the waypoints
+type WaypointStatusEventRepository struct {
+	*GenericRepository[models.WaypointStatusEvent] // Embedding the generic repository
+}
+
+// NewWaypointStatusEventRepository creates a new WaypointStatusEventRepository
+// db: database connection
+// returns: *WaypointStatusEventRepository
+func NewWaypointStatusEventRepository(db *gorm.DB) *WaypointStatusEventRepository {
+	return &WaypointStatusEventRepository{
+		GenericRepository: NewRepository[models.WaypointStatusEvent](db),
+	}
+}
+
+// History returns the latest status events of the waypoint
+// ctx: context
+// waypointID: id of the waypoint
+// limit: maximum number of events, 0 for all
+// returns: []models.WaypointStatusEvent newest first, error
+func (r *WaypointStatusEventRepository) History(
+	ctx context.Context,
+	waypointID uint,
+	limit int,
+) ([]models.WaypointStatusEvent, error) {
+	var events []models.WaypointStatusEvent
+
+	if err := latestEvents(r.db.WithContext(ctx), "waypoint_id", waypointID, limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// RouteConditionEventRepository is a repository for the condition events of the routes
+type RouteConditionEventRepository struct {
+	*GenericRepository[models.RouteConditionEvent] // Embedding the generic repository
+}
+
+// NewRouteConditionEventRepository creates a new RouteConditionEventRepository
+// db: database connection
+// returns: *RouteConditionEventRepository
+func NewRouteConditionEventRepository(db *gorm.DB) *RouteConditionEventRepository {
+	return &RouteConditionEventRepository{
+		GenericRepository: NewRepository[models.RouteConditionEvent](db),
+	}
+}
+
+// History returns the latest condition events of the route
+// ctx: context
+// routeID: id of the route
+// limit: maximum number of events, 0 for all
+// returns: []models.RouteConditionEvent newest first, error
+func (r *RouteConditionEventRepository) History(
+	ctx context.Context,
+	routeID uint,
+	limit int,
+) ([]models.RouteConditionEvent, error) {
+	var events []models.RouteConditionEvent
+
+	if err := latestEvents(r.db.WithContext(ctx), "route_id", routeID, limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// latestEvents selects the events of a waypoint or a route, newest first
+// db: database connection
+// column: column holding the id of the waypoint or the route
+// id: id of the waypoint or the route
+// limit: maximum number of events, 0 for all
+// returns: the query
+func latestEvents(db *gorm.DB, column string, id uint, limit int) *gorm.DB {
+	query := db.Where(column+" = ?", id).Order("created_at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	return query
+}
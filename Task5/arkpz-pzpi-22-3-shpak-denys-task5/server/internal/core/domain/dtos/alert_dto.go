@@ -0,0 +1,82 @@
+package dtos // import "wayra/internal/core/domain/dtos"
+
+import "time"
+
+// AlertDTO is a DTO that represents an alert raised by an alert rule at a waypoint of a route
+type AlertDTO struct {
+	// ID is the unique identifier of the alert
+	// Example: 1
+	ID uint `json:"id"`
+
+	// CompanyID is the unique identifier of the company of the route
+	// Example: 1
+	CompanyID uint `json:"company_id"`
+
+	// RouteID is the unique identifier of the route the alert was raised for
+	// Example: 1
+	RouteID uint `json:"route_id"`
+
+	// WaypointID is the unique identifier of the waypoint the conditions hold at
+	// Example: 1
+	WaypointID uint `json:"waypoint_id"`
+
+	// RuleID is the unique identifier of the rule that raised the alert, empty once the rule is deleted
+	// Example: 1
+	RuleID *uint `json:"rule_id,omitempty"`
+
+	// Type is the type of the alert
+	// Example: Ice Alert
+	Type string `json:"type"`
+
+	// Message is the message of the alert
+	// Example: Potential ice formation detected, the air is freezing and close to its dew point.
+	Message string `json:"message"`
+
+	// Details are the metrics the rule checks for the reading that raised the alert
+	// Example: Temperature: -1.00°C, Dew Point Spread: 0.70°C
+	Details string `json:"details"`
+
+	// Severity is the severity of the alert: info, warning or critical
+	// Example: warning
+	Severity string `json:"severity"`
+
+	// Status is the status of the alert: open, acknowledged or resolved
+	// Example: open
+	Status string `json:"status"`
+
+	// OpenedAt is the time the alert was raised
+	// Example: 2024-12-01T03:00:00Z
+	OpenedAt time.Time `json:"opened_at"`
+
+	// LastTriggeredAt is the time the conditions of the rule were last found to hold
+	// Example: 2024-12-01T06:59:00Z
+	LastTriggeredAt time.Time `json:"last_triggered_at"`
+
+	// AcknowledgedAt is the time a user acknowledged the alert
+	// Example: 2024-12-01T03:10:00Z
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+
+	// AcknowledgedByID is the unique identifier of the user that acknowledged the alert
+	// Example: 1
+	AcknowledgedByID *uint `json:"acknowledged_by_id,omitempty"`
+
+	// AcknowledgementComment is the comment the user acknowledged the alert with
+	// Example: Gritting truck dispatched.
+	AcknowledgementComment string `json:"acknowledgement_comment,omitempty"`
+
+	// ResolvedAt is the time the alert was resolved
+	// Example: 2024-12-01T07:00:00Z
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+
+	// ResolvedByID is the unique identifier of the user that resolved the alert, empty when it resolved
+	// as the conditions cleared
+	// Example: 1
+	ResolvedByID *uint `json:"resolved_by_id,omitempty"`
+
+	// ResolutionComment is the comment the user resolved the alert with
+	// Example: Road treated, no ice left.
+	ResolutionComment string `json:"resolution_comment,omitempty"`
+
+	// Readings are the readings the conditions held for when the alert was raised
+	Readings []SensorDataDTO `json:"readings,omitempty"`
+}
@@ -0,0 +1,64 @@
+package dtos // import "wayra/internal/core/domain/dtos"
+
+import "time"
+
+// AlertRuleDTO is a DTO that represents a weather alert rule of a company
+type AlertRuleDTO struct {
+	// ID is the unique identifier of the rule
+	// Example: 1
+	ID uint `json:"id"`
+
+	// CompanyID is the unique identifier of the company the rule belongs to
+	// Example: 1
+	CompanyID uint `json:"company_id"`
+
+	// Type is the type of the alert the rule raises
+	// Example: Storm Alert
+	Type string `json:"type"`
+
+	// Message is the message of the alert the rule raises
+	// Example: High wind speed detected, potential storm risk.
+	Message string `json:"message"`
+
+	// Severity is the severity of the alert: info, warning or critical
+	// Example: warning
+	Severity string `json:"severity"`
+
+	// Logic tells how the conditions are combined: and or or
+	// Example: and
+	Logic string `json:"logic"`
+
+	// Duration is the number of minutes the conditions must hold before the alert is raised
+	// Example: 15
+	Duration int `json:"duration"`
+
+	// Enabled tells if the rule is evaluated
+	// Example: true
+	Enabled bool `json:"enabled"`
+
+	// Conditions are the conditions of the rule
+	Conditions []AlertConditionDTO `json:"conditions"`
+
+	// CreatedAt is the time the rule was added
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `json:"created_at"`
+
+	// UpdatedAt is the time the rule was last changed
+	// Example: 2024-12-01T12:00:00Z
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AlertConditionDTO is a DTO that represents the comparison of a metric of a reading with a threshold
+type AlertConditionDTO struct {
+	// Metric is the measured or derived metric compared
+	// Example: wind_speed
+	Metric string `json:"metric"`
+
+	// Operator is the comparison: lt, lte, gt or gte
+	// Example: gt
+	Operator string `json:"operator"`
+
+	// Threshold is the value the metric is compared with
+	// Example: 20
+	Threshold float64 `json:"threshold"`
+}
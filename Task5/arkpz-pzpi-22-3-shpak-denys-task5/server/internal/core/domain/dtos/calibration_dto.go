@@ -0,0 +1,60 @@
+package dtos // import "wayra/internal/core/domain/dtos"
+
+import "time"
+
+// CalibrationProfileDTO is a DTO that represents the correction of one measurement of a device or a waypoint
+type CalibrationProfileDTO struct {
+	// ID is the unique identifier of the profile
+	// Example: 1
+	ID uint `json:"id"`
+
+	// DeviceID is the unique identifier of the device the profile corrects
+	// Example: 1
+	DeviceID *uint `json:"device_id,omitempty"`
+
+	// WaypointID is the unique identifier of the waypoint the profile corrects
+	// Example: 1
+	WaypointID *uint `json:"waypoint_id,omitempty"`
+
+	// Metric is the measurement the profile corrects
+	// Example: humidity
+	Metric string `json:"metric"`
+
+	// ValidFrom is the date of the first reading the profile corrects
+	// Example: 2024-12-01T00:00:00Z
+	ValidFrom time.Time `json:"valid_from"`
+
+	// Gain multiplies the value
+	// Example: 1.02
+	Gain float64 `json:"gain"`
+
+	// Offset is added to the value after the gain
+	// Example: -1.5
+	Offset float64 `json:"offset"`
+
+	// Points is the correction table applied before the gain and the offset
+	Points []CalibrationPointDTO `json:"points,omitempty"`
+
+	// Notes tells where the calibration comes from
+	// Example: Lab certificate 2024-117
+	Notes string `json:"notes,omitempty"`
+
+	// CreatedByID is the unique identifier of the user that added the profile
+	// Example: 1
+	CreatedByID *uint `json:"created_by_id,omitempty"`
+
+	// CreatedAt is the time the profile was added
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CalibrationPointDTO is a DTO that represents a raw value of a sensor and its reference value
+type CalibrationPointDTO struct {
+	// Raw is the value recorded by the sensor
+	// Example: 75
+	Raw float64 `json:"raw"`
+
+	// Reference is the value measured by the reference instrument
+	// Example: 72.4
+	Reference float64 `json:"reference"`
+}
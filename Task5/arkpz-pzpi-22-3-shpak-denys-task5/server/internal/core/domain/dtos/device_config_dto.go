@@ -0,0 +1,34 @@
+package dtos // import "wayra/internal/core/domain/dtos"
+
+import "time"
+
+// DeviceConfigDTO is a data transfer object that represents a stored DeviceConfig
+type DeviceConfigDTO struct {
+	// ID is the unique identifier of the DeviceConfig
+	// Example: 1
+	ID uint `json:"id"`
+
+	// CompanyID is the unique identifier of the company the config belongs to
+	// Example: 1
+	CompanyID uint `json:"company_id"`
+
+	// WaypointID is the unique identifier of the overridden waypoint, empty for company defaults
+	// Example: 1
+	WaypointID *uint `json:"waypoint_id,omitempty"`
+
+	// SendDataFrequency is the interval in minutes between two readings, empty when inherited
+	// Example: 20
+	SendDataFrequency *int `json:"send_data_frequency,omitempty"`
+
+	// GetWeatherAlerts tells the device whether to analyze the weather, empty when inherited
+	// Example: true
+	GetWeatherAlerts *bool `json:"get_weather_alerts,omitempty"`
+
+	// Version is incremented every time the config is changed
+	// Example: 3
+	Version uint `json:"version"`
+
+	// UpdatedAt is the time of the last change of the config
+	// Example: 2024-12-01T12:00:00Z
+	UpdatedAt time.Time `json:"updated_at"`
+}
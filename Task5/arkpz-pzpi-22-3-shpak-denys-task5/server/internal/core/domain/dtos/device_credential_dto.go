@@ -0,0 +1,34 @@
+package dtos // import "wayra/internal/core/domain/dtos"
+
+import "time"
+
+// DeviceCredentialDTO is a DTO that represents a credential issued to a device
+type DeviceCredentialDTO struct {
+	// ID is the unique identifier of the credential
+	// Example: 1
+	ID uint `json:"id"`
+
+	// WaypointID is the unique identifier of the waypoint the device is bound to
+	// Example: 1
+	WaypointID uint `json:"waypoint_id"`
+
+	// DeviceSerial is the serial number of the device the credential was issued for
+	// Example: device_serial_1
+	DeviceSerial string `json:"device_serial"`
+
+	// TokenPrefix is the beginning of the token, used to tell credentials apart
+	// Example: wd_3f9a1c
+	TokenPrefix string `json:"token_prefix"`
+
+	// CreatedAt is the time the credential was issued
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `json:"created_at"`
+
+	// LastUsedAt is the time the device last authenticated with the credential
+	// Example: 2024-12-01T12:00:00Z
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+
+	// RevokedAt is the time the credential was revoked
+	// Example: 2024-12-01T12:00:00Z
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
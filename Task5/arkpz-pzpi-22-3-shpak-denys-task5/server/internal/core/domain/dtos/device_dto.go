@@ -0,0 +1,73 @@
+package dtos // import "wayra/internal/core/domain/dtos"
+
+import "time"
+
+// DeviceDTO is a DTO that represents a physical sensor device
+type DeviceDTO struct {
+	// ID is the unique identifier of the device
+	// Example: 1
+	ID uint `json:"id"`
+
+	// Serial is the serial number of the device
+	// Example: 123456789
+	Serial string `json:"serial"`
+
+	// Model is the hardware model of the device
+	// Example: ESP32-DHT22
+	Model string `json:"model,omitempty"`
+
+	// FirmwareVersion is the version of the firmware running on the device
+	// Example: 1.2.0
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+
+	// InstalledAt is the date the device was put into service
+	// Example: 2024-12-01T12:00:00Z
+	InstalledAt *time.Time `json:"installed_at,omitempty"`
+
+	// Status is the status of the device
+	// Example: active
+	Status string `json:"status"`
+
+	// CompanyID is the unique identifier of the company owning the device
+	// Example: 1
+	CompanyID uint `json:"company_id"`
+
+	// WaypointID is the unique identifier of the waypoint the device is installed at
+	// Example: 1
+	WaypointID *uint `json:"waypoint_id,omitempty"`
+
+	// LastSeenAt is the time the device last sent data or a heartbeat
+	// Example: 2024-12-01T12:00:00Z
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+
+	// CreatedAt is the time the device was registered
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeviceAssignmentDTO is a DTO that represents the period a device was installed at a waypoint
+type DeviceAssignmentDTO struct {
+	// ID is the unique identifier of the assignment
+	// Example: 1
+	ID uint `json:"id"`
+
+	// DeviceID is the unique identifier of the device
+	// Example: 1
+	DeviceID uint `json:"device_id"`
+
+	// WaypointID is the unique identifier of the waypoint
+	// Example: 1
+	WaypointID uint `json:"waypoint_id"`
+
+	// AssignedAt is the time the device was installed at the waypoint
+	// Example: 2024-12-01T12:00:00Z
+	AssignedAt time.Time `json:"assigned_at"`
+
+	// UnassignedAt is the time the device was removed from the waypoint
+	// Example: 2024-12-01T12:00:00Z
+	UnassignedAt *time.Time `json:"unassigned_at,omitempty"`
+
+	// AssignedByID is the unique identifier of the user that installed the device
+	// Example: 1
+	AssignedByID *uint `json:"assigned_by_id,omitempty"`
+}
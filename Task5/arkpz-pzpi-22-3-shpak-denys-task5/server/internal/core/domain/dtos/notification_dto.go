@@ -0,0 +1,106 @@
+package dtos // import "wayra/internal/core/domain/dtos"
+
+import "time"
+
+// NotificationChannelDTO is a DTO that represents a webhook or an email address list of a company
+// the alerts are sent to
+type NotificationChannelDTO struct {
+	// ID is the unique identifier of the channel
+	// Example: 1
+	ID uint `json:"id"`
+
+	// CompanyID is the unique identifier of the company the channel belongs to
+	// Example: 1
+	CompanyID uint `json:"company_id"`
+
+	// Name is the name of the channel
+	// Example: Dispatch on-call
+	Name string `json:"name"`
+
+	// Kind is the kind of the channel: webhook or email
+	// Example: webhook
+	Kind string `json:"kind"`
+
+	// Enabled tells if notifications are sent to the channel
+	// Example: true
+	Enabled bool `json:"enabled"`
+
+	// URL is the URL the webhook notifications are posted to
+	// Example: https://hooks.example.com/wayra
+	URL string `json:"url,omitempty"`
+
+	// Recipients are the comma-separated addresses the email notifications are sent to
+	// Example: dispatch@example.com, ops@example.com
+	Recipients string `json:"recipients,omitempty"`
+
+	// MinSeverity is the lowest severity of the alerts sent to the channel: info, warning or critical
+	// Example: warning
+	MinSeverity string `json:"min_severity"`
+
+	// AllRoutes tells if the alerts of every route are sent, otherwise only the ones of RouteIDs are
+	// Example: false
+	AllRoutes bool `json:"all_routes"`
+
+	// RouteIDs are the unique identifiers of the routes whose alerts are sent, unless AllRoutes is set
+	// Example: [1, 2]
+	RouteIDs []uint `json:"route_ids"`
+
+	// SubjectTemplate is the text/template of the subject, the default one when empty
+	// Example: [{{.Severity}}] {{.Type}} on {{.RouteName}}
+	SubjectTemplate string `json:"subject_template"`
+
+	// BodyTemplate is the text/template of the body, the default one when empty
+	// Example: {{.Message}} {{.Details}}
+	BodyTemplate string `json:"body_template"`
+
+	// CreatedAt is the time the channel was added
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `json:"created_at"`
+
+	// UpdatedAt is the time the channel was last changed
+	// Example: 2024-12-01T12:00:00Z
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotificationDeliveryDTO is a DTO that represents the sending of a notification to a channel
+type NotificationDeliveryDTO struct {
+	// ID is the unique identifier of the delivery, sent with the notification
+	// Example: 1
+	ID uint `json:"id"`
+
+	// ChannelID is the unique identifier of the channel the notification is sent to
+	// Example: 1
+	ChannelID uint `json:"channel_id"`
+
+	// AlertID is the unique identifier of the alert the notification tells about, null for tests
+	// Example: 1
+	AlertID *uint `json:"alert_id"`
+
+	// Event is the event the notification is sent for: alert.opened or test
+	// Example: alert.opened
+	Event string `json:"event"`
+
+	// Status is the status of the delivery: pending, delivered or failed
+	// Example: delivered
+	Status string `json:"status"`
+
+	// Attempts is the number of times the notification was sent
+	// Example: 2
+	Attempts int `json:"attempts"`
+
+	// ResponseStatus is the HTTP status the webhook last answered with, 0 for emails
+	// Example: 200
+	ResponseStatus int `json:"response_status"`
+
+	// LastError is the error of the last failed attempt
+	// Example: webhook answered 503 Service Unavailable
+	LastError string `json:"last_error,omitempty"`
+
+	// CreatedAt is the time the notification was queued
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `json:"created_at"`
+
+	// DeliveredAt is the time the notification was accepted, null until then
+	// Example: 2024-12-01T12:00:01Z
+	DeliveredAt *time.Time `json:"delivered_at"`
+}
@@ -1,5 +1,7 @@
 package dtos // import "wayra/internal/core/domain/dtos"
 
+import "time"
+
 // RouteDTO is a DTO that represents a route
 type RouteDTO struct {
 	// ID is the unique identifier of the route
@@ -10,6 +12,14 @@ type RouteDTO struct {
 	// Example: Route 1
 	Name string `json:"name"`
 
+	// Status is the current weather condition of the route
+	// Example: bad_weather_detected
+	Status string `json:"status"`
+
+	// StatusChangedAt is the time the condition was last reported
+	// Example: 2024-12-01T12:00:00Z
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+
 	// Waypoints is a list of waypoints that the route has
 	Waypoints []WaypointDTO `json:"waypoints,omitempty"`
 }
@@ -1,6 +1,9 @@
 package dtos // import "wayra/internal/core/domain/dtos"
 
-import "time"
+import (
+	"time"
+	"wayra/internal/core/domain/models"
+)
 
 // SensorDataDTO is a DTO for SensorData
 type SensorDataDTO struct {
@@ -12,6 +15,22 @@ type SensorDataDTO struct {
 	// Example: 2021-01-01T00:00:00Z
 	Date time.Time `json:"date"`
 
+	// DeviceDate is the date as sent by the device, before the clock skew correction
+	// Example: 2021-01-01T03:00:00Z
+	DeviceDate *time.Time `json:"device_date,omitempty"`
+
+	// ReceivedAt is the time the server received the SensorData
+	// Example: 2021-01-01T00:00:02Z
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
+
+	// TimestampFlag tells why the date can not be trusted: server_time, future, ancient or out_of_order
+	// Example: out_of_order
+	TimestampFlag string `json:"timestamp_flag,omitempty"`
+
+	// QualityFlags lists the data-quality problems of the SensorData: outlier, spike, stuck or gap
+	// Example: ["outlier"]
+	QualityFlags models.QualityFlags `json:"quality_flags,omitempty" swaggertype:"array,string"`
+
 	// Temperature is the temperature of the SensorData
 	// Example: 25.5
 	Temperature float64 `json:"temperature"`
@@ -27,4 +46,40 @@ type SensorDataDTO struct {
 	// WindDirection is the wind direction of the SensorData
 	// Example: 0.5
 	MeanPressure float64 `json:"mean_pressure"`
+
+	// DewPoint is the temperature at which the air of the SensorData would be saturated
+	// Example: 14.2
+	DewPoint float64 `json:"dew_point"`
+
+	// HeatIndex is the temperature felt in the heat, equal to the temperature below 26.7°C
+	// Example: 25.5
+	HeatIndex float64 `json:"heat_index"`
+
+	// WindChill is the temperature felt in the wind, equal to the temperature above 10°C or in calm air
+	// Example: 25.5
+	WindChill float64 `json:"wind_chill"`
+
+	// RawTemperature is the temperature before the calibration, left out when no calibration applies
+	// Example: 26.1
+	RawTemperature *float64 `json:"raw_temperature,omitempty"`
+
+	// RawHumidity is the humidity before the calibration, left out when no calibration applies
+	// Example: 0.55
+	RawHumidity *float64 `json:"raw_humidity,omitempty"`
+
+	// RawWindSpeed is the wind speed before the calibration, left out when no calibration applies
+	// Example: 10.2
+	RawWindSpeed *float64 `json:"raw_wind_speed,omitempty"`
+
+	// RawMeanPressure is the mean pressure before the calibration, left out when no calibration applies
+	// Example: 1012.8
+	RawMeanPressure *float64 `json:"raw_mean_pressure,omitempty"`
+
+	// WaypointID is the unique identifier of the waypoint the SensorData was recorded at
+	// Example: 1
+	WaypointID uint `json:"waypoint_id,omitempty"`
+
+	// DeviceID is the unique identifier of the device that recorded the SensorData
+	// Example: 1
+	DeviceID *uint `json:"device_id,omitempty"`
 }
@@ -0,0 +1,30 @@
+package dtos // import "wayra/internal/core/domain/dtos"
+
+import "time"
+
+// StatusEventDTO is a DTO that represents a status change of a waypoint or a condition change of a route
+type StatusEventDTO struct {
+	// ID is the unique identifier of the event
+	// Example: 1
+	ID uint `json:"id"`
+
+	// Status is the reported status
+	// Example: anomaly_detected
+	Status string `json:"status"`
+
+	// Details is the explanation sent together with the status
+	// Example: Device CV exceeds normal values. Possible sensor malfunction.
+	Details string `json:"details,omitempty"`
+
+	// SourceDevice is the serial number of the device that reported the status
+	// Example: device_serial_1
+	SourceDevice string `json:"source_device,omitempty"`
+
+	// ReportedByID is the unique identifier of the user that reported the status
+	// Example: 1
+	ReportedByID *uint `json:"reported_by_id,omitempty"`
+
+	// CreatedAt is the time the status was reported
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -1,5 +1,7 @@
 package dtos // import "wayra/internal/core/domain/dtos"
 
+import "time"
+
 // WaypointDTO is a data transfer object that represents a Waypoint entity
 type WaypointDTO struct {
 	// ID is the unique identifier of the Waypoint
@@ -22,6 +24,19 @@ type WaypointDTO struct {
 	// Example: -77.0311
 	Longitude float64 `json:"longitude"`
 
-	// Altitude is the altitude of the Waypoint
-	SensorData []SensorDataDTO `json:"sensor_data,omitempty"`
+	// Status is the current health of the Waypoint's device
+	// Example: ok
+	Status string `json:"status"`
+
+	// StatusChangedAt is the time the status was last reported
+	// Example: 2024-12-01T12:00:00Z
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+
+	// Connectivity tells whether the device of the Waypoint is still reporting: unknown, online or offline
+	// Example: online
+	Connectivity string `json:"connectivity"`
+
+	// LastSeenAt is the time the device of the Waypoint last sent data or a heartbeat
+	// Example: 2024-12-01T12:00:00Z
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
 }
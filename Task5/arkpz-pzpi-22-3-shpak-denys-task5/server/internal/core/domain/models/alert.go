@@ -0,0 +1,155 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statuses of the alerts, an alert goes from open to acknowledged to resolved
+// and may be resolved without being acknowledged
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusResolved     = "resolved"
+)
+
+// Alert is a struct that represents an alert raised by an alert rule at a waypoint of a route
+// The alert stays open while the conditions of the rule hold and is resolved when they clear,
+// or when a user resolves it.
+type Alert struct {
+	// ID is the identifier of the alert
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// CompanyID is the identifier of the company of the route
+	// Example: 1
+	CompanyID uint `gorm:"index;not null;column:company_id"`
+
+	// RouteID is the identifier of the route the alert was raised for
+	// Example: 1
+	RouteID uint `gorm:"index;not null;column:route_id"`
+
+	// WaypointID is the identifier of the waypoint the conditions hold at
+	// Example: 1
+	WaypointID uint `gorm:"index;not null;column:waypoint_id"`
+
+	// RuleID is the identifier of the rule that raised the alert, nil once the rule is deleted
+	// Example: 1
+	RuleID *uint `gorm:"index;column:rule_id"`
+
+	// Type is the type of the alert, copied from the rule
+	// Example: Ice Alert
+	Type string `gorm:"size:100;not null;column:type"`
+
+	// Message is the message of the alert, copied from the rule
+	// Example: Potential ice formation detected, the air is freezing and close to its dew point.
+	Message string `gorm:"size:255;not null;column:message"`
+
+	// Details are the metrics the rule checks for the reading that raised the alert
+	// Example: Temperature: -1.00°C, Dew Point Spread: 0.70°C
+	Details string `gorm:"type:text;column:details"`
+
+	// Severity is the severity of the alert, copied from the rule: info, warning or critical
+	// Example: warning
+	Severity string `gorm:"size:20;not null;column:severity"`
+
+	// Status is the status of the alert: open, acknowledged or resolved
+	// Example: open
+	Status string `gorm:"size:20;not null;index;column:status"`
+
+	// OpenedAt is the time the alert was raised
+	// Example: 2024-12-01T03:00:00Z
+	OpenedAt time.Time `gorm:"not null;index;column:opened_at"`
+
+	// LastTriggeredAt is the time the conditions of the rule were last found to hold
+	// Example: 2024-12-01T06:59:00Z
+	LastTriggeredAt time.Time `gorm:"not null;column:last_triggered_at"`
+
+	// AcknowledgedAt is the time a user acknowledged the alert, nil until then
+	// Example: 2024-12-01T03:10:00Z
+	AcknowledgedAt *time.Time `gorm:"column:acknowledged_at"`
+
+	// AcknowledgedByID is the identifier of the user that acknowledged the alert
+	// Example: 1
+	AcknowledgedByID *uint `gorm:"column:acknowledged_by_id"`
+
+	// AcknowledgementComment is the comment the user acknowledged the alert with
+	// Example: Gritting truck dispatched.
+	AcknowledgementComment string `gorm:"type:text;column:acknowledgement_comment"`
+
+	// ResolvedAt is the time the alert was resolved, nil while it is not
+	// Example: 2024-12-01T07:00:00Z
+	ResolvedAt *time.Time `gorm:"column:resolved_at"`
+
+	// ResolvedByID is the identifier of the user that resolved the alert, nil when it resolved as the conditions cleared
+	// Example: 1
+	ResolvedByID *uint `gorm:"column:resolved_by_id"`
+
+	// ResolutionComment is the comment the user resolved the alert with
+	// Example: Road treated, no ice left.
+	ResolutionComment string `gorm:"type:text;column:resolution_comment"`
+
+	// Readings are the readings the conditions held for when the alert was raised, ordered by date
+	// They are copied to the alert, so its history is kept after the retention purges the readings.
+	Readings []AlertReading `gorm:"type:jsonb;serializer:json;column:readings" json:"readings,omitempty"`
+
+	// Route is the relation with the route table
+	Route Route `gorm:"foreignKey:RouteID;constraint:OnDelete:CASCADE;" json:"-"`
+
+	// Waypoint is the relation with the waypoint table
+	Waypoint Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:CASCADE;" json:"-"`
+
+	// Rule is the relation with the alert rule table
+	Rule *AlertRule `gorm:"foreignKey:RuleID;constraint:OnDelete:SET NULL;" json:"-"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (a *Alert) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+// AlertReading is a copy of a reading an alert was raised for
+type AlertReading struct {
+	ID            uint      `json:"id"`                       // identifier of the reading, it may be purged since
+	WaypointID    uint      `json:"waypoint_id"`              // waypoint the reading was recorded at
+	Date          time.Time `json:"date"`                     // date the reading was recorded
+	TimestampFlag string    `json:"timestamp_flag,omitempty"` // why the date can not be trusted, empty when it can
+	Temperature   float64   `json:"temperature"`              // temperature, °C
+	Humidity      float64   `json:"humidity"`                 // relative humidity, %
+	WindSpeed     float64   `json:"wind_speed"`               // wind speed, m/s
+	MeanPressure  float64   `json:"mean_pressure"`            // mean pressure, hPa
+}
+
+// NewAlertReadings copies the readings an alert is raised for
+// readings: readings to copy
+// returns: the copies in the same order
+func NewAlertReadings(readings []SensorData) []AlertReading {
+	copies := make([]AlertReading, 0, len(readings))
+	for _, reading := range readings {
+		copies = append(copies, AlertReading{
+			ID:            reading.ID,
+			WaypointID:    reading.WaypointID,
+			Date:          reading.Date,
+			TimestampFlag: reading.TimestampFlag,
+			Temperature:   reading.Temperature,
+			Humidity:      reading.Humidity,
+			WindSpeed:     reading.WindSpeed,
+			MeanPressure:  reading.MeanPressure,
+		})
+	}
+	return copies
+}
+
+// AlertQuery selects the alerts of a company, optionally narrowed to a route, a waypoint, a status,
+// a severity and the time they were opened in
+type AlertQuery struct {
+	CompanyID  uint      // company of the alerts
+	RouteID    uint      // route of the alerts, 0 for every route
+	WaypointID uint      // waypoint of the alerts, 0 for every waypoint
+	Status     string    // status of the alerts, empty for every status
+	Severity   string    // severity of the alerts, empty for every severity
+	From       time.Time // alerts opened at or after, zero for no bound
+	To         time.Time // alerts opened before, zero for no bound
+	Limit      int       // maximum number of alerts, 0 for all
+}
@@ -0,0 +1,117 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Derived metrics an alert rule may check besides the measured ones
+const (
+	MetricDewPoint       = "dew_point"
+	MetricHeatIndex      = "heat_index"
+	MetricWindChill      = "wind_chill"
+	MetricDewPointSpread = "dew_point_spread" // temperature minus dew point
+)
+
+// Severities of the alert rules
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// Ways the conditions of an alert rule are combined
+const (
+	AlertLogicAnd = "and" // every condition must hold
+	AlertLogicOr  = "or"  // one condition must hold
+)
+
+// Operators comparing a metric to the threshold of a condition
+const (
+	AlertOperatorLessThan       = "lt"
+	AlertOperatorLessOrEqual    = "lte"
+	AlertOperatorGreaterThan    = "gt"
+	AlertOperatorGreaterOrEqual = "gte"
+)
+
+// AlertRule is a struct that represents a weather alert rule of a company
+// The rule raises its alert for a route when its conditions hold at a waypoint of the route
+// for at least Duration minutes.
+type AlertRule struct {
+	// ID is the identifier of the rule
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// CompanyID is the identifier of the company the rule belongs to
+	// Example: 1
+	CompanyID uint `gorm:"index;not null;column:company_id"`
+
+	// Type is the type of the alert the rule raises
+	// Example: Storm Alert
+	Type string `gorm:"size:100;not null;column:type"`
+
+	// Message is the message of the alert the rule raises
+	// Example: High wind speed detected, potential storm risk.
+	Message string `gorm:"size:255;not null;column:message"`
+
+	// Severity is the severity of the alert: info, warning or critical
+	// Example: warning
+	Severity string `gorm:"size:20;not null;column:severity"`
+
+	// Logic tells how the conditions are combined: and or or
+	// Example: and
+	Logic string `gorm:"size:3;not null;column:logic"`
+
+	// Duration is the number of minutes the conditions must hold before the alert is raised
+	// Example: 15
+	Duration int `gorm:"not null;column:duration"`
+
+	// Enabled tells if the rule is evaluated
+	// Example: true
+	Enabled bool `gorm:"not null;column:enabled"`
+
+	// CreatedAt is the time the rule was added
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `gorm:"column:created_at"`
+
+	// UpdatedAt is the time the rule was last changed
+	// Example: 2024-12-01T12:00:00Z
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+
+	// Conditions are the conditions of the rule
+	Conditions []AlertCondition `gorm:"foreignKey:RuleID;constraint:OnDelete:CASCADE;" json:"conditions,omitempty"`
+
+	// Company is the relation with the company table
+	Company Company `gorm:"foreignKey:CompanyID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (r *AlertRule) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Conditions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	})
+}
+
+// AlertCondition is a struct that represents the comparison of a metric of a reading with a threshold
+type AlertCondition struct {
+	// ID is the identifier of the condition
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// RuleID is the identifier of the rule the condition belongs to
+	// Example: 1
+	RuleID uint `gorm:"index;not null;column:rule_id"`
+
+	// Metric is the measured or derived metric compared
+	// Example: wind_speed
+	Metric string `gorm:"size:20;not null;column:metric"`
+
+	// Operator is the comparison: lt, lte, gt or gte
+	// Example: gt
+	Operator string `gorm:"size:3;not null;column:operator"`
+
+	// Threshold is the value the metric is compared with, in the canonical unit of the metric
+	// Example: 20
+	Threshold float64 `gorm:"not null;column:threshold"`
+}
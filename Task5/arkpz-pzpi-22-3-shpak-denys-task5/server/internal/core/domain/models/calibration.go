@@ -0,0 +1,131 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Measurements a calibration applies to
+const (
+	MetricTemperature  = "temperature"
+	MetricHumidity     = "humidity"
+	MetricWindSpeed    = "wind_speed"
+	MetricMeanPressure = "mean_pressure"
+)
+
+// CalibrationProfile is a struct that represents the correction of one measurement of a device or a waypoint
+// A profile applies to the readings recorded from ValidFrom until the next profile of the same measurement.
+// A profile of the device recording a reading takes precedence over a profile of the waypoint.
+// The raw value is first mapped through the Points, when there are any, then multiplied by Gain and added to Offset.
+type CalibrationProfile struct {
+	// ID is the identifier of the profile
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// DeviceID is the identifier of the device the profile corrects, nil for a waypoint profile
+	// Example: 1
+	DeviceID *uint `gorm:"index;column:device_id"`
+
+	// WaypointID is the identifier of the waypoint the profile corrects, nil for a device profile
+	// Example: 1
+	WaypointID *uint `gorm:"index;column:waypoint_id"`
+
+	// Metric is the measurement the profile corrects: temperature, humidity, wind_speed or mean_pressure
+	// Example: humidity
+	Metric string `gorm:"size:20;not null;column:metric"`
+
+	// ValidFrom is the date of the first reading the profile corrects
+	// Example: 2024-12-01T00:00:00Z
+	ValidFrom time.Time `gorm:"type:timestamptz;not null;column:valid_from"`
+
+	// Gain multiplies the value
+	// Example: 1.02
+	Gain float64 `gorm:"not null;default:1;column:gain"`
+
+	// Offset is added to the value after the gain
+	// Example: -1.5
+	Offset float64 `gorm:"not null;default:0;column:offset"`
+
+	// Notes tells where the calibration comes from, like the certificate of a lab
+	// Example: Lab certificate 2024-117
+	Notes string `gorm:"size:255;column:notes"`
+
+	// CreatedByID is the identifier of the user that added the profile
+	// Example: 1
+	CreatedByID *uint `gorm:"column:created_by_id"`
+
+	// CreatedAt is the time the profile was added
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `gorm:"column:created_at"`
+
+	// Points is the correction table, ordered by raw value
+	Points []CalibrationPoint `gorm:"foreignKey:ProfileID;constraint:OnDelete:CASCADE;" json:"points,omitempty"`
+
+	// Device is the device the profile corrects
+	Device *Device `gorm:"foreignKey:DeviceID;constraint:OnDelete:CASCADE;" json:"-"`
+
+	// Waypoint is the waypoint the profile corrects
+	Waypoint *Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (p *CalibrationProfile) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Points", func(db *gorm.DB) *gorm.DB {
+		return db.Order("raw")
+	})
+}
+
+// Correct computes the calibrated value of a raw value
+// Between two points of the table the value is interpolated linearly,
+// beyond the first and the last point the closest segment is extended.
+// raw: value as recorded by the sensor
+// returns: the calibrated value
+func (p *CalibrationProfile) Correct(raw float64) float64 {
+	value := raw
+
+	if len(p.Points) >= 2 {
+		i := 1
+		for i < len(p.Points)-1 && raw > p.Points[i].Raw {
+			i++
+		}
+
+		low, high := p.Points[i-1], p.Points[i]
+		value = low.Reference + (raw-low.Raw)*(high.Reference-low.Reference)/(high.Raw-low.Raw)
+	}
+
+	return value*p.Gain + p.Offset
+}
+
+// CalibrationPoint is a struct that represents a raw value of a sensor and the reference value measured next to it
+type CalibrationPoint struct {
+	// ID is the identifier of the point
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// ProfileID is the identifier of the profile the point belongs to
+	// Example: 1
+	ProfileID uint `gorm:"not null;index;column:profile_id"`
+
+	// Raw is the value recorded by the sensor
+	// Example: 75
+	Raw float64 `gorm:"not null;column:raw"`
+
+	// Reference is the value measured by the reference instrument
+	// Example: 72.4
+	Reference float64 `gorm:"not null;column:reference"`
+}
+
+// RecalibrationQuery selects the stored readings to calibrate again, by device or by waypoint
+type RecalibrationQuery struct {
+	DeviceID   uint      // readings recorded by the device, 0 to select by waypoint
+	WaypointID uint      // readings recorded at the waypoint, 0 to select by device
+	From       time.Time // start of the range, inclusive
+	To         time.Time // end of the range, exclusive
+}
+
+// RecalibrationResult is the outcome of calibrating stored readings again
+type RecalibrationResult struct {
+	Readings int `json:"readings"` // readings of the range
+	Changed  int `json:"changed"`  // readings whose calibrated values changed
+}
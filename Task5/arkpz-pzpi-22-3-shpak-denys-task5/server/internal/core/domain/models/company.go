@@ -43,7 +43,7 @@ type Company struct {
 func (c *Company) LoadRelations(db *gorm.DB) *gorm.DB {
 	withCreator := db.Preload("Creator")
 	withUsers := withCreator.Preload("Users")
-	withRoutes := withUsers.Preload("Routes").Preload("Routes.Waypoints").Preload("Routes.Waypoints.SensorData")
+	withRoutes := withUsers.Preload("Routes").Preload("Routes.Waypoints")
 	withDeliveries := withRoutes.Preload("Deliveries").Preload("Deliveries.Products").Preload("Deliveries.Products.ProductCategory")
 	return withDeliveries
 }
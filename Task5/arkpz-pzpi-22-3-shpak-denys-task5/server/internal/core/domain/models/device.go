@@ -0,0 +1,114 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statuses of a device
+const (
+	DeviceStatusActive      = "active"      // the device is in service
+	DeviceStatusMaintenance = "maintenance" // the device is out of service for repair or calibration
+	DeviceStatusRetired     = "retired"     // the device is permanently out of service
+)
+
+// Device is a struct that represents a physical sensor device of a company
+// A device is installed at no more than one waypoint at a time, its assignments keep the history.
+type Device struct {
+	// ID is the identifier of the device
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// Serial is the serial number of the device
+	// Example: 123456789
+	Serial string `gorm:"size:255;not null;uniqueIndex;column:serial"`
+
+	// Model is the hardware model of the device
+	// Example: ESP32-DHT22
+	Model string `gorm:"size:255;column:model"`
+
+	// FirmwareVersion is the version of the firmware running on the device
+	// Example: 1.2.0
+	FirmwareVersion string `gorm:"size:100;column:firmware_version"`
+
+	// InstalledAt is the date the device was put into service
+	// Example: 2024-12-01T12:00:00Z
+	InstalledAt *time.Time `gorm:"column:installed_at"`
+
+	// Status is the status of the device
+	// Example: active
+	Status string `gorm:"size:50;not null;default:'active';column:status"`
+
+	// CompanyID is the identifier of the company owning the device
+	// Example: 1
+	CompanyID uint `gorm:"not null;index;column:company_id"`
+
+	// WaypointID is the identifier of the waypoint the device is installed at, nil when it is not installed
+	// Example: 1
+	WaypointID *uint `gorm:"index;column:waypoint_id"`
+
+	// LastSeenAt is the time the device last sent data or a heartbeat
+	// Example: 2024-12-01T12:00:00Z
+	LastSeenAt *time.Time `gorm:"column:last_seen_at"`
+
+	// CreatedAt is the time the device was registered
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `gorm:"column:created_at"`
+
+	// Company is the company owning the device
+	Company Company `gorm:"foreignKey:CompanyID;constraint:OnDelete:CASCADE;" json:"company,omitempty"`
+
+	// Waypoint is the waypoint the device is installed at
+	Waypoint *Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:SET NULL;" json:"waypoint,omitempty"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (d *Device) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Company").Preload("Waypoint")
+}
+
+// DeviceAssignment is a struct that represents the period a device was installed at a waypoint
+type DeviceAssignment struct {
+	// ID is the identifier of the assignment
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// DeviceID is the identifier of the device
+	// Example: 1
+	DeviceID uint `gorm:"not null;index;column:device_id"`
+
+	// WaypointID is the identifier of the waypoint
+	// Example: 1
+	WaypointID uint `gorm:"not null;index;column:waypoint_id"`
+
+	// AssignedAt is the time the device was installed at the waypoint
+	// Example: 2024-12-01T12:00:00Z
+	AssignedAt time.Time `gorm:"not null;column:assigned_at"`
+
+	// UnassignedAt is the time the device was removed from the waypoint, nil while it is installed
+	// Example: 2024-12-01T12:00:00Z
+	UnassignedAt *time.Time `gorm:"column:unassigned_at"`
+
+	// AssignedByID is the identifier of the user that installed the device
+	// Example: 1
+	AssignedByID *uint `gorm:"column:assigned_by_id"`
+
+	// Device is the assigned device
+	Device Device `gorm:"foreignKey:DeviceID;constraint:OnDelete:CASCADE;" json:"device,omitempty"`
+
+	// Waypoint is the waypoint the device was installed at
+	Waypoint Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:CASCADE;" json:"waypoint,omitempty"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (a *DeviceAssignment) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+// Covers reports whether the device was installed at the waypoint at the given time
+// at: time to check
+// returns: true if the assignment covers the time
+func (a *DeviceAssignment) Covers(at time.Time) bool {
+	return !at.Before(a.AssignedAt) && (a.UnassignedAt == nil || at.Before(*a.UnassignedAt))
+}
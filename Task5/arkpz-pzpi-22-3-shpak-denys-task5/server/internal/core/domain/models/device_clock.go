@@ -0,0 +1,104 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Limits of the clock skew estimation
+const (
+	clockJitter          = 5 * time.Minute // deltas further than this from the estimate are outliers
+	clockOutliersToReset = 3               // consecutive outliers after which the clock is considered reset
+	clockMaxWeight       = 10              // number of samples the running mean is averaged over at most
+	clockMinSamples      = 3               // samples needed before a clock running late is corrected
+	clockTolerance       = time.Minute     // skews below this are network latency, not a wrong clock
+)
+
+// DeviceClock is a struct that represents the estimated clock skew of the device of a waypoint
+type DeviceClock struct {
+	// WaypointID is the identifier of the waypoint the device reports for
+	// Example: 1
+	WaypointID uint `gorm:"primaryKey;autoIncrement:false;column:waypoint_id"`
+
+	// Skew is the estimated difference between the device clock and the server clock
+	// Example: 10800000000000
+	Skew time.Duration `gorm:"not null;default:0;column:skew"`
+
+	// Correction is the skew subtracted from the dates sent by the device
+	// It only follows the estimate in steps, so a reading sent twice gets the same date.
+	// Example: 10800000000000
+	Correction time.Duration `gorm:"not null;default:0;column:correction"`
+
+	// Samples is the number of deltas the estimate is based on
+	// Example: 12
+	Samples int `gorm:"not null;default:0;column:samples"`
+
+	// Outliers is the number of consecutive deltas that did not match the estimate
+	// Example: 0
+	Outliers int `gorm:"not null;default:0;column:outliers"`
+
+	// LastReadingAt is the corrected date of the newest reading of the device
+	// Example: 2024-12-01T12:00:00Z
+	LastReadingAt *time.Time `gorm:"type:timestamptz;column:last_reading_at"`
+
+	// UpdatedAt is the time the estimate was last updated
+	// Example: 2024-12-01T12:00:00Z
+	UpdatedAt time.Time `gorm:"type:timestamptz;not null;column:updated_at"`
+
+	// Waypoint is the waypoint the device reports for
+	Waypoint Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:CASCADE;"`
+}
+
+// LoadRelations is an implementation of the interface for the gorm library
+func (c *DeviceClock) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+// Observe updates the estimate with the difference between a date sent by the device and the time it was received
+// Deltas far from the estimate, like a backlog sent late, are ignored unless they keep coming,
+// in which case the device clock was reset and the estimate starts over.
+// delta: date sent by the device minus the time the server received it
+func (c *DeviceClock) Observe(delta time.Duration) {
+	switch {
+	case c.Samples == 0:
+		c.Skew = delta
+		c.Samples = 1
+	case absDuration(delta-c.Skew) <= clockJitter:
+		weight := c.Samples + 1
+		if weight > clockMaxWeight {
+			weight = clockMaxWeight
+		}
+		c.Skew += (delta - c.Skew) / time.Duration(weight)
+		c.Samples++
+		c.Outliers = 0
+	default:
+		c.Outliers++
+		if c.Outliers >= clockOutliersToReset {
+			c.Skew = delta
+			c.Samples = 1
+			c.Outliers = 0
+		}
+	}
+
+	// A clock running ahead is certain after one sample, as a reading can not come from the future.
+	// A clock running late can not be told from a delayed reading until enough samples agree.
+	target := time.Duration(0)
+	if absDuration(c.Skew) >= clockTolerance && (c.Skew > 0 || c.Samples >= clockMinSamples) {
+		target = c.Skew.Round(time.Second)
+	}
+
+	if absDuration(target-c.Correction) >= clockTolerance {
+		c.Correction = target
+	}
+}
+
+// absDuration returns the absolute value of the duration
+// d: duration
+// returns: |d|
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
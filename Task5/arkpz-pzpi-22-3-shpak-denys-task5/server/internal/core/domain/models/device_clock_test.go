@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeviceClockObserve(t *testing.T) {
+	repeat := func(delta time.Duration, n int) []time.Duration {
+		deltas := make([]time.Duration, n)
+		for i := range deltas {
+			deltas[i] = delta
+		}
+		return deltas
+	}
+	concat := func(parts ...[]time.Duration) []time.Duration {
+		deltas := []time.Duration{}
+		for _, part := range parts {
+			deltas = append(deltas, part...)
+		}
+		return deltas
+	}
+
+	tests := []struct {
+		name           string
+		deltas         []time.Duration
+		wantSkew       time.Duration
+		wantCorrection time.Duration
+		wantSamples    int
+	}{
+		{"network latency is not corrected", repeat(2*time.Second, 5), 2 * time.Second, 0, 5},
+		{"clock ahead is corrected after one sample", repeat(3*time.Hour, 1), 3 * time.Hour, 3 * time.Hour, 1},
+		{"clock behind waits for enough samples", repeat(-time.Hour, clockMinSamples-1), -time.Hour, 0, clockMinSamples - 1},
+		{"clock behind is corrected once the samples agree", repeat(-time.Hour, clockMinSamples), -time.Hour, -time.Hour, clockMinSamples},
+		{
+			"running mean of close deltas",
+			[]time.Duration{time.Hour, time.Hour + 2*time.Minute},
+			time.Hour + time.Minute, time.Hour + time.Minute, 2,
+		},
+		{
+			"correction follows the estimate in steps only",
+			[]time.Duration{time.Hour, time.Hour + 90*time.Second},
+			time.Hour + 45*time.Second, time.Hour, 2,
+		},
+		{
+			"backlog sent late is ignored",
+			concat(repeat(3*time.Hour, 4), []time.Duration{-2 * time.Hour}, repeat(3*time.Hour, 1)),
+			3 * time.Hour, 3 * time.Hour, 5,
+		},
+		{
+			"clock reset after consecutive outliers",
+			concat(repeat(3*time.Hour, 4), repeat(0, clockOutliersToReset)),
+			0, 0, 1,
+		},
+		{
+			"weight of the running mean is capped",
+			concat(repeat(0, 20), []time.Duration{4 * time.Minute}),
+			4 * time.Minute / clockMaxWeight, 0, 21,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var clock DeviceClock
+			for _, delta := range tt.deltas {
+				clock.Observe(delta)
+			}
+
+			if clock.Skew != tt.wantSkew || clock.Correction != tt.wantCorrection || clock.Samples != tt.wantSamples {
+				t.Errorf("skew %v, correction %v after %d samples, want %v, %v after %d",
+					clock.Skew, clock.Correction, clock.Samples, tt.wantSkew, tt.wantCorrection, tt.wantSamples)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Values the devices use when neither the company nor the waypoint configure them
+const (
+	DefaultSendDataFrequency = 20   // minutes between two readings sent by the device
+	DefaultGetWeatherAlerts  = true // whether the device analyzes the weather on its own
+)
+
+// DeviceConfig is a struct that represents the configuration of the devices
+// A DeviceConfig without WaypointID holds the defaults of the company,
+// a DeviceConfig with WaypointID overrides the defaults for a single waypoint.
+// Empty fields are inherited from the company defaults.
+type DeviceConfig struct {
+	// ID is the identifier of the device config
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// CompanyID is the identifier of the company the config belongs to
+	// A company has at most one config without WaypointID, its defaults
+	// Example: 1
+	CompanyID uint `gorm:"not null;index;uniqueIndex:idx_device_configs_company_defaults,where:waypoint_id IS NULL;column:company_id"`
+
+	// WaypointID is the identifier of the waypoint the config overrides, nil for company defaults
+	// Example: 1
+	WaypointID *uint `gorm:"uniqueIndex;column:waypoint_id"`
+
+	// SendDataFrequency is the interval in minutes between two readings sent by the device
+	// Example: 20
+	SendDataFrequency *int `gorm:"column:send_data_frequency"`
+
+	// GetWeatherAlerts tells the device whether to analyze the weather on its own
+	// Example: true
+	GetWeatherAlerts *bool `gorm:"column:get_weather_alerts"`
+
+	// Version is incremented every time the config is changed
+	// Example: 3
+	Version uint `gorm:"not null;default:1;column:version"`
+
+	// UpdatedAt is the time of the last change of the config
+	// Example: 2024-12-01T12:00:00Z
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+
+	// Company is the company the config belongs to
+	Company Company `gorm:"foreignKey:CompanyID;constraint:OnDelete:CASCADE;" json:"company,omitempty"`
+
+	// Waypoint is the waypoint the config overrides
+	Waypoint *Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:CASCADE;" json:"waypoint,omitempty"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (d *DeviceConfig) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+// EffectiveDeviceConfig is the configuration a device applies after
+// merging the built-in values, the company defaults and the waypoint override
+type EffectiveDeviceConfig struct {
+	WaypointID        uint   `json:"waypoint_id"`         // waypoint the config is resolved for
+	SendDataFrequency int    `json:"send_data_frequency"` // minutes between two readings
+	GetWeatherAlerts  bool   `json:"get_weather_alerts"`  // whether the device analyzes the weather
+	Version           string `json:"version"`             // changes only when the values change
+}
@@ -0,0 +1,55 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeviceCredential is a struct that represents a secret token issued to the device of a waypoint
+// Only the hash of the token is stored, the token itself is shown once when it is issued.
+type DeviceCredential struct {
+	// ID is the identifier of the credential
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// WaypointID is the identifier of the waypoint the device is bound to
+	// Example: 1
+	WaypointID uint `gorm:"not null;index;column:waypoint_id"`
+
+	// DeviceSerial is the serial number of the device the credential was issued for
+	// Example: device_serial_1
+	DeviceSerial string `gorm:"size:255;not null;column:device_serial"`
+
+	// TokenHash is the SHA-256 hash of the token
+	// Example: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+	TokenHash string `gorm:"size:64;not null;uniqueIndex;column:token_hash" json:"-"`
+
+	// TokenPrefix is the beginning of the token, used to tell credentials apart
+	// Example: wd_3f9a1c
+	TokenPrefix string `gorm:"size:16;not null;column:token_prefix"`
+
+	// CreatedByID is the identifier of the user that issued the credential
+	// Example: 1
+	CreatedByID *uint `gorm:"column:created_by_id"`
+
+	// CreatedAt is the time the credential was issued
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `gorm:"not null;column:created_at"`
+
+	// LastUsedAt is the time the device last authenticated with the credential
+	// Example: 2024-12-01T12:00:00Z
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+
+	// RevokedAt is the time the credential was revoked, nil while it is active
+	// Example: 2024-12-01T12:00:00Z
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+
+	// Waypoint is the waypoint the device is bound to
+	Waypoint Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:CASCADE;" json:"waypoint,omitempty"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (d *DeviceCredential) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Waypoint")
+}
@@ -0,0 +1,10 @@
+package models // import "wayra/internal/core/domain/models"
+
+// IngestStatus is the outcome of storing one reading of a batch
+type IngestStatus string
+
+const (
+	IngestStatusCreated   IngestStatus = "created"   // the reading was stored
+	IngestStatusDuplicate IngestStatus = "duplicate" // a reading with the same waypoint and date is already stored
+	IngestStatusRejected  IngestStatus = "rejected"  // the reading is invalid and was not stored
+)
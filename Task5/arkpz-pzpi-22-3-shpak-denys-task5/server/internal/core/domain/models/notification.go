@@ -0,0 +1,182 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of the notification channels
+const (
+	NotificationChannelWebhook = "webhook" // a signed JSON POST to a URL
+	NotificationChannelEmail   = "email"   // an email sent over SMTP
+)
+
+// Events a notification is sent for
+const (
+	NotificationEventAlertOpened = "alert.opened" // an alert rule raised an alert
+	NotificationEventTest        = "test"         // a user tests the channel
+)
+
+// Statuses of the deliveries of the notifications
+const (
+	NotificationDeliveryPending   = "pending"   // the notification is being sent or waits for a retry
+	NotificationDeliveryDelivered = "delivered" // the notification was accepted by the webhook or the SMTP server
+	NotificationDeliveryFailed    = "failed"    // every attempt failed
+)
+
+// NotificationChannel is a struct that represents a webhook or an email address list of a company
+// the alerts are sent to
+type NotificationChannel struct {
+	// ID is the identifier of the channel
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// CompanyID is the identifier of the company the channel belongs to
+	// Example: 1
+	CompanyID uint `gorm:"index;not null;column:company_id"`
+
+	// Name is the name of the channel
+	// Example: Dispatch on-call
+	Name string `gorm:"size:100;not null;column:name"`
+
+	// Kind is the kind of the channel: webhook or email
+	// Example: webhook
+	Kind string `gorm:"size:20;not null;column:kind"`
+
+	// Enabled tells if notifications are sent to the channel
+	// Example: true
+	Enabled bool `gorm:"not null;column:enabled"`
+
+	// URL is the URL the webhook notifications are posted to
+	// Example: https://hooks.example.com/wayra
+	URL string `gorm:"size:2048;column:url"`
+
+	// Secret is the key the webhook notifications are signed with
+	// Example: whsec_3f1c...
+	Secret string `gorm:"size:100;column:secret" json:"-"`
+
+	// Recipients are the comma-separated addresses the email notifications are sent to
+	// Example: dispatch@example.com, ops@example.com
+	Recipients string `gorm:"type:text;column:recipients"`
+
+	// MinSeverity is the lowest severity of the alerts sent to the channel: info, warning or critical
+	// Example: warning
+	MinSeverity string `gorm:"size:20;not null;column:min_severity"`
+
+	// AllRoutes tells if the alerts of every route are sent, otherwise only the ones of Routes are
+	// Example: true
+	AllRoutes bool `gorm:"not null;column:all_routes"`
+
+	// SubjectTemplate is the text/template of the subject, the default one when empty
+	// Example: [{{.Severity}}] {{.Type}} on {{.RouteName}}
+	SubjectTemplate string `gorm:"type:text;column:subject_template"`
+
+	// BodyTemplate is the text/template of the body, the default one when empty
+	// Example: {{.Message}} {{.Details}}
+	BodyTemplate string `gorm:"type:text;column:body_template"`
+
+	// CreatedAt is the time the channel was added
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `gorm:"column:created_at"`
+
+	// UpdatedAt is the time the channel was last changed
+	// Example: 2024-12-01T12:00:00Z
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+
+	// Routes are the routes whose alerts are sent, unless AllRoutes is set
+	Routes []Route `gorm:"many2many:notification_channel_routes;joinForeignKey:ChannelID;constraint:OnDelete:CASCADE;" json:"-"`
+
+	// Company is the relation with the company table
+	Company Company `gorm:"foreignKey:CompanyID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (c *NotificationChannel) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Routes", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	})
+}
+
+// NotificationDelivery is a struct that represents the sending of a notification to a channel
+type NotificationDelivery struct {
+	// ID is the identifier of the delivery
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// ChannelID is the identifier of the channel the notification is sent to
+	// Example: 1
+	ChannelID uint `gorm:"index;not null;column:channel_id"`
+
+	// AlertID is the identifier of the alert the notification tells about, nil for tests
+	// Example: 1
+	AlertID *uint `gorm:"index;column:alert_id"`
+
+	// Event is the event the notification is sent for: alert.opened or test
+	// Example: alert.opened
+	Event string `gorm:"size:50;not null;column:event"`
+
+	// Status is the status of the delivery: pending, delivered or failed
+	// Example: delivered
+	Status string `gorm:"size:20;not null;column:status"`
+
+	// Attempts is the number of times the notification was sent
+	// Example: 2
+	Attempts int `gorm:"not null;column:attempts"`
+
+	// ResponseStatus is the HTTP status the webhook last answered with, 0 for emails
+	// Example: 200
+	ResponseStatus int `gorm:"column:response_status"`
+
+	// LastError is the error of the last failed attempt
+	// Example: webhook answered 503 Service Unavailable
+	LastError string `gorm:"type:text;column:last_error"`
+
+	// CreatedAt is the time the notification was queued
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `gorm:"not null;index;column:created_at"`
+
+	// DeliveredAt is the time the notification was accepted, nil until then
+	// Example: 2024-12-01T12:00:01Z
+	DeliveredAt *time.Time `gorm:"column:delivered_at"`
+
+	// Channel is the relation with the notification channel table
+	Channel NotificationChannel `gorm:"foreignKey:ChannelID;constraint:OnDelete:CASCADE;" json:"-"`
+
+	// Alert is the relation with the alert table
+	Alert *Alert `gorm:"foreignKey:AlertID;constraint:OnDelete:SET NULL;" json:"-"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (d *NotificationDelivery) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+// Notification is what is sent to a channel, rendered from an alert
+// The webhooks receive it as JSON, the emails are made of its subject and text.
+type Notification struct {
+	Event        string    `json:"event"`                   // event the notification is sent for
+	DeliveryID   uint      `json:"delivery_id"`             // delivery of the notification, the same for every retry
+	AlertID      uint      `json:"alert_id,omitempty"`      // alert the notification tells about
+	Type         string    `json:"type"`                    // type of the alert
+	Message      string    `json:"message"`                 // message of the alert
+	Details      string    `json:"details"`                 // metrics that raised the alert
+	Severity     string    `json:"severity"`                // severity of the alert
+	CompanyID    uint      `json:"company_id"`              // company of the route
+	RouteID      uint      `json:"route_id,omitempty"`      // route the alert was raised for
+	RouteName    string    `json:"route_name,omitempty"`    // name of the route
+	WaypointID   uint      `json:"waypoint_id,omitempty"`   // waypoint the conditions hold at
+	WaypointName string    `json:"waypoint_name,omitempty"` // name of the waypoint
+	OpenedAt     time.Time `json:"opened_at"`               // time the alert was raised
+	Subject      string    `json:"subject"`                 // subject rendered from the template of the channel
+	Text         string    `json:"text"`                    // body rendered from the template of the channel
+}
+
+// NotificationRetryPolicy tells how often and how fast a failed notification is sent again
+// The delay doubles after every attempt, from InitialBackoff up to MaxBackoff.
+type NotificationRetryPolicy struct {
+	MaxAttempts    int           // attempts before the delivery fails, at least 1
+	InitialBackoff time.Duration // delay before the second attempt
+	MaxBackoff     time.Duration // longest delay between two attempts
+	Timeout        time.Duration // time an attempt may take
+}
@@ -1,6 +1,10 @@
 package models // import "wayra/internal/core/domain/models"
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Route is a model that represents a route on the delivery way
 type Route struct {
@@ -16,6 +20,14 @@ type Route struct {
 	// Example: 1
 	CompanyID uint `gorm:"not null;column:company_id"`
 
+	// Status is the current weather condition of the route
+	// Example: ok
+	Status string `gorm:"size:50;not null;default:'ok';column:status"`
+
+	// StatusChangedAt is the time the condition was last reported
+	// Example: 2024-12-01T12:00:00Z
+	StatusChangedAt *time.Time `gorm:"column:status_changed_at"`
+
 	// Company is the company that the route belongs to
 	Company Company `gorm:"foreignKey:CompanyID" json:"company,omitempty"`
 
@@ -25,5 +37,5 @@ type Route struct {
 
 // LoadRelations is an implementation of the LoadRelations method from the model interface
 func (r *Route) LoadRelations(db *gorm.DB) *gorm.DB {
-	return db.Preload("Company").Preload("Company.Creator").Preload("Waypoints").Preload("Waypoints.SensorData")
+	return db.Preload("Company").Preload("Company.Creator").Preload("Waypoints")
 }
@@ -6,15 +6,39 @@ import (
 	"gorm.io/gorm"
 )
 
+// Flags of the timestamp of a reading
+const (
+	TimestampFlagServerTime = "server_time"  // the device sent no date, the receive time is used
+	TimestampFlagFuture     = "future"       // the date was too far in the future, the receive time is used
+	TimestampFlagAncient    = "ancient"      // the date was before any valid reading, the receive time is used
+	TimestampFlagOutOfOrder = "out_of_order" // the reading is older than a reading received before it
+)
+
 // SensorData is a struct that represents the sensor_data table in the database
 type SensorData struct {
 	// ID is the primary key of the table
 	// Example: 1
 	ID uint `gorm:"primaryKey;column:id"`
 
-	// Date is the date when the data was recorded
+	// Date is the date when the data was recorded, in UTC and corrected for the clock skew of the device
 	// Example: 2021-08-01 12:00:00
-	Date time.Time `gorm:"type:timestamp;not null;column:date"`
+	Date time.Time `gorm:"type:timestamptz;not null;column:date;index:idx_sensor_data_waypoint_date_unique,unique,priority:2"`
+
+	// DeviceDate is the date as sent by the device, nil when it sent none
+	// Example: 2021-08-01 15:00:00
+	DeviceDate *time.Time `gorm:"type:timestamptz;column:device_date"`
+
+	// ReceivedAt is the time the server received the data, nil for data stored before it was tracked
+	// Example: 2021-08-01 12:00:03
+	ReceivedAt *time.Time `gorm:"type:timestamptz;column:received_at"`
+
+	// TimestampFlag tells why the date can not be trusted, empty when it can
+	// Example: out_of_order
+	TimestampFlag string `gorm:"size:20;not null;default:'';column:timestamp_flag"`
+
+	// QualityFlags holds the data-quality problems found in the reading, suspect readings are left out of the analytics
+	// Example: ["outlier"]
+	QualityFlags QualityFlags `gorm:"type:smallint;not null;default:0;column:quality_flags"`
 
 	// Temperature is the temperature recorded by the sensor
 	// Example: 25.5
@@ -32,12 +56,35 @@ type SensorData struct {
 	// Example: 1013.25
 	MeanPressure float64 `gorm:"not null;column:mean_pressure"`
 
+	// RawTemperature is the temperature before the calibration, nil when no calibration applies
+	// Example: 26.1
+	RawTemperature *float64 `gorm:"column:raw_temperature"`
+
+	// RawHumidity is the humidity before the calibration, nil when no calibration applies
+	// Example: 0.55
+	RawHumidity *float64 `gorm:"column:raw_humidity"`
+
+	// RawWindSpeed is the wind speed before the calibration, nil when no calibration applies
+	// Example: 10.2
+	RawWindSpeed *float64 `gorm:"column:raw_wind_speed"`
+
+	// RawMeanPressure is the mean pressure before the calibration, nil when no calibration applies
+	// Example: 1012.8
+	RawMeanPressure *float64 `gorm:"column:raw_mean_pressure"`
+
 	// WaypointID is the foreign key of the waypoint table
 	// Example: 1
-	WaypointID uint `gorm:"not null;column:waypoint_id"`
+	WaypointID uint `gorm:"not null;column:waypoint_id;index:idx_sensor_data_waypoint_date_unique,unique,priority:1"`
+
+	// DeviceID is the foreign key of the device that recorded the data, nil when the device is not registered
+	// Example: 1
+	DeviceID *uint `gorm:"index;column:device_id"`
 
 	// Waypoint is the relation with the waypoint table
 	Waypoint Waypoint `gorm:"foreignKey:WaypointID" json:"device,omitempty"`
+
+	// Device is the relation with the device table
+	Device *Device `gorm:"foreignKey:DeviceID;constraint:OnDelete:SET NULL;" json:"-"`
 }
 
 // LoadRelations is an implementation of the interface for the gorm library
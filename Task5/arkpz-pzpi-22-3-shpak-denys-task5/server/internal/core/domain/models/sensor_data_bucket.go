@@ -0,0 +1,32 @@
+package models // import "wayra/internal/core/domain/models"
+
+import "time"
+
+// MetricAggregate holds the aggregates of one measured metric over a bucket
+type MetricAggregate struct {
+	Min float64 `json:"min"` // lowest value
+	Max float64 `json:"max"` // highest value
+	Avg float64 `json:"avg"` // arithmetic mean of the values
+}
+
+// SensorDataBucket holds the aggregates of the sensor readings recorded in a time bucket
+type SensorDataBucket struct {
+	WaypointID   *uint            `json:"waypoint_id,omitempty"` // waypoint the readings belong to, nil when aggregated over a route
+	Start        time.Time        `json:"start"`                 // start of the bucket, aligned to the bucket size in UTC
+	Count        int              `json:"count"`                 // number of readings
+	Temperature  MetricAggregate  `json:"temperature"`           // aggregates of the temperature
+	Humidity     MetricAggregate  `json:"humidity"`              // aggregates of the humidity
+	WindSpeed    MetricAggregate  `json:"wind_speed"`            // aggregates of the wind speed
+	MeanPressure MetricAggregate  `json:"mean_pressure"`         // aggregates of the pressure
+	DewPoint     *MetricAggregate `json:"dew_point,omitempty"`   // aggregates of the dew point, nil for rollups without it
+	HeatIndex    *MetricAggregate `json:"heat_index,omitempty"`  // aggregates of the heat index, nil for rollups without it
+	WindChill    *MetricAggregate `json:"wind_chill,omitempty"`  // aggregates of the wind chill, nil for rollups without it
+}
+
+// BucketQuery selects the readings recorded in [From, To) and the size of the buckets they are grouped in
+type BucketQuery struct {
+	From           time.Time     // start of the range, inclusive
+	To             time.Time     // end of the range, exclusive
+	Bucket         time.Duration // size of a bucket
+	IncludeFlagged bool          // whether the readings flagged as suspect are aggregated too
+}
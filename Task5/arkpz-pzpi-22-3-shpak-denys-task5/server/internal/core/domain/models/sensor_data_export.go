@@ -0,0 +1,31 @@
+package models // import "wayra/internal/core/domain/models"
+
+import "time"
+
+// SensorDataExportRow is a reading as exported, with the waypoint it was recorded at
+type SensorDataExportRow struct {
+	ID            uint         `json:"id"`                       // identifier of the reading
+	Date          time.Time    `json:"date"`                     // date the reading was recorded, in UTC
+	RouteID       uint         `json:"route_id"`                 // route of the waypoint
+	WaypointID    uint         `json:"waypoint_id"`              // waypoint the reading was recorded at
+	WaypointName  string       `json:"waypoint_name"`            // name of the waypoint
+	Latitude      float64      `json:"latitude"`                 // latitude of the waypoint
+	Longitude     float64      `json:"longitude"`                // longitude of the waypoint
+	Temperature   float64      `json:"temperature"`              // temperature in °C
+	Humidity      float64      `json:"humidity"`                 // humidity in %
+	WindSpeed     float64      `json:"wind_speed"`               // wind speed in m/s
+	MeanPressure  float64      `json:"mean_pressure"`            // pressure in hPa
+	DeviceID      *uint        `json:"device_id"`                // device that recorded the reading, nil when not registered
+	TimestampFlag string       `json:"timestamp_flag,omitempty"` // why the date can not be trusted, empty when it can
+	QualityFlags  QualityFlags `json:"quality_flags,omitempty"`  // data-quality problems found in the reading
+}
+
+// SensorDataExportQuery selects the readings to export: the readings of one waypoint, route or company
+// recorded in [From, To). Exactly one of the scope IDs is set.
+type SensorDataExportQuery struct {
+	WaypointID uint      // waypoint to export the readings of
+	RouteID    uint      // route to export the readings of
+	CompanyID  uint      // company to export the readings of
+	From       time.Time // start of the range, inclusive
+	To         time.Time // end of the range, exclusive
+}
@@ -0,0 +1,60 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// QualityFlags is the set of data-quality problems found in a reading, stored as a bit mask
+type QualityFlags uint8
+
+// Data-quality flags of a reading
+const (
+	QualityFlagOutlier QualityFlags = 1 << iota // a measurement is far from the recent readings of the waypoint
+	QualityFlagSpike                            // a measurement jumps away from the previous reading and back at the next one
+	QualityFlagStuck                            // every measurement repeats the previous readings, the sensor is frozen
+	QualityFlagGap                              // the reading follows a gap longer than the expected interval of the device
+
+	// QualityFlagsSuspect are the flags of readings that are left out of the analytics and the alerts by default
+	// A reading after a gap is valid, the gap only tells that readings are missing before it.
+	QualityFlagsSuspect = QualityFlagOutlier | QualityFlagSpike | QualityFlagStuck
+)
+
+// qualityFlagNames are the names of the flags, in the order of their bits
+var qualityFlagNames = []string{"outlier", "spike", "stuck", "gap"}
+
+// Names returns the names of the flags that are set
+// returns: the names in the order of the bits, empty when no flag is set
+func (f QualityFlags) Names() []string {
+	names := []string{}
+	for i, name := range qualityFlagNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Suspect checks if the reading should be left out of the analytics and the alerts
+// returns: true if a suspect flag is set
+func (f QualityFlags) Suspect() bool {
+	return f&QualityFlagsSuspect != 0
+}
+
+// String joins the names of the flags that are set with commas
+func (f QualityFlags) String() string {
+	return strings.Join(f.Names(), ",")
+}
+
+// MarshalJSON encodes the flags as the list of their names
+func (f QualityFlags) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Names())
+}
+
+// QualityAssessment is the outcome of a data-quality pass over the readings of a waypoint
+type QualityAssessment struct {
+	Assessed int `json:"assessed"` // readings whose flags were computed
+	Flagged  int `json:"flagged"`  // assessed readings with at least one flag
+	Suspect  int `json:"suspect"`  // assessed readings left out of the analytics and the alerts
+	Changed  int `json:"changed"`  // assessed readings whose stored flags were updated
+}
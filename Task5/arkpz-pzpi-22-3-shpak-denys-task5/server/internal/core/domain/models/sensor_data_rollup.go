@@ -0,0 +1,95 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Sizes of the buckets of the rollups
+const (
+	HourlyRollupSize = time.Hour
+	DailyRollupSize  = 24 * time.Hour
+)
+
+// SensorDataRollup holds the aggregates of the readings of a waypoint recorded in a time bucket
+// Raw readings are rolled up into the hourly and daily tables before they are purged.
+type SensorDataRollup struct {
+	// WaypointID is the identifier of the waypoint the readings belong to
+	// Example: 1
+	WaypointID uint `gorm:"primaryKey;autoIncrement:false;column:waypoint_id"`
+
+	// Start is the start of the bucket, aligned to the bucket size in UTC
+	// Example: 2024-12-01T12:00:00Z
+	Start time.Time `gorm:"primaryKey;type:timestamptz;column:bucket_start"`
+
+	// Count is the number of readings rolled up into the bucket
+	// Example: 12
+	Count int `gorm:"not null;column:count"`
+
+	// Temperature holds the aggregates of the temperature
+	Temperature MetricAggregate `gorm:"embedded;embeddedPrefix:temperature_"`
+
+	// Humidity holds the aggregates of the humidity
+	Humidity MetricAggregate `gorm:"embedded;embeddedPrefix:humidity_"`
+
+	// WindSpeed holds the aggregates of the wind speed
+	WindSpeed MetricAggregate `gorm:"embedded;embeddedPrefix:wind_speed_"`
+
+	// MeanPressure holds the aggregates of the pressure
+	MeanPressure MetricAggregate `gorm:"embedded;embeddedPrefix:mean_pressure_"`
+
+	// DewPoint holds the aggregates of the dew point, nil for buckets rolled up before it was derived
+	DewPoint *MetricAggregate `gorm:"embedded;embeddedPrefix:dew_point_"`
+
+	// HeatIndex holds the aggregates of the heat index, nil for buckets rolled up before it was derived
+	HeatIndex *MetricAggregate `gorm:"embedded;embeddedPrefix:heat_index_"`
+
+	// WindChill holds the aggregates of the wind chill, nil for buckets rolled up before it was derived
+	WindChill *MetricAggregate `gorm:"embedded;embeddedPrefix:wind_chill_"`
+}
+
+// HourlySensorData is a struct that represents the sensor_data_hourly table in the database
+type HourlySensorData struct {
+	// SensorDataRollup holds the bucket and its aggregates
+	SensorDataRollup
+
+	// Waypoint is the relation with the waypoint table
+	Waypoint Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// TableName is an implementation of the Tabler interface for the gorm library
+func (HourlySensorData) TableName() string {
+	return "sensor_data_hourly"
+}
+
+// LoadRelations is an implementation of the interface for the gorm library
+func (h *HourlySensorData) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+// DailySensorData is a struct that represents the sensor_data_daily table in the database
+type DailySensorData struct {
+	// SensorDataRollup holds the bucket and its aggregates
+	SensorDataRollup
+
+	// Waypoint is the relation with the waypoint table
+	Waypoint Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// TableName is an implementation of the Tabler interface for the gorm library
+func (DailySensorData) TableName() string {
+	return "sensor_data_daily"
+}
+
+// LoadRelations is an implementation of the interface for the gorm library
+func (d *DailySensorData) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+// RetentionPolicy tells how long the readings of a company are kept at every resolution
+// Daily rollups are kept forever.
+type RetentionPolicy struct {
+	RawAge    time.Duration // raw readings older than this are rolled up and purged
+	HourlyAge time.Duration // hourly rollups older than this are purged, 0 keeps them
+}
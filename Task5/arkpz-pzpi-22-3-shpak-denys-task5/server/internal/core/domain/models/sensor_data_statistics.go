@@ -0,0 +1,18 @@
+package models // import "wayra/internal/core/domain/models"
+
+// MetricStatistics holds the summary statistics of one measured metric
+type MetricStatistics struct {
+	Mean                   float64 `json:"mean"`                     // arithmetic mean of the values
+	StdDev                 float64 `json:"stddev"`                   // population standard deviation of the values
+	CoefficientOfVariation float64 `json:"coefficient_of_variation"` // StdDev divided by Mean, 0 when Mean is 0
+}
+
+// SensorDataStatistics holds the summary statistics of a set of sensor readings
+type SensorDataStatistics struct {
+	WaypointID   *uint            `json:"waypoint_id,omitempty"` // waypoint the readings belong to, nil for the whole route
+	Count        int              `json:"count"`                 // number of readings
+	Temperature  MetricStatistics `json:"temperature"`           // statistics of the temperature
+	Humidity     MetricStatistics `json:"humidity"`              // statistics of the humidity
+	WindSpeed    MetricStatistics `json:"wind_speed"`            // statistics of the wind speed
+	MeanPressure MetricStatistics `json:"mean_pressure"`         // statistics of the pressure
+}
@@ -0,0 +1,97 @@
+package models // import "wayra/internal/core/domain/models"
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The status of a waypoint is one of these constants
+const (
+	WaypointStatusOK              = "ok"
+	WaypointStatusAnomalyDetected = "anomaly_detected"
+)
+
+// The condition of a route is one of these constants
+const (
+	RouteConditionOK                 = "ok"
+	RouteConditionBadWeatherDetected = "bad_weather_detected"
+)
+
+// WaypointStatusEvent is a struct that represents a change of the health of a waypoint's device
+type WaypointStatusEvent struct {
+	// ID is the identifier of the event
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// WaypointID is the identifier of the waypoint the event belongs to
+	// Example: 1
+	WaypointID uint `gorm:"not null;index;column:waypoint_id"`
+
+	// Status is the status reported for the waypoint
+	// Example: anomaly_detected
+	Status string `gorm:"size:50;not null;column:status"`
+
+	// Details is the explanation sent together with the status
+	// Example: Device CV exceeds normal values. Possible sensor malfunction.
+	Details string `gorm:"type:text;column:details"`
+
+	// SourceDevice is the serial number of the device that reported the status
+	// Example: device_serial_1
+	SourceDevice string `gorm:"size:255;column:source_device"`
+
+	// ReportedByID is the identifier of the user that reported the status
+	// Example: 1
+	ReportedByID *uint `gorm:"column:reported_by_id"`
+
+	// CreatedAt is the time the status was reported
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `gorm:"not null;index;column:created_at"`
+
+	// Waypoint is the waypoint the event belongs to
+	Waypoint Waypoint `gorm:"foreignKey:WaypointID;constraint:OnDelete:CASCADE;" json:"waypoint,omitempty"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (e *WaypointStatusEvent) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+// RouteConditionEvent is a struct that represents a change of the weather condition of a route
+type RouteConditionEvent struct {
+	// ID is the identifier of the event
+	// Example: 1
+	ID uint `gorm:"primaryKey;column:id"`
+
+	// RouteID is the identifier of the route the event belongs to
+	// Example: 1
+	RouteID uint `gorm:"not null;index;column:route_id"`
+
+	// Status is the condition reported for the route
+	// Example: bad_weather_detected
+	Status string `gorm:"size:50;not null;column:status"`
+
+	// Details is the explanation sent together with the condition
+	// Example: CV indicates abnormal weather conditions across multiple waypoints.
+	Details string `gorm:"type:text;column:details"`
+
+	// SourceDevice is the serial number of the device that reported the condition
+	// Example: device_serial_1
+	SourceDevice string `gorm:"size:255;column:source_device"`
+
+	// ReportedByID is the identifier of the user that reported the condition
+	// Example: 1
+	ReportedByID *uint `gorm:"column:reported_by_id"`
+
+	// CreatedAt is the time the condition was reported
+	// Example: 2024-12-01T12:00:00Z
+	CreatedAt time.Time `gorm:"not null;index;column:created_at"`
+
+	// Route is the route the event belongs to
+	Route Route `gorm:"foreignKey:RouteID;constraint:OnDelete:CASCADE;" json:"route,omitempty"`
+}
+
+// LoadRelations is an implementation of the LoadRelations interface
+func (e *RouteConditionEvent) LoadRelations(db *gorm.DB) *gorm.DB {
+	return db
+}
@@ -0,0 +1,55 @@
+package models // import "wayra/internal/core/domain/models"
+
+import "time"
+
+// StreamEventType is the kind of change a stream event tells about
+type StreamEventType string
+
+// Types of the stream events
+const (
+	StreamEventSensorData     StreamEventType = "sensor_data"     // a reading was ingested, Data is a SensorData
+	StreamEventWaypointStatus StreamEventType = "waypoint_status" // a status was reported for a waypoint, Data is a WaypointStatusEvent
+	StreamEventRouteCondition StreamEventType = "route_condition" // a condition was reported for a route, Data is a RouteConditionEvent
+	StreamEventConnectivity   StreamEventType = "connectivity"    // a device went offline or came back online, Data is a ConnectivityChange
+	StreamEventWeatherAlert   StreamEventType = "weather_alert"   // the weather alerts of a route changed, Data is a []WeatherAlert
+	StreamEventAlert          StreamEventType = "alert"           // an alert was opened, acknowledged or resolved, Data is an Alert
+)
+
+// StreamEvent is a change published to the subscribers of a company, route or waypoint
+type StreamEvent struct {
+	Type       StreamEventType // kind of change
+	CompanyID  uint            // company the change belongs to
+	RouteID    uint            // route the change belongs to
+	WaypointID uint            // waypoint the change belongs to, 0 for changes of a whole route
+	Date       time.Time       // time of the change
+	Data       interface{}     // the change, depending on the type
+}
+
+// StreamScope selects the events a subscriber receives, the most specific ID that is set wins
+// A waypoint scope also receives the events of its route as a whole, like its weather alerts,
+// so its RouteID has to be set as well. The CompanyID is always set.
+type StreamScope struct {
+	CompanyID  uint // every event of the company, when neither a route nor a waypoint is set
+	RouteID    uint // every event of the route and its waypoints
+	WaypointID uint // every event of the waypoint
+}
+
+// Matches checks if an event is in the scope
+// event: event to check
+// returns: true if the subscriber receives the event
+func (s StreamScope) Matches(event StreamEvent) bool {
+	switch {
+	case s.WaypointID != 0:
+		return event.WaypointID == s.WaypointID || (event.WaypointID == 0 && event.RouteID == s.RouteID)
+	case s.RouteID != 0:
+		return event.RouteID == s.RouteID
+	default:
+		return event.CompanyID == s.CompanyID
+	}
+}
+
+// ConnectivityChange tells that the device of a waypoint changed its connectivity
+type ConnectivityChange struct {
+	Connectivity string     `json:"connectivity"`           // new connectivity of the device
+	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"` // time the device was last seen
+}
@@ -1,6 +1,17 @@
 package models // import "wayra/internal/core/domain/models"
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Connectivity of the device of a waypoint
+const (
+	ConnectivityUnknown = "unknown" // the device has never reported
+	ConnectivityOnline  = "online"  // the device reports within its expected interval
+	ConnectivityOffline = "offline" // the device missed its expected reports
+)
 
 // Waypoint is a struct that represents the Waypoint model of the database
 type Waypoint struct {
@@ -13,8 +24,9 @@ type Waypoint struct {
 	Name string `gorm:"size:255;not null;column:name"`
 
 	// DeviceSerial is the serial number of the device that sent the waypoint
+	// A serial can be bound to only one waypoint at a time, waypoints without a device have an empty serial
 	// Example: 123456789
-	DeviceSerial string `gorm:"size:255;not null;column:device_serial"`
+	DeviceSerial string `gorm:"size:255;not null;index:idx_waypoints_device_serial_unique,unique,where:device_serial <> '';column:device_serial"`
 
 	// Latitude is the latitude of the waypoint
 	// Example: -12.04318
@@ -28,6 +40,22 @@ type Waypoint struct {
 	// Example: 0
 	RouteID uint `gorm:"not null;column:route_id"`
 
+	// Status is the current health of the waypoint's device
+	// Example: ok
+	Status string `gorm:"size:50;not null;default:'ok';column:status"`
+
+	// StatusChangedAt is the time the status was last reported
+	// Example: 2024-12-01T12:00:00Z
+	StatusChangedAt *time.Time `gorm:"column:status_changed_at"`
+
+	// Connectivity tells whether the device of the waypoint is still reporting
+	// Example: online
+	Connectivity string `gorm:"size:20;not null;default:'unknown';column:connectivity"`
+
+	// LastSeenAt is the time the device of the waypoint last sent data or a heartbeat
+	// Example: 2024-12-01T12:00:00Z
+	LastSeenAt *time.Time `gorm:"column:last_seen_at"`
+
 	// Route is the route to which the waypoint belongs
 	Route Route `gorm:"foreignKey:RouteID" json:"route,omitempty"`
 
@@ -37,7 +65,6 @@ type Waypoint struct {
 
 // LoadRelations is an implementation of the LoadRelations interface
 func (w *Waypoint) LoadRelations(db *gorm.DB) *gorm.DB {
-	withRoute := db.Preload("Route").Preload("Route.Company").Preload("Route.Company.Creator")
-	withSensorData := withRoute.Preload("SensorData")
-	return withSensorData
+	// The sensor data is not preloaded, it is queried by time range instead
+	return db.Preload("Route").Preload("Route.Company").Preload("Route.Company.Creator")
 }
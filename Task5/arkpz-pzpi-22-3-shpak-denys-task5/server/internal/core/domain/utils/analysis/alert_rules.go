@@ -0,0 +1,284 @@
+package analysis // import "wayra/internal/core/domain/utils/analysis"
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"wayra/internal/core/domain/models"
+	utilsMeteo "wayra/internal/core/domain/utils/meteo"
+)
+
+// alertMetric tells how a metric of a reading is computed and shown in the details of an alert
+type alertMetric struct {
+	label string                          // name of the metric in the details
+	unit  string                          // unit appended to the value in the details
+	value func(models.SensorData) float64 // value of the metric for a reading
+}
+
+// alertMetrics are the metrics the alert rules may check
+var alertMetrics = map[string]alertMetric{
+	models.MetricTemperature: {"Temperature", "°C", func(r models.SensorData) float64 {
+		return r.Temperature
+	}},
+	models.MetricHumidity: {"Humidity", "%", func(r models.SensorData) float64 {
+		return r.Humidity
+	}},
+	models.MetricWindSpeed: {"Wind Speed", " m/s", func(r models.SensorData) float64 {
+		return r.WindSpeed
+	}},
+	models.MetricMeanPressure: {"Pressure", " hPa", func(r models.SensorData) float64 {
+		return r.MeanPressure
+	}},
+	models.MetricDewPoint: {"Dew Point", "°C", func(r models.SensorData) float64 {
+		return utilsMeteo.DewPoint(r.Temperature, r.Humidity)
+	}},
+	models.MetricHeatIndex: {"Heat Index", "°C", func(r models.SensorData) float64 {
+		return utilsMeteo.HeatIndex(r.Temperature, r.Humidity)
+	}},
+	models.MetricWindChill: {"Wind Chill", "°C", func(r models.SensorData) float64 {
+		return utilsMeteo.WindChill(r.Temperature, r.WindSpeed)
+	}},
+	models.MetricDewPointSpread: {"Dew Point Spread", "°C", func(r models.SensorData) float64 {
+		return r.Temperature - utilsMeteo.DewPoint(r.Temperature, r.Humidity)
+	}},
+}
+
+// IsAlertMetric checks if an alert rule may check a metric
+// metric: name of the metric
+// returns: true for the measured metrics and the derived ones
+func IsAlertMetric(metric string) bool {
+	_, ok := alertMetrics[metric]
+	return ok
+}
+
+// DefaultAlertRules returns the rules every company starts with
+// They raise the alerts that were built into the server before the rules could be configured,
+// the icing risk rule based on the dew point is added disabled, for the companies to opt in.
+// returns: the rules without a company
+func DefaultAlertRules() []models.AlertRule {
+	rule := func(
+		alertType, message, severity, logic string,
+		conditions ...models.AlertCondition,
+	) models.AlertRule {
+		return models.AlertRule{
+			Type:       alertType,
+			Message:    message,
+			Severity:   severity,
+			Logic:      logic,
+			Enabled:    true,
+			Conditions: conditions,
+		}
+	}
+	condition := func(metric, operator string, threshold float64) models.AlertCondition {
+		return models.AlertCondition{Metric: metric, Operator: operator, Threshold: threshold}
+	}
+
+	icingRisk := rule("Icing Risk Alert",
+		"Potential ice formation detected, the air is freezing and close to its dew point.",
+		models.AlertSeverityWarning, models.AlertLogicAnd,
+		condition(models.MetricTemperature, models.AlertOperatorLessOrEqual, utilsMeteo.IcingMaxTemperature),
+		condition(models.MetricDewPointSpread, models.AlertOperatorLessOrEqual, utilsMeteo.IcingDewPointSpread),
+	)
+	icingRisk.Enabled = false
+
+	return []models.AlertRule{
+		rule("Ice Alert",
+			"Potential ice formation detected due to low temperature and high humidity.",
+			models.AlertSeverityWarning, models.AlertLogicAnd,
+			condition(models.MetricTemperature, models.AlertOperatorLessThan, 0),
+			condition(models.MetricHumidity, models.AlertOperatorGreaterThan, 80),
+		),
+		rule("Storm Alert",
+			"High wind speed detected, potential storm risk.",
+			models.AlertSeverityWarning, models.AlertLogicAnd,
+			condition(models.MetricWindSpeed, models.AlertOperatorGreaterThan, 20),
+		),
+		rule("Low Pressure Alert",
+			"Low atmospheric pressure detected, potential severe weather conditions.",
+			models.AlertSeverityWarning, models.AlertLogicAnd,
+			condition(models.MetricMeanPressure, models.AlertOperatorLessThan, 980),
+		),
+		rule("Heat Alert",
+			"High temperature detected, risk of heat-related issues.",
+			models.AlertSeverityWarning, models.AlertLogicAnd,
+			condition(models.MetricTemperature, models.AlertOperatorGreaterThan, 35),
+		),
+		rule("Low Humidity Alert",
+			"Low humidity detected, risk of dry conditions.",
+			models.AlertSeverityInfo, models.AlertLogicAnd,
+			condition(models.MetricHumidity, models.AlertOperatorLessThan, 20),
+		),
+		rule("Cold Storm Alert",
+			"High wind speed combined with low temperature detected, risk of severe cold storm.",
+			models.AlertSeverityCritical, models.AlertLogicAnd,
+			condition(models.MetricWindSpeed, models.AlertOperatorGreaterThan, 30),
+			condition(models.MetricTemperature, models.AlertOperatorLessThan, 5),
+		),
+		icingRisk,
+	}
+}
+
+// MatchesAlertRule checks if a reading meets the conditions of a rule, regardless of the duration
+// rule: rule to check, with its conditions
+// reading: reading to check
+// returns: true if every condition holds for an and rule, or one of them for an or rule
+func MatchesAlertRule(rule models.AlertRule, reading models.SensorData) bool {
+	if len(rule.Conditions) == 0 {
+		return false
+	}
+
+	for _, condition := range rule.Conditions {
+		holds := matchesAlertCondition(condition, reading)
+		if rule.Logic == models.AlertLogicOr && holds {
+			return true
+		}
+		if rule.Logic != models.AlertLogicOr && !holds {
+			return false
+		}
+	}
+
+	return rule.Logic != models.AlertLogicOr
+}
+
+// RaisedAlert is an alert rule whose conditions hold at a waypoint
+type RaisedAlert struct {
+	Rule       models.AlertRule    // rule that raised the alert, with its conditions
+	WaypointID uint                // waypoint the conditions hold at
+	Readings   []models.SensorData // readings the conditions hold for, ordered by date, the latest one last
+}
+
+// EvaluateAlertRule checks if a rule raises its alert for the readings of a waypoint
+// The conditions must hold for the latest reading and for every reading before it back to one recorded
+// at least Duration minutes earlier, so a rule with a duration needs a reading that old to raise the alert.
+// rule: rule to evaluate, with its conditions
+// readings: readings of one waypoint ordered by date
+// returns: the readings the conditions hold for, from the earliest one needed to the latest one, and true
+// if the alert is raised
+func EvaluateAlertRule(rule models.AlertRule, readings []models.SensorData) ([]models.SensorData, bool) {
+	if !rule.Enabled || len(readings) == 0 {
+		return nil, false
+	}
+
+	latest := readings[len(readings)-1]
+	since := latest.Date.Add(-time.Duration(rule.Duration) * time.Minute)
+	for i := len(readings) - 1; i >= 0; i-- {
+		if !MatchesAlertRule(rule, readings[i]) {
+			return nil, false
+		}
+		if !readings[i].Date.After(since) {
+			return readings[i:], true
+		}
+	}
+
+	return nil, false
+}
+
+// AlertDetails formats the metrics a rule checks for the reading that raised its alert
+// rule: rule that raised the alert
+// reading: reading that raised the alert
+// returns: the details, e.g. Wind Speed: 25.00 m/s, Temperature: 2.00°C
+func AlertDetails(rule models.AlertRule, reading models.SensorData) string {
+	details := []string{}
+	seen := make(map[string]bool, len(rule.Conditions))
+	for _, condition := range rule.Conditions {
+		metric, ok := alertMetrics[condition.Metric]
+		if !ok || seen[condition.Metric] {
+			continue
+		}
+
+		seen[condition.Metric] = true
+		details = append(details, fmt.Sprintf("%s: %.2f%s", metric.label, metric.value(reading), metric.unit))
+	}
+
+	return strings.Join(details, ", ")
+}
+
+// matchesAlertCondition checks if a reading meets a condition
+// condition: condition to check
+// reading: reading to check
+// returns: true if the metric of the reading compares to the threshold as the operator tells
+func matchesAlertCondition(condition models.AlertCondition, reading models.SensorData) bool {
+	metric, ok := alertMetrics[condition.Metric]
+	if !ok {
+		return false
+	}
+
+	value := metric.value(reading)
+	switch condition.Operator {
+	case models.AlertOperatorLessThan:
+		return value < condition.Threshold
+	case models.AlertOperatorLessOrEqual:
+		return value <= condition.Threshold
+	case models.AlertOperatorGreaterThan:
+		return value > condition.Threshold
+	case models.AlertOperatorGreaterOrEqual:
+		return value >= condition.Threshold
+	default:
+		return false
+	}
+}
+
+// AlertHistory returns how long before the latest reading of a waypoint the rules need readings
+// rules: rules to evaluate
+// returns: the longest duration of the enabled rules
+func AlertHistory(rules []models.AlertRule) time.Duration {
+	history := time.Duration(0)
+	for _, rule := range rules {
+		if rule.Enabled {
+			history = max(history, time.Duration(rule.Duration)*time.Minute)
+		}
+	}
+
+	return history
+}
+
+// RaiseAlertRules evaluates the rules at every waypoint of a route
+// rules: rules of the company of the route, with their conditions
+// waypoints: waypoints of the route to evaluate, in the order of the route
+// readings: readings of the waypoints ordered by waypoint and date
+// returns: an alert for every rule and waypoint it is raised at, in the order of the rules and then of the waypoints
+func RaiseAlertRules(
+	rules []models.AlertRule,
+	waypoints []models.Waypoint,
+	readings []models.SensorData,
+) []RaisedAlert {
+	byWaypoint := make(map[uint][]models.SensorData, len(waypoints))
+	for _, reading := range readings {
+		byWaypoint[reading.WaypointID] = append(byWaypoint[reading.WaypointID], reading)
+	}
+
+	raised := []RaisedAlert{}
+	for _, rule := range rules {
+		for _, waypoint := range waypoints {
+			triggering, ok := EvaluateAlertRule(rule, byWaypoint[waypoint.ID])
+			if ok {
+				raised = append(raised, RaisedAlert{Rule: rule, WaypointID: waypoint.ID, Readings: triggering})
+			}
+		}
+	}
+
+	return raised
+}
+
+// EvaluateAlertRules turns the raised alerts of a route into weather alerts
+// A rule raises a single alert for the route, with the details of the first waypoint it is raised at.
+// raised: alerts raised for the route, as returned by RaiseAlertRules
+// returns: the alerts in the order of the rules
+func EvaluateAlertRules(raised []RaisedAlert) []models.WeatherAlert {
+	alerts := []models.WeatherAlert{}
+	for i, alert := range raised {
+		// The alerts of a rule follow each other, only the first one is kept
+		if i > 0 && raised[i-1].Rule.ID == alert.Rule.ID {
+			continue
+		}
+
+		alerts = append(alerts, models.WeatherAlert{
+			Type:     alert.Rule.Type,
+			Message:  alert.Rule.Message,
+			Details:  AlertDetails(alert.Rule, alert.Readings[len(alert.Readings)-1]),
+			Severity: alert.Rule.Severity,
+		})
+	}
+
+	return alerts
+}
@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"testing"
+	"wayra/internal/core/domain/models"
+)
+
+// stormRule raises an alert for strong wind, together with cold for an and rule or either of them for an or rule
+func stormRule(logic string, duration int) models.AlertRule {
+	return models.AlertRule{
+		ID:       1,
+		Type:     "storm",
+		Logic:    logic,
+		Duration: duration,
+		Enabled:  true,
+		Conditions: []models.AlertCondition{
+			{Metric: models.MetricWindSpeed, Operator: models.AlertOperatorGreaterThan, Threshold: 20},
+			{Metric: models.MetricTemperature, Operator: models.AlertOperatorLessThan, Threshold: 0},
+		},
+	}
+}
+
+func TestMatchesAlertRule(t *testing.T) {
+	windyCold := testReading(1, 0, -5, 50, 25, 1013)
+	windy := testReading(1, 0, 5, 50, 25, 1013)
+	cold := testReading(1, 0, -5, 50, 5, 1013)
+	calm := testReading(1, 0, 5, 50, 5, 1013)
+
+	tests := []struct {
+		name    string
+		rule    models.AlertRule
+		reading models.SensorData
+		want    bool
+	}{
+		{"and, every condition holds", stormRule(models.AlertLogicAnd, 0), windyCold, true},
+		{"and, one condition holds", stormRule(models.AlertLogicAnd, 0), windy, false},
+		{"and, no condition holds", stormRule(models.AlertLogicAnd, 0), calm, false},
+		{"or, every condition holds", stormRule(models.AlertLogicOr, 0), windyCold, true},
+		{"or, the first condition holds", stormRule(models.AlertLogicOr, 0), windy, true},
+		{"or, the second condition holds", stormRule(models.AlertLogicOr, 0), cold, true},
+		{"or, no condition holds", stormRule(models.AlertLogicOr, 0), calm, false},
+		{"no conditions", models.AlertRule{Logic: models.AlertLogicAnd, Enabled: true}, windyCold, false},
+		{
+			"threshold included by gte",
+			models.AlertRule{Logic: models.AlertLogicAnd, Conditions: []models.AlertCondition{
+				{Metric: models.MetricWindSpeed, Operator: models.AlertOperatorGreaterOrEqual, Threshold: 25},
+			}},
+			windy, true,
+		},
+		{
+			"threshold excluded by gt",
+			models.AlertRule{Logic: models.AlertLogicAnd, Conditions: []models.AlertCondition{
+				{Metric: models.MetricWindSpeed, Operator: models.AlertOperatorGreaterThan, Threshold: 25},
+			}},
+			windy, false,
+		},
+		{
+			"unknown metric",
+			models.AlertRule{Logic: models.AlertLogicOr, Conditions: []models.AlertCondition{
+				{Metric: "visibility", Operator: models.AlertOperatorLessThan, Threshold: 100},
+			}},
+			windyCold, false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchesAlertRule(tt.rule, tt.reading); got != tt.want {
+				t.Errorf("MatchesAlertRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateAlertRule(t *testing.T) {
+	// windy builds readings every 10 minutes with the wind speeds, all of them cold
+	windy := func(windSpeeds ...float64) []models.SensorData {
+		readings := make([]models.SensorData, 0, len(windSpeeds))
+		for i, windSpeed := range windSpeeds {
+			readings = append(readings, testReading(1, 10*i, -5, 50, windSpeed, 1013))
+		}
+		return readings
+	}
+	disabled := stormRule(models.AlertLogicAnd, 0)
+	disabled.Enabled = false
+
+	tests := []struct {
+		name         string
+		rule         models.AlertRule
+		readings     []models.SensorData
+		wantRaised   bool
+		wantReadings int
+	}{
+		{"latest reading without a duration", stormRule(models.AlertLogicAnd, 0), windy(5, 5, 25), true, 1},
+		{"latest reading does not match", stormRule(models.AlertLogicAnd, 0), windy(25, 25, 5), false, 0},
+		{"held for the whole duration", stormRule(models.AlertLogicAnd, 20), windy(5, 25, 25, 25), true, 3},
+		{"held for longer than the duration", stormRule(models.AlertLogicAnd, 15), windy(25, 25, 25, 25), true, 3},
+		{"interrupted within the duration", stormRule(models.AlertLogicAnd, 20), windy(25, 5, 25, 25), false, 0},
+		{"no reading as old as the duration", stormRule(models.AlertLogicAnd, 60), windy(25, 25, 25), false, 0},
+		{"or rule held for the duration", stormRule(models.AlertLogicOr, 10), windy(5, 5), true, 2},
+		{"disabled rule", disabled, windy(25), false, 0},
+		{"no readings", stormRule(models.AlertLogicAnd, 0), nil, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readings, raised := EvaluateAlertRule(tt.rule, tt.readings)
+			if raised != tt.wantRaised || len(readings) != tt.wantReadings {
+				t.Fatalf("EvaluateAlertRule() = %d readings, %v, want %d, %v", len(readings), raised, tt.wantReadings, tt.wantRaised)
+			}
+			if raised && !readings[len(readings)-1].Date.Equal(tt.readings[len(tt.readings)-1].Date) {
+				t.Error("the latest reading is not the last one returned")
+			}
+		})
+	}
+}
+
+func TestEvaluateAlertRulesKeepsOneAlertPerRule(t *testing.T) {
+	calm := stormRule(models.AlertLogicAnd, 0)
+	calm.ID, calm.Type = 2, "calm"
+	calm.Conditions = []models.AlertCondition{{Metric: models.MetricWindSpeed, Operator: models.AlertOperatorLessThan, Threshold: 10}}
+
+	waypoints := []models.Waypoint{{ID: 1}, {ID: 2}, {ID: 3}}
+	readings := []models.SensorData{
+		testReading(1, 0, -5, 50, 25, 1013),
+		testReading(2, 0, -5, 50, 30, 1013),
+		testReading(3, 0, -5, 50, 5, 1013),
+	}
+
+	raised := RaiseAlertRules([]models.AlertRule{stormRule(models.AlertLogicAnd, 0), calm}, waypoints, readings)
+	if len(raised) != 3 {
+		t.Fatalf("raised %d alerts, want storm at waypoints 1 and 2 and calm at waypoint 3", len(raised))
+	}
+
+	alerts := EvaluateAlertRules(raised)
+	if len(alerts) != 2 || alerts[0].Type != "storm" || alerts[1].Type != "calm" {
+		t.Fatalf("alerts %+v, want storm and calm", alerts)
+	}
+	if want := "Wind Speed: 25.00 m/s, Temperature: -5.00°C"; alerts[0].Details != want {
+		t.Errorf("details %q, want %q", alerts[0].Details, want)
+	}
+}
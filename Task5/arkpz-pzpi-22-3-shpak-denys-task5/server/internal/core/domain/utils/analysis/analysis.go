@@ -3,8 +3,6 @@
 package analysis // import "wayra/internal/core/domain/utils/analysis"
 
 import (
-	"fmt"
-	"wayra/internal/core/domain/models"
 	utilsMath "wayra/internal/core/domain/utils/math"
 )
 
@@ -13,6 +11,9 @@ type DeliveryMetrics struct {
 	Temperature float64 // Temperature in Celsius
 	Humidity    float64 // Humidity in percentage
 	WindSpeed   float64 // Wind speed in m/s
+	DewPoint    float64 // Dew point in Celsius
+	HeatIndex   float64 // Heat index in Celsius
+	WindChill   float64 // Wind chill in Celsius
 	TotalWeight float64 // Total weight in kg
 
 	DeliverySpeed float64 // Delivery speed in km/h
@@ -25,117 +26,64 @@ type PredictData struct {
 	Time     float64 // Time in hours
 }
 
-// PredictedData is a struct that contains the predicted data
-// data: the predicted data
-// return: the predicted data
-func LinearRegression(data []DeliveryMetrics) []float64 {
+// LinearRegression computes the coefficients of the regression of the delivery speed on the weather features
+// and the total weight, the beta of every term is computed on its own
+// data: the metrics of the completed deliveries
+// features: the weather features of the regression
+// return: the free term, the betas of the features in their order, the beta of the total weight and the error
+func LinearRegression(data []DeliveryMetrics, features []RegressionFeature) []float64 {
 	n := len(data)
 
-	// X
-	temperature := make([]float64, n)
-	humidity := make([]float64, n)
-	windSpeed := make([]float64, n)
-	totalWeight := make([]float64, n)
+	// X, the weather features followed by the total weight
+	terms := make([][]float64, len(features)+1)
+	for j := range terms {
+		terms[j] = make([]float64, n)
+	}
 
 	// Y
 	deliverySpeed := make([]float64, n)
 
 	for i, metrics := range data {
-		temperature[i] = metrics.Temperature
-		humidity[i] = metrics.Humidity
-		windSpeed[i] = metrics.WindSpeed
-		totalWeight[i] = metrics.TotalWeight
+		for j, feature := range features {
+			terms[j][i] = metrics.value(feature)
+		}
+		terms[len(features)][i] = metrics.TotalWeight
 
 		deliverySpeed[i] = metrics.DeliverySpeed
 	}
 
-	// X^2
-	temperatureSquared := utilsMath.Square(temperature)
-	humiditySquared := utilsMath.Square(humidity)
-	windSpeedSquared := utilsMath.Square(windSpeed)
-	totalWeightSquared := utilsMath.Square(totalWeight)
-	fmt.Printf("totalWeightSquared: %v\n", totalWeightSquared)
-
-	// Y^2
-	deliverySpeedSquared := utilsMath.Square(deliverySpeed)
-
-	// XY
-	temperatureDeliverySpeed := utilsMath.Multiply(temperature, deliverySpeed)
-	humidityDeliverySpeed := utilsMath.Multiply(humidity, deliverySpeed)
-	windSpeedDeliverySpeed := utilsMath.Multiply(windSpeed, deliverySpeed)
-	totalWeightDeliverySpeed := utilsMath.Multiply(totalWeight, deliverySpeed)
-	fmt.Printf("totalWeightDeliverySpeed: %v\n", totalWeightDeliverySpeed)
-
-	// X sum
-	sumTemperature := utilsMath.Sum(temperature)
-	sumHumidity := utilsMath.Sum(humidity)
-	sumWindSpeed := utilsMath.Sum(windSpeed)
-	sumTotalWeight := utilsMath.Sum(totalWeight)
-	fmt.Printf("sumTotalWeight: %v\n", sumTotalWeight)
-
-	// Y sum
+	// Y sum and AvgY
 	sumDeliverySpeed := utilsMath.Sum(deliverySpeed)
-
-	// X^2 sum
-	sumTemperatureSquared := utilsMath.Sum(temperatureSquared)
-	sumHumiditySquared := utilsMath.Sum(humiditySquared)
-	sumWindSpeedSquared := utilsMath.Sum(windSpeedSquared)
-	sumTotalWeightSquared := utilsMath.Sum(totalWeightSquared)
-	fmt.Printf("sumTotalWeightSquared: %v\n", sumTotalWeightSquared)
-
-	// Y^2 sum
-	_ = utilsMath.Sum(deliverySpeedSquared)
-
-	// XY sum
-	sumTemperatureDeliverySpeed := utilsMath.Sum(temperatureDeliverySpeed)
-	sumHumidityDeliverySpeed := utilsMath.Sum(humidityDeliverySpeed)
-	sumWindSpeedDeliverySpeed := utilsMath.Sum(windSpeedDeliverySpeed)
-	sumTotalWeightDeliverySpeed := utilsMath.Sum(totalWeightDeliverySpeed)
-	fmt.Printf("sumTotalWeightDeliverySpeed: %v\n", sumTotalWeightDeliverySpeed)
-
-	// AvgX
-	avgTemperature := utilsMath.Mean(temperature)
-	avgHumidity := utilsMath.Mean(humidity)
-	avgWindSpeed := utilsMath.Mean(windSpeed)
-	avgTotalWeight := utilsMath.Mean(totalWeight)
-	fmt.Printf("avgTotalWeight: %v\n", avgTotalWeight)
-
-	// AvgY
 	avgDeliverySpeed := utilsMath.Mean(deliverySpeed)
 
-	// Betas
-	betaTemperature := CalculateBeta(sumTemperature, sumDeliverySpeed, sumTemperatureDeliverySpeed, sumTemperatureSquared, n)
-	betaHumidity := CalculateBeta(sumHumidity, sumDeliverySpeed, sumHumidityDeliverySpeed, sumHumiditySquared, n)
-	betaWindSpeed := CalculateBeta(sumWindSpeed, sumDeliverySpeed, sumWindSpeedDeliverySpeed, sumWindSpeedSquared, n)
-	betaTotalWeight := CalculateBeta(sumTotalWeight, sumDeliverySpeed, sumTotalWeightDeliverySpeed, sumTotalWeightSquared, n)
-
-	fmt.Printf("Beta Total Weight: %f\n", betaTotalWeight)
-
-	// Free term
-	beta0 := avgDeliverySpeed - betaTemperature*avgTemperature - betaHumidity*avgHumidity - betaWindSpeed*avgWindSpeed - betaTotalWeight*avgTotalWeight
-
-	// Calculate errors
-	temperatureErrors := make([]float64, n)
-	humidityErrors := make([]float64, n)
-	windSpeedErrors := make([]float64, n)
-	totalWeightErrors := make([]float64, n)
+	// Betas and free term
+	betas := make([]float64, len(terms))
+	beta0 := avgDeliverySpeed
+	for j, values := range terms {
+		betas[j] = CalculateBeta(
+			utilsMath.Sum(values),
+			sumDeliverySpeed,
+			utilsMath.Sum(utilsMath.Multiply(values, deliverySpeed)),
+			utilsMath.Sum(utilsMath.Square(values)),
+			n,
+		)
+		beta0 -= betas[j] * utilsMath.Mean(values)
+	}
 
+	// Calculate errors, the total weight is left out of the residuals
+	residuals := make([]float64, n)
 	for i := 0; i < n; i++ {
-		temperatureErrors[i] = deliverySpeed[i] - beta0 - betaTemperature*temperature[i] - betaHumidity*humidity[i] - betaWindSpeed*windSpeed[i]
-		humidityErrors[i] = deliverySpeed[i] - beta0 - betaTemperature*temperature[i] - betaHumidity*humidity[i] - betaWindSpeed*windSpeed[i]
-		windSpeedErrors[i] = deliverySpeed[i] - beta0 - betaTemperature*temperature[i] - betaHumidity*humidity[i] - betaWindSpeed*windSpeed[i]
-		totalWeightErrors[i] = deliverySpeed[i] - beta0 - betaTemperature*temperature[i] - betaHumidity*humidity[i] - betaWindSpeed*windSpeed[i]
+		residuals[i] = deliverySpeed[i] - beta0
+		for j := range features {
+			residuals[i] -= betas[j] * terms[j][i]
+		}
 	}
 
-	// Calculate average error
-	avgTemperatureError := utilsMath.Mean(temperatureErrors)
-	avgHumidityError := utilsMath.Mean(humidityErrors)
-	avgWindSpeedError := utilsMath.Mean(windSpeedErrors)
-	avgTotalWeightError := utilsMath.Mean(totalWeightErrors)
-
-	error := avgTemperatureError + avgHumidityError + avgWindSpeedError + avgTotalWeightError
+	// Every term adds the average error
+	avgError := float64(len(terms)) * utilsMath.Mean(residuals)
 
-	return []float64{beta0, betaTemperature, betaHumidity, betaWindSpeed, betaTotalWeight, error}
+	coeffs := append([]float64{beta0}, betas...)
+	return append(coeffs, avgError)
 }
 
 // CalculateBeta calculates the beta value of regression
@@ -155,21 +103,15 @@ func CalculateBeta(sumX, sumY, sumXY, sumXSquared float64, n int) float64 {
 
 // Predict predicts the delivery speed
 // coeffs: the coefficients of the regression
-// input: the input data
-// totalWeight: the total weight of the delivery
+// features: the weather features the coefficients were computed for
+// input: the weather and the total weight of the delivery
 // return: the predicted delivery speed
-func Predict(coeffs []float64, input models.SensorData, totalWeight float64) float64 {
-	features := []float64{
-		input.Temperature,
-		input.Humidity,
-		input.WindSpeed,
-		totalWeight,
-	}
-
+func Predict(coeffs []float64, features []RegressionFeature, input DeliveryMetrics) float64 {
 	result := coeffs[0]
-	for i := 0; i < len(features); i++ {
-		result += coeffs[i+1] * features[i]
+	for i, feature := range features {
+		result += coeffs[i+1] * input.value(feature)
 	}
+	result += coeffs[len(features)+1] * input.TotalWeight
 
 	result += coeffs[len(coeffs)-1]
 
@@ -0,0 +1,91 @@
+package analysis // import "wayra/internal/core/domain/utils/analysis"
+
+import "wayra/internal/core/domain/models"
+
+// calibratedMeasurement points to a measurement of a reading and to its raw value
+type calibratedMeasurement struct {
+	metric string    // metric of the profiles correcting the measurement
+	value  *float64  // calibrated value
+	raw    **float64 // value before the calibration, nil when no calibration applies
+}
+
+// calibratedMeasurements returns the measurements of a reading a calibration may correct
+// reading: reading to look at
+// returns: the measurements
+func calibratedMeasurements(reading *models.SensorData) [4]calibratedMeasurement {
+	return [4]calibratedMeasurement{
+		{models.MetricTemperature, &reading.Temperature, &reading.RawTemperature},
+		{models.MetricHumidity, &reading.Humidity, &reading.RawHumidity},
+		{models.MetricWindSpeed, &reading.WindSpeed, &reading.RawWindSpeed},
+		{models.MetricMeanPressure, &reading.MeanPressure, &reading.RawMeanPressure},
+	}
+}
+
+// Calibrate corrects the measurements of a reading with the profiles in effect when it was recorded
+// Every measurement is corrected from its raw value, so calibrating a reading again replaces the previous
+// correction. The profile of the device that recorded the reading takes precedence over the profile of the
+// waypoint, and of the profiles of the same scope the one valid from the latest date applies.
+// A measurement without a profile gets its raw value back.
+// reading: reading to correct, with its device and its date
+// profiles: profiles to choose from, profiles of other devices and waypoints are ignored
+// returns: true if a measurement or a raw value of the reading changed
+func Calibrate(reading *models.SensorData, profiles []models.CalibrationProfile) bool {
+	changed := false
+
+	for _, measurement := range calibratedMeasurements(reading) {
+		raw := *measurement.value
+		if *measurement.raw != nil {
+			raw = **measurement.raw
+		}
+
+		value, rawValue := raw, (*float64)(nil)
+		if profile := profileFor(reading, measurement.metric, profiles); profile != nil {
+			value, rawValue = profile.Correct(raw), &raw
+		}
+
+		if value != *measurement.value || (rawValue == nil) != (*measurement.raw == nil) {
+			changed = true
+		}
+		*measurement.value, *measurement.raw = value, rawValue
+	}
+
+	return changed
+}
+
+// profileFor picks the profile correcting a measurement of a reading
+// reading: reading to correct
+// metric: measurement to correct
+// profiles: profiles to choose from
+// returns: the profile, nil if none applies
+func profileFor(reading *models.SensorData, metric string, profiles []models.CalibrationProfile) *models.CalibrationProfile {
+	var deviceProfile, waypointProfile *models.CalibrationProfile
+
+	later := func(profile, current *models.CalibrationProfile) bool {
+		return current == nil || profile.ValidFrom.After(current.ValidFrom) ||
+			(profile.ValidFrom.Equal(current.ValidFrom) && profile.ID > current.ID)
+	}
+
+	for i := range profiles {
+		profile := &profiles[i]
+		if profile.Metric != metric || profile.ValidFrom.After(reading.Date) {
+			continue
+		}
+
+		switch {
+		case profile.DeviceID != nil:
+			if reading.DeviceID != nil && *profile.DeviceID == *reading.DeviceID && later(profile, deviceProfile) {
+				deviceProfile = profile
+			}
+		case profile.WaypointID != nil:
+			if *profile.WaypointID == reading.WaypointID && later(profile, waypointProfile) {
+				waypointProfile = profile
+			}
+		}
+	}
+
+	if deviceProfile != nil {
+		return deviceProfile
+	}
+
+	return waypointProfile
+}
@@ -0,0 +1,191 @@
+package analysis // import "wayra/internal/core/domain/utils/analysis"
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"wayra/internal/core/domain/models"
+	utilsMath "wayra/internal/core/domain/utils/math"
+)
+
+// ErrInvalidClassifierConfig is returned when the thresholds of the classifier make no sense
+var ErrInvalidClassifierConfig = errors.New("anomaly_factor must be at least 1, stable_cv must be positive and min_readings at least 2")
+
+// Classification is the verdict of the classifier for the device of a waypoint
+type Classification string
+
+// Classifications of the devices
+const (
+	ClassificationNormal           Classification = "normal"            // the device varies like its peers
+	ClassificationDeviceIssue      Classification = "device_issue"      // only the device varies, its sensor is probably broken
+	ClassificationBadWeather       Classification = "bad_weather"       // the device varies and so does the rest of the route
+	ClassificationInsufficientData Classification = "insufficient_data" // not enough readings of the device or of its peers
+)
+
+// ClassifierConfig holds the thresholds of the classifier
+type ClassifierConfig struct {
+	AnomalyFactor float64 `json:"anomaly_factor"` // how many times the CV of the device may exceed the CV of its peers
+	StableCV      float64 `json:"stable_cv"`      // peers with every CV below this value are considered stable
+	MinReadings   int     `json:"min_readings"`   // readings a device and its peers need to be classified
+}
+
+// DefaultClassifierConfig returns the thresholds used by the firmware
+// returns: the default config
+func DefaultClassifierConfig() ClassifierConfig {
+	return ClassifierConfig{
+		AnomalyFactor: 1.5,
+		StableCV:      0.1,
+		MinReadings:   3,
+	}
+}
+
+// Validate checks the thresholds
+// returns: ErrInvalidClassifierConfig if a threshold is out of range
+func (c ClassifierConfig) Validate() error {
+	if c.AnomalyFactor < 1 || c.StableCV <= 0 || c.MinReadings < 2 {
+		return ErrInvalidClassifierConfig
+	}
+
+	return nil
+}
+
+// MeasurementVariation compares the variation of one measurement of a device with its peers
+type MeasurementVariation struct {
+	Measurement string  `json:"measurement"` // name of the measurement
+	CV          float64 `json:"cv"`          // coefficient of variation of the device
+	PeersCV     float64 `json:"peers_cv"`    // coefficient of variation of the other waypoints
+	Anomalous   bool    `json:"anomalous"`   // whether the device varies more than allowed
+}
+
+// WaypointClassification is the verdict of the classifier for the device of a waypoint
+type WaypointClassification struct {
+	WaypointID     uint                   `json:"waypoint_id"`            // waypoint of the device
+	Classification Classification         `json:"classification"`         // verdict
+	Readings       int                    `json:"readings"`               // readings of the device that were analyzed
+	Measurements   []MeasurementVariation `json:"measurements,omitempty"` // details of the verdict
+}
+
+// measurementSeries holds the values of every measurement of a set of readings
+type measurementSeries [4][]float64
+
+// measurementNames are the names of the measurements, in the order of measurementSeries
+var measurementNames = [4]string{"temperature", "humidity", "wind_speed", "mean_pressure"}
+
+// add appends the measurements of the reading to the series
+// reading: reading to add
+func (s *measurementSeries) add(reading models.SensorData) {
+	s[0] = append(s[0], reading.Temperature)
+	s[1] = append(s[1], reading.Humidity)
+	s[2] = append(s[2], reading.WindSpeed)
+	s[3] = append(s[3], reading.MeanPressure)
+}
+
+// ClassifyWaypoints tells sensor malfunctions from bad weather, like compareWithOtherWaypoints of the firmware
+// The coefficient of variation of every measurement of a device is compared with the one of the readings
+// of every other waypoint of the route. A device varying more than AnomalyFactor times its peers is
+// a device issue when its peers are stable, and bad weather when they vary as well.
+// Unlike the firmware, the absolute mean is used so temperatures below zero do not hide the variation.
+// waypointIDs: waypoints of the route, waypoints without readings are classified as insufficient_data
+// readings: readings of the route in the analyzed window
+// config: thresholds of the classifier
+// returns: the classification of every waypoint, ordered by waypoint ID
+func ClassifyWaypoints(
+	waypointIDs []uint,
+	readings []models.SensorData,
+	config ClassifierConfig,
+) []WaypointClassification {
+	series := make(map[uint]*measurementSeries, len(waypointIDs))
+	for _, waypointID := range waypointIDs {
+		series[waypointID] = &measurementSeries{}
+	}
+	for _, reading := range readings {
+		if series[reading.WaypointID] == nil {
+			series[reading.WaypointID] = &measurementSeries{}
+		}
+		series[reading.WaypointID].add(reading)
+	}
+
+	ids := make([]uint, 0, len(series))
+	for waypointID := range series {
+		ids = append(ids, waypointID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	classifications := make([]WaypointClassification, 0, len(ids))
+	for _, waypointID := range ids {
+		own := series[waypointID]
+
+		var peers measurementSeries
+		for _, other := range ids {
+			if other == waypointID {
+				continue
+			}
+			for i := range peers {
+				peers[i] = append(peers[i], series[other][i]...)
+			}
+		}
+
+		classifications = append(classifications, classify(waypointID, *own, peers, config))
+	}
+
+	return classifications
+}
+
+// classify classifies the device of one waypoint
+// waypointID: waypoint of the device
+// own: readings of the device
+// peers: readings of the other waypoints
+// config: thresholds of the classifier
+// returns: the classification
+func classify(waypointID uint, own, peers measurementSeries, config ClassifierConfig) WaypointClassification {
+	result := WaypointClassification{
+		WaypointID:     waypointID,
+		Classification: ClassificationInsufficientData,
+		Readings:       len(own[0]),
+	}
+
+	if len(own[0]) < config.MinReadings || len(peers[0]) < config.MinReadings {
+		return result
+	}
+
+	anomalous, stable := false, true
+	for i, name := range measurementNames {
+		variation := MeasurementVariation{
+			Measurement: name,
+			CV:          CoefficientOfVariation(own[i]),
+			PeersCV:     CoefficientOfVariation(peers[i]),
+		}
+		variation.Anomalous = variation.CV > variation.PeersCV*config.AnomalyFactor
+
+		anomalous = anomalous || variation.Anomalous
+		stable = stable && variation.PeersCV < config.StableCV
+		result.Measurements = append(result.Measurements, variation)
+	}
+
+	switch {
+	case !anomalous:
+		result.Classification = ClassificationNormal
+	case stable:
+		result.Classification = ClassificationDeviceIssue
+	default:
+		result.Classification = ClassificationBadWeather
+	}
+
+	return result
+}
+
+// CoefficientOfVariation returns the standard deviation of the data divided by its absolute mean
+// data: values to analyze
+// returns: the coefficient of variation, 0 for no data or a zero mean
+func CoefficientOfVariation(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	mean := math.Abs(utilsMath.Mean(data))
+	if mean == 0 {
+		return 0
+	}
+
+	return utilsMath.StdDev(data) / mean
+}
@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// testStart is the date of the first reading of the tests
+var testStart = time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC)
+
+// testReading builds a reading of a waypoint recorded the given minutes after testStart
+func testReading(waypointID uint, minutes int, temperature, humidity, windSpeed, meanPressure float64) models.SensorData {
+	return models.SensorData{
+		WaypointID:   waypointID,
+		Date:         testStart.Add(time.Duration(minutes) * time.Minute),
+		Temperature:  temperature,
+		Humidity:     humidity,
+		WindSpeed:    windSpeed,
+		MeanPressure: meanPressure,
+	}
+}
+
+// temperatures builds the readings of a waypoint with the temperatures and steady other measurements
+func temperatures(waypointID uint, values ...float64) []models.SensorData {
+	readings := make([]models.SensorData, 0, len(values))
+	for i, value := range values {
+		readings = append(readings, testReading(waypointID, 10*i, value, 50, 5, 1013))
+	}
+	return readings
+}
+
+func TestCoefficientOfVariation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []float64
+		want float64
+	}{
+		{"no data", nil, 0},
+		{"zero mean", []float64{-1, 1}, 0},
+		{"constant", []float64{10, 10, 10}, 0},
+		{"positive", []float64{10, 20, 30}, math.Sqrt(200.0/3) / 20},
+		{"below zero uses the absolute mean", []float64{-10, -20, -30}, math.Sqrt(200.0/3) / 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CoefficientOfVariation(tt.data); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("CoefficientOfVariation(%v) = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyWaypoints(t *testing.T) {
+	join := func(series ...[]models.SensorData) []models.SensorData {
+		readings := []models.SensorData{}
+		for _, s := range series {
+			readings = append(readings, s...)
+		}
+		return readings
+	}
+
+	tests := []struct {
+		name        string
+		waypointIDs []uint
+		readings    []models.SensorData
+		want        []Classification
+	}{
+		{
+			"every device varies like its peers",
+			[]uint{1, 2, 3},
+			join(temperatures(1, 10, 11, 12), temperatures(2, 10, 11, 12), temperatures(3, 10, 11, 12)),
+			[]Classification{ClassificationNormal, ClassificationNormal, ClassificationNormal},
+		},
+		{
+			"only one device varies while its peers are stable",
+			[]uint{1, 2, 3},
+			join(temperatures(1, 10, 20, 30), temperatures(2, 10, 10.1, 10.2), temperatures(3, 10, 10.1, 10.2)),
+			[]Classification{ClassificationDeviceIssue, ClassificationNormal, ClassificationNormal},
+		},
+		{
+			"one device varies more while its peers vary as well",
+			[]uint{1, 2, 3},
+			join(temperatures(1, 5, 20, 35), temperatures(2, 10, 14, 18), temperatures(3, 10, 14, 18)),
+			[]Classification{ClassificationBadWeather, ClassificationNormal, ClassificationNormal},
+		},
+		{
+			"sub-zero device varying alone",
+			[]uint{1, 2},
+			join(temperatures(1, -10, -20, -30), temperatures(2, -10, -10.1, -10.2)),
+			[]Classification{ClassificationDeviceIssue, ClassificationNormal},
+		},
+		{
+			"too few readings of the device",
+			[]uint{1, 2},
+			join(temperatures(1, 10, 30), temperatures(2, 10, 11, 12)),
+			[]Classification{ClassificationInsufficientData, ClassificationInsufficientData},
+		},
+		{
+			"waypoint without readings",
+			[]uint{3, 1, 2},
+			join(temperatures(1, 10, 11, 12), temperatures(2, 10, 11, 12)),
+			[]Classification{ClassificationNormal, ClassificationNormal, ClassificationInsufficientData},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyWaypoints(tt.waypointIDs, tt.readings, DefaultClassifierConfig())
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d classifications, want %d", len(got), len(tt.want))
+			}
+			for i, classification := range got {
+				if i > 0 && classification.WaypointID <= got[i-1].WaypointID {
+					t.Errorf("classifications are not ordered by waypoint ID: %d after %d", classification.WaypointID, got[i-1].WaypointID)
+				}
+				if classification.Classification != tt.want[i] {
+					t.Errorf("waypoint %d: %s, want %s (%+v)",
+						classification.WaypointID, classification.Classification, tt.want[i], classification.Measurements)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package analysis // import "wayra/internal/core/domain/utils/analysis"
+
+import (
+	"errors"
+	"strings"
+	"wayra/internal/core/domain/models"
+	utilsMeteo "wayra/internal/core/domain/utils/meteo"
+)
+
+// ErrInvalidRegressionFeature is returned when an unknown or repeated weather feature is configured for the regression
+var ErrInvalidRegressionFeature = errors.New(
+	"regression features must be distinct and one of: temperature, humidity, wind_speed, dew_point, heat_index, wind_chill",
+)
+
+// RegressionFeature is a weather metric the delivery speed is regressed on
+type RegressionFeature string
+
+// Weather features of the regression
+const (
+	FeatureTemperature RegressionFeature = "temperature" // °C
+	FeatureHumidity    RegressionFeature = "humidity"    // %
+	FeatureWindSpeed   RegressionFeature = "wind_speed"  // m/s
+	FeatureDewPoint    RegressionFeature = "dew_point"   // °C, derived from the temperature and the humidity
+	FeatureHeatIndex   RegressionFeature = "heat_index"  // °C, derived from the temperature and the humidity
+	FeatureWindChill   RegressionFeature = "wind_chill"  // °C, derived from the temperature and the wind speed
+)
+
+// featureNames are the names of the features in the equation of the regression
+var featureNames = map[RegressionFeature]string{
+	FeatureTemperature: "Temperature",
+	FeatureHumidity:    "Humidity",
+	FeatureWindSpeed:   "WindSpeed",
+	FeatureDewPoint:    "DewPoint",
+	FeatureHeatIndex:   "HeatIndex",
+	FeatureWindChill:   "WindChill",
+}
+
+// DefaultRegressionFeatures returns the features used when none are configured
+// returns: temperature, humidity and wind speed
+func DefaultRegressionFeatures() []RegressionFeature {
+	return []RegressionFeature{FeatureTemperature, FeatureHumidity, FeatureWindSpeed}
+}
+
+// ParseRegressionFeatures parses the configured features of the regression
+// names: names of the features, e.g. temperature or dew_point
+// returns: the features in the given order, DefaultRegressionFeatures when there are none,
+// ErrInvalidRegressionFeature if a name is unknown or repeated
+func ParseRegressionFeatures(names []string) ([]RegressionFeature, error) {
+	if len(names) == 0 {
+		return DefaultRegressionFeatures(), nil
+	}
+
+	features := make([]RegressionFeature, 0, len(names))
+	seen := make(map[RegressionFeature]bool, len(names))
+	for _, name := range names {
+		feature := RegressionFeature(strings.TrimSpace(name))
+		if _, ok := featureNames[feature]; !ok || seen[feature] {
+			return nil, ErrInvalidRegressionFeature
+		}
+
+		seen[feature] = true
+		features = append(features, feature)
+	}
+
+	return features, nil
+}
+
+// Name returns the name of the feature in the equation of the regression
+// returns: the name, e.g. DewPoint
+func (f RegressionFeature) Name() string {
+	return featureNames[f]
+}
+
+// WeatherMetrics averages the weather of the readings into the features of a delivery
+// The derived metrics are computed for every reading before they are averaged, like in the aggregates of the readings.
+// readings: readings of the route, at least one
+// returns: the metrics without a total weight and a delivery speed
+func WeatherMetrics(readings []models.SensorData) DeliveryMetrics {
+	metrics := DeliveryMetrics{}
+	for _, reading := range readings {
+		metrics.Temperature += reading.Temperature
+		metrics.Humidity += reading.Humidity
+		metrics.WindSpeed += reading.WindSpeed
+		metrics.DewPoint += utilsMeteo.DewPoint(reading.Temperature, reading.Humidity)
+		metrics.HeatIndex += utilsMeteo.HeatIndex(reading.Temperature, reading.Humidity)
+		metrics.WindChill += utilsMeteo.WindChill(reading.Temperature, reading.WindSpeed)
+	}
+
+	n := float64(len(readings))
+	metrics.Temperature /= n
+	metrics.Humidity /= n
+	metrics.WindSpeed /= n
+	metrics.DewPoint /= n
+	metrics.HeatIndex /= n
+	metrics.WindChill /= n
+
+	return metrics
+}
+
+// value returns the value of a feature of the delivery
+// feature: the feature
+// returns: the value
+func (m DeliveryMetrics) value(feature RegressionFeature) float64 {
+	switch feature {
+	case FeatureHumidity:
+		return m.Humidity
+	case FeatureWindSpeed:
+		return m.WindSpeed
+	case FeatureDewPoint:
+		return m.DewPoint
+	case FeatureHeatIndex:
+		return m.HeatIndex
+	case FeatureWindChill:
+		return m.WindChill
+	default:
+		return m.Temperature
+	}
+}
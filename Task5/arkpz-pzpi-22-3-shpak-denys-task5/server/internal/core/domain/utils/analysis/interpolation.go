@@ -0,0 +1,268 @@
+package analysis // import "wayra/internal/core/domain/utils/analysis"
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+	"wayra/internal/core/domain/models"
+	utilsMath "wayra/internal/core/domain/utils/math"
+)
+
+// ErrInvalidInterpolationConfig is returned when the limits of the interpolation make no sense
+var ErrInvalidInterpolationConfig = errors.New(
+	"tolerance must not be negative, max_gap must exceed tolerance, max_distance and power must be positive " +
+		"and min_neighbours at least 1",
+)
+
+// EstimateMethod tells how the measurements of a waypoint were obtained
+type EstimateMethod string
+
+// Methods of the estimates
+const (
+	EstimateMeasured EstimateMethod = "measured" // a reading of the waypoint was recorded close enough to the time
+	EstimateTemporal EstimateMethod = "temporal" // interpolated between the readings of the waypoint around the time
+	EstimateSpatial  EstimateMethod = "spatial"  // weighted by inverse distance from the neighbouring waypoints
+)
+
+// InterpolationConfig holds the limits of the interpolation
+type InterpolationConfig struct {
+	Tolerance     time.Duration `json:"tolerance"`      // distance in time at which a reading is taken as it is
+	MaxGap        time.Duration `json:"max_gap"`        // longest gap between two readings that is interpolated
+	MaxDistance   float64       `json:"max_distance"`   // km, neighbours farther away are not taken into account
+	Power         float64       `json:"power"`          // power of the distance in the inverse distance weights
+	MinNeighbours int           `json:"min_neighbours"` // neighbours needed for a spatial estimate
+}
+
+// DefaultInterpolationConfig returns the limits used for every waypoint
+// returns: the default config
+func DefaultInterpolationConfig() InterpolationConfig {
+	return InterpolationConfig{
+		Tolerance:     30 * time.Minute,
+		MaxGap:        6 * time.Hour,
+		MaxDistance:   50,
+		Power:         2,
+		MinNeighbours: 1,
+	}
+}
+
+// Validate checks the limits
+// returns: ErrInvalidInterpolationConfig if a limit is out of range
+func (c InterpolationConfig) Validate() error {
+	if c.Tolerance < 0 || c.MaxGap <= c.Tolerance || c.MaxDistance <= 0 || c.Power <= 0 || c.MinNeighbours < 1 {
+		return ErrInvalidInterpolationConfig
+	}
+
+	return nil
+}
+
+// WaypointEstimate holds the measurements of a waypoint at a point in time
+type WaypointEstimate struct {
+	WaypointID   uint           `json:"waypoint_id"`          // waypoint the measurements belong to
+	Date         time.Time      `json:"date"`                 // point in time of the measurements
+	Temperature  float64        `json:"temperature"`          // °C
+	Humidity     float64        `json:"humidity"`             // %
+	WindSpeed    float64        `json:"wind_speed"`           // m/s
+	MeanPressure float64        `json:"mean_pressure"`        // hPa
+	Estimated    bool           `json:"estimated"`            // whether the measurements were not recorded but estimated
+	Method       EstimateMethod `json:"method"`               // how the measurements were obtained
+	Neighbours   []uint         `json:"neighbours,omitempty"` // waypoints a spatial estimate was computed from
+}
+
+// SensorData returns the measurements as a reading of the waypoint
+// returns: the reading, without an ID
+func (e WaypointEstimate) SensorData() models.SensorData {
+	return models.SensorData{
+		WaypointID:   e.WaypointID,
+		Date:         e.Date,
+		Temperature:  e.Temperature,
+		Humidity:     e.Humidity,
+		WindSpeed:    e.WindSpeed,
+		MeanPressure: e.MeanPressure,
+	}
+}
+
+// EstimateWaypoints estimates the measurements of the target waypoints at a point in time
+// A reading within Tolerance of the time is taken as it is, otherwise the readings of the waypoint around
+// the time are interpolated linearly when they are at most MaxGap apart. Waypoints left without a value
+// are estimated by inverse distance weighting of the measured or interpolated values of the neighbours
+// within MaxDistance, spatial estimates are never used for other spatial estimates.
+// targets: waypoints to estimate
+// neighbours: waypoints that may lend their values, the targets may be among them
+// at: point in time of the estimates
+// readings: readings of the targets and the neighbours around the time, the latest at or before it and
+// the earliest after it of every waypoint are used
+// config: limits of the interpolation
+// returns: the estimates of the targets that could be estimated, in the order of the targets
+func EstimateWaypoints(
+	targets []models.Waypoint,
+	neighbours []models.Waypoint,
+	at time.Time,
+	readings []models.SensorData,
+	config InterpolationConfig,
+) []WaypointEstimate {
+	before := make(map[uint]models.SensorData)
+	after := make(map[uint]models.SensorData)
+	for _, reading := range readings {
+		if !reading.Date.After(at) {
+			if latest, ok := before[reading.WaypointID]; !ok || reading.Date.After(latest.Date) {
+				before[reading.WaypointID] = reading
+			}
+			continue
+		}
+		if earliest, ok := after[reading.WaypointID]; !ok || reading.Date.Before(earliest.Date) {
+			after[reading.WaypointID] = reading
+		}
+	}
+
+	temporal := func(waypointID uint) (WaypointEstimate, bool) {
+		b, hasBefore := before[waypointID]
+		a, hasAfter := after[waypointID]
+		return estimateTemporal(waypointID, at, b, hasBefore, a, hasAfter, config)
+	}
+
+	estimates := make([]WaypointEstimate, 0, len(targets))
+	for _, target := range targets {
+		if estimate, ok := temporal(target.ID); ok {
+			estimates = append(estimates, estimate)
+			continue
+		}
+
+		values := []WaypointEstimate{}
+		distances := []float64{}
+		for _, neighbour := range neighbours {
+			if neighbour.ID == target.ID {
+				continue
+			}
+
+			distance := utilsMath.HaversineDistance(
+				target.Latitude,
+				target.Longitude,
+				neighbour.Latitude,
+				neighbour.Longitude,
+			)
+			if distance > config.MaxDistance {
+				continue
+			}
+
+			if value, ok := temporal(neighbour.ID); ok {
+				values = append(values, value)
+				distances = append(distances, distance)
+			}
+		}
+
+		if len(values) < config.MinNeighbours {
+			continue
+		}
+
+		estimates = append(estimates, estimateSpatial(target.ID, at, values, distances, config.Power))
+	}
+
+	return estimates
+}
+
+// estimateTemporal estimates the measurements of a waypoint from its own readings around a point in time
+// waypointID: waypoint to estimate
+// at: point in time of the estimate
+// before: latest reading at or before the time, if hasBefore
+// after: earliest reading after the time, if hasAfter
+// config: limits of the interpolation
+// returns: the estimate and false if the readings are too far from the time
+func estimateTemporal(
+	waypointID uint,
+	at time.Time,
+	before models.SensorData,
+	hasBefore bool,
+	after models.SensorData,
+	hasAfter bool,
+	config InterpolationConfig,
+) (WaypointEstimate, bool) {
+	estimate := WaypointEstimate{WaypointID: waypointID, Date: at}
+
+	// The closer of the two readings is the one in effect at the time
+	nearest, found := models.SensorData{}, false
+	if hasBefore && at.Sub(before.Date) <= config.Tolerance {
+		nearest, found = before, true
+	}
+	if hasAfter && after.Date.Sub(at) <= config.Tolerance && (!found || after.Date.Sub(at) < at.Sub(before.Date)) {
+		nearest, found = after, true
+	}
+	if found {
+		estimate.Method = EstimateMeasured
+		estimate.Temperature = nearest.Temperature
+		estimate.Humidity = nearest.Humidity
+		estimate.WindSpeed = nearest.WindSpeed
+		estimate.MeanPressure = nearest.MeanPressure
+		return estimate, true
+	}
+
+	if !hasBefore || !hasAfter || after.Date.Sub(before.Date) > config.MaxGap {
+		return estimate, false
+	}
+
+	ratio := float64(at.Sub(before.Date)) / float64(after.Date.Sub(before.Date))
+	lerp := func(from, to float64) float64 { return from + (to-from)*ratio }
+
+	estimate.Estimated = true
+	estimate.Method = EstimateTemporal
+	estimate.Temperature = lerp(before.Temperature, after.Temperature)
+	estimate.Humidity = lerp(before.Humidity, after.Humidity)
+	estimate.WindSpeed = lerp(before.WindSpeed, after.WindSpeed)
+	estimate.MeanPressure = lerp(before.MeanPressure, after.MeanPressure)
+	return estimate, true
+}
+
+// estimateSpatial estimates the measurements of a waypoint by inverse distance weighting of its neighbours
+// A neighbour at the same place as the waypoint lends its values as they are.
+// waypointID: waypoint to estimate
+// at: point in time of the estimate
+// values: values of the neighbours
+// distances: distances in km to the neighbours, in the order of the values
+// power: power of the distance in the weights
+// returns: the estimate
+func estimateSpatial(
+	waypointID uint,
+	at time.Time,
+	values []WaypointEstimate,
+	distances []float64,
+	power float64,
+) WaypointEstimate {
+	estimate := WaypointEstimate{
+		WaypointID: waypointID,
+		Date:       at,
+		Estimated:  true,
+		Method:     EstimateSpatial,
+	}
+
+	weights := make([]float64, len(values))
+	for i, distance := range distances {
+		if distance == 0 {
+			weights = make([]float64, len(values))
+			weights[i] = 1
+			break
+		}
+		weights[i] = 1 / math.Pow(distance, power)
+	}
+
+	totalWeight := 0.0
+	for i, value := range values {
+		if weights[i] == 0 {
+			continue
+		}
+
+		totalWeight += weights[i]
+		estimate.Temperature += weights[i] * value.Temperature
+		estimate.Humidity += weights[i] * value.Humidity
+		estimate.WindSpeed += weights[i] * value.WindSpeed
+		estimate.MeanPressure += weights[i] * value.MeanPressure
+		estimate.Neighbours = append(estimate.Neighbours, value.WaypointID)
+	}
+
+	estimate.Temperature /= totalWeight
+	estimate.Humidity /= totalWeight
+	estimate.WindSpeed /= totalWeight
+	estimate.MeanPressure /= totalWeight
+	sort.Slice(estimate.Neighbours, func(i, j int) bool { return estimate.Neighbours[i] < estimate.Neighbours[j] })
+
+	return estimate
+}
@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+func TestEstimateSpatial(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         []WaypointEstimate
+		distances      []float64
+		wantTemp       float64
+		wantNeighbours []uint
+	}{
+		{
+			"weighted by the inverse square distance",
+			[]WaypointEstimate{{WaypointID: 3, Temperature: 20}, {WaypointID: 2, Temperature: 10}},
+			[]float64{2, 1},
+			12,
+			[]uint{2, 3},
+		},
+		{
+			"equal distances average",
+			[]WaypointEstimate{{WaypointID: 2, Temperature: 10}, {WaypointID: 3, Temperature: 20}},
+			[]float64{5, 5},
+			15,
+			[]uint{2, 3},
+		},
+		{
+			"neighbour at the same place lends its values",
+			[]WaypointEstimate{{WaypointID: 2, Temperature: 10}, {WaypointID: 3, Temperature: 20}},
+			[]float64{1, 0},
+			20,
+			[]uint{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := estimateSpatial(1, testStart, tt.values, tt.distances, 2)
+			if math.Abs(got.Temperature-tt.wantTemp) > 1e-9 {
+				t.Errorf("temperature %v, want %v", got.Temperature, tt.wantTemp)
+			}
+			if !slices.Equal(got.Neighbours, tt.wantNeighbours) {
+				t.Errorf("neighbours %v, want %v", got.Neighbours, tt.wantNeighbours)
+			}
+			if !got.Estimated || got.Method != EstimateSpatial || got.WaypointID != 1 {
+				t.Errorf("estimate %+v, want a spatial estimate of waypoint 1", got)
+			}
+		})
+	}
+}
+
+func TestEstimateWaypoints(t *testing.T) {
+	at := testStart.Add(6 * time.Hour)
+	minutes := func(offset time.Duration) int { return int(at.Add(offset).Sub(testStart) / time.Minute) }
+	reading := func(waypointID uint, offset time.Duration, temperature float64) models.SensorData {
+		return testReading(waypointID, minutes(offset), temperature, 50, 5, 1013)
+	}
+
+	// Waypoints along a meridian, so the distances are proportional to the latitudes
+	target := models.Waypoint{ID: 1, Latitude: 50, Longitude: 30}
+	near := models.Waypoint{ID: 2, Latitude: 50.1, Longitude: 30}
+	farther := models.Waypoint{ID: 3, Latitude: 49.8, Longitude: 30}
+	tooFar := models.Waypoint{ID: 4, Latitude: 51, Longitude: 30}
+	neighbours := []models.Waypoint{target, near, farther, tooFar}
+
+	tests := []struct {
+		name       string
+		readings   []models.SensorData
+		wantMethod EstimateMethod // empty when no estimate is expected
+		wantTemp   float64
+	}{
+		{
+			"reading within the tolerance",
+			[]models.SensorData{reading(1, -10*time.Minute, 12), reading(1, -2*time.Hour, 5)},
+			EstimateMeasured, 12,
+		},
+		{
+			"closer reading after the time",
+			[]models.SensorData{reading(1, -20*time.Minute, 10), reading(1, 10*time.Minute, 20)},
+			EstimateMeasured, 20,
+		},
+		{
+			"interpolated between the readings around the time",
+			[]models.SensorData{reading(1, -time.Hour, 10), reading(1, 3*time.Hour, 18), reading(1, -3*time.Hour, 0)},
+			EstimateTemporal, 12,
+		},
+		{
+			"gap too long falls back to the neighbours",
+			[]models.SensorData{
+				reading(1, -4*time.Hour, 0), reading(1, 3*time.Hour, 0),
+				reading(2, 0, 10), reading(3, 0, 20),
+			},
+			EstimateSpatial, 12,
+		},
+		{
+			"neighbours are interpolated in time",
+			[]models.SensorData{reading(2, -time.Hour, 5), reading(2, time.Hour, 15), reading(3, 0, 20)},
+			EstimateSpatial, 12,
+		},
+		{
+			"neighbour too far away",
+			[]models.SensorData{reading(4, 0, 10)},
+			"", 0,
+		},
+		{
+			"only one side of the time",
+			[]models.SensorData{reading(1, -2*time.Hour, 10)},
+			"", 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimateWaypoints([]models.Waypoint{target}, neighbours, at, tt.readings, DefaultInterpolationConfig())
+			if tt.wantMethod == "" {
+				if len(got) != 0 {
+					t.Fatalf("estimates %+v, want none", got)
+				}
+				return
+			}
+
+			if len(got) != 1 {
+				t.Fatalf("got %d estimates, want 1", len(got))
+			}
+			if got[0].Method != tt.wantMethod || got[0].Estimated != (tt.wantMethod != EstimateMeasured) {
+				t.Errorf("method %s estimated %v, want %s", got[0].Method, got[0].Estimated, tt.wantMethod)
+			}
+			if math.Abs(got[0].Temperature-tt.wantTemp) > 1e-6 {
+				t.Errorf("temperature %v, want %v", got[0].Temperature, tt.wantTemp)
+			}
+			if !got[0].Date.Equal(at) {
+				t.Errorf("date %v, want %v", got[0].Date, at)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package analysis // import "wayra/internal/core/domain/utils/analysis"
+
+import (
+	"errors"
+	"math"
+	"time"
+	"wayra/internal/core/domain/models"
+	utilsMath "wayra/internal/core/domain/utils/math"
+)
+
+// ErrInvalidQualityConfig is returned when the thresholds of the data-quality pass make no sense
+var ErrInvalidQualityConfig = errors.New(
+	"min_readings must be at least 3 and at most window, thresholds must be positive, " +
+		"stuck_run must be at least 2 and at most window + 1, gap_factor must exceed 1",
+)
+
+// madScale turns a median absolute deviation into an estimate of the standard deviation of normal data
+const madScale = 0.6745
+
+// MetricThresholds holds a threshold for every measurement, in its canonical unit
+type MetricThresholds struct {
+	Temperature  float64 `json:"temperature"`   // °C
+	Humidity     float64 `json:"humidity"`      // %
+	WindSpeed    float64 `json:"wind_speed"`    // m/s
+	MeanPressure float64 `json:"mean_pressure"` // hPa
+}
+
+// values returns the thresholds in the order of measurementSeries
+// returns: the thresholds
+func (t MetricThresholds) values() [4]float64 {
+	return [4]float64{t.Temperature, t.Humidity, t.WindSpeed, t.MeanPressure}
+}
+
+// QualityConfig holds the thresholds of the data-quality pass
+type QualityConfig struct {
+	Window       int              `json:"window"`        // preceding readings an outlier is judged against
+	MinReadings  int              `json:"min_readings"`  // preceding readings needed to judge outliers
+	MADThreshold float64          `json:"mad_threshold"` // modified z-score above which a measurement is an outlier
+	ZThreshold   float64          `json:"z_threshold"`   // z-score used instead when the median absolute deviation is 0
+	MinDeviation MetricThresholds `json:"min_deviation"` // distance to the median below which a measurement is never an outlier
+	SpikeStep    MetricThresholds `json:"spike_step"`    // smallest jump away from and back to the neighbours of a spike
+	StuckRun     int              `json:"stuck_run"`     // identical readings in a row after which the sensor is frozen
+	GapFactor    float64          `json:"gap_factor"`    // expected intervals that may pass between two readings
+}
+
+// DefaultQualityConfig returns the thresholds used for every waypoint
+// The minimum deviations keep the resolution of the sensors and ordinary weather changes from being outliers.
+// returns: the default config
+func DefaultQualityConfig() QualityConfig {
+	return QualityConfig{
+		Window:       30,
+		MinReadings:  10,
+		MADThreshold: 3.5,
+		ZThreshold:   4,
+		MinDeviation: MetricThresholds{Temperature: 5, Humidity: 15, WindSpeed: 10, MeanPressure: 10},
+		SpikeStep:    MetricThresholds{Temperature: 8, Humidity: 25, WindSpeed: 20, MeanPressure: 5},
+		StuckRun:     6,
+		GapFactor:    2,
+	}
+}
+
+// Validate checks the thresholds
+// returns: ErrInvalidQualityConfig if a threshold is out of range
+func (c QualityConfig) Validate() error {
+	if c.MinReadings < 3 || c.Window < c.MinReadings || c.MADThreshold <= 0 || c.ZThreshold <= 0 {
+		return ErrInvalidQualityConfig
+	}
+	if c.StuckRun < 2 || c.StuckRun > c.Window+1 || c.GapFactor <= 1 {
+		return ErrInvalidQualityConfig
+	}
+
+	for _, thresholds := range []MetricThresholds{c.MinDeviation, c.SpikeStep} {
+		for _, threshold := range thresholds.values() {
+			if threshold <= 0 {
+				return ErrInvalidQualityConfig
+			}
+		}
+	}
+
+	return nil
+}
+
+// ContextReadings returns how many readings before and after a range AssessQuality needs
+// to compute the flags of the range and of the readings next to it exactly
+// returns: the number of readings
+func (c QualityConfig) ContextReadings() int {
+	return c.Window + 1
+}
+
+// AssessQuality computes the data-quality flags of the readings of one waypoint
+// Outliers, frozen sensors and gaps are judged from the preceding readings only, so the flags of a reading
+// never change when newer readings arrive. A spike is judged from both neighbours, so the last reading
+// can only be flagged as a spike once the next one is known.
+// readings: readings of the waypoint ordered by date
+// interval: expected interval between two readings, 0 when unknown, which disables the gap detection
+// config: thresholds of the pass
+// returns: the flags of every reading, in the order of the readings
+func AssessQuality(readings []models.SensorData, interval time.Duration, config QualityConfig) []models.QualityFlags {
+	flags := make([]models.QualityFlags, len(readings))
+
+	var series measurementSeries
+	for _, reading := range readings {
+		series.add(reading)
+	}
+
+	maxGap := time.Duration(float64(interval) * config.GapFactor)
+	gapBefore := func(i int) bool {
+		return interval > 0 && i > 0 && readings[i].Date.Sub(readings[i-1].Date) > maxGap
+	}
+
+	minDeviation := config.MinDeviation.values()
+	spikeStep := config.SpikeStep.values()
+
+	run := 1
+	for i := range readings {
+		if gapBefore(i) {
+			flags[i] |= models.QualityFlagGap
+		}
+
+		if i > 0 && sameMeasurements(readings[i], readings[i-1]) {
+			run++
+		} else {
+			run = 1
+		}
+		if run >= config.StuckRun {
+			flags[i] |= models.QualityFlagStuck
+		}
+
+		start := max(0, i-config.Window)
+		for m := range series {
+			if i-start >= config.MinReadings && isOutlier(series[m][i], series[m][start:i], minDeviation[m], config) {
+				flags[i] |= models.QualityFlagOutlier
+			}
+
+			if i > 0 && i < len(readings)-1 && !gapBefore(i) && !gapBefore(i+1) &&
+				isSpike(series[m][i-1], series[m][i], series[m][i+1], spikeStep[m]) {
+				flags[i] |= models.QualityFlagSpike
+			}
+		}
+	}
+
+	return flags
+}
+
+// isOutlier checks if a measurement is far from the window of the preceding measurements
+// The modified z-score based on the median absolute deviation is used, as a single outlier in the window
+// barely moves it. A window without spread falls back to the z-score, and a constant window makes
+// every measurement far enough from it an outlier.
+// value: measurement to judge
+// window: preceding measurements
+// minDeviation: distance to the median below which the measurement is never an outlier
+// config: thresholds of the pass
+// returns: true if the measurement is an outlier
+func isOutlier(value float64, window []float64, minDeviation float64, config QualityConfig) bool {
+	median := utilsMath.Median(window)
+	deviation := math.Abs(value - median)
+	if deviation < minDeviation {
+		return false
+	}
+
+	if mad := utilsMath.MedianAbsoluteDeviation(window); mad > 0 {
+		return madScale*deviation/mad > config.MADThreshold
+	}
+
+	if stdDev := utilsMath.StdDev(window); stdDev > 0 {
+		return math.Abs(value-utilsMath.Mean(window))/stdDev > config.ZThreshold
+	}
+
+	return true
+}
+
+// isSpike checks if a measurement jumps away from the previous one and back at the next one
+// previous: previous measurement
+// value: measurement to judge
+// next: next measurement
+// step: smallest jump of a spike
+// returns: true if the measurement is a spike
+func isSpike(previous, value, next, step float64) bool {
+	up, down := value-previous, next-value
+	return math.Abs(up) >= step && math.Abs(down) >= step && (up > 0) != (down > 0)
+}
+
+// sameMeasurements checks if every measurement of two readings is identical
+// a: first reading
+// b: second reading
+// returns: true if the readings only differ by their date
+func sameMeasurements(a, b models.SensorData) bool {
+	return a.Temperature == b.Temperature &&
+		a.Humidity == b.Humidity &&
+		a.WindSpeed == b.WindSpeed &&
+		a.MeanPressure == b.MeanPressure
+}
@@ -0,0 +1,143 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// testQualityConfig is a small config so the cases stay short
+var testQualityConfig = QualityConfig{
+	Window:       5,
+	MinReadings:  3,
+	MADThreshold: 3.5,
+	ZThreshold:   4,
+	MinDeviation: MetricThresholds{Temperature: 1, Humidity: 1, WindSpeed: 1, MeanPressure: 1},
+	SpikeStep:    MetricThresholds{Temperature: 5, Humidity: 5, WindSpeed: 5, MeanPressure: 5},
+	StuckRun:     3,
+	GapFactor:    2,
+}
+
+func TestIsOutlier(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  float64
+		window []float64
+		want   bool
+	}{
+		{"closer than the minimum deviation", 10.5, []float64{10, 10, 10}, false},
+		{"far by the median absolute deviation", 30, []float64{10, 12, 11, 13, 10}, true},
+		{"within the median absolute deviation", 14, []float64{10, 12, 11, 13, 10}, false},
+		{"far by the z-score without a median absolute deviation", 20, []float64{10, 10, 10, 10, 12}, true},
+		{"within the z-score without a median absolute deviation", 13, []float64{10, 10, 10, 10, 12}, false},
+		{"away from a constant window", 12, []float64{10, 10, 10}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOutlier(tt.value, tt.window, 1, testQualityConfig); got != tt.want {
+				t.Errorf("isOutlier(%v, %v) = %v, want %v", tt.value, tt.window, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSpike(t *testing.T) {
+	tests := []struct {
+		name                  string
+		previous, value, next float64
+		want                  bool
+	}{
+		{"up and back", 10, 20, 10, true},
+		{"down and back by the step", 10, 5, 10, true},
+		{"steady rise", 10, 20, 30, false},
+		{"jump smaller than the step", 10, 14, 10, false},
+		{"no way back", 10, 20, 18, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSpike(tt.previous, tt.value, tt.next, 5); got != tt.want {
+				t.Errorf("isSpike(%v, %v, %v) = %v, want %v", tt.previous, tt.value, tt.next, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssessQuality(t *testing.T) {
+	// series builds readings every 10 minutes, the humidity changing so the readings are never identical
+	series := func(temperatures ...float64) []models.SensorData {
+		readings := make([]models.SensorData, 0, len(temperatures))
+		for i, temperature := range temperatures {
+			readings = append(readings, testReading(1, 10*i, temperature, 50+float64(i%2), 5, 1013))
+		}
+		return readings
+	}
+	delayed := func(readings []models.SensorData, i int, delay time.Duration) []models.SensorData {
+		for ; i < len(readings); i++ {
+			readings[i].Date = readings[i].Date.Add(delay)
+		}
+		return readings
+	}
+
+	const (
+		outlier = models.QualityFlagOutlier
+		spike   = models.QualityFlagSpike
+		stuck   = models.QualityFlagStuck
+		gap     = models.QualityFlagGap
+	)
+
+	tests := []struct {
+		name     string
+		readings []models.SensorData
+		interval time.Duration
+		want     []models.QualityFlags
+	}{
+		{"clean", series(10, 10.5, 11, 10.5, 10), 10 * time.Minute, []models.QualityFlags{0, 0, 0, 0, 0}},
+		{"outlier", series(10, 12, 11, 13, 10, 30), 10 * time.Minute, []models.QualityFlags{0, 0, 0, 0, 0, outlier}},
+		{"outlier needs enough preceding readings", series(10, 12, 30), 10 * time.Minute, []models.QualityFlags{0, 0, 0}},
+		{"spike", series(10, 10.5, 25, 11), 10 * time.Minute, []models.QualityFlags{0, 0, spike, 0}},
+		{"last reading is no spike yet", series(10, 10.5, 25), 10 * time.Minute, []models.QualityFlags{0, 0, 0}},
+		{
+			"spike across a gap",
+			delayed(series(10, 10.5, 25, 11), 3, time.Hour), 10 * time.Minute,
+			[]models.QualityFlags{0, 0, 0, gap},
+		},
+		{
+			"gap",
+			delayed(series(10, 10.5, 11, 10.5), 3, 30*time.Minute), 10 * time.Minute,
+			[]models.QualityFlags{0, 0, 0, gap},
+		},
+		{
+			"gap detection disabled without an interval",
+			delayed(series(10, 10.5, 11, 10.5), 3, 30*time.Minute), 0,
+			[]models.QualityFlags{0, 0, 0, 0},
+		},
+		{
+			"stuck",
+			[]models.SensorData{
+				testReading(1, 0, 10, 50, 5, 1013),
+				testReading(1, 10, 10, 50, 5, 1013),
+				testReading(1, 20, 10, 50, 5, 1013),
+				testReading(1, 30, 10, 50, 5, 1013),
+				testReading(1, 40, 10.5, 50, 5, 1013),
+			},
+			10 * time.Minute,
+			[]models.QualityFlags{0, 0, stuck, stuck, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AssessQuality(tt.readings, tt.interval, testQualityConfig)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d flags, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("reading %d: flags %v, want %v", i, got[i].Names(), tt.want[i].Names())
+				}
+			}
+		})
+	}
+}
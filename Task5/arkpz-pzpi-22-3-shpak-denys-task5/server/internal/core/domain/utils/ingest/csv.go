@@ -0,0 +1,280 @@
+package ingest // import "wayra/internal/core/domain/utils/ingest"
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// Errors returned while reading a CSV import
+var (
+	ErrMissingColumn   = errors.New("column not found")
+	ErrUnknownField    = errors.New("unknown field")
+	ErrTooManyLines    = errors.New("too many lines")
+	ErrInvalidDate     = errors.New("invalid date")
+	ErrInvalidNumber   = errors.New("invalid number")
+	ErrDateOutOfBounds = errors.New("date is in the future or before any valid reading")
+)
+
+// csvFields are the fields a CSV import can map columns to
+var csvFields = []string{"date", "temperature", "humidity", "wind_speed", "mean_pressure", "waypoint_id", "device_serial"}
+
+// csvAliases holds the other names of the fields that are only found in the headers of logger exports
+var csvAliases = map[string][]string{
+	"date":          {"timestamp", "time", "datetime", "recorded_at"},
+	"device_serial": {"serial", "deviceSerial", "device"},
+}
+
+// CSVOptions tells how to read a CSV import
+type CSVOptions struct {
+	Columns    map[string]string // header of the column of every field by field name, other fields are matched by name or alias
+	Units      *Units            // units of the measurements, canonical units when nil
+	DateLayout string            // Go layout of the dates, RFC 3339 when empty
+	Location   *time.Location    // time zone of the dates without an offset, UTC when nil
+	Delimiter  rune              // field delimiter, ',' when 0
+	MaxLines   int               // maximum number of data lines, no limit when 0
+}
+
+// CSVLine is a data line of a CSV import
+type CSVLine struct {
+	Line         int               // line number in the file, the header is line 1
+	Reading      models.SensorData // reading in canonical units with its UTC date, WaypointID is 0 when not in the file
+	DeviceSerial string            // serial of the device, empty when not in the file
+	Err          error             // why the line is invalid, nil when it is valid
+}
+
+// ReadCSV reads every data line of a CSV import
+// The dates are historical and trusted as they are, they are neither corrected for a clock skew nor
+// replaced by the receive time, so a date that can not be valid makes the line invalid.
+// r: CSV file with a header line
+// options: how to read the file
+// returns: the data lines, or ErrMissingColumn, ErrUnknownField, ErrTooManyLines or a CSV error for the whole file
+func ReadCSV(r io.Reader, options CSVOptions) ([]CSVLine, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if options.Delimiter != 0 {
+		reader.Comma = options.Delimiter
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := csvColumns(header, options.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := options.DateLayout
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	location := options.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	latest := time.Now().UTC().Add(MaxClockAhead)
+	var lines []CSVLine
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			lines = append(lines, CSVLine{Line: parseErr.Line, Err: parseErr.Err})
+			continue
+		}
+
+		if isBlankRecord(record) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		if options.MaxLines > 0 && len(lines) >= options.MaxLines {
+			return nil, fmt.Errorf("%w: at most %d", ErrTooManyLines, options.MaxLines)
+		}
+
+		parsed := CSVLine{Line: line}
+		parsed.Reading, parsed.DeviceSerial, parsed.Err = readCSVRecord(record, columns, options.Units, layout, location)
+		if parsed.Err == nil && (parsed.Reading.Date.Before(EarliestDate) || parsed.Reading.Date.After(latest)) {
+			parsed.Err = ErrDateOutOfBounds
+		}
+
+		lines = append(lines, parsed)
+	}
+
+	return lines, nil
+}
+
+// csvColumns finds the column of every field in the header
+// header: header line of the file
+// mapping: header of the column of every field by field name
+// returns: the index of the column of every field found, or ErrMissingColumn and ErrUnknownField
+func csvColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	indexes := make(map[string]int, len(header))
+	for i, name := range header {
+		indexes[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	for field := range mapping {
+		if !isCSVField(field) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownField, field)
+		}
+	}
+
+	columns := make(map[string]int, len(csvFields))
+	for _, field := range csvFields {
+		if name, ok := mapping[field]; ok {
+			index, found := indexes[strings.ToLower(strings.TrimSpace(name))]
+			if !found {
+				return nil, fmt.Errorf("%w: %s", ErrMissingColumn, name)
+			}
+			columns[field] = index
+			continue
+		}
+
+		names := append([]string{field}, aliases[field]...)
+		names = append(names, csvAliases[field]...)
+		for _, name := range names {
+			if index, found := indexes[strings.ToLower(name)]; found {
+				columns[field] = index
+				break
+			}
+		}
+	}
+
+	for _, field := range []string{"date", "temperature", "humidity", "wind_speed", "mean_pressure"} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, field)
+		}
+	}
+
+	return columns, nil
+}
+
+// readCSVRecord builds the reading of a data line
+// record: fields of the line
+// columns: index of the column of every field
+// units: units of the measurements
+// layout: Go layout of the dates
+// location: time zone of the dates without an offset
+// returns: the reading in canonical units, the device serial and an error if the line is invalid
+func readCSVRecord(
+	record []string,
+	columns map[string]int,
+	units *Units,
+	layout string,
+	location *time.Location,
+) (models.SensorData, string, error) {
+	value := func(field string) string {
+		index, ok := columns[field]
+		if !ok || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	reading := Reading{SchemaVersion: SchemaVersion2, Units: units}
+	measurements := []struct {
+		field  string
+		target **float64
+	}{
+		{"temperature", &reading.Temperature},
+		{"humidity", &reading.Humidity},
+		{"wind_speed", &reading.WindSpeed},
+		{"mean_pressure", &reading.MeanPressure},
+	}
+	for _, measurement := range measurements {
+		raw := value(measurement.field)
+		if raw == "" {
+			continue
+		}
+
+		number, err := parseCSVNumber(raw)
+		if err != nil {
+			return models.SensorData{}, "", fmt.Errorf("%w: %s", ErrInvalidNumber, measurement.field)
+		}
+		*measurement.target = &number
+	}
+
+	if raw := value("waypoint_id"); raw != "" {
+		waypointID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return models.SensorData{}, "", fmt.Errorf("%w: waypoint_id", ErrInvalidNumber)
+		}
+		reading.WaypointID = uint(waypointID)
+	}
+
+	sensorData, err := reading.ToSensorData()
+	if err != nil {
+		return models.SensorData{}, "", err
+	}
+
+	date, err := time.ParseInLocation(layout, value("date"), location)
+	if err != nil {
+		return models.SensorData{}, "", ErrInvalidDate
+	}
+	date = date.UTC().Truncate(time.Microsecond)
+
+	sensorData.Date = date
+	sensorData.DeviceDate = &date
+
+	return sensorData, value("device_serial"), nil
+}
+
+// parseCSVNumber parses a number, accepting a decimal comma as written by loggers in many locales
+// raw: number as written in the file
+// returns: the number, error
+func parseCSVNumber(raw string) (float64, error) {
+	if strings.Contains(raw, ",") && !strings.Contains(raw, ".") {
+		raw = strings.Replace(raw, ",", ".", 1)
+	}
+
+	number, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, ErrInvalidNumber
+	}
+
+	return number, nil
+}
+
+// isCSVField checks if a field can be mapped to a column
+// field: name of the field
+// returns: true if the field is known
+func isCSVField(field string) bool {
+	for _, known := range csvFields {
+		if field == known {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlankRecord checks if every field of a line is empty
+// record: fields of the line
+// returns: true if the line is blank
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+// Package ingest provides the versioned schema of the readings sent by the devices.
+// It accepts the measurements under their aliases and in the declared units,
+// converts them into the canonical units and rejects physically impossible values.
+package ingest // import "wayra/internal/core/domain/utils/ingest"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"wayra/internal/core/domain/models"
+)
+
+// Versions of the reading schema
+const (
+	SchemaVersion1      = 1              // first firmware, canonical units, pressure may be sent as "pressure"
+	SchemaVersion2      = 2              // the units of the measurements may be declared in "units"
+	LatestSchemaVersion = SchemaVersion2 // version of the readings built by the server
+)
+
+// Errors returned while reading a measurement
+var (
+	ErrUnsupportedSchemaVersion = errors.New("unsupported schema_version")
+	ErrUnitsNotSupported        = errors.New("units can only be declared with schema_version 2")
+	ErrUnknownUnit              = errors.New("unknown unit")
+	ErrAmbiguousField           = errors.New("field is sent under more than one alias")
+	ErrMissingMeasurement       = errors.New("measurement is required")
+	ErrImpossibleValue          = errors.New("value is physically impossible")
+)
+
+// aliases holds the other names the devices send the fields under, by canonical name
+var aliases = map[string][]string{
+	"schema_version": {"v", "version"},
+	"temperature":    {"temp", "t"},
+	"humidity":       {"hum", "rh", "relative_humidity"},
+	"wind_speed":     {"windSpeed", "wind"},
+	"mean_pressure":  {"pressure", "meanPressure"},
+	"waypoint_id":    {"waypointId", "waypoint"},
+}
+
+// Units declares the units the measurements of a reading are sent in, empty means the canonical unit
+type Units struct {
+	// Temperature unit: C, F or K
+	// Example: C
+	Temperature string `json:"temperature,omitempty" example:"C"`
+
+	// Wind speed unit: m/s, km/h, mph or kn
+	// Example: km/h
+	WindSpeed string `json:"wind_speed,omitempty" example:"km/h"`
+
+	// Pressure unit: hPa, mbar, kPa, Pa, mmHg or inHg
+	// Example: hPa
+	Pressure string `json:"pressure,omitempty" example:"hPa"`
+}
+
+// Reading is a reading as sent by a device
+// Every field is also accepted under the aliases listed in the aliases table.
+type Reading struct {
+	// SchemaVersion is the version of the schema, 1 when omitted
+	// Example: 2
+	SchemaVersion int `json:"schema_version,omitempty" example:"2"`
+
+	// Date is the date and time when the reading was recorded, in RFC 3339 with any offset
+	// Example: 2021-09-01T12:00:00Z
+	Date string `json:"date" example:"2021-09-01T12:00:00Z"`
+
+	// Temperature is the temperature recorded by the sensor, in °C unless declared in Units
+	// Example: 25.5
+	Temperature *float64 `json:"temperature" example:"25.5"`
+
+	// Humidity is the relative humidity recorded by the sensor, in percent
+	// Example: 50.0
+	Humidity *float64 `json:"humidity" example:"50.0"`
+
+	// WindSpeed is the wind speed recorded by the sensor, in m/s unless declared in Units
+	// Example: 5.2
+	WindSpeed *float64 `json:"wind_speed" example:"5.2"`
+
+	// MeanPressure is the pressure recorded by the sensor, in hPa unless declared in Units
+	// Example: 1013.25
+	MeanPressure *float64 `json:"mean_pressure" example:"1013.25"`
+
+	// WaypointID is the ID of the waypoint where the reading was recorded
+	// Example: 1
+	WaypointID uint `json:"waypoint_id" example:"1"`
+
+	// Units declares the units of the measurements, only with schema_version 2
+	Units *Units `json:"units,omitempty"`
+}
+
+// UnmarshalJSON decodes a reading, renaming the aliased fields to their canonical names
+// data: JSON object
+// returns: ErrAmbiguousField if a field is sent under more than one name, or a decoding error
+func (r *Reading) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	for canonical, names := range aliases {
+		for _, alias := range names {
+			value, ok := fields[alias]
+			if !ok {
+				continue
+			}
+
+			if _, ok := fields[canonical]; ok {
+				return fmt.Errorf("%w: %s", ErrAmbiguousField, canonical)
+			}
+
+			fields[canonical] = value
+			delete(fields, alias)
+		}
+	}
+
+	canonicalData, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	// plain has the fields of Reading without its methods, so decoding it does not recurse
+	type plain Reading
+	return json.Unmarshal(canonicalData, (*plain)(r))
+}
+
+// Normalize converts the measurements into the canonical units and checks that they are possible
+// Measurements that are not sent stay nil.
+// returns: the reading in canonical units, with the latest schema version and no units
+func (r Reading) Normalize() (Reading, error) {
+	switch r.SchemaVersion {
+	case 0, SchemaVersion1:
+		if r.Units != nil {
+			return Reading{}, ErrUnitsNotSupported
+		}
+	case SchemaVersion2:
+	default:
+		return Reading{}, fmt.Errorf("%w: %d", ErrUnsupportedSchemaVersion, r.SchemaVersion)
+	}
+
+	units := Units{}
+	if r.Units != nil {
+		units = *r.Units
+	}
+
+	normalized := r
+	normalized.SchemaVersion = LatestSchemaVersion
+	normalized.Units = nil
+
+	var err error
+	if normalized.Temperature, err = convert("temperature", r.Temperature, units.Temperature, temperatureUnits); err != nil {
+		return Reading{}, err
+	}
+	if normalized.Humidity, err = convert("humidity", r.Humidity, "", humidityUnits); err != nil {
+		return Reading{}, err
+	}
+	if normalized.WindSpeed, err = convert("wind_speed", r.WindSpeed, units.WindSpeed, windSpeedUnits); err != nil {
+		return Reading{}, err
+	}
+	if normalized.MeanPressure, err = convert("mean_pressure", r.MeanPressure, units.Pressure, pressureUnits); err != nil {
+		return Reading{}, err
+	}
+
+	return normalized, nil
+}
+
+// ToSensorData normalizes the reading and builds the sensor data, every measurement is required
+// The date is left to the caller.
+// returns: the sensor data in canonical units
+func (r Reading) ToSensorData() (models.SensorData, error) {
+	normalized, err := r.Normalize()
+	if err != nil {
+		return models.SensorData{}, err
+	}
+
+	measurements := []struct {
+		name  string
+		value *float64
+	}{
+		{"temperature", normalized.Temperature},
+		{"humidity", normalized.Humidity},
+		{"wind_speed", normalized.WindSpeed},
+		{"mean_pressure", normalized.MeanPressure},
+	}
+	for _, measurement := range measurements {
+		if measurement.value == nil {
+			return models.SensorData{}, fmt.Errorf("%w: %s", ErrMissingMeasurement, measurement.name)
+		}
+	}
+
+	return models.SensorData{
+		Temperature:  *normalized.Temperature,
+		Humidity:     *normalized.Humidity,
+		WindSpeed:    *normalized.WindSpeed,
+		MeanPressure: *normalized.MeanPressure,
+		WaypointID:   normalized.WaypointID,
+	}, nil
+}
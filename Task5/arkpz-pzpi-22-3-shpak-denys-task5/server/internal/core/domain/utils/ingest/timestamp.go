@@ -0,0 +1,101 @@
+package ingest // import "wayra/internal/core/domain/utils/ingest"
+
+import (
+	"sort"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// MaxClockAhead is how far in the future a corrected date may be before it is replaced by the receive time
+const MaxClockAhead = 5 * time.Minute
+
+// EarliestDate is the earliest valid date, older dates come from a clock that was never set,
+// like an ESP32 whose NTP sync failed and that counts from 1970
+var EarliestDate = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// NormalizeDate turns the date sent by a device into the UTC date the reading is stored with
+// deviceDate: date sent by the device, nil when it sent none
+// receivedAt: time the server received the reading
+// correction: clock skew of the device, subtracted from its date
+// lastReadingAt: corrected date of the newest reading of the device, nil when there is none
+// returns: the date, truncated to the microseconds kept by the database, and its timestamp flag
+func NormalizeDate(
+	deviceDate *time.Time,
+	receivedAt time.Time,
+	correction time.Duration,
+	lastReadingAt *time.Time,
+) (time.Time, string) {
+	receivedAt = receivedAt.UTC().Truncate(time.Microsecond)
+	if deviceDate == nil {
+		return receivedAt, models.TimestampFlagServerTime
+	}
+
+	date := deviceDate.Add(-correction).UTC().Truncate(time.Microsecond)
+	switch {
+	case date.After(receivedAt.Add(MaxClockAhead)):
+		return receivedAt, models.TimestampFlagFuture
+	case date.Before(EarliestDate):
+		return receivedAt, models.TimestampFlagAncient
+	case lastReadingAt != nil && date.Before(*lastReadingAt):
+		return date, models.TimestampFlagOutOfOrder
+	}
+
+	return date, ""
+}
+
+// SpreadFallbackDates gives distinct dates to the readings of a waypoint that fell back to the same receive time,
+// otherwise all but one of them would be dropped as duplicates
+// The readings are spread a microsecond apart in the order of the dates sent by their device.
+// Readings sent with the same date keep the same one, readings sent without a date come first in the order of the batch.
+// readings: normalized readings, their dates are changed in place
+func SpreadFallbackDates(readings []models.SensorData) {
+	type fallbackKey struct {
+		waypointID uint
+		date       time.Time
+	}
+
+	groups := make(map[fallbackKey][]int)
+	keys := []fallbackKey{}
+	for i := range readings {
+		if !isFallback(readings[i].TimestampFlag) {
+			continue
+		}
+
+		key := fallbackKey{readings[i].WaypointID, readings[i].Date}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, key := range keys {
+		indexes := groups[key]
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return deviceDateBefore(readings[indexes[a]].DeviceDate, readings[indexes[b]].DeviceDate)
+		})
+
+		offset := 0
+		for n, i := range indexes {
+			previous := readings[indexes[max(n-1, 0)]].DeviceDate
+			if n > 0 && (previous == nil || readings[i].DeviceDate == nil || !previous.Equal(*readings[i].DeviceDate)) {
+				offset++
+			}
+			readings[i].Date = key.date.Add(time.Duration(offset) * time.Microsecond)
+		}
+	}
+}
+
+// isFallback tells whether a reading with the timestamp flag is dated with its receive time
+func isFallback(flag string) bool {
+	return flag == models.TimestampFlagServerTime ||
+		flag == models.TimestampFlagFuture ||
+		flag == models.TimestampFlagAncient
+}
+
+// deviceDateBefore orders the dates sent by devices, a missing date comes first
+func deviceDateBefore(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	return a.Before(*b)
+}
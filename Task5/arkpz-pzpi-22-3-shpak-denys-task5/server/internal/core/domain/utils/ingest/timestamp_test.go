@@ -0,0 +1,127 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	receivedAt := time.Date(2024, 12, 1, 12, 0, 0, 123456789, time.UTC)
+	received := receivedAt.Truncate(time.Microsecond)
+	at := func(offset time.Duration) *time.Time {
+		date := received.Add(offset)
+		return &date
+	}
+
+	tests := []struct {
+		name          string
+		deviceDate    *time.Time
+		correction    time.Duration
+		lastReadingAt *time.Time
+		wantDate      time.Time
+		wantFlag      string
+	}{
+		{"no date", nil, 0, nil, received, models.TimestampFlagServerTime},
+		{"date taken as sent", at(-time.Minute), 0, nil, received.Add(-time.Minute), ""},
+		{"corrected for the skew", at(3*time.Hour - time.Minute), 3 * time.Hour, nil, received.Add(-time.Minute), ""},
+		{"slightly ahead is kept", at(MaxClockAhead), 0, nil, received.Add(MaxClockAhead), ""},
+		{"too far in the future", at(MaxClockAhead + time.Second), 0, nil, received, models.TimestampFlagFuture},
+		{"ahead until corrected", at(2 * time.Hour), 2 * time.Hour, nil, received, ""},
+		{"clock never set", &time.Time{}, 0, nil, received, models.TimestampFlagAncient},
+		{"older than the last reading", at(-time.Hour), 0, at(-time.Minute), received.Add(-time.Hour), models.TimestampFlagOutOfOrder},
+		{"newer than the last reading", at(-time.Minute), 0, at(-time.Hour), received.Add(-time.Minute), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, flag := NormalizeDate(tt.deviceDate, receivedAt, tt.correction, tt.lastReadingAt)
+			if !date.Equal(tt.wantDate) || flag != tt.wantFlag {
+				t.Errorf("NormalizeDate() = %v, %q, want %v, %q", date, flag, tt.wantDate, tt.wantFlag)
+			}
+			if date.Location() != time.UTC || date.Nanosecond()%1000 != 0 {
+				t.Errorf("date %v is not UTC truncated to the microsecond", date)
+			}
+		})
+	}
+}
+
+func TestSpreadFallbackDates(t *testing.T) {
+	received := time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *time.Time {
+		date := received.Add(offset)
+		return &date
+	}
+	reading := func(waypointID uint, deviceDate *time.Time, date time.Time, flag string) models.SensorData {
+		return models.SensorData{WaypointID: waypointID, DeviceDate: deviceDate, Date: date, TimestampFlag: flag}
+	}
+
+	const (
+		serverTime = models.TimestampFlagServerTime
+		future     = models.TimestampFlagFuture
+		ancient    = models.TimestampFlagAncient
+	)
+
+	tests := []struct {
+		name     string
+		readings []models.SensorData
+		want     []time.Duration // offsets of the dates from the receive time
+	}{
+		{
+			"backlog of a clock far ahead keeps the order of the device",
+			[]models.SensorData{
+				reading(1, at(48*time.Hour+2*time.Minute), received, future),
+				reading(1, at(48*time.Hour), received, future),
+				reading(1, at(48*time.Hour+time.Minute), received, future),
+			},
+			[]time.Duration{2 * time.Microsecond, 0, time.Microsecond},
+		},
+		{
+			"same date sent twice stays a duplicate",
+			[]models.SensorData{
+				reading(1, &time.Time{}, received, ancient),
+				reading(1, &time.Time{}, received, ancient),
+				reading(1, at(-30*365*24*time.Hour), received, ancient),
+			},
+			[]time.Duration{0, 0, time.Microsecond},
+		},
+		{
+			"readings without a date come first in the order of the batch",
+			[]models.SensorData{
+				reading(1, at(time.Hour), received, future),
+				reading(1, nil, received, serverTime),
+				reading(1, nil, received, serverTime),
+			},
+			[]time.Duration{2 * time.Microsecond, 0, time.Microsecond},
+		},
+		{
+			"waypoints and receive times are spread apart separately",
+			[]models.SensorData{
+				reading(1, nil, received, serverTime),
+				reading(2, nil, received, serverTime),
+				reading(1, nil, received.Add(time.Second), serverTime),
+			},
+			[]time.Duration{0, 0, time.Second},
+		},
+		{
+			"trusted dates are kept",
+			[]models.SensorData{
+				reading(1, at(0), received, ""),
+				reading(1, nil, received, serverTime),
+				reading(1, at(-time.Hour), received.Add(-time.Hour), models.TimestampFlagOutOfOrder),
+			},
+			[]time.Duration{0, 0, -time.Hour},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SpreadFallbackDates(tt.readings)
+			for i, reading := range tt.readings {
+				if want := received.Add(tt.want[i]); !reading.Date.Equal(want) {
+					t.Errorf("reading %d dated %v, want %v", i, reading.Date, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package ingest // import "wayra/internal/core/domain/utils/ingest"
+
+import (
+	"fmt"
+	"math"
+)
+
+// quantity describes how a measurement is converted into its canonical unit and which values are possible
+type quantity struct {
+	canonical   string                           // canonical unit, used when no unit is declared
+	conversions map[string]func(float64) float64 // conversion into the canonical unit, by unit
+	min, max    float64                          // possible values in the canonical unit
+}
+
+// temperatureUnits converts temperatures into °C
+// The range covers the coldest and hottest air temperatures ever recorded, with a margin for cargo refrigeration.
+var temperatureUnits = quantity{
+	canonical: "C",
+	conversions: map[string]func(float64) float64{
+		"C": func(v float64) float64 { return v },
+		"F": func(v float64) float64 { return (v - 32) * 5 / 9 },
+		"K": func(v float64) float64 { return v - 273.15 },
+	},
+	min: -100,
+	max: 70,
+}
+
+// humidityUnits holds the relative humidity in percent
+var humidityUnits = quantity{
+	canonical: "%",
+	conversions: map[string]func(float64) float64{
+		"%": func(v float64) float64 { return v },
+	},
+	min: 0,
+	max: 100,
+}
+
+// windSpeedUnits converts wind speeds into m/s
+// The strongest gust ever recorded is 113 m/s.
+var windSpeedUnits = quantity{
+	canonical: "m/s",
+	conversions: map[string]func(float64) float64{
+		"m/s":  func(v float64) float64 { return v },
+		"km/h": func(v float64) float64 { return v / 3.6 },
+		"mph":  func(v float64) float64 { return v * 0.44704 },
+		"kn":   func(v float64) float64 { return v * 0.514444 },
+	},
+	min: 0,
+	max: 120,
+}
+
+// pressureUnits converts pressures into hPa
+// The range goes from above the summit of Everest to above the highest pressure ever recorded at sea level.
+var pressureUnits = quantity{
+	canonical: "hPa",
+	conversions: map[string]func(float64) float64{
+		"hPa":  func(v float64) float64 { return v },
+		"mbar": func(v float64) float64 { return v },
+		"kPa":  func(v float64) float64 { return v * 10 },
+		"Pa":   func(v float64) float64 { return v / 100 },
+		"mmHg": func(v float64) float64 { return v * 1.333224 },
+		"inHg": func(v float64) float64 { return v * 33.863886 },
+	},
+	min: 300,
+	max: 1100,
+}
+
+// convert converts a measurement into the canonical unit of its quantity and checks that it is possible
+// name: name of the measurement, used in the errors
+// value: measurement, nil when it was not sent
+// unit: declared unit, empty for the canonical unit
+// q: quantity of the measurement
+// returns: the measurement in the canonical unit, nil when it was not sent
+func convert(name string, value *float64, unit string, q quantity) (*float64, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if unit == "" {
+		unit = q.canonical
+	}
+
+	conversion, ok := q.conversions[unit]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s for %s", ErrUnknownUnit, unit, name)
+	}
+
+	converted := conversion(*value)
+	if math.IsNaN(converted) || converted < q.min || converted > q.max {
+		return nil, fmt.Errorf("%w: %s %g %s, expected %g to %g %s",
+			ErrImpossibleValue, name, *value, unit, q.min, q.max, q.canonical)
+	}
+
+	return &converted, nil
+}
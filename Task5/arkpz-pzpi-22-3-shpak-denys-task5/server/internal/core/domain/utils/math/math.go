@@ -2,7 +2,10 @@
 // This package is used to perform mathematical operations on data.
 package math // import "wayra/internal/core/domain/utils/math"
 
-import "math"
+import (
+	"math"
+	"sort"
+)
 
 // Transpose returns the transpose of a matrix.
 // matrix: a 2D slice of float64.
@@ -178,3 +181,33 @@ func Sum(data []float64) float64 {
 	}
 	return sum
 }
+
+// Median returns the median of a slice of float64, the data is not modified.
+// data: a slice of float64.
+// returns: a float64 - the median of the data, 0 for no data.
+func Median(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// MedianAbsoluteDeviation returns the median of the absolute deviations of the data from its median.
+// data: a slice of float64.
+// returns: a float64 - the median absolute deviation of the data, 0 for no data.
+func MedianAbsoluteDeviation(data []float64) float64 {
+	median := Median(data)
+	deviations := make([]float64, len(data))
+	for i, value := range data {
+		deviations[i] = math.Abs(value - median)
+	}
+	return Median(deviations)
+}
@@ -0,0 +1,73 @@
+// Package meteo provides the meteorological metrics derived from the measured ones.
+// Temperatures are in °C, relative humidities in percent and wind speeds in m/s.
+package meteo // import "wayra/internal/core/domain/utils/meteo"
+
+import "math"
+
+// Coefficients of the Magnus formula over water, valid from -45°C to 60°C
+const (
+	MagnusA = 17.62  // dimensionless
+	MagnusB = 243.12 // °C
+)
+
+// Limits of the derived metrics
+const (
+	MinHumidity           = 1.0  // %, drier readings are clamped so the dew point stays finite
+	HeatIndexMin          = 26.7 // °C, below it the heat index equals the temperature
+	WindChillMaxTemp      = 10.0 // °C, above it the wind chill equals the temperature
+	WindChillMinWindSpeed = 4.8  // km/h, calmer air does not chill
+	IcingMaxTemperature   = 0.0  // °C, icing needs freezing air
+	IcingDewPointSpread   = 3.0  // °C, icing needs air this close to saturation
+)
+
+// DewPoint computes the temperature at which the air would be saturated, by the Magnus formula
+// temperature: temperature of the air
+// humidity: relative humidity, clamped to [MinHumidity, 100]
+// returns: the dew point
+func DewPoint(temperature, humidity float64) float64 {
+	humidity = math.Min(math.Max(humidity, MinHumidity), 100)
+
+	gamma := math.Log(humidity/100) + MagnusA*temperature/(MagnusB+temperature)
+	return MagnusB * gamma / (MagnusA - gamma)
+}
+
+// HeatIndex computes the temperature felt in hot and humid air, by the Rothfusz regression of the NWS
+// The small adjustments of the NWS for very dry and very humid air are left out.
+// temperature: temperature of the air
+// humidity: relative humidity
+// returns: the heat index, the temperature itself below HeatIndexMin
+func HeatIndex(temperature, humidity float64) float64 {
+	if temperature < HeatIndexMin {
+		return temperature
+	}
+
+	t := temperature*1.8 + 32
+	rh := humidity
+	fahrenheit := -42.379 + 2.04901523*t + 10.14333127*rh -
+		0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+		0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+	return (fahrenheit - 32) / 1.8
+}
+
+// WindChill computes the temperature felt in cold wind, by the formula of Environment Canada and the NWS
+// temperature: temperature of the air
+// windSpeed: wind speed
+// returns: the wind chill, the temperature itself above WindChillMaxTemp or in calm air
+func WindChill(temperature, windSpeed float64) float64 {
+	kmh := windSpeed * 3.6
+	if temperature > WindChillMaxTemp || kmh <= WindChillMinWindSpeed {
+		return temperature
+	}
+
+	v := math.Pow(kmh, 0.16)
+	return 13.12 + 0.6215*temperature - 11.37*v + 0.3965*temperature*v
+}
+
+// IcingRisk checks if ice may form, that is the air is freezing and close to saturation
+// temperature: temperature of the air
+// humidity: relative humidity
+// returns: true if the temperature is at most IcingMaxTemperature and within IcingDewPointSpread of the dew point
+func IcingRisk(temperature, humidity float64) bool {
+	return temperature <= IcingMaxTemperature && temperature-DewPoint(temperature, humidity) <= IcingDewPointSpread
+}
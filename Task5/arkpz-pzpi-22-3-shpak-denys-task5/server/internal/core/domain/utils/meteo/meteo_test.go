@@ -0,0 +1,123 @@
+package meteo
+
+import (
+	"math"
+	"testing"
+)
+
+// fahrenheit converts a temperature in °F to °C, the unit of the NWS tables
+func fahrenheit(temperature float64) float64 {
+	return (temperature - 32) / 1.8
+}
+
+// kmh converts a wind speed in km/h to m/s, the unit of the Environment Canada tables
+func kmh(windSpeed float64) float64 {
+	return windSpeed / 3.6
+}
+
+func TestDewPoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		temperature float64
+		humidity    float64
+		want        float64
+		tolerance   float64
+	}{
+		{"mild air", 20, 50, 9.3, 0.05},
+		{"humid air", 30, 70, 23.9, 0.05},
+		{"freezing air", -10, 80, -12.8, 0.05},
+		{"saturated air", 25, 100, 25, 1e-9},
+		{"saturated at zero", 0, 100, 0, 1e-9},
+		{"dry air is clamped", 10, 0, DewPoint(10, MinHumidity), 1e-9},
+		{"supersaturated air is clamped", 15, 120, 15, 1e-9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DewPoint(tt.temperature, tt.humidity); math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("DewPoint(%v, %v) = %v, want %v ± %v", tt.temperature, tt.humidity, got, tt.want, tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestHeatIndex(t *testing.T) {
+	// The NWS table rounds to whole °F
+	tolerance := 1 / 1.8
+
+	tests := []struct {
+		name        string
+		temperature float64
+		humidity    float64
+		want        float64
+		tolerance   float64
+	}{
+		{"90 °F at 70 %", fahrenheit(90), 70, fahrenheit(106), tolerance},
+		{"100 °F at 40 %", fahrenheit(100), 40, fahrenheit(109), tolerance},
+		{"86 °F at 90 %", fahrenheit(86), 90, fahrenheit(105), tolerance},
+		{"95 °F at 50 %", fahrenheit(95), 50, fahrenheit(105), tolerance},
+		{"at the limit", HeatIndexMin, 50, 27.1, 0.05},
+		{"just below the limit", HeatIndexMin - 0.1, 50, HeatIndexMin - 0.1, 0},
+		{"mild air", 20, 90, 20, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HeatIndex(tt.temperature, tt.humidity); math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("HeatIndex(%v, %v) = %v, want %v ± %v", tt.temperature, tt.humidity, got, tt.want, tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestWindChill(t *testing.T) {
+	// The Environment Canada table rounds to whole °C
+	const tolerance = 0.5
+
+	tests := []struct {
+		name        string
+		temperature float64
+		windSpeed   float64
+		want        float64
+		tolerance   float64
+	}{
+		{"-20 °C at 30 km/h", -20, kmh(30), -33, tolerance},
+		{"-10 °C at 20 km/h", -10, kmh(20), -18, tolerance},
+		{"0 °C at 10 km/h", 0, kmh(10), -3, tolerance},
+		{"-30 °C at 50 km/h", -30, kmh(50), -49, tolerance},
+		{"at the temperature limit", WindChillMaxTemp, 10, 6.2, 0.05},
+		{"above the temperature limit", WindChillMaxTemp + 0.1, 10, WindChillMaxTemp + 0.1, 0},
+		{"at the wind speed limit", -10, kmh(WindChillMinWindSpeed), -10, 0},
+		{"calm air", -10, 0, -10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WindChill(tt.temperature, tt.windSpeed); math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("WindChill(%v, %v) = %v, want %v ± %v", tt.temperature, tt.windSpeed, got, tt.want, tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestIcingRisk(t *testing.T) {
+	tests := []struct {
+		name        string
+		temperature float64
+		humidity    float64
+		want        bool
+	}{
+		{"freezing fog", -2, 95, true},
+		{"at zero and saturated", 0, 100, true},
+		{"freezing dry air", -5, 40, false},
+		{"saturated above zero", 1, 100, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IcingRisk(tt.temperature, tt.humidity); got != tt.want {
+				t.Errorf("IcingRisk(%v, %v) = %v, want %v", tt.temperature, tt.humidity, got, tt.want)
+			}
+		})
+	}
+}
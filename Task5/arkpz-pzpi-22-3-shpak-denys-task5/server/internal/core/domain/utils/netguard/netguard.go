@@ -0,0 +1,147 @@
+// Package netguard provides the policy of the hosts the server may send requests to on behalf of the companies.
+// The webhooks of the companies must not reach the loopback, private or link-local addresses of the network
+// the server runs in, unless the host is allowed in the config.
+package netguard // import "wayra/internal/core/domain/utils/netguard"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ErrAddressNotAllowed is returned for the hosts resolving to a loopback, private, link-local or unspecified address
+var ErrAddressNotAllowed = errors.New("address is loopback, private or link-local")
+
+// Networks that are not public although the netip.Addr methods do not tell so
+var (
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10") // carrier-grade NAT, RFC 6598
+	thisNetwork        = netip.MustParsePrefix("0.0.0.0/8")     // "this network", RFC 791
+)
+
+// Guard tells which hosts may be reached
+type Guard struct {
+	hosts    map[string]bool // host names allowed whatever they resolve to
+	prefixes []netip.Prefix  // networks allowed although they are not public
+}
+
+// New creates a new Guard
+// allowedHosts: host names, addresses and CIDR networks allowed although they are not public
+// returns: *Guard
+func New(allowedHosts []string) *Guard {
+	guard := &Guard{hosts: make(map[string]bool)}
+	for _, host := range allowedHosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(host); err == nil {
+			guard.prefixes = append(guard.prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(host); err == nil {
+			guard.prefixes = append(guard.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		guard.hosts[host] = true
+	}
+
+	return guard
+}
+
+// AllowsHost checks if a host name is allowed whatever it resolves to
+// host: host name without the port
+// returns: true if the host is in the allowlist
+func (g *Guard) AllowsHost(host string) bool {
+	return g.hosts[strings.ToLower(strings.TrimSuffix(host, "."))]
+}
+
+// AllowsAddr checks if an address may be reached
+// addr: address to check
+// returns: true if the address is public or in an allowed network
+func (g *Guard) AllowsAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range g.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return IsPublic(addr)
+}
+
+// CheckHost checks a host before a request is sent to it, resolving the host names
+// ctx: context bounding the lookup
+// host: host name or address without the port
+// returns: ErrAddressNotAllowed if the host resolves to an address that may not be reached, the error of the lookup
+func (g *Guard) CheckHost(ctx context.Context, host string) error {
+	host = strings.Trim(host, "[]")
+	if g.AllowsHost(host) {
+		return nil
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !g.AllowsAddr(addr) {
+			return ErrAddressNotAllowed
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !g.AllowsAddr(addr) {
+			return ErrAddressNotAllowed
+		}
+	}
+
+	return nil
+}
+
+// DialContext dials like net.Dialer, refusing the connections to the addresses that may not be reached
+// The address is checked once resolved, so a host name changing what it resolves to after CheckHost is still refused.
+// dialer: dialer to dial with, its Control is replaced
+// returns: the dial function for http.Transport
+func (g *Guard) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	guarded := *dialer
+	guarded.Control = func(_, address string, _ syscall.RawConn) error {
+		addrPort, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return err
+		}
+		if !g.AllowsAddr(addrPort.Addr()) {
+			return ErrAddressNotAllowed
+		}
+		return nil
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(address); err == nil && g.AllowsHost(host) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		return guarded.DialContext(ctx, network, address)
+	}
+}
+
+// IsPublic checks if an address is neither loopback, private, link-local, shared nor unspecified
+// addr: address to check
+// returns: true if the address may be reached without being allowed
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() ||
+		addr.IsUnspecified() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+
+	return !sharedAddressSpace.Contains(addr) && !thisNetwork.Contains(addr)
+}
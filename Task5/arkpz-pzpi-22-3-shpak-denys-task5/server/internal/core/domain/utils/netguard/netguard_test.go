@@ -0,0 +1,115 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"127.10.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.32.0.1", true},
+		{"192.168.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := IsPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("IsPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		host    string
+		wantErr error
+	}{
+		{"public address", nil, "93.184.216.34", nil},
+		{"loopback", nil, "127.0.0.1", ErrAddressNotAllowed},
+		{"bracketed IPv6 loopback", nil, "[::1]", ErrAddressNotAllowed},
+		{"localhost", nil, "localhost", ErrAddressNotAllowed},
+		{"private", nil, "192.168.1.1", ErrAddressNotAllowed},
+		{"allowed address", []string{"192.168.1.1"}, "192.168.1.1", nil},
+		{"other address of an allowed one", []string{"192.168.1.1"}, "192.168.1.2", ErrAddressNotAllowed},
+		{"allowed network", []string{" 192.168.0.0/16 "}, "192.168.1.2", nil},
+		{"allowed host name", []string{"LocalHost"}, "localhost.", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.allowed).CheckHost(context.Background(), tt.host)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckHost(%s) = %v, want %v", tt.host, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	tests := []struct {
+		name    string
+		allowed []string
+		address string
+		wantErr error
+	}{
+		{"loopback refused", nil, "127.0.0.1:" + port, ErrAddressNotAllowed},
+		{"localhost refused once resolved", nil, "localhost:" + port, ErrAddressNotAllowed},
+		{"allowed address", []string{"127.0.0.1"}, "127.0.0.1:" + port, nil},
+		{"allowed host name", []string{"localhost"}, "localhost:" + port, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dial := New(tt.allowed).DialContext(&net.Dialer{})
+			conn, err := dial(context.Background(), "tcp4", tt.address)
+			if conn != nil {
+				conn.Close()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("dial %s = %v, want %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -33,3 +33,23 @@ func ParseDuration(durationStr string) (time.Duration, error) {
 	totalDuration := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
 	return totalDuration, nil
 }
+
+// ParseInterval parses an interval such as "5m", "1h" or "1d".
+// Besides the units of time.ParseDuration, whole days are accepted with the "d" suffix.
+// intervalStr: the interval to parse.
+// Returns a time.Duration and an error if the interval is invalid.
+func ParseInterval(intervalStr string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(intervalStr, "d"); ok {
+		count, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid interval: %s", intervalStr)
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval: %s", intervalStr)
+	}
+	return interval, nil
+}
@@ -0,0 +1,17 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// AlertRepository extends the Repository with the queries on alerts that link their readings,
+// filter them and change their status only from the statuses it may change from.
+type AlertRepository interface {
+	Repository[models.Alert]
+	Active(ctx context.Context, routeID uint) ([]models.Alert, error)
+	Filter(ctx context.Context, query models.AlertQuery) ([]models.Alert, error)
+	Touch(ctx context.Context, ids []uint, at time.Time) error
+	UpdateStatus(ctx context.Context, alert *models.Alert, from ...string) (bool, error)
+}
@@ -0,0 +1,14 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+)
+
+// AlertRuleRepository extends the Repository with the queries on alert rules that have to replace
+// the conditions of a rule and seed the rules of a company atomically.
+type AlertRuleRepository interface {
+	Repository[models.AlertRule]
+	ByCompany(ctx context.Context, companyID uint) ([]models.AlertRule, error)
+	AddDefaults(ctx context.Context, companyID uint, rules []models.AlertRule) error
+}
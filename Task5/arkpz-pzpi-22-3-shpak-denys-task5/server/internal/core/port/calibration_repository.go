@@ -0,0 +1,14 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// CalibrationRepository extends the Repository with the lookup of the profiles applying to a set of readings,
+// which has to load the profiles with their tables in a single pass on every ingest.
+type CalibrationRepository interface {
+	Repository[models.CalibrationProfile]
+	ForScopes(ctx context.Context, deviceIDs, waypointIDs []uint, until time.Time) ([]models.CalibrationProfile, error)
+}
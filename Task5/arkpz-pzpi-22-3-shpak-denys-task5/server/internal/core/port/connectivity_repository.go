@@ -0,0 +1,15 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// ConnectivityRepository tracks when the devices of the waypoints last reported.
+// It writes only the connectivity columns, so it is cheap enough to run on every ingest.
+type ConnectivityRepository interface {
+	TouchLastSeen(ctx context.Context, waypointIDs []uint, at time.Time) ([]models.Waypoint, error)
+	OnlineWaypoints(ctx context.Context) ([]models.Waypoint, error)
+	MarkOffline(ctx context.Context, waypointID uint, seenBefore time.Time) (bool, error)
+}
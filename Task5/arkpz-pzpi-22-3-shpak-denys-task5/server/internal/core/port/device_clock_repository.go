@@ -0,0 +1,12 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+)
+
+// DeviceClockRepository stores the clock skew estimated for the devices of the waypoints.
+type DeviceClockRepository interface {
+	ForWaypoints(ctx context.Context, waypointIDs []uint) ([]models.DeviceClock, error)
+	Save(ctx context.Context, clocks []models.DeviceClock) error
+}
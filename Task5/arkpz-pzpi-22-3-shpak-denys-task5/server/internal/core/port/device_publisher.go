@@ -0,0 +1,14 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+)
+
+// DevicePublisher is the interface that wraps the pushing of the configs and the alerts to the devices
+// connected over MQTT. The publishing is a no-op when no broker is configured.
+type DevicePublisher interface {
+	PublishWaypointConfig(ctx context.Context, waypointID uint) error
+	PublishCompanyConfigs(ctx context.Context, companyID uint) error
+	PublishAlert(ctx context.Context, waypoint models.Waypoint, alert models.Alert) error
+}
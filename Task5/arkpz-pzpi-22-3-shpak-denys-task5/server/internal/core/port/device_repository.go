@@ -0,0 +1,15 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// DeviceRepository extends the Repository with the assignment of devices to waypoints,
+// which has to update the device, its assignments and the waypoints together.
+type DeviceRepository interface {
+	Repository[models.Device]
+	Assign(ctx context.Context, device *models.Device, waypoint models.Waypoint, assignedByID uint, at time.Time) error
+	Unassign(ctx context.Context, device *models.Device, at time.Time) error
+}
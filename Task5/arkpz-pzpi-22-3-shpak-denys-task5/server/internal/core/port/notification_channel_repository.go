@@ -0,0 +1,14 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+)
+
+// NotificationChannelRepository extends the Repository with the queries on notification channels that have to
+// link their routes without changing them and list the deliveries of a channel.
+type NotificationChannelRepository interface {
+	Repository[models.NotificationChannel]
+	ByCompany(ctx context.Context, companyID uint) ([]models.NotificationChannel, error)
+	Deliveries(ctx context.Context, channelID uint, limit int) ([]models.NotificationDelivery, error)
+}
@@ -0,0 +1,12 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+)
+
+// NotificationSender is the interface that wraps the sending of a notification to a channel of one kind.
+// Send returns the HTTP status the receiver answered with, 0 when there is none.
+type NotificationSender interface {
+	Send(ctx context.Context, channel models.NotificationChannel, notification models.Notification) (int, error)
+}
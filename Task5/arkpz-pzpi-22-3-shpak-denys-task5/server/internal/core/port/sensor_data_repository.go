@@ -0,0 +1,51 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// SensorDataRepository extends the Repository with the queries on sensor data
+// that have to be computed by the database instead of preloading every reading.
+type SensorDataRepository interface {
+	Repository[models.SensorData]
+	LatestByRoute(ctx context.Context, routeID uint, limit int, includeFlagged bool) ([]models.SensorData, error)
+	StatisticsByRoute(
+		ctx context.Context,
+		routeID uint,
+		limit int,
+		includeFlagged bool,
+	) ([]models.SensorDataStatistics, error)
+	BetweenByRoute(ctx context.Context, routeID uint, from, to time.Time, includeFlagged bool) ([]models.SensorData, error)
+	BucketsByWaypoint(ctx context.Context, waypointID uint, query models.BucketQuery) ([]models.SensorDataBucket, error)
+	BucketsByRoute(
+		ctx context.Context,
+		routeID uint,
+		query models.BucketQuery,
+		perWaypoint bool,
+	) ([]models.SensorDataBucket, error)
+	Export(ctx context.Context, query models.SensorDataExportQuery, fn func(models.SensorDataExportRow) error) error
+	FindExisting(ctx context.Context, readings []models.SensorData) ([]models.SensorData, error)
+	AddBatch(ctx context.Context, readings []models.SensorData) error
+	QualityWindow(
+		ctx context.Context,
+		waypointID uint,
+		from, to time.Time,
+		contextReadings int,
+	) ([]models.SensorData, error)
+	UpdateQualityFlags(ctx context.Context, flags map[uint]models.QualityFlags) error
+	AroundByWaypoints(
+		ctx context.Context,
+		waypointIDs []uint,
+		at time.Time,
+		window time.Duration,
+	) ([]models.SensorData, error)
+	ForRecalibration(
+		ctx context.Context,
+		query models.RecalibrationQuery,
+		afterID uint,
+		limit int,
+	) ([]models.SensorData, error)
+	UpdateMeasurements(ctx context.Context, readings []models.SensorData) error
+}
@@ -0,0 +1,16 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// SensorDataRollupRepository rolls the raw readings up into the hourly and daily aggregate tables
+// and reads the raw readings and the rollups as a single series.
+type SensorDataRollupRepository interface {
+	CompanyIDs(ctx context.Context) ([]uint, error)
+	RollUp(ctx context.Context, companyID uint, before time.Time) (int64, error)
+	PurgeHourly(ctx context.Context, companyID uint, before time.Time) (int64, error)
+	SummaryByRoute(ctx context.Context, routeID uint, from, to time.Time) (models.SensorDataBucket, error)
+}
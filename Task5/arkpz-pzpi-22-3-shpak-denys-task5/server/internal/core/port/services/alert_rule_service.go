@@ -0,0 +1,26 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"errors"
+	"wayra/internal/core/domain/models"
+)
+
+// Errors returned by the AlertRuleService
+var (
+	ErrInvalidAlertRule = errors.New(
+		"type (up to 100 characters) and message (up to 255) are required, " +
+			"severity must be one of: info, warning, critical, " +
+			"logic one of: and, or, and duration between 0 and 1440 minutes",
+	)
+	ErrInvalidAlertCondition = errors.New(
+		"a rule needs at least one condition, metric must be one of: temperature, humidity, wind_speed, " +
+			"mean_pressure, dew_point, heat_index, wind_chill, dew_point_spread, and operator one of: lt, lte, gt, gte",
+	)
+)
+
+// AlertRuleService is the interface that wraps the methods managing the weather alert rules of the companies.
+type AlertRuleService interface {
+	Service[models.AlertRule]
+	GetByCompany(ctx context.Context, companyID uint) ([]models.AlertRule, error)
+}
@@ -0,0 +1,24 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"errors"
+	"wayra/internal/core/domain/models"
+)
+
+// Errors returned by the AlertService
+var (
+	ErrInvalidAlertQuery      = errors.New("status must be one of: open, acknowledged, resolved, and severity one of: info, warning, critical")
+	ErrAlertNotAcknowledgable = errors.New("only open alerts can be acknowledged")
+	ErrAlertResolved          = errors.New("alert is already resolved")
+)
+
+// AlertService is the interface that wraps the methods keeping the alerts raised by the alert rules
+// and moving them from open to acknowledged to resolved.
+type AlertService interface {
+	Service[models.Alert]
+	EvaluateRoute(ctx context.Context, route models.Route) error
+	GetAlerts(ctx context.Context, query models.AlertQuery) ([]models.Alert, error)
+	Acknowledge(ctx context.Context, alert *models.Alert, userID uint, comment string) error
+	Resolve(ctx context.Context, alert *models.Alert, userID uint, comment string) error
+}
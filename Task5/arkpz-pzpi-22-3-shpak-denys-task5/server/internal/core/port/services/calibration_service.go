@@ -0,0 +1,25 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"errors"
+	"wayra/internal/core/domain/models"
+)
+
+// Errors returned by the CalibrationService
+var (
+	ErrInvalidCalibrationMetric  = errors.New("metric must be one of: temperature, humidity, wind_speed, mean_pressure")
+	ErrInvalidCalibrationScope   = errors.New("a calibration belongs to either a device or a waypoint")
+	ErrInvalidCalibrationGain    = errors.New("gain must not be 0")
+	ErrInvalidCalibrationTable   = errors.New("a correction table needs at least 2 points with distinct raw values")
+	ErrInvalidRecalibrationQuery = errors.New("from must be before to, and either a device or a waypoint must be set")
+)
+
+// CalibrationService is the interface that wraps the methods correcting the readings of drifting sensors.
+type CalibrationService interface {
+	Service[models.CalibrationProfile]
+	GetByDevice(ctx context.Context, deviceID uint) ([]models.CalibrationProfile, error)
+	GetByWaypoint(ctx context.Context, waypointID uint) ([]models.CalibrationProfile, error)
+	Apply(ctx context.Context, readings []models.SensorData) error
+	Recalibrate(ctx context.Context, query models.RecalibrationQuery) (models.RecalibrationResult, error)
+}
@@ -0,0 +1,13 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"time"
+)
+
+// ConnectivityService is the interface that wraps the last-seen tracking and offline detection methods.
+type ConnectivityService interface {
+	RecordActivity(ctx context.Context, waypointIDs ...uint) error
+	DetectOffline(ctx context.Context) (int, error)
+	RunOfflineChecker(ctx context.Context, interval time.Duration)
+}
@@ -0,0 +1,21 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"errors"
+	"wayra/internal/core/domain/models"
+)
+
+// Errors returned by the DeviceAuthService
+var (
+	ErrDeviceSerialMissing = errors.New("waypoint has no device serial to issue a credential for")
+	ErrInvalidDeviceToken  = errors.New("invalid or revoked device token")
+)
+
+// DeviceAuthService provides the service interface for the authentication of devices.
+type DeviceAuthService interface {
+	IssueCredential(ctx context.Context, waypoint models.Waypoint, issuedByID uint) (string, *models.DeviceCredential, error)
+	RevokeCredentials(ctx context.Context, waypointID uint) error
+	ListCredentials(ctx context.Context, waypointID uint) ([]models.DeviceCredential, error)
+	AuthenticateDevice(ctx context.Context, token string) (*models.DeviceCredential, error)
+}
@@ -0,0 +1,22 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"errors"
+	"wayra/internal/core/domain/models"
+)
+
+// ErrInvalidDeviceConfig is returned when a device config holds values the devices can not apply
+var ErrInvalidDeviceConfig = errors.New("send_data_frequency must be between 1 and 1440 minutes")
+
+// DeviceConfigService is the interface that wraps the DeviceConfig methods.
+type DeviceConfigService interface {
+	Service[models.DeviceConfig]
+	GetCompanyDefaults(ctx context.Context, companyID uint) (*models.DeviceConfig, error)
+	GetWaypointOverride(ctx context.Context, waypointID uint) (*models.DeviceConfig, error)
+	SaveCompanyDefaults(ctx context.Context, companyID uint, changes models.DeviceConfig) (*models.DeviceConfig, error)
+	SaveWaypointOverride(ctx context.Context, waypoint models.Waypoint, changes models.DeviceConfig) (*models.DeviceConfig, error)
+	DeleteWaypointOverride(ctx context.Context, waypointID uint) error
+	GetEffectiveConfig(ctx context.Context, waypoint models.Waypoint) (*models.EffectiveDeviceConfig, error)
+	GetEffectiveConfigs(ctx context.Context, companyID uint, waypointIDs []uint) (map[uint]*models.EffectiveDeviceConfig, error)
+}
@@ -0,0 +1,27 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"errors"
+	"wayra/internal/core/domain/models"
+)
+
+// Errors returned by the DeviceService
+var (
+	ErrDeviceSerialRequired = errors.New("serial is required")
+	ErrDeviceSerialTaken    = errors.New("a device with this serial is already registered")
+	ErrInvalidDeviceStatus  = errors.New("status must be one of: active, maintenance, retired")
+	ErrDeviceRetired        = errors.New("retired devices can not be installed")
+	ErrDeviceNotAssigned    = errors.New("device is not installed at a waypoint")
+	ErrDeviceOtherCompany   = errors.New("device and waypoint belong to different companies")
+	ErrDeviceHasHistory     = errors.New("device has been installed before, retire it instead to keep its history")
+)
+
+// DeviceService is the interface that wraps the Device methods.
+type DeviceService interface {
+	Service[models.Device]
+	GetByCompany(ctx context.Context, companyID uint) ([]models.Device, error)
+	Assign(ctx context.Context, device *models.Device, waypoint models.Waypoint, assignedByID uint) error
+	Unassign(ctx context.Context, device *models.Device) error
+	GetAssignments(ctx context.Context, deviceID uint) ([]models.DeviceAssignment, error)
+}
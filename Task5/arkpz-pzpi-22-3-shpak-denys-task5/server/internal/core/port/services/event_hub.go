@@ -0,0 +1,17 @@
+package services // import "wayra/internal/core/port/services"
+
+import "wayra/internal/core/domain/models"
+
+// EventHub is the interface that wraps the methods of the in-process publish/subscribe of the stream events.
+type EventHub interface {
+	Publish(events ...models.StreamEvent)
+	Subscribe(scope models.StreamScope) Subscription
+	HasSubscribers(companyID uint) bool
+}
+
+// Subscription is the interface of a subscriber of the EventHub.
+type Subscription interface {
+	Events() <-chan models.StreamEvent
+	TakeDropped() uint64
+	Close()
+}
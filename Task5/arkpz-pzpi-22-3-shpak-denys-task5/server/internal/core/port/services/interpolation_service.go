@@ -0,0 +1,24 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"errors"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/analysis"
+)
+
+// ErrNoEstimate is returned when neither the readings of a waypoint nor the ones of its neighbours are close enough
+var ErrNoEstimate = errors.New("no readings of the waypoint or its neighbours are close enough to the time")
+
+// InterpolationService is the interface that wraps the methods estimating the missing readings of the waypoints.
+type InterpolationService interface {
+	EstimateWaypoint(ctx context.Context, waypoint models.Waypoint, at time.Time) (analysis.WaypointEstimate, error)
+	EstimateRoute(ctx context.Context, route models.Route, at time.Time) ([]analysis.WaypointEstimate, error)
+	EstimateWaypoints(
+		ctx context.Context,
+		companyID uint,
+		waypoints []models.Waypoint,
+		at time.Time,
+	) ([]analysis.WaypointEstimate, error)
+}
@@ -0,0 +1,31 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"errors"
+	"wayra/internal/core/domain/models"
+)
+
+// Errors returned by the NotificationService
+var (
+	ErrInvalidNotificationChannel = errors.New(
+		"name (up to 100 characters) is required, kind must be one of: webhook, email, " +
+			"and min_severity one of: info, warning, critical",
+	)
+	ErrNotificationKindUnavailable   = errors.New("email channels need the SMTP server to be configured")
+	ErrInvalidWebhookURL             = errors.New("url must be an absolute http or https URL")
+	ErrWebhookHostNotAllowed         = errors.New("url must resolve to a public address")
+	ErrInvalidEmailRecipients        = errors.New("recipients must be a comma-separated list of email addresses")
+	ErrInvalidNotificationTemplate   = errors.New("subject_template and body_template must be valid text/template templates")
+	ErrNotificationRouteOtherCompany = errors.New("routes must belong to the company of the channel")
+)
+
+// NotificationService is the interface that wraps the methods managing the notification channels of the companies
+// and sending the alerts to them.
+type NotificationService interface {
+	Service[models.NotificationChannel]
+	GetByCompany(ctx context.Context, companyID uint) ([]models.NotificationChannel, error)
+	GetDeliveries(ctx context.Context, channelID uint, limit int) ([]models.NotificationDelivery, error)
+	NotifyAlert(ctx context.Context, alert models.Alert, route models.Route)
+	SendTest(ctx context.Context, channel models.NotificationChannel) (*models.NotificationDelivery, error)
+}
@@ -0,0 +1,18 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"errors"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// ErrInvalidQualityRange is returned when a data-quality pass is asked for an empty range
+var ErrInvalidQualityRange = errors.New("from must be before to")
+
+// QualityService is the interface that wraps the methods flagging the data-quality problems of the readings.
+type QualityService interface {
+	AssessReadings(ctx context.Context, readings []models.SensorData) error
+	AssessWaypoint(ctx context.Context, waypointID uint, from, to time.Time) (models.QualityAssessment, error)
+	AssessRoute(ctx context.Context, route models.Route, from, to time.Time) (models.QualityAssessment, error)
+}
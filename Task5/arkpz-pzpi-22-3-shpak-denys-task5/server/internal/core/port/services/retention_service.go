@@ -0,0 +1,14 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+)
+
+// RetentionService is the interface that wraps the methods rolling up and purging the old sensor data.
+type RetentionService interface {
+	PolicyFor(companyID uint) models.RetentionPolicy
+	ApplyRetention(ctx context.Context) (int64, error)
+	RunRetention(ctx context.Context, interval time.Duration)
+}
@@ -2,10 +2,18 @@ package services // import "wayra/internal/core/port/services"
 
 import (
 	"context"
+	"errors"
+	"time"
 	"wayra/internal/core/domain/models"
 	"wayra/internal/core/domain/utils/analysis"
 )
 
+// ErrInvalidRouteCondition is returned when an unknown condition is reported for a route
+var ErrInvalidRouteCondition = errors.New("status must be one of: ok, bad_weather_detected")
+
+// ErrNoDeliveryHistory is returned when the company has no completed delivery to learn the delivery speed from
+var ErrNoDeliveryHistory = errors.New("no completed deliveries with readings to predict the delivery speed from")
+
 // RouteService is the interface that defines the methods that the RouteService
 type RouteService interface {
 	Service[models.Route]
@@ -15,5 +23,29 @@ type RouteService interface {
 		includeWeight bool,
 		considerPerishable bool,
 	) (string, *analysis.PredictData, []float64, models.Route, error)
+	RegressionFeatures() []analysis.RegressionFeature
 	GetWeatherAlert(ctx context.Context, route models.Route) ([]models.WeatherAlert, error)
+	RaiseAlerts(ctx context.Context, route models.Route) ([]analysis.RaisedAlert, error)
+	GetLatestSensorData(ctx context.Context, routeID uint, limit int, includeFlagged bool) ([]models.SensorData, error)
+	GetSensorDataStatistics(
+		ctx context.Context,
+		routeID uint,
+		limit int,
+		includeFlagged bool,
+	) ([]models.SensorDataStatistics, error)
+	ReportCondition(ctx context.Context, route *models.Route, event *models.RouteConditionEvent) error
+	GetConditionHistory(ctx context.Context, routeID uint, limit int) ([]models.RouteConditionEvent, error)
+	GetSensorDataBuckets(
+		ctx context.Context,
+		routeID uint,
+		query models.BucketQuery,
+		perWaypoint bool,
+	) ([]models.SensorDataBucket, error)
+	ClassifyWaypoints(
+		ctx context.Context,
+		route models.Route,
+		from, to time.Time,
+		config analysis.ClassifierConfig,
+		includeFlagged bool,
+	) ([]analysis.WaypointClassification, error)
 }
@@ -1,10 +1,25 @@
 package services // import "wayra/internal/core/port/services"
 
 import (
+	"context"
+	"errors"
 	"wayra/internal/core/domain/models"
 )
 
+// ErrInvalidBucketQuery is returned when a bucket query has an empty range, too small buckets or too many of them
+var ErrInvalidBucketQuery = errors.New("from must be before to, bucket must be at least 1m and the range at most 5000 buckets")
+
+// ErrInvalidExportQuery is returned when an export has an empty range or no scope
+var ErrInvalidExportQuery = errors.New("from must be before to and a waypoint, route or company must be selected")
+
+// ErrDuplicateReading is returned when the waypoint already has a reading recorded at the same date
+var ErrDuplicateReading = errors.New("the waypoint already has a reading recorded at this date")
+
 // SensorDataService is the interface that wraps the basic SensorData service methods.
 type SensorDataService interface {
 	Service[models.SensorData]
+	IngestBatch(ctx context.Context, readings []models.SensorData) ([]models.IngestStatus, error)
+	Import(ctx context.Context, readings []models.SensorData, dryRun bool) ([]models.IngestStatus, error)
+	GetWaypointBuckets(ctx context.Context, waypointID uint, query models.BucketQuery) ([]models.SensorDataBucket, error)
+	Export(ctx context.Context, query models.SensorDataExportQuery, fn func(models.SensorDataExportRow) error) error
 }
@@ -0,0 +1,11 @@
+package services // import "wayra/internal/core/port/services"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+)
+
+// StreamService is the interface that wraps the methods publishing the ingested readings and the alerts they raise.
+type StreamService interface {
+	PublishReadings(ctx context.Context, readings []models.SensorData)
+}
@@ -1,10 +1,24 @@
 package services // import "wayra/internal/core/port/services"
 
 import (
+	"context"
+	"errors"
 	"wayra/internal/core/domain/models"
 )
 
+// Errors returned by the WaypointService
+var (
+	ErrDeviceSerialNotFound  = errors.New("no waypoint is bound to this device serial")
+	ErrDeviceSerialDuplicate = errors.New("device serial is bound to more than one waypoint")
+	ErrDeviceSerialInUse     = errors.New("device serial is already bound to another waypoint")
+	ErrInvalidWaypointStatus = errors.New("status must be one of: ok, anomaly_detected")
+)
+
 // WaypointService is the interface that wraps the basic Waypoint methods.
 type WaypointService interface {
 	Service[models.Waypoint]
+	GetByDeviceSerial(ctx context.Context, deviceSerial string) (*models.Waypoint, error)
+	GetWithDevicesByCompany(ctx context.Context, companyID uint) ([]models.Waypoint, error)
+	ReportStatus(ctx context.Context, waypoint *models.Waypoint, event *models.WaypointStatusEvent) error
+	GetStatusHistory(ctx context.Context, waypointID uint, limit int) ([]models.WaypointStatusEvent, error)
 }
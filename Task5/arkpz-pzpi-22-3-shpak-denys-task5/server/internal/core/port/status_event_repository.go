@@ -0,0 +1,20 @@
+package port // import "wayra/internal/core/port"
+
+import (
+	"context"
+	"wayra/internal/core/domain/models"
+)
+
+// WaypointStatusEventRepository extends the Repository with the status history of a waypoint,
+// ordered and limited by the database.
+type WaypointStatusEventRepository interface {
+	Repository[models.WaypointStatusEvent]
+	History(ctx context.Context, waypointID uint, limit int) ([]models.WaypointStatusEvent, error)
+}
+
+// RouteConditionEventRepository extends the Repository with the condition history of a route,
+// ordered and limited by the database.
+type RouteConditionEventRepository interface {
+	Repository[models.RouteConditionEvent]
+	History(ctx context.Context, routeID uint, limit int) ([]models.RouteConditionEvent, error)
+}
@@ -0,0 +1,116 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"strings"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/analysis"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// maxAlertRuleDuration is the longest time in minutes the conditions of a rule may be required to hold
+const maxAlertRuleDuration = 24 * 60
+
+// AlertRuleService is a service that manages the weather alert rules of the companies
+type AlertRuleService struct {
+	*GenericService[models.AlertRule]                          // Embedding the generic service
+	alertRuleRepository               port.AlertRuleRepository // Repository with the rules of the companies
+}
+
+// NewAlertRuleService creates a new alert rule service
+// repo: the repository to use
+// returns: a new alert rule service
+func NewAlertRuleService(repo port.AlertRuleRepository) *AlertRuleService {
+	return &AlertRuleService{
+		GenericService:      NewGenericService[models.AlertRule](repo),
+		alertRuleRepository: repo,
+	}
+}
+
+// Create adds an alert rule to a company
+// ctx: context
+// rule: rule to add, with its company and its conditions
+// returns: ErrInvalidAlertRule, ErrInvalidAlertCondition or an error
+func (s *AlertRuleService) Create(ctx context.Context, rule *models.AlertRule) error {
+	if err := validateAlertRule(rule); err != nil {
+		return err
+	}
+
+	return s.Repository.Add(ctx, rule)
+}
+
+// Update replaces an alert rule and its conditions
+// ctx: context
+// rule: rule with its ID and every field it should have
+// returns: ErrInvalidAlertRule, ErrInvalidAlertCondition or an error
+func (s *AlertRuleService) Update(ctx context.Context, rule *models.AlertRule) error {
+	if err := validateAlertRule(rule); err != nil {
+		return err
+	}
+
+	return s.Repository.Update(ctx, rule)
+}
+
+// GetByCompany returns the alert rules of a company
+// A company that has no rules yet gets the default rules first, so disable a rule to silence it
+// instead of deleting every rule.
+// ctx: context
+// companyID: ID of the company
+// returns: the rules with their conditions ordered by ID and an error
+func (s *AlertRuleService) GetByCompany(ctx context.Context, companyID uint) ([]models.AlertRule, error) {
+	rules, err := s.alertRuleRepository.ByCompany(ctx, companyID)
+	if err != nil || len(rules) > 0 {
+		return rules, err
+	}
+
+	if err := s.alertRuleRepository.AddDefaults(ctx, companyID, analysis.DefaultAlertRules()); err != nil {
+		return nil, err
+	}
+
+	return s.alertRuleRepository.ByCompany(ctx, companyID)
+}
+
+// validateAlertRule checks the fields and the conditions of a rule
+// The type and the message are trimmed, the logic defaults to and.
+// rule: rule to check
+// returns: ErrInvalidAlertRule, ErrInvalidAlertCondition or nil
+func validateAlertRule(rule *models.AlertRule) error {
+	rule.Type = strings.TrimSpace(rule.Type)
+	rule.Message = strings.TrimSpace(rule.Message)
+	if rule.Logic == "" {
+		rule.Logic = models.AlertLogicAnd
+	}
+
+	if rule.Type == "" || len(rule.Type) > 100 || rule.Message == "" || len(rule.Message) > 255 {
+		return services.ErrInvalidAlertRule
+	}
+	if rule.Duration < 0 || rule.Duration > maxAlertRuleDuration {
+		return services.ErrInvalidAlertRule
+	}
+	switch rule.Severity {
+	case models.AlertSeverityInfo, models.AlertSeverityWarning, models.AlertSeverityCritical:
+	default:
+		return services.ErrInvalidAlertRule
+	}
+	if rule.Logic != models.AlertLogicAnd && rule.Logic != models.AlertLogicOr {
+		return services.ErrInvalidAlertRule
+	}
+
+	if len(rule.Conditions) == 0 {
+		return services.ErrInvalidAlertCondition
+	}
+	for _, condition := range rule.Conditions {
+		if !analysis.IsAlertMetric(condition.Metric) {
+			return services.ErrInvalidAlertCondition
+		}
+		switch condition.Operator {
+		case models.AlertOperatorLessThan, models.AlertOperatorLessOrEqual,
+			models.AlertOperatorGreaterThan, models.AlertOperatorGreaterOrEqual:
+		default:
+			return services.ErrInvalidAlertCondition
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/analysis"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// AlertService is a service that keeps the alerts raised by the alert rules of the companies
+// An alert is opened when the conditions of a rule start to hold at a waypoint and resolved when they clear.
+type AlertService struct {
+	*GenericService[models.Alert]                              // Embedding the generic service
+	alertRepository               port.AlertRepository         // Repository with the alerts
+	routeService                  services.RouteService        // Service evaluating the alert rules at the routes
+	eventHub                      services.EventHub            // Hub the changes of the alerts are published to
+	notificationService           services.NotificationService // Service sending the opened alerts to the notification channels
+	devicePublisher               port.DevicePublisher         // Publisher pushing the opened alerts to the devices
+}
+
+// NewAlertService creates a new alert service
+// repo: Repository with the alerts
+// routeService: Service evaluating the alert rules at the routes
+// eventHub: Hub the changes of the alerts are published to
+// notificationService: Service sending the opened alerts to the notification channels
+// devicePublisher: Publisher pushing the opened alerts to the devices
+// returns: a new alert service
+func NewAlertService(
+	repo port.AlertRepository,
+	routeService services.RouteService,
+	eventHub services.EventHub,
+	notificationService services.NotificationService,
+	devicePublisher port.DevicePublisher,
+) *AlertService {
+	return &AlertService{
+		GenericService:      NewGenericService[models.Alert](repo),
+		alertRepository:     repo,
+		routeService:        routeService,
+		eventHub:            eventHub,
+		notificationService: notificationService,
+		devicePublisher:     devicePublisher,
+	}
+}
+
+// alertKey identifies the alert of a rule at a waypoint
+type alertKey struct {
+	ruleID     uint // ID of the rule
+	waypointID uint // ID of the waypoint
+}
+
+// EvaluateRoute evaluates the alert rules at the waypoints of a route and brings its alerts up to date
+// An alert is opened for every rule and waypoint the conditions newly hold at, and the unresolved alerts
+// whose conditions cleared are resolved. The alerts of a waypoint whose device is offline are left alone,
+// as the weather there is unknown.
+// ctx: Context of the request
+// route: Route with its waypoints
+// returns: an error
+func (s *AlertService) EvaluateRoute(ctx context.Context, route models.Route) error {
+	raised, err := s.routeService.RaiseAlerts(ctx, route)
+	if err != nil {
+		return err
+	}
+
+	active, err := s.alertRepository.Active(ctx, route.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	activeByKey := make(map[alertKey]models.Alert, len(active))
+	for _, alert := range active {
+		if alert.RuleID != nil {
+			activeByKey[alertKey{*alert.RuleID, alert.WaypointID}] = alert
+		}
+	}
+
+	stillRaised := make(map[uint]bool, len(active))
+	touched := []uint{}
+	for _, alert := range raised {
+		if existing, ok := activeByKey[alertKey{alert.Rule.ID, alert.WaypointID}]; ok {
+			stillRaised[existing.ID] = true
+			touched = append(touched, existing.ID)
+			continue
+		}
+
+		if err := s.open(ctx, route, alert, now); err != nil {
+			return err
+		}
+	}
+
+	if err := s.alertRepository.Touch(ctx, touched, now); err != nil {
+		return err
+	}
+
+	offline := make(map[uint]bool, len(route.Waypoints))
+	for _, waypoint := range route.Waypoints {
+		offline[waypoint.ID] = isOffline(waypoint)
+	}
+
+	for i := range active {
+		if stillRaised[active[i].ID] || offline[active[i].WaypointID] {
+			continue
+		}
+
+		active[i].Status = models.AlertStatusResolved
+		active[i].ResolvedAt = &now
+		ok, err := s.alertRepository.UpdateStatus(
+			ctx,
+			&active[i],
+			models.AlertStatusOpen,
+			models.AlertStatusAcknowledged,
+		)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.publish(active[i], now)
+		}
+	}
+
+	return nil
+}
+
+// EvaluateAlerts evaluates the alert rules at every route
+// A route that fails is logged and skipped, so it does not hold back the alerts of the others.
+// returns: an error if the routes could not be listed or the context was canceled
+func (s *AlertService) EvaluateAlerts(ctx context.Context) error {
+	routes, err := s.routeService.Where(ctx, &models.Route{})
+	if err != nil {
+		return err
+	}
+
+	for _, route := range routes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := s.EvaluateRoute(ctx, route); err != nil {
+			slog.Error("alert evaluation of a route failed",
+				slog.Uint64("route_id", uint64(route.ID)),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	return nil
+}
+
+// RunAlertEvaluation runs EvaluateAlerts every interval until the context is canceled
+// ctx: Context that stops the evaluation
+// interval: Time between two evaluations
+func (s *AlertService) RunAlertEvaluation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.EvaluateAlerts(ctx); err != nil {
+				slog.Error("alert evaluation failed", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// GetAlerts returns the alerts selected by the query, without their readings
+// ctx: Context of the request
+// query: company of the alerts and the filters to apply
+// returns: the alerts newest first, ErrInvalidAlertQuery, and an error
+func (s *AlertService) GetAlerts(ctx context.Context, query models.AlertQuery) ([]models.Alert, error) {
+	switch query.Status {
+	case "", models.AlertStatusOpen, models.AlertStatusAcknowledged, models.AlertStatusResolved:
+	default:
+		return nil, services.ErrInvalidAlertQuery
+	}
+	switch query.Severity {
+	case "", models.AlertSeverityInfo, models.AlertSeverityWarning, models.AlertSeverityCritical:
+	default:
+		return nil, services.ErrInvalidAlertQuery
+	}
+
+	return s.alertRepository.Filter(ctx, query)
+}
+
+// Acknowledge records that a user is dealing with an open alert
+// ctx: Context of the request
+// alert: alert to acknowledge, updated with its new status
+// userID: ID of the user acknowledging the alert
+// comment: comment of the user, may be empty
+// returns: ErrAlertNotAcknowledgable if the alert is not open, and an error
+func (s *AlertService) Acknowledge(ctx context.Context, alert *models.Alert, userID uint, comment string) error {
+	now := time.Now().UTC()
+	changes := *alert
+	changes.Status = models.AlertStatusAcknowledged
+	changes.AcknowledgedAt = &now
+	changes.AcknowledgedByID = &userID
+	changes.AcknowledgementComment = strings.TrimSpace(comment)
+
+	ok, err := s.alertRepository.UpdateStatus(ctx, &changes, models.AlertStatusOpen)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return services.ErrAlertNotAcknowledgable
+	}
+
+	*alert = changes
+	s.publish(*alert, now)
+	return nil
+}
+
+// Resolve closes an open or acknowledged alert on behalf of a user
+// Should the conditions still hold, the next evaluation of the rules opens a new alert.
+// ctx: Context of the request
+// alert: alert to resolve, updated with its new status
+// userID: ID of the user resolving the alert
+// comment: comment of the user, may be empty
+// returns: ErrAlertResolved if the alert is resolved already, and an error
+func (s *AlertService) Resolve(ctx context.Context, alert *models.Alert, userID uint, comment string) error {
+	now := time.Now().UTC()
+	changes := *alert
+	changes.Status = models.AlertStatusResolved
+	changes.ResolvedAt = &now
+	changes.ResolvedByID = &userID
+	changes.ResolutionComment = strings.TrimSpace(comment)
+
+	ok, err := s.alertRepository.UpdateStatus(
+		ctx,
+		&changes,
+		models.AlertStatusOpen,
+		models.AlertStatusAcknowledged,
+	)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return services.ErrAlertResolved
+	}
+
+	*alert = changes
+	s.publish(*alert, now)
+	return nil
+}
+
+// open stores an alert for a rule raised at a waypoint, publishes it, sends it to the notification channels
+// and pushes it to the device of the waypoint
+// ctx: Context of the request
+// route: Route the rule was raised for, with its waypoints
+// raised: rule, waypoint and the readings that raised it
+// now: time of the evaluation
+// returns: an error
+func (s *AlertService) open(ctx context.Context, route models.Route, raised analysis.RaisedAlert, now time.Time) error {
+	ruleID := raised.Rule.ID
+	alert := &models.Alert{
+		CompanyID:       route.CompanyID,
+		RouteID:         route.ID,
+		WaypointID:      raised.WaypointID,
+		RuleID:          &ruleID,
+		Type:            raised.Rule.Type,
+		Message:         raised.Rule.Message,
+		Details:         analysis.AlertDetails(raised.Rule, raised.Readings[len(raised.Readings)-1]),
+		Severity:        raised.Rule.Severity,
+		Status:          models.AlertStatusOpen,
+		OpenedAt:        now,
+		LastTriggeredAt: now,
+		Readings:        models.NewAlertReadings(raised.Readings),
+	}
+	if err := s.alertRepository.Add(ctx, alert); err != nil {
+		return err
+	}
+
+	s.publish(*alert, now)
+	s.notificationService.NotifyAlert(ctx, *alert, route)
+	s.pushToDevice(ctx, *alert, route)
+	return nil
+}
+
+// pushToDevice publishes an opened alert to the device of its waypoint
+// The alert is stored even when the device can not be reached, so the error is only logged.
+// ctx: Context of the request
+// alert: alert that was opened
+// route: Route of the alert, with its waypoints
+func (s *AlertService) pushToDevice(ctx context.Context, alert models.Alert, route models.Route) {
+	for _, waypoint := range route.Waypoints {
+		if waypoint.ID != alert.WaypointID {
+			continue
+		}
+
+		waypoint.Route = route
+		if err := s.devicePublisher.PublishAlert(ctx, waypoint, alert); err != nil {
+			slog.Error("failed to push the alert to the device",
+				slog.Uint64("alert_id", uint64(alert.ID)),
+				slog.Uint64("waypoint_id", uint64(waypoint.ID)),
+				slog.String("error", err.Error()),
+			)
+		}
+		return
+	}
+}
+
+// publish tells the subscribers of the company that an alert was opened, acknowledged or resolved
+// alert: alert that changed
+// now: time of the change
+func (s *AlertService) publish(alert models.Alert, now time.Time) {
+	s.eventHub.Publish(models.StreamEvent{
+		Type:       models.StreamEventAlert,
+		CompanyID:  alert.CompanyID,
+		RouteID:    alert.RouteID,
+		WaypointID: alert.WaypointID,
+		Date:       now,
+		Data:       alert,
+	})
+}
@@ -0,0 +1,247 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/analysis"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// recalibrationPageSize is the number of readings loaded, calibrated and stored at once by a recalibration
+const recalibrationPageSize = 1000
+
+// CalibrationService is a service that manages the calibration profiles and corrects the readings with them
+type CalibrationService struct {
+	*GenericService[models.CalibrationProfile]                            // Embedding the generic service
+	calibrationRepository                      port.CalibrationRepository // Repository with the lookup of the profiles of the readings
+	sensorDataRepository                       port.SensorDataRepository  // Repository with the readings to recalibrate
+	qualityService                             services.QualityService    // Service flagging the recalibrated readings again
+}
+
+// NewCalibrationService creates a new calibration service
+// repo: the repository to use
+// sensorDataRepository: the repository with the readings to recalibrate
+// qualityService: the service flagging the recalibrated readings again
+// returns: a new calibration service
+func NewCalibrationService(
+	repo port.CalibrationRepository,
+	sensorDataRepository port.SensorDataRepository,
+	qualityService services.QualityService,
+) *CalibrationService {
+	return &CalibrationService{
+		GenericService:        NewGenericService[models.CalibrationProfile](repo),
+		calibrationRepository: repo,
+		sensorDataRepository:  sensorDataRepository,
+		qualityService:        qualityService,
+	}
+}
+
+// Create adds a calibration profile, valid from now when no date is given
+// The profile corrects the readings ingested from then on, the stored readings are only corrected by Recalibrate.
+// ctx: context
+// profile: profile to add, its table is sorted by raw value
+// returns: ErrInvalidCalibrationMetric, ErrInvalidCalibrationScope, ErrInvalidCalibrationGain,
+// ErrInvalidCalibrationTable or an error
+func (s *CalibrationService) Create(ctx context.Context, profile *models.CalibrationProfile) error {
+	if !isCalibrationMetric(profile.Metric) {
+		return services.ErrInvalidCalibrationMetric
+	}
+	if (profile.DeviceID == nil) == (profile.WaypointID == nil) {
+		return services.ErrInvalidCalibrationScope
+	}
+	if profile.Gain == 0 {
+		return services.ErrInvalidCalibrationGain
+	}
+
+	if len(profile.Points) > 0 {
+		sort.Slice(profile.Points, func(i, j int) bool { return profile.Points[i].Raw < profile.Points[j].Raw })
+		if len(profile.Points) < 2 {
+			return services.ErrInvalidCalibrationTable
+		}
+		for i := 1; i < len(profile.Points); i++ {
+			if profile.Points[i].Raw == profile.Points[i-1].Raw {
+				return services.ErrInvalidCalibrationTable
+			}
+		}
+	}
+
+	if profile.ValidFrom.IsZero() {
+		profile.ValidFrom = time.Now().UTC()
+	}
+
+	return s.Repository.Add(ctx, profile)
+}
+
+// GetByDevice returns the calibration profiles of a device
+// ctx: context
+// deviceID: ID of the device
+// returns: the profiles with their tables ordered by valid_from and an error
+func (s *CalibrationService) GetByDevice(ctx context.Context, deviceID uint) ([]models.CalibrationProfile, error) {
+	profiles, err := s.Repository.Where(ctx, &models.CalibrationProfile{DeviceID: &deviceID})
+	if err != nil {
+		return nil, err
+	}
+
+	sortProfiles(profiles)
+	return profiles, nil
+}
+
+// GetByWaypoint returns the calibration profiles of a waypoint
+// ctx: context
+// waypointID: ID of the waypoint
+// returns: the profiles with their tables ordered by valid_from and an error
+func (s *CalibrationService) GetByWaypoint(ctx context.Context, waypointID uint) ([]models.CalibrationProfile, error) {
+	profiles, err := s.Repository.Where(ctx, &models.CalibrationProfile{WaypointID: &waypointID})
+	if err != nil {
+		return nil, err
+	}
+
+	sortProfiles(profiles)
+	return profiles, nil
+}
+
+// Apply corrects the readings with the profiles of their devices and waypoints in effect when they were recorded
+// The raw measurements are kept next to the corrected ones.
+// ctx: context
+// readings: readings to correct, attributed to their devices and with their dates
+// returns: error
+func (s *CalibrationService) Apply(ctx context.Context, readings []models.SensorData) error {
+	_, err := s.calibrate(ctx, readings)
+	return err
+}
+
+// Recalibrate corrects the stored readings of a device or a waypoint again with the current profiles,
+// like after a lab calibration comes back, and flags the changed readings again
+// The readings are processed in pages, every page is stored in its own transaction.
+// Readings that were already rolled up and purged can not be recalibrated.
+// ctx: context
+// query: device or waypoint and time range of the readings
+// returns: the number of readings of the range and of changed readings, ErrInvalidRecalibrationQuery or an error
+func (s *CalibrationService) Recalibrate(
+	ctx context.Context,
+	query models.RecalibrationQuery,
+) (models.RecalibrationResult, error) {
+	result := models.RecalibrationResult{}
+	if !query.From.Before(query.To) || (query.DeviceID == 0) == (query.WaypointID == 0) {
+		return result, services.ErrInvalidRecalibrationQuery
+	}
+
+	changedWaypoints := make(map[uint]bool)
+	afterID := uint(0)
+	for {
+		readings, err := s.sensorDataRepository.ForRecalibration(ctx, query, afterID, recalibrationPageSize)
+		if err != nil {
+			return result, err
+		}
+		if len(readings) == 0 {
+			break
+		}
+		afterID = readings[len(readings)-1].ID
+		result.Readings += len(readings)
+
+		changed, err := s.calibrate(ctx, readings)
+		if err != nil {
+			return result, err
+		}
+		if err := s.sensorDataRepository.UpdateMeasurements(ctx, changed); err != nil {
+			return result, err
+		}
+
+		result.Changed += len(changed)
+		for _, reading := range changed {
+			changedWaypoints[reading.WaypointID] = true
+		}
+	}
+
+	// The flags depend on the values, the readings are stored recalibrated even when flagging them again fails
+	for waypointID := range changedWaypoints {
+		if _, err := s.qualityService.AssessWaypoint(ctx, waypointID, query.From, query.To); err != nil {
+			slog.Error("data-quality pass after a recalibration failed",
+				slog.Uint64("waypoint_id", uint64(waypointID)),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	return result, nil
+}
+
+// calibrate corrects the readings with the profiles of their devices and waypoints
+// ctx: context
+// readings: readings to correct
+// returns: the readings that changed, error
+func (s *CalibrationService) calibrate(ctx context.Context, readings []models.SensorData) ([]models.SensorData, error) {
+	if len(readings) == 0 {
+		return nil, nil
+	}
+
+	deviceIDs := make([]uint, 0, len(readings))
+	seenDevices := make(map[uint]bool)
+	latest := readings[0].Date
+	for _, reading := range readings {
+		if reading.DeviceID != nil && !seenDevices[*reading.DeviceID] {
+			seenDevices[*reading.DeviceID] = true
+			deviceIDs = append(deviceIDs, *reading.DeviceID)
+		}
+		if reading.Date.After(latest) {
+			latest = reading.Date
+		}
+	}
+
+	profiles, err := s.calibrationRepository.ForScopes(ctx, deviceIDs, waypointIDsOf(readings), latest)
+	if err != nil {
+		return nil, err
+	}
+
+	profilesByDevice := make(map[uint][]models.CalibrationProfile)
+	profilesByWaypoint := make(map[uint][]models.CalibrationProfile)
+	for _, profile := range profiles {
+		if profile.DeviceID != nil {
+			profilesByDevice[*profile.DeviceID] = append(profilesByDevice[*profile.DeviceID], profile)
+		}
+		if profile.WaypointID != nil {
+			profilesByWaypoint[*profile.WaypointID] = append(profilesByWaypoint[*profile.WaypointID], profile)
+		}
+	}
+
+	changed := make([]models.SensorData, 0, len(readings))
+	for i := range readings {
+		candidates := profilesByWaypoint[readings[i].WaypointID]
+		if readings[i].DeviceID != nil {
+			candidates = append(candidates[:len(candidates):len(candidates)], profilesByDevice[*readings[i].DeviceID]...)
+		}
+
+		if analysis.Calibrate(&readings[i], candidates) {
+			changed = append(changed, readings[i])
+		}
+	}
+
+	return changed, nil
+}
+
+// sortProfiles orders the profiles by the date they are valid from, then by ID
+// profiles: profiles to sort
+func sortProfiles(profiles []models.CalibrationProfile) {
+	sort.Slice(profiles, func(i, j int) bool {
+		if !profiles[i].ValidFrom.Equal(profiles[j].ValidFrom) {
+			return profiles[i].ValidFrom.Before(profiles[j].ValidFrom)
+		}
+		return profiles[i].ID < profiles[j].ID
+	})
+}
+
+// isCalibrationMetric checks if a calibration can correct the metric
+// metric: metric to check
+// returns: true if the metric is a measurement of the readings
+func isCalibrationMetric(metric string) bool {
+	switch metric {
+	case models.MetricTemperature, models.MetricHumidity, models.MetricWindSpeed, models.MetricMeanPressure:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,173 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// offlineAfterMissedReports is the number of reports a device may miss before it is considered offline
+const offlineAfterMissedReports = 2
+
+// ConnectivityService is a service that tracks when the devices last reported and detects the offline ones
+type ConnectivityService struct {
+	connectivityRepository port.ConnectivityRepository  // Repository for the last-seen tracking
+	deviceConfigService    services.DeviceConfigService // Service resolving the report interval of the devices
+	eventHub               services.EventHub            // Hub the connectivity changes are published to
+}
+
+// NewConnectivityService creates a new connectivity service
+// connectivityRepository: Repository for the last-seen tracking
+// deviceConfigService: Service resolving the report interval of the devices
+// eventHub: Hub the connectivity changes are published to
+// returns: a new connectivity service
+func NewConnectivityService(
+	connectivityRepository port.ConnectivityRepository,
+	deviceConfigService services.DeviceConfigService,
+	eventHub services.EventHub,
+) *ConnectivityService {
+	return &ConnectivityService{
+		connectivityRepository: connectivityRepository,
+		deviceConfigService:    deviceConfigService,
+		eventHub:               eventHub,
+	}
+}
+
+// RecordActivity marks the devices of the waypoints as online and seen now
+// The devices that were offline are logged and published as back online.
+// ctx: Context of the request
+// waypointIDs: IDs of the waypoints whose device reported
+// returns: An error if the operation failed
+func (s *ConnectivityService) RecordActivity(ctx context.Context, waypointIDs ...uint) error {
+	now := time.Now().UTC()
+	backOnline, err := s.connectivityRepository.TouchLastSeen(ctx, waypointIDs, now)
+	if err != nil {
+		return err
+	}
+
+	for _, waypoint := range backOnline {
+		attrs := []any{
+			slog.Uint64("waypoint_id", uint64(waypoint.ID)),
+			slog.String("device_serial", waypoint.DeviceSerial),
+		}
+		if waypoint.LastSeenAt != nil {
+			attrs = append(attrs, slog.Duration("offline_for", now.Sub(*waypoint.LastSeenAt)))
+		}
+		slog.Info("device back online", attrs...)
+
+		s.publish(waypoint, models.ConnectivityOnline, &now, now)
+	}
+
+	return nil
+}
+
+// DetectOffline marks as offline the devices that missed their expected reports and publishes them
+// The expected interval of a device is the send_data_frequency of its effective config,
+// the configs are resolved with one query per company.
+// ctx: Context of the request
+// returns: The number of devices marked offline and an error
+func (s *ConnectivityService) DetectOffline(ctx context.Context) (int, error) {
+	waypoints, err := s.connectivityRepository.OnlineWaypoints(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	waypointIDsByCompany := make(map[uint][]uint)
+	for _, waypoint := range waypoints {
+		if waypoint.LastSeenAt != nil {
+			companyID := waypoint.Route.CompanyID
+			waypointIDsByCompany[companyID] = append(waypointIDsByCompany[companyID], waypoint.ID)
+		}
+	}
+
+	configs := make(map[uint]*models.EffectiveDeviceConfig, len(waypoints))
+	for companyID, waypointIDs := range waypointIDsByCompany {
+		companyConfigs, err := s.deviceConfigService.GetEffectiveConfigs(ctx, companyID, waypointIDs)
+		if err != nil {
+			return 0, err
+		}
+		for waypointID, config := range companyConfigs {
+			configs[waypointID] = config
+		}
+	}
+
+	now := time.Now().UTC()
+	marked := 0
+	for _, waypoint := range waypoints {
+		config, ok := configs[waypoint.ID]
+		if !ok {
+			continue
+		}
+
+		interval := time.Duration(config.SendDataFrequency) * time.Minute
+		seenBefore := now.Add(-offlineAfterMissedReports * interval)
+		if !waypoint.LastSeenAt.Before(seenBefore) {
+			continue
+		}
+
+		ok, err := s.connectivityRepository.MarkOffline(ctx, waypoint.ID, seenBefore)
+		if err != nil {
+			return marked, err
+		}
+		if ok {
+			marked++
+			slog.Warn("device went offline",
+				slog.Uint64("waypoint_id", uint64(waypoint.ID)),
+				slog.String("device_serial", waypoint.DeviceSerial),
+				slog.Time("last_seen_at", *waypoint.LastSeenAt),
+			)
+
+			s.publish(waypoint, models.ConnectivityOffline, waypoint.LastSeenAt, now)
+		}
+	}
+
+	return marked, nil
+}
+
+// publish tells the subscribers that the device of a waypoint changed its connectivity
+// waypoint: Waypoint of the device, with its route
+// connectivity: New connectivity of the device
+// lastSeenAt: Time the device was last seen
+// now: Time of the change
+func (s *ConnectivityService) publish(waypoint models.Waypoint, connectivity string, lastSeenAt *time.Time, now time.Time) {
+	s.eventHub.Publish(models.StreamEvent{
+		Type:       models.StreamEventConnectivity,
+		CompanyID:  waypoint.Route.CompanyID,
+		RouteID:    waypoint.RouteID,
+		WaypointID: waypoint.ID,
+		Date:       now,
+		Data: models.ConnectivityChange{
+			Connectivity: connectivity,
+			LastSeenAt:   lastSeenAt,
+		},
+	})
+}
+
+// RunOfflineChecker runs DetectOffline every interval until the context is canceled
+// ctx: Context that stops the checker
+// interval: Time between two checks
+func (s *ConnectivityService) RunOfflineChecker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.DetectOffline(ctx); err != nil {
+				slog.Error("offline check failed", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// isOffline checks if the device of the waypoint is known to be offline
+// waypoint: Waypoint to check
+// returns: true if the device is offline
+func isOffline(waypoint models.Waypoint) bool {
+	return waypoint.Connectivity == models.ConnectivityOffline
+}
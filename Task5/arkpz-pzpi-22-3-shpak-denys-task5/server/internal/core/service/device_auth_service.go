@@ -0,0 +1,155 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// deviceTokenPrefix marks the tokens issued to devices
+const deviceTokenPrefix = "wd_"
+
+// lastUsedResolution is how often the last usage of a credential is written to the database
+const lastUsedResolution = time.Minute
+
+// DeviceAuthService is a service that issues, checks and revokes the credentials of devices
+type DeviceAuthService struct {
+	credentialRepository port.Repository[models.DeviceCredential] // Repository for the device credentials
+}
+
+// NewDeviceAuthService creates a new device auth service
+// credentialRepository: Repository for the device credentials
+// returns: a new device auth service
+func NewDeviceAuthService(credentialRepository port.Repository[models.DeviceCredential]) *DeviceAuthService {
+	return &DeviceAuthService{
+		credentialRepository: credentialRepository,
+	}
+}
+
+// IssueCredential issues a new token for the device of the waypoint and revokes the previous ones
+// ctx: Context of the request
+// waypoint: Waypoint the device is bound to
+// issuedByID: ID of the user issuing the credential
+// returns: the token, which is not stored and can not be shown again, and the credential
+func (s *DeviceAuthService) IssueCredential(
+	ctx context.Context,
+	waypoint models.Waypoint,
+	issuedByID uint,
+) (string, *models.DeviceCredential, error) {
+	if waypoint.DeviceSerial == "" {
+		return "", nil, services.ErrDeviceSerialMissing
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	token := deviceTokenPrefix + hex.EncodeToString(secret)
+
+	if err := s.RevokeCredentials(ctx, waypoint.ID); err != nil {
+		return "", nil, err
+	}
+
+	credential := &models.DeviceCredential{
+		WaypointID:   waypoint.ID,
+		DeviceSerial: waypoint.DeviceSerial,
+		TokenHash:    hashDeviceToken(token),
+		TokenPrefix:  token[:len(deviceTokenPrefix)+6],
+		CreatedByID:  &issuedByID,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	if err := s.credentialRepository.Add(ctx, credential); err != nil {
+		return "", nil, err
+	}
+
+	return token, credential, nil
+}
+
+// RevokeCredentials revokes every active credential of the device of the waypoint
+// ctx: Context of the request
+// waypointID: ID of the waypoint
+// returns: An error if the operation failed
+func (s *DeviceAuthService) RevokeCredentials(ctx context.Context, waypointID uint) error {
+	credentials, err := s.credentialRepository.Where(ctx, "waypoint_id = ? AND revoked_at IS NULL", waypointID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, credential := range credentials {
+		if err := s.credentialRepository.Update(ctx, &models.DeviceCredential{
+			ID:        credential.ID,
+			RevokedAt: &now,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListCredentials returns the credentials issued for the device of the waypoint, newest first
+// ctx: Context of the request
+// waypointID: ID of the waypoint
+// returns: The credentials and an error
+func (s *DeviceAuthService) ListCredentials(ctx context.Context, waypointID uint) ([]models.DeviceCredential, error) {
+	credentials, err := s.credentialRepository.Where(ctx, &models.DeviceCredential{WaypointID: waypointID})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.After(credentials[j].CreatedAt)
+	})
+
+	return credentials, nil
+}
+
+// AuthenticateDevice checks a device token
+// The token is only valid while it is not revoked and the waypoint is still bound to the same device.
+// ctx: Context of the request
+// token: Token sent by the device
+// returns: The credential with its waypoint, or ErrInvalidDeviceToken
+func (s *DeviceAuthService) AuthenticateDevice(ctx context.Context, token string) (*models.DeviceCredential, error) {
+	credentials, err := s.credentialRepository.Where(ctx, "token_hash = ? AND revoked_at IS NULL", hashDeviceToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(credentials) == 0 {
+		return nil, services.ErrInvalidDeviceToken
+	}
+
+	credential := credentials[0]
+	if credential.Waypoint.DeviceSerial != credential.DeviceSerial {
+		return nil, services.ErrInvalidDeviceToken
+	}
+
+	now := time.Now().UTC()
+	if credential.LastUsedAt == nil || now.Sub(*credential.LastUsedAt) > lastUsedResolution {
+		if err := s.credentialRepository.Update(ctx, &models.DeviceCredential{
+			ID:         credential.ID,
+			LastUsedAt: &now,
+		}); err != nil {
+			return nil, err
+		}
+		credential.LastUsedAt = &now
+	}
+
+	return &credential, nil
+}
+
+// hashDeviceToken returns the hex encoded SHA-256 hash of the token
+// token: Token to hash
+// returns: The hash stored in the database
+func hashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
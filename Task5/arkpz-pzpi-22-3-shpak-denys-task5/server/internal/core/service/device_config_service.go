@@ -0,0 +1,306 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+
+	"gorm.io/gorm"
+)
+
+// DeviceConfigService is a service that manages the configuration of the devices
+type DeviceConfigService struct {
+	*GenericService[models.DeviceConfig]                      // Embedding the generic service
+	devicePublisher                      port.DevicePublisher // Publisher pushing the changed configs to the devices, nil until set
+}
+
+// NewDeviceConfigService creates a new device config service
+// repo: the repository to use
+// returns: a new device config service
+func NewDeviceConfigService(repo port.Repository[models.DeviceConfig]) *DeviceConfigService {
+	return &DeviceConfigService{
+		GenericService: NewGenericService(repo),
+	}
+}
+
+// SetDevicePublisher sets the publisher the changed configs are pushed to the devices with
+// The publisher resolves the configs with this service, so it can not be passed to the constructor.
+// devicePublisher: publisher pushing the changed configs to the devices
+func (s *DeviceConfigService) SetDevicePublisher(devicePublisher port.DevicePublisher) {
+	s.devicePublisher = devicePublisher
+}
+
+// GetCompanyDefaults returns the default device config of the company
+// ctx: context
+// companyID: ID of the company
+// returns: the company defaults, nil if the company has none
+func (s *DeviceConfigService) GetCompanyDefaults(ctx context.Context, companyID uint) (*models.DeviceConfig, error) {
+	configs, err := s.Repository.Where(ctx, "company_id = ? AND waypoint_id IS NULL", companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	return &configs[0], nil
+}
+
+// GetWaypointOverride returns the device config override of the waypoint
+// ctx: context
+// waypointID: ID of the waypoint
+// returns: the waypoint override, nil if the waypoint has none
+func (s *DeviceConfigService) GetWaypointOverride(ctx context.Context, waypointID uint) (*models.DeviceConfig, error) {
+	configs, err := s.Repository.Where(ctx, "waypoint_id = ?", waypointID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	return &configs[0], nil
+}
+
+// SaveCompanyDefaults creates or changes the default device config of the company
+// Only the fields set in changes are applied. The new config is pushed to every device of the company.
+// ctx: context
+// companyID: ID of the company
+// changes: values to apply
+// returns: the saved company defaults
+func (s *DeviceConfigService) SaveCompanyDefaults(
+	ctx context.Context,
+	companyID uint,
+	changes models.DeviceConfig,
+) (*models.DeviceConfig, error) {
+	config, err := s.save(ctx, func() (*models.DeviceConfig, error) {
+		return s.GetCompanyDefaults(ctx, companyID)
+	}, &models.DeviceConfig{CompanyID: companyID}, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	s.push(func(publisher port.DevicePublisher) error {
+		return publisher.PublishCompanyConfigs(ctx, companyID)
+	})
+	return config, nil
+}
+
+// SaveWaypointOverride creates or changes the device config override of the waypoint
+// Only the fields set in changes are applied. The new config is pushed to the device of the waypoint.
+// ctx: context
+// waypoint: waypoint to override the config for, with its route loaded
+// changes: values to apply
+// returns: the saved waypoint override
+func (s *DeviceConfigService) SaveWaypointOverride(
+	ctx context.Context,
+	waypoint models.Waypoint,
+	changes models.DeviceConfig,
+) (*models.DeviceConfig, error) {
+	waypointID := waypoint.ID
+	config, err := s.save(ctx, func() (*models.DeviceConfig, error) {
+		return s.GetWaypointOverride(ctx, waypoint.ID)
+	}, &models.DeviceConfig{
+		CompanyID:  waypoint.Route.CompanyID,
+		WaypointID: &waypointID,
+	}, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	s.push(func(publisher port.DevicePublisher) error {
+		return publisher.PublishWaypointConfig(ctx, waypoint.ID)
+	})
+	return config, nil
+}
+
+// DeleteWaypointOverride removes the device config override of the waypoint
+// The config falling back to the company defaults is pushed to the device of the waypoint.
+// ctx: context
+// waypointID: ID of the waypoint
+// returns: error
+func (s *DeviceConfigService) DeleteWaypointOverride(ctx context.Context, waypointID uint) error {
+	existing, err := s.GetWaypointOverride(ctx, waypointID)
+	if err != nil || existing == nil {
+		return err
+	}
+
+	if err := s.Repository.Delete(ctx, existing.ID); err != nil {
+		return err
+	}
+
+	s.push(func(publisher port.DevicePublisher) error {
+		return publisher.PublishWaypointConfig(ctx, waypointID)
+	})
+	return nil
+}
+
+// push pushes a changed config to the devices
+// The config is stored even when the devices can not be reached, they get it with the reply to their next telemetry,
+// so the error is only logged.
+// publish: publishes the config with the publisher
+func (s *DeviceConfigService) push(publish func(publisher port.DevicePublisher) error) {
+	if s.devicePublisher == nil {
+		return
+	}
+
+	if err := publish(s.devicePublisher); err != nil {
+		slog.Error("failed to push the device config", slog.String("error", err.Error()))
+	}
+}
+
+// GetEffectiveConfig merges the built-in values, the company defaults and the waypoint override
+// ctx: context
+// waypoint: waypoint to resolve the config for, with its route loaded
+// returns: the config the device should apply
+func (s *DeviceConfigService) GetEffectiveConfig(
+	ctx context.Context,
+	waypoint models.Waypoint,
+) (*models.EffectiveDeviceConfig, error) {
+	companyDefaults, err := s.GetCompanyDefaults(ctx, waypoint.Route.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	override, err := s.GetWaypointOverride(ctx, waypoint.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return effectiveConfig(waypoint.ID, companyDefaults, override), nil
+}
+
+// GetEffectiveConfigs resolves the effective configs of waypoints of one company with a single query
+// ctx: context
+// companyID: ID of the company
+// waypointIDs: IDs of the waypoints of the company
+// returns: the config every device should apply by the ID of its waypoint
+func (s *DeviceConfigService) GetEffectiveConfigs(
+	ctx context.Context,
+	companyID uint,
+	waypointIDs []uint,
+) (map[uint]*models.EffectiveDeviceConfig, error) {
+	configs, err := s.Repository.Where(ctx, "company_id = ?", companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var companyDefaults *models.DeviceConfig
+	overrides := make(map[uint]*models.DeviceConfig, len(configs))
+	for i, config := range configs {
+		if config.WaypointID == nil {
+			companyDefaults = &configs[i]
+		} else {
+			overrides[*config.WaypointID] = &configs[i]
+		}
+	}
+
+	effective := make(map[uint]*models.EffectiveDeviceConfig, len(waypointIDs))
+	for _, waypointID := range waypointIDs {
+		effective[waypointID] = effectiveConfig(waypointID, companyDefaults, overrides[waypointID])
+	}
+
+	return effective, nil
+}
+
+// effectiveConfig merges the built-in values, the company defaults and the waypoint override
+// waypointID: ID of the waypoint
+// companyDefaults: defaults of the company, nil if it has none
+// override: override of the waypoint, nil if it has none
+// returns: the config the device should apply
+func effectiveConfig(waypointID uint, companyDefaults, override *models.DeviceConfig) *models.EffectiveDeviceConfig {
+	effective := &models.EffectiveDeviceConfig{
+		WaypointID:        waypointID,
+		SendDataFrequency: models.DefaultSendDataFrequency,
+		GetWeatherAlerts:  models.DefaultGetWeatherAlerts,
+	}
+
+	for _, config := range []*models.DeviceConfig{companyDefaults, override} {
+		if config == nil {
+			continue
+		}
+		if config.SendDataFrequency != nil {
+			effective.SendDataFrequency = *config.SendDataFrequency
+		}
+		if config.GetWeatherAlerts != nil {
+			effective.GetWeatherAlerts = *config.GetWeatherAlerts
+		}
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf(
+		"%d|%d|%t",
+		effective.WaypointID,
+		effective.SendDataFrequency,
+		effective.GetWeatherAlerts,
+	)))
+	effective.Version = hex.EncodeToString(sum[:8])
+
+	return effective
+}
+
+// save applies the changes to the existing config or creates a new one
+// Should another request create the config first, the unique indexes reject the new one
+// and the changes are applied to the config it created.
+// ctx: context
+// load: loads the config stored in the database, nil if there is none
+// fresh: config to create when there is no existing one
+// changes: values to apply
+// returns: the saved config
+func (s *DeviceConfigService) save(
+	ctx context.Context,
+	load func() (*models.DeviceConfig, error),
+	fresh *models.DeviceConfig,
+	changes models.DeviceConfig,
+) (*models.DeviceConfig, error) {
+	if changes.SendDataFrequency != nil && (*changes.SendDataFrequency < 1 || *changes.SendDataFrequency > 1440) {
+		return nil, services.ErrInvalidDeviceConfig
+	}
+
+	existing, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		fresh.SendDataFrequency = changes.SendDataFrequency
+		fresh.GetWeatherAlerts = changes.GetWeatherAlerts
+		fresh.Version = 1
+
+		err := s.Repository.Add(ctx, fresh)
+		if err == nil {
+			return fresh, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, err
+		}
+
+		if existing, err = load(); err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+	}
+
+	if changes.SendDataFrequency != nil {
+		existing.SendDataFrequency = changes.SendDataFrequency
+	}
+	if changes.GetWeatherAlerts != nil {
+		existing.GetWeatherAlerts = changes.GetWeatherAlerts
+	}
+	existing.Version++
+
+	if err := s.Repository.Update(ctx, existing); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
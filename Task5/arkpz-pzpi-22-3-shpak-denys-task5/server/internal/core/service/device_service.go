@@ -0,0 +1,175 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"sort"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// DeviceService is a service that manages the registry of devices and their installation at waypoints
+type DeviceService struct {
+	*GenericService[models.Device]                                          // Embedding the generic service
+	deviceRepository               port.DeviceRepository                    // Repository with the assignment operations
+	assignmentRepository           port.Repository[models.DeviceAssignment] // Repository for the assignment history
+}
+
+// NewDeviceService creates a new device service
+// repo: the repository to use
+// assignmentRepository: the repository for the assignment history
+// returns: a new device service
+func NewDeviceService(
+	repo port.DeviceRepository,
+	assignmentRepository port.Repository[models.DeviceAssignment],
+) *DeviceService {
+	return &DeviceService{
+		GenericService:       NewGenericService[models.Device](repo),
+		deviceRepository:     repo,
+		assignmentRepository: assignmentRepository,
+	}
+}
+
+// Create registers a new device, it is not installed at a waypoint yet
+// ctx: context
+// device: device to register
+// returns: ErrDeviceSerialRequired, ErrDeviceSerialTaken, ErrInvalidDeviceStatus or an error
+func (s *DeviceService) Create(ctx context.Context, device *models.Device) error {
+	if device.Serial == "" {
+		return services.ErrDeviceSerialRequired
+	}
+
+	if device.Status == "" {
+		device.Status = models.DeviceStatusActive
+	}
+	if !isDeviceStatus(device.Status) {
+		return services.ErrInvalidDeviceStatus
+	}
+
+	devices, err := s.Repository.Where(ctx, "serial = ?", device.Serial)
+	if err != nil {
+		return err
+	}
+	if len(devices) > 0 {
+		return services.ErrDeviceSerialTaken
+	}
+
+	device.WaypointID = nil
+	return s.Repository.Add(ctx, device)
+}
+
+// Update updates the non-zero fields of a device, retiring a device removes it from its waypoint
+// The serial and the waypoint of a device are not changed, use Assign and Unassign instead.
+// ctx: context
+// device: changes to apply, identified by their ID
+// returns: ErrInvalidDeviceStatus or an error
+func (s *DeviceService) Update(ctx context.Context, device *models.Device) error {
+	if device.Status != "" && !isDeviceStatus(device.Status) {
+		return services.ErrInvalidDeviceStatus
+	}
+
+	current, err := s.Repository.GetByID(ctx, device.ID)
+	if err != nil {
+		return err
+	}
+
+	if device.Status == models.DeviceStatusRetired && current.WaypointID != nil {
+		if err := s.deviceRepository.Unassign(ctx, current, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+
+	device.Serial = ""
+	device.WaypointID = nil
+	return s.Repository.Update(ctx, device)
+}
+
+// Delete deletes a device that has never been installed
+// Devices with an installation history keep attributing their readings, so they can only be retired.
+// ctx: context
+// id: ID of the device
+// returns: ErrDeviceHasHistory or an error
+func (s *DeviceService) Delete(ctx context.Context, id uint) error {
+	if s.assignmentRepository.CountWhere(ctx, &models.DeviceAssignment{DeviceID: id}) > 0 {
+		return services.ErrDeviceHasHistory
+	}
+
+	return s.Repository.Delete(ctx, id)
+}
+
+// GetByCompany returns the devices of the company
+// ctx: context
+// companyID: ID of the company
+// returns: the devices and an error
+func (s *DeviceService) GetByCompany(ctx context.Context, companyID uint) ([]models.Device, error) {
+	return s.Repository.Where(ctx, &models.Device{CompanyID: companyID})
+}
+
+// Assign installs the device at the waypoint
+// ctx: context
+// device: device to install, updated with its new waypoint
+// waypoint: waypoint to install the device at, its route must be loaded
+// assignedByID: ID of the user installing the device
+// returns: ErrDeviceRetired, ErrDeviceOtherCompany or an error
+func (s *DeviceService) Assign(
+	ctx context.Context,
+	device *models.Device,
+	waypoint models.Waypoint,
+	assignedByID uint,
+) error {
+	if device.Status == models.DeviceStatusRetired {
+		return services.ErrDeviceRetired
+	}
+
+	if waypoint.Route.CompanyID != device.CompanyID {
+		return services.ErrDeviceOtherCompany
+	}
+
+	if device.WaypointID != nil && *device.WaypointID == waypoint.ID {
+		return nil
+	}
+
+	return s.deviceRepository.Assign(ctx, device, waypoint, assignedByID, time.Now().UTC())
+}
+
+// Unassign removes the device from its waypoint
+// ctx: context
+// device: device to remove, updated without a waypoint
+// returns: ErrDeviceNotAssigned or an error
+func (s *DeviceService) Unassign(ctx context.Context, device *models.Device) error {
+	if device.WaypointID == nil {
+		return services.ErrDeviceNotAssigned
+	}
+
+	return s.deviceRepository.Unassign(ctx, device, time.Now().UTC())
+}
+
+// GetAssignments returns the installation history of the device, newest first
+// ctx: context
+// deviceID: ID of the device
+// returns: the assignments and an error
+func (s *DeviceService) GetAssignments(ctx context.Context, deviceID uint) ([]models.DeviceAssignment, error) {
+	assignments, err := s.assignmentRepository.Where(ctx, &models.DeviceAssignment{DeviceID: deviceID})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(assignments, func(i, j int) bool {
+		return assignments[i].AssignedAt.After(assignments[j].AssignedAt)
+	})
+
+	return assignments, nil
+}
+
+// isDeviceStatus checks if the status is a known device status
+// status: status to check
+// returns: true if the status is known
+func isDeviceStatus(status string) bool {
+	switch status {
+	case models.DeviceStatusActive, models.DeviceStatusMaintenance, models.DeviceStatusRetired:
+		return true
+	default:
+		return false
+	}
+}
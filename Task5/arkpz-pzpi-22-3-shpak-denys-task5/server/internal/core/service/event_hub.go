@@ -0,0 +1,125 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"sync"
+	"sync/atomic"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port/services"
+)
+
+// EventHub is an in-process publish/subscribe of the stream events
+// Publishing never blocks: every subscriber has a buffer, and the events that do not fit into the buffer
+// of a slow subscriber are dropped and counted for it instead of holding up the ingest.
+type EventHub struct {
+	mu          sync.RWMutex
+	subscribers map[*subscription]struct{} // current subscribers
+	companies   map[uint]int               // number of subscribers of every company
+	bufferSize  int                        // events a subscriber may fall behind before events are dropped
+}
+
+// NewEventHub creates a new event hub
+// bufferSize: events a subscriber may fall behind before events are dropped
+// returns: a new event hub
+func NewEventHub(bufferSize int) *EventHub {
+	return &EventHub{
+		subscribers: make(map[*subscription]struct{}),
+		companies:   make(map[uint]int),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Publish delivers the events to every subscriber whose scope matches them
+// events: events to publish
+func (h *EventHub) Publish(events ...models.StreamEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, event := range events {
+		for sub := range h.subscribers {
+			if !sub.scope.Matches(event) {
+				continue
+			}
+
+			select {
+			case sub.events <- event:
+			default:
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the events in the scope
+// The subscriber has to be closed once it stops reading the events.
+// scope: events to receive
+// returns: the subscription
+func (h *EventHub) Subscribe(scope models.StreamScope) services.Subscription {
+	sub := &subscription{
+		hub:    h,
+		scope:  scope,
+		events: make(chan models.StreamEvent, h.bufferSize),
+	}
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.companies[scope.CompanyID]++
+	h.mu.Unlock()
+
+	return sub
+}
+
+// HasSubscribers checks if anybody subscribed to the events of a company, so publishers can skip
+// building events nobody receives
+// companyID: ID of the company, 0 for any company
+// returns: true if the company has at least one subscriber
+func (h *EventHub) HasSubscribers(companyID uint) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if companyID == 0 {
+		return len(h.subscribers) > 0
+	}
+
+	return h.companies[companyID] > 0
+}
+
+// unsubscribe removes a subscriber
+// sub: subscriber to remove
+func (h *EventHub) unsubscribe(sub *subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+
+	delete(h.subscribers, sub)
+	if h.companies[sub.scope.CompanyID]--; h.companies[sub.scope.CompanyID] == 0 {
+		delete(h.companies, sub.scope.CompanyID)
+	}
+}
+
+// subscription is a subscriber of the EventHub
+type subscription struct {
+	hub     *EventHub               // hub the subscriber is registered at
+	scope   models.StreamScope      // events the subscriber receives
+	events  chan models.StreamEvent // buffer of the events not read yet
+	dropped atomic.Uint64           // events dropped since TakeDropped was last called
+}
+
+// Events returns the channel the events are delivered to
+// returns: the channel, it is never closed
+func (s *subscription) Events() <-chan models.StreamEvent {
+	return s.events
+}
+
+// TakeDropped returns the number of events dropped because the buffer was full and resets it
+// returns: the number of events dropped since the last call
+func (s *subscription) TakeDropped() uint64 {
+	return s.dropped.Swap(0)
+}
+
+// Close unregisters the subscriber, no event is delivered to it afterwards
+func (s *subscription) Close() {
+	s.hub.unsubscribe(s)
+}
@@ -0,0 +1,143 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/analysis"
+	utilsMath "wayra/internal/core/domain/utils/math"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// InterpolationService is a service that estimates the measurements of the waypoints missing a reading
+type InterpolationService struct {
+	sensorDataRepository port.SensorDataRepository     // Repository with the readings of the waypoints
+	routeRepository      port.Repository[models.Route] // Repository for the routes lending their waypoints as neighbours
+	config               analysis.InterpolationConfig  // Limits of the interpolation
+}
+
+// NewInterpolationService creates a new interpolation service
+// sensorDataRepository: Repository with the readings of the waypoints
+// routeRepository: Repository for the routes lending their waypoints as neighbours
+// config: Limits of the interpolation
+// returns: a new interpolation service, error if the limits are invalid
+func NewInterpolationService(
+	sensorDataRepository port.SensorDataRepository,
+	routeRepository port.Repository[models.Route],
+	config analysis.InterpolationConfig,
+) (*InterpolationService, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &InterpolationService{
+		sensorDataRepository: sensorDataRepository,
+		routeRepository:      routeRepository,
+		config:               config,
+	}, nil
+}
+
+// EstimateWaypoint estimates the measurements of a waypoint at a point in time
+// ctx: Context of the request
+// waypoint: Waypoint to estimate, with its route
+// at: Point in time of the estimate
+// returns: The estimate, ErrNoEstimate if no reading is close enough, and an error
+func (s *InterpolationService) EstimateWaypoint(
+	ctx context.Context,
+	waypoint models.Waypoint,
+	at time.Time,
+) (analysis.WaypointEstimate, error) {
+	estimates, err := s.EstimateWaypoints(ctx, waypoint.Route.CompanyID, []models.Waypoint{waypoint}, at)
+	if err != nil {
+		return analysis.WaypointEstimate{}, err
+	}
+	if len(estimates) == 0 {
+		return analysis.WaypointEstimate{}, services.ErrNoEstimate
+	}
+
+	return estimates[0], nil
+}
+
+// EstimateRoute estimates the measurements of every waypoint of a route at a point in time
+// ctx: Context of the request
+// route: Route to estimate, with its waypoints
+// at: Point in time of the estimates
+// returns: The estimates of the waypoints that could be estimated and an error
+func (s *InterpolationService) EstimateRoute(
+	ctx context.Context,
+	route models.Route,
+	at time.Time,
+) ([]analysis.WaypointEstimate, error) {
+	return s.EstimateWaypoints(ctx, route.CompanyID, route.Waypoints, at)
+}
+
+// EstimateWaypoints estimates the measurements of waypoints at a point in time
+// The waypoints of every route of the company within MaxDistance of a waypoint are its neighbours.
+// ctx: Context of the request
+// companyID: ID of the company of the waypoints
+// waypoints: Waypoints to estimate
+// at: Point in time of the estimates
+// returns: The estimates of the waypoints that could be estimated, in the order of the waypoints, and an error
+func (s *InterpolationService) EstimateWaypoints(
+	ctx context.Context,
+	companyID uint,
+	waypoints []models.Waypoint,
+	at time.Time,
+) ([]analysis.WaypointEstimate, error) {
+	if len(waypoints) == 0 {
+		return []analysis.WaypointEstimate{}, nil
+	}
+
+	routes, err := s.routeRepository.Where(ctx, &models.Route{CompanyID: companyID})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[uint]bool)
+	for _, waypoint := range waypoints {
+		ids[waypoint.ID] = true
+	}
+
+	neighbours := []models.Waypoint{}
+	for _, route := range routes {
+		for _, candidate := range route.Waypoints {
+			if ids[candidate.ID] || s.isNeighbour(candidate, waypoints) {
+				ids[candidate.ID] = true
+				neighbours = append(neighbours, candidate)
+			}
+		}
+	}
+
+	waypointIDs := make([]uint, 0, len(ids))
+	for id := range ids {
+		waypointIDs = append(waypointIDs, id)
+	}
+
+	readings, err := s.sensorDataRepository.AroundByWaypoints(ctx, waypointIDs, at, s.config.MaxGap)
+	if err != nil {
+		return nil, err
+	}
+
+	return analysis.EstimateWaypoints(waypoints, neighbours, at, readings, s.config), nil
+}
+
+// isNeighbour checks if a waypoint is close enough to one of the waypoints to lend it its values
+// candidate: Waypoint that may lend its values
+// waypoints: Waypoints to estimate
+// returns: true if the candidate is within MaxDistance of a waypoint
+func (s *InterpolationService) isNeighbour(candidate models.Waypoint, waypoints []models.Waypoint) bool {
+	for _, waypoint := range waypoints {
+		distance := utilsMath.HaversineDistance(
+			waypoint.Latitude,
+			waypoint.Longitude,
+			candidate.Latitude,
+			candidate.Longitude,
+		)
+		if distance <= s.config.MaxDistance {
+			return true
+		}
+	}
+
+	return false
+}
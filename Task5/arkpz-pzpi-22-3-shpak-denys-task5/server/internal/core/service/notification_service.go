@@ -0,0 +1,458 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/netguard"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// webhookSecretPrefix marks the secrets the webhook notifications are signed with
+const webhookSecretPrefix = "whsec_"
+
+// Templates of the notifications of the channels without their own
+const (
+	defaultSubjectTemplate = `[{{.Severity}}] {{.Type}}{{if .RouteName}} on {{.RouteName}}{{end}}`
+	defaultBodyTemplate    = `{{.Message}}
+{{if .RouteName}}
+Route: {{.RouteName}}{{end}}{{if .WaypointName}}
+Waypoint: {{.WaypointName}}{{end}}{{if .Details}}
+Details: {{.Details}}{{end}}
+Opened at: {{.OpenedAt.Format "2006-01-02 15:04 MST"}}
+`
+)
+
+// alertSeverityRanks orders the severities of the alerts, a channel receives the alerts ranked at least its minimum
+var alertSeverityRanks = map[string]int{
+	models.AlertSeverityInfo:     0,
+	models.AlertSeverityWarning:  1,
+	models.AlertSeverityCritical: 2,
+}
+
+// NotificationService is a service that manages the notification channels of the companies
+// and sends the alerts to them
+type NotificationService struct {
+	*GenericService[models.NotificationChannel]                                              // Embedding the generic service
+	channelRepository                           port.NotificationChannelRepository           // Repository with the channels
+	deliveryRepository                          port.Repository[models.NotificationDelivery] // Repository with the delivery log
+	routeRepository                             port.Repository[models.Route]                // Repository for the routes of the channels
+	senders                                     map[string]port.NotificationSender           // Senders by kind of channel
+	retryPolicy                                 models.NotificationRetryPolicy               // How often a failed notification is sent again
+	webhookGuard                                *netguard.Guard                              // Hosts the webhooks may point to
+}
+
+// NewNotificationService creates a new notification service
+// repo: Repository with the channels
+// deliveryRepository: Repository with the delivery log
+// routeRepository: Repository for the routes of the channels
+// senders: Senders by kind of channel, a kind without a sender can not be used
+// retryPolicy: How often a failed notification is sent again
+// webhookGuard: Hosts the webhooks may point to
+// returns: a new notification service
+func NewNotificationService(
+	repo port.NotificationChannelRepository,
+	deliveryRepository port.Repository[models.NotificationDelivery],
+	routeRepository port.Repository[models.Route],
+	senders map[string]port.NotificationSender,
+	retryPolicy models.NotificationRetryPolicy,
+	webhookGuard *netguard.Guard,
+) *NotificationService {
+	if retryPolicy.MaxAttempts < 1 {
+		retryPolicy.MaxAttempts = 1
+	}
+
+	return &NotificationService{
+		GenericService:     NewGenericService[models.NotificationChannel](repo),
+		channelRepository:  repo,
+		deliveryRepository: deliveryRepository,
+		routeRepository:    routeRepository,
+		senders:            senders,
+		retryPolicy:        retryPolicy,
+		webhookGuard:       webhookGuard,
+	}
+}
+
+// Create adds a notification channel to a company
+// A webhook channel without a secret gets a new one.
+// ctx: context
+// channel: channel to add, with its company and the routes whose alerts it receives, none for every route
+// returns: the errors of the channel validation or an error
+func (s *NotificationService) Create(ctx context.Context, channel *models.NotificationChannel) error {
+	if err := s.validateChannel(ctx, channel); err != nil {
+		return err
+	}
+
+	return s.Repository.Add(ctx, channel)
+}
+
+// Update replaces a notification channel and its routes
+// A webhook channel without a secret gets a new one, so clearing the secret rotates it.
+// ctx: context
+// channel: channel with its ID and every field it should have
+// returns: the errors of the channel validation or an error
+func (s *NotificationService) Update(ctx context.Context, channel *models.NotificationChannel) error {
+	if err := s.validateChannel(ctx, channel); err != nil {
+		return err
+	}
+
+	return s.Repository.Update(ctx, channel)
+}
+
+// GetByCompany returns the notification channels of a company
+// ctx: context
+// companyID: ID of the company
+// returns: the channels with their routes ordered by ID and an error
+func (s *NotificationService) GetByCompany(ctx context.Context, companyID uint) ([]models.NotificationChannel, error) {
+	return s.channelRepository.ByCompany(ctx, companyID)
+}
+
+// GetDeliveries returns the latest deliveries of the notifications sent to a channel
+// ctx: context
+// channelID: ID of the channel
+// limit: maximum number of deliveries, 0 for all
+// returns: the deliveries newest first and an error
+func (s *NotificationService) GetDeliveries(
+	ctx context.Context,
+	channelID uint,
+	limit int,
+) ([]models.NotificationDelivery, error) {
+	return s.channelRepository.Deliveries(ctx, channelID, limit)
+}
+
+// NotifyAlert sends an alert that was opened to the enabled channels of its company that accept its severity
+// and its route
+// The deliveries are logged and sent in the background, retrying the failed ones. Failures are logged,
+// the alert is kept anyway.
+// ctx: context
+// alert: alert that was opened
+// route: route of the alert, with its waypoints
+func (s *NotificationService) NotifyAlert(ctx context.Context, alert models.Alert, route models.Route) {
+	channels, err := s.channelRepository.ByCompany(ctx, alert.CompanyID)
+	if err != nil {
+		slog.Error("notifying alert failed",
+			slog.Uint64("alert_id", uint64(alert.ID)),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	notification := models.Notification{
+		Event:      models.NotificationEventAlertOpened,
+		AlertID:    alert.ID,
+		Type:       alert.Type,
+		Message:    alert.Message,
+		Details:    alert.Details,
+		Severity:   alert.Severity,
+		CompanyID:  alert.CompanyID,
+		RouteID:    route.ID,
+		RouteName:  route.Name,
+		WaypointID: alert.WaypointID,
+		OpenedAt:   alert.OpenedAt,
+	}
+	for _, waypoint := range route.Waypoints {
+		if waypoint.ID == alert.WaypointID {
+			notification.WaypointName = waypoint.Name
+		}
+	}
+
+	for _, channel := range channels {
+		if !channel.Enabled || !acceptsAlert(channel, alert) {
+			continue
+		}
+
+		alertID := alert.ID
+		delivery, err := s.queue(ctx, channel, &alertID, &notification)
+		if err != nil {
+			slog.Error("notifying alert failed",
+				slog.Uint64("alert_id", uint64(alert.ID)),
+				slog.Uint64("channel_id", uint64(channel.ID)),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+
+		go s.deliver(channel, delivery, notification, s.retryPolicy.MaxAttempts)
+	}
+}
+
+// SendTest sends a test notification to a channel, once and right away, even if the channel is disabled
+// ctx: context
+// channel: channel to test
+// returns: the delivery, failed if the channel did not accept the notification, and an error
+func (s *NotificationService) SendTest(
+	ctx context.Context,
+	channel models.NotificationChannel,
+) (*models.NotificationDelivery, error) {
+	notification := models.Notification{
+		Event:     models.NotificationEventTest,
+		Type:      "Test Notification",
+		Message:   "This is a test notification, the channel is set up correctly.",
+		Severity:  models.AlertSeverityInfo,
+		CompanyID: channel.CompanyID,
+		OpenedAt:  time.Now().UTC(),
+	}
+
+	delivery, err := s.queue(ctx, channel, nil, &notification)
+	if err != nil {
+		return nil, err
+	}
+
+	s.deliver(channel, delivery, notification, 1)
+	return delivery, nil
+}
+
+// queue logs a pending delivery of a notification to a channel and renders the notification for it
+// ctx: context
+// channel: channel the notification is sent to
+// alertID: ID of the alert the notification tells about, nil for tests
+// notification: notification to send, its delivery ID, subject and text are filled in
+// returns: the delivery and an error
+func (s *NotificationService) queue(
+	ctx context.Context,
+	channel models.NotificationChannel,
+	alertID *uint,
+	notification *models.Notification,
+) (*models.NotificationDelivery, error) {
+	delivery := &models.NotificationDelivery{
+		ChannelID: channel.ID,
+		AlertID:   alertID,
+		Event:     notification.Event,
+		Status:    models.NotificationDeliveryPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.deliveryRepository.Add(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	notification.DeliveryID = delivery.ID
+	if err := renderNotification(channel, notification); err != nil {
+		delivery.Status = models.NotificationDeliveryFailed
+		delivery.LastError = err.Error()
+		if err := s.deliveryRepository.Update(ctx, delivery); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// deliver sends a notification to a channel, retrying with an exponential backoff, and logs the outcome
+// ctx is not taken from the caller, the delivery outlives the evaluation of the alert rules.
+// channel: channel the notification is sent to
+// delivery: pending delivery of the notification, updated with the outcome
+// notification: rendered notification
+// maxAttempts: attempts before the delivery fails
+func (s *NotificationService) deliver(
+	channel models.NotificationChannel,
+	delivery *models.NotificationDelivery,
+	notification models.Notification,
+	maxAttempts int,
+) {
+	sender, ok := s.senders[channel.Kind]
+	backoff := s.retryPolicy.InitialBackoff
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff = min(2*backoff, s.retryPolicy.MaxBackoff)
+		}
+
+		err := services.ErrNotificationKindUnavailable
+		status := 0
+		if ok {
+			ctx, cancel := context.WithTimeout(context.Background(), s.retryPolicy.Timeout)
+			status, err = sender.Send(ctx, channel, notification)
+			cancel()
+		}
+
+		delivery.Attempts = attempt
+		delivery.ResponseStatus = status
+		if err == nil {
+			now := time.Now().UTC()
+			delivery.Status = models.NotificationDeliveryDelivered
+			delivery.DeliveredAt = &now
+		} else {
+			delivery.LastError = err.Error()
+			if attempt == maxAttempts || !ok {
+				delivery.Status = models.NotificationDeliveryFailed
+			}
+		}
+
+		if err := s.deliveryRepository.Update(context.Background(), delivery); err != nil {
+			slog.Error("logging notification delivery failed",
+				slog.Uint64("delivery_id", uint64(delivery.ID)),
+				slog.String("error", err.Error()),
+			)
+		}
+		if delivery.Status != models.NotificationDeliveryPending {
+			return
+		}
+	}
+}
+
+// validateChannel checks the fields of a channel and the routes it receives the alerts of
+// The name, the URL and the recipients are trimmed, the minimum severity defaults to info,
+// a webhook channel without a secret gets a new one and AllRoutes is set when no route is given.
+// ctx: context
+// channel: channel to check
+// returns: the errors of the channel validation or an error
+func (s *NotificationService) validateChannel(ctx context.Context, channel *models.NotificationChannel) error {
+	channel.Name = strings.TrimSpace(channel.Name)
+	channel.URL = strings.TrimSpace(channel.URL)
+	channel.Recipients = strings.TrimSpace(channel.Recipients)
+	if channel.MinSeverity == "" {
+		channel.MinSeverity = models.AlertSeverityInfo
+	}
+
+	if channel.Name == "" || len(channel.Name) > 100 {
+		return services.ErrInvalidNotificationChannel
+	}
+	if _, ok := alertSeverityRanks[channel.MinSeverity]; !ok {
+		return services.ErrInvalidNotificationChannel
+	}
+
+	switch channel.Kind {
+	case models.NotificationChannelWebhook:
+		parsed, err := url.Parse(channel.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return services.ErrInvalidWebhookURL
+		}
+		if err := s.webhookGuard.CheckHost(ctx, parsed.Hostname()); err != nil {
+			return fmt.Errorf("%w: %s", services.ErrWebhookHostNotAllowed, err)
+		}
+		if channel.Secret == "" {
+			secret := make([]byte, 24)
+			if _, err := rand.Read(secret); err != nil {
+				return err
+			}
+			channel.Secret = webhookSecretPrefix + hex.EncodeToString(secret)
+		}
+	case models.NotificationChannelEmail:
+		if _, ok := s.senders[models.NotificationChannelEmail]; !ok {
+			return services.ErrNotificationKindUnavailable
+		}
+		if _, err := mail.ParseAddressList(channel.Recipients); err != nil {
+			return services.ErrInvalidEmailRecipients
+		}
+	default:
+		return services.ErrInvalidNotificationChannel
+	}
+
+	sample := models.Notification{Event: models.NotificationEventTest, OpenedAt: time.Now().UTC()}
+	if err := renderNotification(*channel, &sample); err != nil {
+		return fmt.Errorf("%w: %s", services.ErrInvalidNotificationTemplate, err)
+	}
+
+	channel.AllRoutes = len(channel.Routes) == 0
+	if channel.AllRoutes {
+		return nil
+	}
+
+	routeIDs := make([]uint, 0, len(channel.Routes))
+	for _, route := range channel.Routes {
+		routeIDs = append(routeIDs, route.ID)
+	}
+	routes, err := s.routeRepository.Where(ctx, "company_id = ? AND id IN ?", channel.CompanyID, routeIDs)
+	if err != nil {
+		return err
+	}
+	if len(routes) != len(uniqueIDs(routeIDs)) {
+		return services.ErrNotificationRouteOtherCompany
+	}
+	channel.Routes = routes
+
+	return nil
+}
+
+// acceptsAlert checks if a channel receives an alert
+// channel: channel with its routes
+// alert: alert to send
+// returns: true if the severity of the alert is at least the minimum of the channel and its route is one of the channel's
+func acceptsAlert(channel models.NotificationChannel, alert models.Alert) bool {
+	if alertSeverityRanks[alert.Severity] < alertSeverityRanks[channel.MinSeverity] {
+		return false
+	}
+	if channel.AllRoutes {
+		return true
+	}
+
+	for _, route := range channel.Routes {
+		if route.ID == alert.RouteID {
+			return true
+		}
+	}
+	return false
+}
+
+// renderNotification fills in the subject and the text of a notification from the templates of a channel
+// channel: channel with its templates, the default ones are used when they are empty
+// notification: notification to render
+// returns: an error if a template can not be parsed or executed
+func renderNotification(channel models.NotificationChannel, notification *models.Notification) error {
+	subjectTemplate := channel.SubjectTemplate
+	if subjectTemplate == "" {
+		subjectTemplate = defaultSubjectTemplate
+	}
+	bodyTemplate := channel.BodyTemplate
+	if bodyTemplate == "" {
+		bodyTemplate = defaultBodyTemplate
+	}
+
+	subject, err := executeTemplate("subject", subjectTemplate, *notification)
+	if err != nil {
+		return err
+	}
+	text, err := executeTemplate("body", bodyTemplate, *notification)
+	if err != nil {
+		return err
+	}
+
+	// A subject is a single header line
+	notification.Subject = strings.Join(strings.Fields(subject), " ")
+	notification.Text = text
+	return nil
+}
+
+// executeTemplate parses and executes a text/template
+// name: name of the template in the errors
+// text: template
+// data: data the template is executed with
+// returns: the output and an error
+func executeTemplate(name, text string, data models.Notification) (string, error) {
+	parsed, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	if err := parsed.Execute(&output, data); err != nil {
+		return "", err
+	}
+
+	return output.String(), nil
+}
+
+// uniqueIDs removes the duplicates from a list of IDs
+// ids: IDs, possibly repeated
+// returns: the distinct IDs in their first order
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/netguard"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// fakeDeliveryRepository keeps every state a delivery was logged with
+type fakeDeliveryRepository struct {
+	port.Repository[models.NotificationDelivery]
+	mu      sync.Mutex
+	added   int
+	updates []models.NotificationDelivery
+	done    chan models.NotificationDelivery // receives the deliveries that are no longer pending
+}
+
+func (f *fakeDeliveryRepository) Add(_ context.Context, delivery *models.NotificationDelivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.added++
+	delivery.ID = uint(f.added)
+	return nil
+}
+
+func (f *fakeDeliveryRepository) Update(_ context.Context, delivery *models.NotificationDelivery) error {
+	f.mu.Lock()
+	f.updates = append(f.updates, *delivery)
+	f.mu.Unlock()
+
+	if delivery.Status != models.NotificationDeliveryPending && f.done != nil {
+		f.done <- *delivery
+	}
+	return nil
+}
+
+// fakeChannelRepository returns the same channels for every company
+type fakeChannelRepository struct {
+	port.NotificationChannelRepository
+	channels []models.NotificationChannel
+}
+
+func (f *fakeChannelRepository) ByCompany(context.Context, uint) ([]models.NotificationChannel, error) {
+	return f.channels, nil
+}
+
+// fakeSender fails the first attempts and records when every attempt was made
+type fakeSender struct {
+	mu       sync.Mutex
+	failures int
+	attempts []time.Time
+	sent     []models.Notification
+}
+
+func (f *fakeSender) Send(_ context.Context, _ models.NotificationChannel, notification models.Notification) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.attempts = append(f.attempts, time.Now())
+	f.sent = append(f.sent, notification)
+	if len(f.attempts) <= f.failures {
+		return http.StatusInternalServerError, errors.New("webhook answered 500 Internal Server Error")
+	}
+	return http.StatusOK, nil
+}
+
+// newTestNotificationService creates a notification service sending the webhooks through the sender
+func newTestNotificationService(
+	channels *fakeChannelRepository,
+	deliveries *fakeDeliveryRepository,
+	sender port.NotificationSender,
+	retryPolicy models.NotificationRetryPolicy,
+) *NotificationService {
+	senders := map[string]port.NotificationSender{}
+	if sender != nil {
+		senders[models.NotificationChannelWebhook] = sender
+	}
+
+	return NewNotificationService(channels, deliveries, nil, senders, retryPolicy, netguard.New(nil))
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	policy := models.NotificationRetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     25 * time.Millisecond,
+		Timeout:        time.Second,
+	}
+
+	tests := []struct {
+		name         string
+		failures     int
+		noSender     bool
+		wantStatus   string
+		wantAttempts int
+		wantResponse int
+		wantBackoffs []time.Duration
+	}{
+		{"first attempt delivered", 0, false, models.NotificationDeliveryDelivered, 1, http.StatusOK, nil},
+		{
+			"delivered after retries", 2, false, models.NotificationDeliveryDelivered, 3, http.StatusOK,
+			[]time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+		},
+		{
+			"every attempt failed", 10, false, models.NotificationDeliveryFailed, 4, http.StatusInternalServerError,
+			[]time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond},
+		},
+		{"no sender for the kind", 0, true, models.NotificationDeliveryFailed, 1, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &fakeSender{failures: tt.failures}
+			deliveries := &fakeDeliveryRepository{}
+			var s *NotificationService
+			if tt.noSender {
+				s = newTestNotificationService(&fakeChannelRepository{}, deliveries, nil, policy)
+			} else {
+				s = newTestNotificationService(&fakeChannelRepository{}, deliveries, sender, policy)
+			}
+
+			channel := models.NotificationChannel{ID: 1, Kind: models.NotificationChannelWebhook}
+			delivery := &models.NotificationDelivery{ID: 1, Status: models.NotificationDeliveryPending}
+			s.deliver(channel, delivery, models.Notification{}, policy.MaxAttempts)
+
+			if delivery.Status != tt.wantStatus || delivery.Attempts != tt.wantAttempts {
+				t.Fatalf("delivery %s after %d attempts, want %s after %d", delivery.Status, delivery.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if delivery.ResponseStatus != tt.wantResponse {
+				t.Errorf("response status %d, want %d", delivery.ResponseStatus, tt.wantResponse)
+			}
+			if (delivery.DeliveredAt != nil) != (tt.wantStatus == models.NotificationDeliveryDelivered) {
+				t.Errorf("delivered at %v with status %s", delivery.DeliveredAt, delivery.Status)
+			}
+			if tt.noSender && delivery.LastError != services.ErrNotificationKindUnavailable.Error() {
+				t.Errorf("last error %q, want %q", delivery.LastError, services.ErrNotificationKindUnavailable)
+			}
+			if tt.failures > 0 && delivery.LastError == "" {
+				t.Error("last error of the failed attempts is not logged")
+			}
+
+			// Every attempt is logged, only the last one leaves the pending status
+			if len(deliveries.updates) != tt.wantAttempts {
+				t.Fatalf("logged %d updates, want %d", len(deliveries.updates), tt.wantAttempts)
+			}
+			for i, update := range deliveries.updates[:len(deliveries.updates)-1] {
+				if update.Status != models.NotificationDeliveryPending || update.Attempts != i+1 {
+					t.Errorf("update %d: %s after %d attempts, want pending after %d", i, update.Status, update.Attempts, i+1)
+				}
+			}
+
+			for i, want := range tt.wantBackoffs {
+				if gap := sender.attempts[i+1].Sub(sender.attempts[i]); gap < want {
+					t.Errorf("attempt %d sent %v after the previous one, want at least %v", i+2, gap, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyAlertDeliversToTheAcceptingChannels(t *testing.T) {
+	channels := &fakeChannelRepository{channels: []models.NotificationChannel{
+		{ID: 1, Kind: models.NotificationChannelWebhook, Enabled: true, AllRoutes: true, MinSeverity: models.AlertSeverityWarning},
+		{ID: 2, Kind: models.NotificationChannelWebhook, Enabled: false, AllRoutes: true, MinSeverity: models.AlertSeverityInfo},
+		{ID: 3, Kind: models.NotificationChannelWebhook, Enabled: true, AllRoutes: true, MinSeverity: models.AlertSeverityCritical},
+		{ID: 4, Kind: models.NotificationChannelWebhook, Enabled: true, Routes: []models.Route{{ID: 9}}, MinSeverity: models.AlertSeverityInfo},
+	}}
+	deliveries := &fakeDeliveryRepository{done: make(chan models.NotificationDelivery, 4)}
+	sender := &fakeSender{failures: 1}
+	s := newTestNotificationService(channels, deliveries, sender, models.NotificationRetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Timeout:        time.Second,
+	})
+
+	alert := models.Alert{ID: 5, CompanyID: 1, RouteID: 2, WaypointID: 3, Type: "storm", Severity: models.AlertSeverityWarning, OpenedAt: time.Now()}
+	s.NotifyAlert(context.Background(), alert, models.Route{ID: 2, Name: "North", Waypoints: []models.Waypoint{{ID: 3, Name: "Pass"}}})
+
+	select {
+	case delivery := <-deliveries.done:
+		if delivery.ChannelID != 1 || delivery.Status != models.NotificationDeliveryDelivered || delivery.Attempts != 2 {
+			t.Errorf("delivery to channel %d %s after %d attempts, want channel 1 delivered after 2",
+				delivery.ChannelID, delivery.Status, delivery.Attempts)
+		}
+		if delivery.AlertID == nil || *delivery.AlertID != alert.ID {
+			t.Errorf("delivery of alert %v, want %d", delivery.AlertID, alert.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert was not delivered")
+	}
+
+	select {
+	case delivery := <-deliveries.done:
+		t.Errorf("alert also delivered to channel %d", delivery.ChannelID)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if sent := sender.sent[len(sender.sent)-1]; sent.WaypointName != "Pass" || sent.RouteName != "North" || sent.Subject == "" {
+		t.Errorf("notification %+v, want the names of the route and the waypoint and a subject", sent)
+	}
+}
+
+func TestValidateChannelRejectsPrivateWebhookHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		allowed []string
+		wantErr error
+	}{
+		{"public address", "https://93.184.216.34/hook", nil, nil},
+		{"loopback", "http://127.0.0.1:8080/hook", nil, services.ErrWebhookHostNotAllowed},
+		{"loopback IPv6", "http://[::1]/hook", nil, services.ErrWebhookHostNotAllowed},
+		{"localhost", "http://localhost/hook", nil, services.ErrWebhookHostNotAllowed},
+		{"RFC 1918", "http://10.0.0.5/hook", nil, services.ErrWebhookHostNotAllowed},
+		{"RFC 1918 192.168", "http://192.168.1.10/hook", nil, services.ErrWebhookHostNotAllowed},
+		{"cloud metadata", "http://169.254.169.254/latest/meta-data", nil, services.ErrWebhookHostNotAllowed},
+		{"unspecified", "http://0.0.0.0/hook", nil, services.ErrWebhookHostNotAllowed},
+		{"IPv4-mapped loopback", "http://[::ffff:127.0.0.1]/hook", nil, services.ErrWebhookHostNotAllowed},
+		{"allowed network", "http://10.0.0.5/hook", []string{"10.0.0.0/8"}, nil},
+		{"allowed address", "http://127.0.0.1:8080/hook", []string{"127.0.0.1"}, nil},
+		{"allowed host name", "http://localhost/hook", []string{"localhost"}, nil},
+		{"not http", "ftp://93.184.216.34/hook", nil, services.ErrInvalidWebhookURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewNotificationService(
+				&fakeChannelRepository{}, &fakeDeliveryRepository{}, nil,
+				map[string]port.NotificationSender{models.NotificationChannelWebhook: &fakeSender{}},
+				models.NotificationRetryPolicy{}, netguard.New(tt.allowed),
+			)
+
+			channel := models.NotificationChannel{Name: "Ops", Kind: models.NotificationChannelWebhook, URL: tt.url}
+			err := s.validateChannel(context.Background(), &channel)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (channel.Secret == "" || !channel.AllRoutes) {
+				t.Errorf("channel %+v, want a secret and every route", channel)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"sort"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/analysis"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// QualityService is a service that flags the outliers, spikes, frozen sensors and gaps in the readings
+type QualityService struct {
+	sensorDataRepository port.SensorDataRepository        // Repository with the readings and their flags
+	waypointRepository   port.Repository[models.Waypoint] // Repository for the waypoints of the readings
+	deviceConfigService  services.DeviceConfigService     // Service resolving the report interval of the devices
+	config               analysis.QualityConfig           // Thresholds of the data-quality pass
+}
+
+// NewQualityService creates a new data-quality service
+// sensorDataRepository: Repository with the readings and their flags
+// waypointRepository: Repository for the waypoints of the readings
+// deviceConfigService: Service resolving the report interval of the devices
+// config: Thresholds of the data-quality pass
+// returns: a new data-quality service, error if the thresholds are invalid
+func NewQualityService(
+	sensorDataRepository port.SensorDataRepository,
+	waypointRepository port.Repository[models.Waypoint],
+	deviceConfigService services.DeviceConfigService,
+	config analysis.QualityConfig,
+) (*QualityService, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &QualityService{
+		sensorDataRepository: sensorDataRepository,
+		waypointRepository:   waypointRepository,
+		deviceConfigService:  deviceConfigService,
+		config:               config,
+	}, nil
+}
+
+// AssessReadings flags the stored readings and the readings next to them whose flags they may change
+// ctx: Context of the request
+// readings: stored readings, of one or more waypoints
+// returns: An error if the operation failed
+func (s *QualityService) AssessReadings(ctx context.Context, readings []models.SensorData) error {
+	type dateRange struct{ from, to time.Time }
+
+	ranges := make(map[uint]*dateRange)
+	for _, reading := range readings {
+		r, ok := ranges[reading.WaypointID]
+		if !ok {
+			ranges[reading.WaypointID] = &dateRange{reading.Date, reading.Date}
+			continue
+		}
+		if reading.Date.Before(r.from) {
+			r.from = reading.Date
+		}
+		if reading.Date.After(r.to) {
+			r.to = reading.Date
+		}
+	}
+
+	for _, waypointID := range waypointIDsOf(readings) {
+		if _, err := s.AssessWaypoint(ctx, waypointID, ranges[waypointID].from, ranges[waypointID].to); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AssessWaypoint flags the readings of a waypoint recorded in the time range
+// The reading before the range may turn out to be a spike and the readings after it are judged against it,
+// so they are assessed as well. Only the flags that changed are stored.
+// ctx: Context of the request
+// waypointID: ID of the waypoint
+// from: Start of the range, inclusive
+// to: End of the range, inclusive
+// returns: The outcome of the pass and an error
+func (s *QualityService) AssessWaypoint(
+	ctx context.Context,
+	waypointID uint,
+	from, to time.Time,
+) (models.QualityAssessment, error) {
+	assessment := models.QualityAssessment{}
+
+	interval, err := s.expectedInterval(ctx, waypointID)
+	if err != nil {
+		return assessment, err
+	}
+
+	contextReadings := s.config.ContextReadings()
+	readings, err := s.sensorDataRepository.QualityWindow(ctx, waypointID, from, to, contextReadings)
+	if err != nil {
+		return assessment, err
+	}
+	if len(readings) == 0 {
+		return assessment, nil
+	}
+
+	flags := analysis.AssessQuality(readings, interval, s.config)
+
+	// The readings are ordered by date, first is the first reading of the range and after the first one past it
+	first := sort.Search(len(readings), func(i int) bool { return !readings[i].Date.Before(from) })
+	after := sort.Search(len(readings), func(i int) bool { return readings[i].Date.After(to) })
+
+	// The last reading loaded after the range lacks its next neighbour, unless it is the newest of the waypoint
+	end := len(readings)
+	if len(readings)-after == contextReadings {
+		end--
+	}
+
+	changed := make(map[uint]models.QualityFlags)
+	for i := max(first-1, 0); i < end; i++ {
+		assessment.Assessed++
+		if flags[i] != 0 {
+			assessment.Flagged++
+		}
+		if flags[i].Suspect() {
+			assessment.Suspect++
+		}
+		if flags[i] != readings[i].QualityFlags {
+			changed[readings[i].ID] = flags[i]
+		}
+	}
+	assessment.Changed = len(changed)
+
+	return assessment, s.sensorDataRepository.UpdateQualityFlags(ctx, changed)
+}
+
+// AssessRoute flags the readings of every waypoint of a route recorded in the time range
+// ctx: Context of the request
+// route: Route to assess, with its waypoints
+// from: Start of the range, inclusive
+// to: End of the range, inclusive
+// returns: The outcome of the pass over every waypoint and an error
+func (s *QualityService) AssessRoute(
+	ctx context.Context,
+	route models.Route,
+	from, to time.Time,
+) (models.QualityAssessment, error) {
+	total := models.QualityAssessment{}
+	if !from.Before(to) {
+		return total, services.ErrInvalidQualityRange
+	}
+
+	for _, waypoint := range route.Waypoints {
+		assessment, err := s.AssessWaypoint(ctx, waypoint.ID, from, to)
+		if err != nil {
+			return total, err
+		}
+
+		total.Assessed += assessment.Assessed
+		total.Flagged += assessment.Flagged
+		total.Suspect += assessment.Suspect
+		total.Changed += assessment.Changed
+	}
+
+	return total, nil
+}
+
+// expectedInterval resolves the interval the device of a waypoint reports at
+// ctx: Context of the request
+// waypointID: ID of the waypoint
+// returns: The send_data_frequency of its effective config and an error
+func (s *QualityService) expectedInterval(ctx context.Context, waypointID uint) (time.Duration, error) {
+	waypoint, err := s.waypointRepository.GetByID(ctx, waypointID)
+	if err != nil {
+		return 0, err
+	}
+
+	config, err := s.deviceConfigService.GetEffectiveConfig(ctx, *waypoint)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(config.SendDataFrequency) * time.Minute, nil
+}
@@ -0,0 +1,121 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port"
+)
+
+// RetentionService is a service that rolls the old readings up into hourly and daily aggregates and purges them
+type RetentionService struct {
+	rollupRepository port.SensorDataRollupRepository // Repository for the rollups
+	defaultPolicy    models.RetentionPolicy          // Policy of the companies without their own
+	companyPolicies  map[uint]models.RetentionPolicy // Policies of the companies by company ID
+}
+
+// NewRetentionService creates a new retention service
+// rollupRepository: Repository for the rollups
+// defaultPolicy: Policy of the companies without their own
+// companyPolicies: Policies of the companies by company ID, zero ages fall back to the default policy
+// returns: a new retention service
+func NewRetentionService(
+	rollupRepository port.SensorDataRollupRepository,
+	defaultPolicy models.RetentionPolicy,
+	companyPolicies map[uint]models.RetentionPolicy,
+) *RetentionService {
+	return &RetentionService{
+		rollupRepository: rollupRepository,
+		defaultPolicy:    defaultPolicy,
+		companyPolicies:  companyPolicies,
+	}
+}
+
+// PolicyFor returns the retention policy of a company
+// Hourly rollups are kept at least as long as the raw readings, so every purged day stays covered by them.
+// companyID: ID of the company
+// returns: the policy of the company
+func (s *RetentionService) PolicyFor(companyID uint) models.RetentionPolicy {
+	policy := s.defaultPolicy
+	if override, ok := s.companyPolicies[companyID]; ok {
+		if override.RawAge > 0 {
+			policy.RawAge = override.RawAge
+		}
+		if override.HourlyAge > 0 {
+			policy.HourlyAge = override.HourlyAge
+		}
+	}
+
+	if policy.HourlyAge > 0 && policy.HourlyAge < policy.RawAge {
+		policy.HourlyAge = policy.RawAge
+	}
+
+	return policy
+}
+
+// ApplyRetention rolls up and purges the readings of every company according to its policy
+// The cutoffs are aligned to midnight UTC so a day is always rolled up and purged as a whole.
+// ctx: Context of the request
+// returns: The number of readings rolled up and an error
+func (s *RetentionService) ApplyRetention(ctx context.Context) (int64, error) {
+	companyIDs, err := s.rollupRepository.CompanyIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	var total int64
+	for _, companyID := range companyIDs {
+		policy := s.PolicyFor(companyID)
+		if policy.RawAge <= 0 {
+			continue
+		}
+
+		rawBefore := now.Add(-policy.RawAge).Truncate(models.DailyRollupSize)
+		rolledUp, err := s.rollupRepository.RollUp(ctx, companyID, rawBefore)
+		if err != nil {
+			return total, err
+		}
+		total += rolledUp
+
+		var purged int64
+		if policy.HourlyAge > 0 {
+			hourlyBefore := now.Add(-policy.HourlyAge).Truncate(models.DailyRollupSize)
+			if purged, err = s.rollupRepository.PurgeHourly(ctx, companyID, hourlyBefore); err != nil {
+				return total, err
+			}
+		}
+
+		if rolledUp > 0 || purged > 0 {
+			slog.Info("sensor data retention applied",
+				slog.Uint64("company_id", uint64(companyID)),
+				slog.Int64("readings_rolled_up", rolledUp),
+				slog.Int64("hourly_rollups_purged", purged),
+				slog.Time("raw_before", rawBefore),
+			)
+		}
+	}
+
+	return total, nil
+}
+
+// RunRetention runs ApplyRetention now and then every interval until the context is canceled
+// ctx: Context that stops the job
+// interval: Time between two runs
+func (s *RetentionService) RunRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.ApplyRetention(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("sensor data retention failed", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
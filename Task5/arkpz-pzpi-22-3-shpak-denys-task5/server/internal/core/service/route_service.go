@@ -5,12 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strings"
 	"time"
 	"wayra/internal/core/domain/models"
 	"wayra/internal/core/domain/utils/analysis"
 	utilsMath "wayra/internal/core/domain/utils/math"
+	utilsMeteo "wayra/internal/core/domain/utils/meteo"
 	utilsTime "wayra/internal/core/domain/utils/time"
 	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
 
 	"log/slog"
 )
@@ -20,35 +23,72 @@ type RouteService struct {
 	*GenericService[models.Route]                                    // Embedding the GenericService struct for the Route model
 	waypointRepository            port.Repository[models.Waypoint]   // Repository for the Waypoint model
 	deliveryRepository            port.Repository[models.Delivery]   // Repository for the Delivery model
-	sensorDataRepository          port.Repository[models.SensorData] // Repository for the SensorData model
+	sensorDataRepository          port.SensorDataRepository          // Repository for the SensorData model
+	conditionEventRepository      port.RouteConditionEventRepository // Repository for the RouteConditionEvent model
+	rollupRepository              port.SensorDataRollupRepository    // Repository for the rollups of the SensorData model
+	interpolationService          services.InterpolationService      // Service estimating the waypoints missing a reading
+	eventHub                      services.EventHub                  // Hub the condition changes are published to
+	alertRuleService              services.AlertRuleService          // Service with the alert rules of the companies
+	features                      []analysis.RegressionFeature       // Weather features the delivery speed is regressed on
 }
 
+// deliveryWeatherMargin is how long before and after a delivery the readings of its route are taken into account
+const deliveryWeatherMargin = time.Hour
+
+// alertHistoryMargin is how much earlier than the longest duration of the alert rules the readings are loaded,
+// so the reading the conditions have to hold since is among them
+const alertHistoryMargin = time.Hour
+
 // NewRouteService is a function that creates a new RouteService instance
 // repo: Repository for the Route model
 // waypointRepository: Repository for the Waypoint model
 // deliveryRepository: Repository for the Delivery model
 // sensorDataRepository: Repository for the SensorData model
+// conditionEventRepository: Repository for the RouteConditionEvent model
+// rollupRepository: Repository for the rollups of the SensorData model
+// interpolationService: Service estimating the waypoints missing a reading
+// eventHub: Hub the condition changes are published to
+// alertRuleService: Service with the alert rules of the companies
+// features: Weather features the delivery speed is regressed on
 // Returns a pointer to the RouteService instance
 func NewRouteService(
 	repo port.Repository[models.Route],
 	waypointRepository port.Repository[models.Waypoint],
 	deliveryRepository port.Repository[models.Delivery],
-	sensorDataRepository port.Repository[models.SensorData],
+	sensorDataRepository port.SensorDataRepository,
+	conditionEventRepository port.RouteConditionEventRepository,
+	rollupRepository port.SensorDataRollupRepository,
+	interpolationService services.InterpolationService,
+	eventHub services.EventHub,
+	alertRuleService services.AlertRuleService,
+	features []analysis.RegressionFeature,
 ) *RouteService {
 	return &RouteService{
-		GenericService:       NewGenericService(repo),
-		waypointRepository:   waypointRepository,
-		deliveryRepository:   deliveryRepository,
-		sensorDataRepository: sensorDataRepository,
+		GenericService:           NewGenericService(repo),
+		waypointRepository:       waypointRepository,
+		deliveryRepository:       deliveryRepository,
+		sensorDataRepository:     sensorDataRepository,
+		conditionEventRepository: conditionEventRepository,
+		rollupRepository:         rollupRepository,
+		interpolationService:     interpolationService,
+		eventHub:                 eventHub,
+		alertRuleService:         alertRuleService,
+		features:                 features,
 	}
 }
 
+// RegressionFeatures is a function that returns the weather features the delivery speed is regressed on
+// Returns the features in the order of their coefficients
+func (s *RouteService) RegressionFeatures() []analysis.RegressionFeature {
+	return s.features
+}
+
 // GetOptimalRoute is a function that returns the optimal route for a delivery
 // ctx: Context for the request
 // delivery: Delivery for which the optimal route is to be found
 // includeWeight: Boolean to include weight in the calculation
 // considerPerishable: Boolean to consider perishable products in the calculation
-// Returns the additional message, predict data, coefficients, optimal route, and error
+// Returns the additional message, predict data, coefficients, optimal route, and ErrNoDeliveryHistory or error
 func (s *RouteService) GetOptimalRoute(
 	ctx context.Context,
 	delivery *models.Delivery,
@@ -85,7 +125,12 @@ func (s *RouteService) GetOptimalRoute(
 		}
 
 		for _, delivery := range deliveries {
-			data := CalculateRouteMetrics(delivery, waypoints, includeWeight)
+			weather, err := s.deliveryWeather(ctx, delivery)
+			if err != nil {
+				return "", nil, nil, models.Route{}, err
+			}
+
+			data := CalculateRouteMetrics(delivery, waypoints, weather, includeWeight)
 			if data == nil {
 				continue
 			}
@@ -94,7 +139,10 @@ func (s *RouteService) GetOptimalRoute(
 		}
 	}
 
-	coeffs = analysis.LinearRegression(deliveryMetrics)
+	if len(deliveryMetrics) == 0 {
+		return "", nil, nil, models.Route{}, services.ErrNoDeliveryHistory
+	}
+	coeffs = analysis.LinearRegression(deliveryMetrics, s.features)
 
 	for _, route := range routes {
 		waypoints, err := s.waypointRepository.Where(ctx, &models.Waypoint{RouteID: route.ID})
@@ -102,12 +150,27 @@ func (s *RouteService) GetOptimalRoute(
 			return "", nil, nil, models.Route{}, err
 		}
 
-		latestSensorData := []models.SensorData{}
-		for _, waypoint := range waypoints {
-			latestSensorData = append(
-				latestSensorData,
-				waypoint.SensorData[len(waypoint.SensorData)-1],
-			)
+		latestSensorData, err := s.sensorDataRepository.LatestByRoute(ctx, route.ID, 1, false)
+		if err != nil {
+			return "", nil, nil, models.Route{}, err
+		}
+
+		// Waypoints without a reading are estimated from their neighbours, so sparse routes are not skipped
+		estimates, err := s.interpolationService.EstimateWaypoints(
+			ctx,
+			route.CompanyID,
+			waypointsWithout(waypoints, latestSensorData),
+			time.Now().UTC(),
+		)
+		if err != nil {
+			return "", nil, nil, models.Route{}, err
+		}
+		for _, estimate := range estimates {
+			latestSensorData = append(latestSensorData, estimate.SensorData())
+		}
+
+		if len(latestSensorData) == 0 {
+			continue
 		}
 
 		avgTemp := 0.0
@@ -140,7 +203,14 @@ func (s *RouteService) GetOptimalRoute(
 			}
 		}
 
-		predictedSpeed := analysis.Predict(coeffs, avgData, totalWeight)
+		weather := analysis.WeatherMetrics(latestSensorData)
+		weather.TotalWeight = totalWeight
+
+		predictedSpeed := analysis.Predict(coeffs, s.features, weather)
+		// A regression fitted on too few deliveries can predict no speed at all, the route can not be timed then
+		if math.IsNaN(predictedSpeed) || math.IsInf(predictedSpeed, 0) || predictedSpeed <= 0 {
+			continue
+		}
 
 		var distance float64
 
@@ -196,15 +266,215 @@ func (s *RouteService) GetOptimalRoute(
 		}
 	}
 
+	if optimalRoute == nil {
+		return "", nil, nil, models.Route{}, errors.New("no route of the company has sensor data and a positive predicted speed")
+	}
+
 	return additionalMessage, &predictData, coeffs, *optimalRoute, nil
 }
 
+// waypointsWithout is a function that returns the waypoints that none of the readings belongs to
+// waypoints: Waypoints to filter
+// readings: Readings of some of the waypoints
+// Returns the waypoints without a reading
+func waypointsWithout(waypoints []models.Waypoint, readings []models.SensorData) []models.Waypoint {
+	withReadings := make(map[uint]bool, len(readings))
+	for _, reading := range readings {
+		withReadings[reading.WaypointID] = true
+	}
+
+	without := []models.Waypoint{}
+	for _, waypoint := range waypoints {
+		if !withReadings[waypoint.ID] {
+			without = append(without, waypoint)
+		}
+	}
+
+	return without
+}
+
+// deliveryWeather is a function that returns the aggregates of the readings of the route of a delivery
+// recorded during the delivery, read from the rollups once the raw readings were purged
+// ctx: Context for the request
+// delivery: Delivery the readings are taken for
+// Returns the aggregates with a zero Count when there are no readings or the duration is invalid, and error
+func (s *RouteService) deliveryWeather(ctx context.Context, delivery models.Delivery) (models.SensorDataBucket, error) {
+	duration, err := utilsTime.ParseDuration(delivery.Duration)
+	if err != nil {
+		return models.SensorDataBucket{}, nil
+	}
+
+	from := delivery.Date.Add(-deliveryWeatherMargin)
+	to := delivery.Date.Add(duration + deliveryWeatherMargin)
+
+	return s.rollupRepository.SummaryByRoute(ctx, delivery.RouteID, from, to)
+}
+
+// GetLatestSensorData is a function that returns the latest readings of every waypoint of a route
+// ctx: Context for the request
+// routeID: ID of the route
+// limit: Number of readings to return per waypoint
+// includeFlagged: Whether the readings flagged as suspect are returned too
+// Returns the readings ordered by waypoint, newest first, and error
+func (s *RouteService) GetLatestSensorData(
+	ctx context.Context,
+	routeID uint,
+	limit int,
+	includeFlagged bool,
+) ([]models.SensorData, error) {
+	return s.sensorDataRepository.LatestByRoute(ctx, routeID, limit, includeFlagged)
+}
+
+// GetSensorDataStatistics is a function that returns the summary statistics of the latest readings of a route
+// ctx: Context for the request
+// routeID: ID of the route
+// limit: Number of readings to take per waypoint
+// includeFlagged: Whether the readings flagged as suspect are taken too
+// Returns the statistics of every waypoint followed by the statistics of the whole route, and error
+func (s *RouteService) GetSensorDataStatistics(
+	ctx context.Context,
+	routeID uint,
+	limit int,
+	includeFlagged bool,
+) ([]models.SensorDataStatistics, error) {
+	statistics, err := s.sensorDataRepository.StatisticsByRoute(ctx, routeID, limit, includeFlagged)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range statistics {
+		for _, metric := range []*models.MetricStatistics{
+			&statistics[i].Temperature,
+			&statistics[i].Humidity,
+			&statistics[i].WindSpeed,
+			&statistics[i].MeanPressure,
+		} {
+			if metric.Mean != 0 {
+				metric.CoefficientOfVariation = metric.StdDev / metric.Mean
+			}
+		}
+	}
+
+	return statistics, nil
+}
+
+// ReportCondition is a function that records a condition event, makes it the current condition of the route and publishes it
+// ctx: Context for the request
+// route: Route the condition is reported for, updated with the new condition
+// event: Event to record, its RouteID and CreatedAt are filled in
+// Returns ErrInvalidRouteCondition for unknown conditions, and error
+func (s *RouteService) ReportCondition(
+	ctx context.Context,
+	route *models.Route,
+	event *models.RouteConditionEvent,
+) error {
+	if event.Status != models.RouteConditionOK && event.Status != models.RouteConditionBadWeatherDetected {
+		return services.ErrInvalidRouteCondition
+	}
+
+	now := time.Now().UTC()
+	event.RouteID = route.ID
+	event.CreatedAt = now
+
+	if err := s.conditionEventRepository.Add(ctx, event); err != nil {
+		return err
+	}
+
+	// Only the status columns are written so the rest of the route stays untouched
+	changes := &models.Route{ID: route.ID, Status: event.Status, StatusChangedAt: &now}
+	if err := s.Repository.Update(ctx, changes); err != nil {
+		return err
+	}
+
+	route.Status = changes.Status
+	route.StatusChangedAt = changes.StatusChangedAt
+
+	s.eventHub.Publish(models.StreamEvent{
+		Type:      models.StreamEventRouteCondition,
+		CompanyID: route.CompanyID,
+		RouteID:   route.ID,
+		Date:      now,
+		Data:      *event,
+	})
+	return nil
+}
+
+// GetConditionHistory is a function that returns the condition events of a route, newest first
+// ctx: Context for the request
+// routeID: ID of the route
+// limit: Maximum number of events to return, 0 for all
+// Returns the events and error
+func (s *RouteService) GetConditionHistory(
+	ctx context.Context,
+	routeID uint,
+	limit int,
+) ([]models.RouteConditionEvent, error) {
+	return s.conditionEventRepository.History(ctx, routeID, limit)
+}
+
+// GetSensorDataBuckets is a function that returns the aggregates of the readings of a route grouped in time buckets
+// ctx: Context for the request
+// routeID: ID of the route
+// query: Time range and size of the buckets
+// perWaypoint: Whether every waypoint gets its own buckets instead of aggregating the whole route
+// Returns the buckets that have readings, ErrInvalidBucketQuery, and error
+func (s *RouteService) GetSensorDataBuckets(
+	ctx context.Context,
+	routeID uint,
+	query models.BucketQuery,
+	perWaypoint bool,
+) ([]models.SensorDataBucket, error) {
+	if err := validateBucketQuery(query); err != nil {
+		return nil, err
+	}
+
+	return s.sensorDataRepository.BucketsByRoute(ctx, routeID, query, perWaypoint)
+}
+
+// ClassifyWaypoints is a function that tells sensor malfunctions from bad weather for every waypoint of a route
+// ctx: Context for the request
+// route: Route to analyze, with its waypoints
+// from: Start of the analyzed window
+// to: End of the analyzed window
+// config: Thresholds of the classifier
+// includeFlagged: Whether the readings flagged as suspect are analyzed too
+// Returns the classification of every waypoint and error
+func (s *RouteService) ClassifyWaypoints(
+	ctx context.Context,
+	route models.Route,
+	from, to time.Time,
+	config analysis.ClassifierConfig,
+	includeFlagged bool,
+) ([]analysis.WaypointClassification, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	readings, err := s.sensorDataRepository.BetweenByRoute(ctx, route.ID, from, to, includeFlagged)
+	if err != nil {
+		return nil, err
+	}
+
+	waypointIDs := make([]uint, 0, len(route.Waypoints))
+	for _, waypoint := range route.Waypoints {
+		waypointIDs = append(waypointIDs, waypoint.ID)
+	}
+
+	return analysis.ClassifyWaypoints(waypointIDs, readings, config), nil
+}
+
 // CalculateRouteMetrics is a function that calculates the metrics for a delivery route
 // delivery: Delivery for which the metrics are to be calculated
 // waypoints: Waypoints for the delivery route
+// weather: Aggregates of the readings of the route recorded during the delivery
 // includeWeight: Boolean to include weight in the calculation
-// Returns the calculated metrics for the delivery route
-func CalculateRouteMetrics(delivery models.Delivery, waypoints []models.Waypoint, includeWeight bool) *analysis.DeliveryMetrics {
+// Returns the calculated metrics for the delivery route, nil when there are no readings
+func CalculateRouteMetrics(
+	delivery models.Delivery,
+	waypoints []models.Waypoint,
+	weather models.SensorDataBucket,
+	includeWeight bool,
+) *analysis.DeliveryMetrics {
 	speedData := analysis.DeliveryMetrics{}
 	totalDistance := 0.0
 	for i := 0; i < len(waypoints)-1; i++ {
@@ -216,17 +486,7 @@ func CalculateRouteMetrics(delivery models.Delivery, waypoints []models.Waypoint
 		)
 	}
 
-	sensorData := []models.SensorData{}
-
-	for _, waypoint := range waypoints {
-		sensorData = append(sensorData, waypoint.SensorData...)
-	}
-
-	tempSum := 0.0
-	humiditySum := 0.0
-	windSpeedSum := 0.0
 	totalWeight := 0.0
-	count := 0
 
 	for _, product := range delivery.Products {
 		totalWeight += product.Weight
@@ -238,22 +498,17 @@ func CalculateRouteMetrics(delivery models.Delivery, waypoints []models.Waypoint
 		return nil
 	}
 
-	for _, sensorData := range sensorData {
-		oneHourBefore := delivery.Date.Add(-1 * time.Hour)
-		oneHourAfter := delivery.Date.Add(time.Hour)
-
-		if sensorData.Date.After(oneHourBefore) && sensorData.Date.Before(oneHourAfter.Add(duration)) {
-			tempSum += sensorData.Temperature
-			humiditySum += sensorData.Humidity
-			windSpeedSum += sensorData.WindSpeed
-			count++
-		}
+	if weather.Count == 0 {
+		return nil
 	}
 
 	speedData = analysis.DeliveryMetrics{
-		Temperature:   tempSum / float64(count),
-		Humidity:      humiditySum / float64(count),
-		WindSpeed:     windSpeedSum / float64(count),
+		Temperature:   weather.Temperature.Avg,
+		Humidity:      weather.Humidity.Avg,
+		WindSpeed:     weather.WindSpeed.Avg,
+		DewPoint:      derivedAvg(weather.DewPoint, utilsMeteo.DewPoint(weather.Temperature.Avg, weather.Humidity.Avg)),
+		HeatIndex:     derivedAvg(weather.HeatIndex, utilsMeteo.HeatIndex(weather.Temperature.Avg, weather.Humidity.Avg)),
+		WindChill:     derivedAvg(weather.WindChill, utilsMeteo.WindChill(weather.Temperature.Avg, weather.WindSpeed.Avg)),
 		DeliverySpeed: totalDistance / duration.Hours(),
 	}
 
@@ -266,6 +521,18 @@ func CalculateRouteMetrics(delivery models.Delivery, waypoints []models.Waypoint
 	return &speedData
 }
 
+// derivedAvg is a function that returns the average of a derived metric over a delivery
+// aggregate: Aggregates of the metric, nil when the readings were rolled up before it was derived
+// fallback: The metric derived from the averages of the measured metrics
+// Returns the average of the metric
+func derivedAvg(aggregate *models.MetricAggregate, fallback float64) float64 {
+	if aggregate == nil {
+		return fallback
+	}
+
+	return aggregate.Avg
+}
+
 // GetWeatherAlert is a function that returns the weather alerts for a route
 // ctx: Context for the request
 // route: Route for which the weather alerts are to be found
@@ -280,87 +547,130 @@ func (s *RouteService) GetWeatherAlert(
 		return nil, errors.New("no waypoints found for the route")
 	}
 
-	latestSensorData := []models.SensorData{}
-	for _, waypoint := range route.Waypoints {
-		latestSensorData = append(
-			latestSensorData,
-			waypoint.SensorData[len(waypoint.SensorData)-1],
-		)
+	latestSensorData, onlineWaypoints, offlineWaypoints, err := s.latestAlertReadings(ctx, route)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(offlineWaypoints) > 0 {
+		alerts = append(alerts, models.WeatherAlert{
+			Type:    "Device Offline Alert",
+			Message: "Devices stopped reporting, the weather at their waypoints is unknown.",
+			Details: fmt.Sprintf("Offline waypoints: %s", strings.Join(offlineWaypoints, ", ")),
+		})
 	}
 
 	if len(latestSensorData) == 0 {
+		if len(alerts) > 0 {
+			return alerts, nil
+		}
 		return nil, errors.New("no sensor data available for the route")
 	}
 
-	existingAlertTypes := make(map[string]bool)
+	raised, err := s.raiseAlertRules(ctx, route, latestSensorData, onlineWaypoints)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts = append(alerts, analysis.EvaluateAlertRules(raised)...)
 
-	for _, data := range latestSensorData {
-		if data.Temperature < 0 && data.Humidity > 80 {
-			if !existingAlertTypes["Ice Alert"] {
-				alerts = append(alerts, models.WeatherAlert{
-					Type:    "Ice Alert",
-					Message: "Potential ice formation detected due to low temperature and high humidity.",
-					Details: fmt.Sprintf("Temperature: %.2f°C, Humidity: %.2f%%", data.Temperature, data.Humidity),
-				})
-				existingAlertTypes["Ice Alert"] = true
-			}
-		}
+	return alerts, nil
+}
 
-		if data.WindSpeed > 20 {
-			if !existingAlertTypes["Storm Alert"] {
-				alerts = append(alerts, models.WeatherAlert{
-					Type:    "Storm Alert",
-					Message: "High wind speed detected, potential storm risk.",
-					Details: fmt.Sprintf("Wind Speed: %.2f m/s", data.WindSpeed),
-				})
-				existingAlertTypes["Storm Alert"] = true
-			}
-		}
+// RaiseAlerts is a function that evaluates the alert rules of the company of a route at its waypoints
+// Waypoints with an offline device or without readings raise nothing.
+// ctx: Context for the request
+// route: Route with its waypoints
+// Returns an alert for every rule and waypoint it is raised at, and error
+func (s *RouteService) RaiseAlerts(ctx context.Context, route models.Route) ([]analysis.RaisedAlert, error) {
+	latestSensorData, onlineWaypoints, _, err := s.latestAlertReadings(ctx, route)
+	if err != nil || len(latestSensorData) == 0 {
+		return nil, err
+	}
 
-		if data.MeanPressure < 980 {
-			if !existingAlertTypes["Low Pressure Alert"] {
-				alerts = append(alerts, models.WeatherAlert{
-					Type:    "Low Pressure Alert",
-					Message: "Low atmospheric pressure detected, potential severe weather conditions.",
-					Details: fmt.Sprintf("Pressure: %.2f hPa", data.MeanPressure),
-				})
-				existingAlertTypes["Low Pressure Alert"] = true
-			}
+	return s.raiseAlertRules(ctx, route, latestSensorData, onlineWaypoints)
+}
+
+// latestAlertReadings is a function that returns the latest readings the weather alerts of a route are raised for
+// ctx: Context for the request
+// route: Route with its waypoints
+// Returns the latest reading of every waypoint with an online device, those waypoints in the order of the route,
+// the names of the waypoints with an offline device, and error
+func (s *RouteService) latestAlertReadings(
+	ctx context.Context,
+	route models.Route,
+) ([]models.SensorData, []models.Waypoint, []string, error) {
+	// A suspect reading, like a single bogus spike, must not raise an alert on its own
+	latestReadings, err := s.sensorDataRepository.LatestByRoute(ctx, route.ID, 1, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	latestByWaypoint := make(map[uint]models.SensorData, len(latestReadings))
+	for _, reading := range latestReadings {
+		latestByWaypoint[reading.WaypointID] = reading
+	}
+
+	latestSensorData := []models.SensorData{}
+	onlineWaypoints := []models.Waypoint{}
+	offlineWaypoints := []string{}
+	for _, waypoint := range route.Waypoints {
+		// The last reading of an offline device is stale, it must not pass for the current weather
+		if isOffline(waypoint) {
+			offlineWaypoints = append(offlineWaypoints, waypoint.Name)
+			continue
 		}
 
-		if data.Temperature > 35 {
-			if !existingAlertTypes["Heat Alert"] {
-				alerts = append(alerts, models.WeatherAlert{
-					Type:    "Heat Alert",
-					Message: "High temperature detected, risk of heat-related issues.",
-					Details: fmt.Sprintf("Temperature: %.2f°C", data.Temperature),
-				})
-				existingAlertTypes["Heat Alert"] = true
-			}
+		reading, ok := latestByWaypoint[waypoint.ID]
+		if !ok {
+			continue
 		}
 
-		if data.Humidity < 20 {
-			if !existingAlertTypes["Low Humidity Alert"] {
-				alerts = append(alerts, models.WeatherAlert{
-					Type:    "Low Humidity Alert",
-					Message: "Low humidity detected, risk of dry conditions.",
-					Details: fmt.Sprintf("Humidity: %.2f%%", data.Humidity),
-				})
-				existingAlertTypes["Low Humidity Alert"] = true
+		latestSensorData = append(latestSensorData, reading)
+		onlineWaypoints = append(onlineWaypoints, waypoint)
+	}
+
+	return latestSensorData, onlineWaypoints, offlineWaypoints, nil
+}
+
+// raiseAlertRules is a function that evaluates the alert rules of the company of a route
+// ctx: Context for the request
+// route: Route the readings belong to
+// latestSensorData: Latest reading of every waypoint to evaluate
+// waypoints: Waypoints to evaluate, in the order of the route
+// Returns an alert for every rule and waypoint it is raised at, and error
+func (s *RouteService) raiseAlertRules(
+	ctx context.Context,
+	route models.Route,
+	latestSensorData []models.SensorData,
+	waypoints []models.Waypoint,
+) ([]analysis.RaisedAlert, error) {
+	rules, err := s.alertRuleService.GetByCompany(ctx, route.CompanyID)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	// The rules with a duration need the readings of the waypoints before their latest ones
+	history := latestSensorData
+	if window := analysis.AlertHistory(rules); window > 0 {
+		from := latestSensorData[0].Date
+		for _, reading := range latestSensorData {
+			if reading.Date.Before(from) {
+				from = reading.Date
 			}
 		}
 
-		if data.WindSpeed > 30 && data.Temperature < 5 {
-			if !existingAlertTypes["Cold Storm Alert"] {
-				alerts = append(alerts, models.WeatherAlert{
-					Type:    "Cold Storm Alert",
-					Message: "High wind speed combined with low temperature detected, risk of severe cold storm.",
-					Details: fmt.Sprintf("Wind Speed: %.2f m/s, Temperature: %.2f°C", data.WindSpeed, data.Temperature),
-				})
-				existingAlertTypes["Cold Storm Alert"] = true
-			}
+		history, err = s.sensorDataRepository.BetweenByRoute(
+			ctx,
+			route.ID,
+			from.Add(-window-alertHistoryMargin),
+			time.Now().UTC(),
+			false,
+		)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	return alerts, nil
+	return analysis.RaiseAlertRules(rules, waypoints, history), nil
 }
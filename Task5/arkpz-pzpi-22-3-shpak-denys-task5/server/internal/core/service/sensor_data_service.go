@@ -1,20 +1,484 @@
 package service // import "wayra/internal/core/service"
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
 	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/ingest"
 	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+
+	"gorm.io/gorm"
+)
+
+// Limits of the bucket queries
+const (
+	minBucketSize      = time.Minute // smallest bucket, finer charts should use the raw readings
+	maxBucketsPerQuery = 5000        // buckets one query may span, per waypoint
 )
 
 // SensorDataService is a service that manages the sensor data
 type SensorDataService struct {
-	*GenericService[models.SensorData] // Embedding the generic service
+	*GenericService[models.SensorData]                                          // Embedding the generic service
+	sensorDataRepository               port.SensorDataRepository                // Repository with the bulk operations on sensor data
+	assignmentRepository               port.Repository[models.DeviceAssignment] // Repository for the installation history of the devices
+	connectivityService                services.ConnectivityService             // Service for the last-seen tracking of the devices
+	clockRepository                    port.DeviceClockRepository               // Repository for the clock skew of the devices
+	qualityService                     services.QualityService                  // Service flagging the data-quality problems of the readings
+	streamService                      services.StreamService                   // Service publishing the ingested readings to the subscribers
+	calibrationService                 services.CalibrationService              // Service correcting the readings of drifting sensors
 }
 
 // NewSensorDataService creates a new sensor data service
 // repo: the repository to use
+// assignmentRepository: the repository for the installation history of the devices
+// connectivityService: the service for the last-seen tracking of the devices
+// clockRepository: the repository for the clock skew of the devices
+// qualityService: the service flagging the data-quality problems of the readings
+// streamService: the service publishing the ingested readings to the subscribers
+// calibrationService: the service correcting the readings of drifting sensors
 // returns: a new sensor data service
-func NewSensorDataService(repo port.Repository[models.SensorData]) *SensorDataService {
+func NewSensorDataService(
+	repo port.SensorDataRepository,
+	assignmentRepository port.Repository[models.DeviceAssignment],
+	connectivityService services.ConnectivityService,
+	clockRepository port.DeviceClockRepository,
+	qualityService services.QualityService,
+	streamService services.StreamService,
+	calibrationService services.CalibrationService,
+) *SensorDataService {
 	return &SensorDataService{
-		GenericService: NewGenericService(repo),
+		GenericService:       NewGenericService[models.SensorData](repo),
+		sensorDataRepository: repo,
+		assignmentRepository: assignmentRepository,
+		connectivityService:  connectivityService,
+		clockRepository:      clockRepository,
+		qualityService:       qualityService,
+		streamService:        streamService,
+		calibrationService:   calibrationService,
+	}
+}
+
+// Create stores a reading sent by a device, attributed to the device installed at the waypoint
+// when it was recorded, and marks the device as seen
+// The date is taken from DeviceDate and corrected for the clock skew of the device,
+// the measurements are corrected by the calibration of the device.
+// ctx: context
+// sensorData: reading to store
+// returns: error
+func (s *SensorDataService) Create(ctx context.Context, sensorData *models.SensorData) error {
+	readings := []models.SensorData{*sensorData}
+	if err := s.normalizeDates(ctx, readings); err != nil {
+		return err
+	}
+	if err := s.attributeToDevices(ctx, readings); err != nil {
+		return err
+	}
+	if err := s.calibrationService.Apply(ctx, readings); err != nil {
+		return err
+	}
+
+	*sensorData = readings[0]
+	if err := s.Repository.Add(ctx, sensorData); err != nil {
+		return duplicateReadingError(err)
+	}
+	s.assessQuality(ctx, []models.SensorData{*sensorData})
+	s.streamService.PublishReadings(ctx, []models.SensorData{*sensorData})
+
+	return s.connectivityService.RecordActivity(ctx, sensorData.WaypointID)
+}
+
+// readingKey identifies a reading, a device can not record two readings at the same time
+type readingKey struct {
+	waypointID uint
+	date       time.Time
+}
+
+// IngestBatch stores a batch of readings with a single bulk insert, attributed to their devices
+// The dates are taken from DeviceDate and corrected for the clock skew of the devices,
+// the measurements are corrected by the calibration of the devices.
+// Readings that are already stored, or repeated in the batch, are skipped,
+// so sending the same backlog twice does not create duplicates.
+// ctx: context
+// readings: readings to store, their IDs and dates are filled in
+// returns: the status of every reading in the order of the batch, error
+func (s *SensorDataService) IngestBatch(ctx context.Context, readings []models.SensorData) ([]models.IngestStatus, error) {
+	if err := s.normalizeDates(ctx, readings); err != nil {
+		return nil, err
+	}
+
+	if err := s.attributeToDevices(ctx, readings); err != nil {
+		return nil, err
+	}
+	if err := s.calibrationService.Apply(ctx, readings); err != nil {
+		return nil, err
+	}
+
+	statuses, err := s.insertNew(ctx, readings, false)
+	if err != nil {
+		return nil, err
+	}
+	created := createdOf(readings, statuses)
+	s.assessQuality(ctx, created)
+	s.streamService.PublishReadings(ctx, created)
+
+	// Duplicates still prove the device is alive, so every waypoint of the batch is marked as seen
+	if err := s.connectivityService.RecordActivity(ctx, waypointIDsOf(readings)...); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// Import stores historical readings, like the exports of a logger, attributed to their devices
+// The dates are trusted as they are: they are not corrected for the clock skew of the devices,
+// do not update it and do not mark the devices as seen. The measurements are corrected by the calibration
+// in effect when they were recorded.
+// Readings that are already stored, or repeated in the import, are skipped.
+// The readings are not streamed to the subscribers, they are history rather than news.
+// Every new reading is inserted in a single transaction, so an import is either stored whole or not at all.
+// ctx: context
+// readings: readings to store with their UTC dates, their IDs are filled in
+// dryRun: only compute the statuses, nothing is stored
+// returns: the status every reading has or would have in the order of the import, error
+func (s *SensorDataService) Import(
+	ctx context.Context,
+	readings []models.SensorData,
+	dryRun bool,
+) ([]models.IngestStatus, error) {
+	now := time.Now().UTC()
+	for i := range readings {
+		readings[i].ReceivedAt = &now
+		readings[i].TimestampFlag = ""
+	}
+
+	if err := s.attributeToDevices(ctx, readings); err != nil {
+		return nil, err
+	}
+	if err := s.calibrationService.Apply(ctx, readings); err != nil {
+		return nil, err
 	}
+
+	statuses, err := s.insertNew(ctx, readings, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		s.assessQuality(ctx, createdOf(readings, statuses))
+	}
+
+	return statuses, nil
+}
+
+// Update stores the changes to a reading and flags it again, with its neighbours
+// The raw measurements are corrected by the calibration in effect at the date of the reading,
+// so a later recalibration starts from the edited values.
+// ctx: context
+// sensorData: reading to update, with its raw measurements, the stored reading is loaded back into it
+// returns: ErrDuplicateReading, error
+func (s *SensorDataService) Update(ctx context.Context, sensorData *models.SensorData) error {
+	readings := []models.SensorData{*sensorData}
+	if err := s.calibrationService.Apply(ctx, readings); err != nil {
+		return err
+	}
+
+	*sensorData = readings[0]
+	if err := s.Repository.Update(ctx, sensorData); err != nil {
+		return duplicateReadingError(err)
+	}
+
+	// Update leaves the zero and nil fields out, so the measurements and the cleared raw values are written explicitly
+	if err := s.sensorDataRepository.UpdateMeasurements(ctx, readings); err != nil {
+		return err
+	}
+
+	stored, err := s.Repository.GetByID(ctx, sensorData.ID)
+	if err != nil {
+		return err
+	}
+	*sensorData = *stored
+	s.assessQuality(ctx, []models.SensorData{*sensorData})
+
+	return nil
+}
+
+// assessQuality flags the stored readings, with their neighbours
+// The readings are stored even when the pass fails, they are flagged by the next pass over their range.
+// ctx: context
+// readings: readings that were stored
+func (s *SensorDataService) assessQuality(ctx context.Context, readings []models.SensorData) {
+	if len(readings) == 0 {
+		return
+	}
+
+	if err := s.qualityService.AssessReadings(ctx, readings); err != nil {
+		slog.Error("data-quality pass failed",
+			slog.Int("readings", len(readings)),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// duplicateReadingError maps the violation of the unique index on the waypoint and date of the readings
+// err: error of the database
+// returns: ErrDuplicateReading, err
+func duplicateReadingError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return services.ErrDuplicateReading
+	}
+
+	return err
+}
+
+// createdOf returns the readings that were created
+// readings: readings of a batch
+// statuses: status of every reading
+// returns: the created readings
+func createdOf(readings []models.SensorData, statuses []models.IngestStatus) []models.SensorData {
+	created := make([]models.SensorData, 0, len(readings))
+	for i, status := range statuses {
+		if status == models.IngestStatusCreated {
+			created = append(created, readings[i])
+		}
+	}
+
+	return created
+}
+
+// insertNew inserts the readings that are not stored yet, nor repeated earlier in the slice
+// The statuses are taken from the readings the database actually inserted, so a reading stored
+// by a concurrent request in the meantime is reported as a duplicate.
+// ctx: context
+// readings: readings to insert, the IDs of the inserted ones are filled in
+// dryRun: only compute the statuses from the stored readings, nothing is inserted
+// returns: the status of every reading, error
+func (s *SensorDataService) insertNew(
+	ctx context.Context,
+	readings []models.SensorData,
+	dryRun bool,
+) ([]models.IngestStatus, error) {
+	statuses := make([]models.IngestStatus, len(readings))
+	seen := make(map[readingKey]bool, len(readings))
+
+	if dryRun {
+		existing, err := s.sensorDataRepository.FindExisting(ctx, readings)
+		if err != nil {
+			return nil, err
+		}
+		for _, reading := range existing {
+			seen[readingKey{reading.WaypointID, reading.Date.UTC()}] = true
+		}
+	}
+
+	toInsert := make([]models.SensorData, 0, len(readings))
+	positions := make([]int, 0, len(readings))
+	for i, reading := range readings {
+		key := readingKey{reading.WaypointID, reading.Date.UTC()}
+		if seen[key] {
+			statuses[i] = models.IngestStatusDuplicate
+			continue
+		}
+
+		seen[key] = true
+		statuses[i] = models.IngestStatusCreated
+		toInsert = append(toInsert, reading)
+		positions = append(positions, i)
+	}
+
+	if dryRun {
+		return statuses, nil
+	}
+
+	if err := s.sensorDataRepository.AddBatch(ctx, toInsert); err != nil {
+		return nil, err
+	}
+
+	for i, position := range positions {
+		readings[position].ID = toInsert[i].ID
+		if toInsert[i].ID == 0 {
+			statuses[position] = models.IngestStatusDuplicate
+		}
+	}
+
+	return statuses, nil
+}
+
+// GetWaypointBuckets returns the aggregates of the readings of a waypoint grouped in time buckets
+// ctx: context
+// waypointID: ID of the waypoint
+// query: time range and size of the buckets
+// returns: the buckets that have readings, ErrInvalidBucketQuery, error
+func (s *SensorDataService) GetWaypointBuckets(
+	ctx context.Context,
+	waypointID uint,
+	query models.BucketQuery,
+) ([]models.SensorDataBucket, error) {
+	if err := validateBucketQuery(query); err != nil {
+		return nil, err
+	}
+
+	return s.sensorDataRepository.BucketsByWaypoint(ctx, waypointID, query)
+}
+
+// Export streams the readings selected by the query to fn, oldest first
+// The readings are read from a database cursor, so they are never all held in memory.
+// Readings that were rolled up and purged are not exported.
+// ctx: context
+// query: scope and time range of the readings
+// fn: function called with every reading, an error stops the export and is returned
+// returns: ErrInvalidExportQuery, the error of fn, error
+func (s *SensorDataService) Export(
+	ctx context.Context,
+	query models.SensorDataExportQuery,
+	fn func(models.SensorDataExportRow) error,
+) error {
+	if !query.From.Before(query.To) || (query.WaypointID == 0 && query.RouteID == 0 && query.CompanyID == 0) {
+		return services.ErrInvalidExportQuery
+	}
+
+	return s.sensorDataRepository.Export(ctx, query, fn)
+}
+
+// validateBucketQuery checks that the query spans a range of a reasonable number of buckets
+// query: query to check
+// returns: ErrInvalidBucketQuery if the query is invalid
+func validateBucketQuery(query models.BucketQuery) error {
+	if !query.From.Before(query.To) || query.Bucket < minBucketSize {
+		return services.ErrInvalidBucketQuery
+	}
+
+	if query.To.Sub(query.From)/query.Bucket > maxBucketsPerQuery {
+		return services.ErrInvalidBucketQuery
+	}
+
+	return nil
+}
+
+// normalizeDates sets the UTC date of every reading from the date sent by its device
+// The clock skew of every device is estimated from the newest date it sent, as older ones may be a backlog,
+// and subtracted from its dates. Dates that can not be trusted are flagged,
+// the readings dated with the same receive time instead are spread apart so none is taken for a duplicate.
+// ctx: context
+// readings: readings to normalize, readings without ReceivedAt are considered received now
+// returns: error
+func (s *SensorDataService) normalizeDates(ctx context.Context, readings []models.SensorData) error {
+	waypointIDs := waypointIDsOf(readings)
+	if len(waypointIDs) == 0 {
+		return nil
+	}
+
+	stored, err := s.clockRepository.ForWaypoints(ctx, waypointIDs)
+	if err != nil {
+		return err
+	}
+
+	clocks := make(map[uint]*models.DeviceClock, len(waypointIDs))
+	for i := range stored {
+		clocks[stored[i].WaypointID] = &stored[i]
+	}
+
+	now := time.Now().UTC()
+	newest := make(map[uint]models.SensorData, len(waypointIDs))
+	for i := range readings {
+		if readings[i].ReceivedAt == nil {
+			readings[i].ReceivedAt = &now
+		}
+
+		latest, ok := newest[readings[i].WaypointID]
+		if readings[i].DeviceDate != nil && (!ok || readings[i].DeviceDate.After(*latest.DeviceDate)) {
+			newest[readings[i].WaypointID] = readings[i]
+		}
+	}
+
+	for _, waypointID := range waypointIDs {
+		if clocks[waypointID] == nil {
+			clocks[waypointID] = &models.DeviceClock{WaypointID: waypointID}
+		}
+		if latest, ok := newest[waypointID]; ok {
+			clocks[waypointID].Observe(latest.DeviceDate.Sub(*latest.ReceivedAt))
+		}
+	}
+
+	for i := range readings {
+		clock := clocks[readings[i].WaypointID]
+		date, flag := ingest.NormalizeDate(readings[i].DeviceDate, *readings[i].ReceivedAt, clock.Correction, clock.LastReadingAt)
+		readings[i].Date = date
+		readings[i].TimestampFlag = flag
+
+		// The receive time standing in for a wrong date says nothing about the order of the readings
+		if trustedOrder(flag) && (clock.LastReadingAt == nil || date.After(*clock.LastReadingAt)) {
+			clock.LastReadingAt = &date
+		}
+	}
+
+	// Readings without a date are moved past the receive time by the spreading
+	ingest.SpreadFallbackDates(readings)
+	for i := range readings {
+		clock := clocks[readings[i].WaypointID]
+		date := readings[i].Date
+		if trustedOrder(readings[i].TimestampFlag) && date.After(*clock.LastReadingAt) {
+			clock.LastReadingAt = &date
+		}
+	}
+
+	updated := make([]models.DeviceClock, 0, len(clocks))
+	for _, waypointID := range waypointIDs {
+		clocks[waypointID].UpdatedAt = now
+		updated = append(updated, *clocks[waypointID])
+	}
+
+	return s.clockRepository.Save(ctx, updated)
+}
+
+// trustedOrder tells whether the date of a reading with the timestamp flag tells the order of the readings
+func trustedOrder(flag string) bool {
+	return flag != models.TimestampFlagFuture && flag != models.TimestampFlagAncient
+}
+
+// attributeToDevices sets the device installed at the waypoint of every reading when it was recorded,
+// so the readings stay attributed to the device after it moves to another waypoint
+// ctx: context
+// readings: readings to attribute, readings of unregistered devices get no device
+// returns: error
+func (s *SensorDataService) attributeToDevices(ctx context.Context, readings []models.SensorData) error {
+	waypointIDs := waypointIDsOf(readings)
+	if len(waypointIDs) == 0 {
+		return nil
+	}
+
+	assignments, err := s.assignmentRepository.Where(ctx, "waypoint_id IN ?", waypointIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range readings {
+		readings[i].DeviceID = nil
+		for _, assignment := range assignments {
+			if assignment.WaypointID == readings[i].WaypointID && assignment.Covers(readings[i].Date) {
+				deviceID := assignment.DeviceID
+				readings[i].DeviceID = &deviceID
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// waypointIDsOf returns the distinct waypoints of the readings
+// readings: readings to look at
+// returns: the IDs of the waypoints in the order they first appear
+func waypointIDsOf(readings []models.SensorData) []uint {
+	waypointIDs := make([]uint, 0, len(readings))
+	seen := make(map[uint]bool, len(readings))
+	for _, reading := range readings {
+		if !seen[reading.WaypointID] {
+			seen[reading.WaypointID] = true
+			waypointIDs = append(waypointIDs, reading.WaypointID)
+		}
+	}
+
+	return waypointIDs
 }
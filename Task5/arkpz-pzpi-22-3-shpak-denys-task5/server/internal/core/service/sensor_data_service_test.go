@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port"
+)
+
+// fakeSensorDataRepository skips the readings already stored like the ON CONFLICT DO NOTHING insert
+type fakeSensorDataRepository struct {
+	port.SensorDataRepository
+	stored   map[readingKey]bool
+	inserted int
+	err      error
+}
+
+func (f *fakeSensorDataRepository) FindExisting(_ context.Context, readings []models.SensorData) ([]models.SensorData, error) {
+	existing := []models.SensorData{}
+	for _, reading := range readings {
+		if f.stored[readingKey{reading.WaypointID, reading.Date.UTC()}] {
+			existing = append(existing, reading)
+		}
+	}
+	return existing, nil
+}
+
+func (f *fakeSensorDataRepository) AddBatch(_ context.Context, readings []models.SensorData) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	for i := range readings {
+		key := readingKey{readings[i].WaypointID, readings[i].Date.UTC()}
+		if f.stored[key] {
+			continue
+		}
+		f.stored[key] = true
+		f.inserted++
+		readings[i].ID = uint(100 + f.inserted)
+	}
+	return nil
+}
+
+func TestInsertNewStatuses(t *testing.T) {
+	base := time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC)
+	kyiv := time.FixedZone("EET", 2*60*60)
+	reading := func(waypointID uint, date time.Time) models.SensorData {
+		return models.SensorData{WaypointID: waypointID, Date: date, Temperature: 20}
+	}
+
+	const (
+		created   = models.IngestStatusCreated
+		duplicate = models.IngestStatusDuplicate
+	)
+
+	tests := []struct {
+		name         string
+		stored       []models.SensorData // readings stored before, e.g. by a concurrent request
+		readings     []models.SensorData
+		dryRun       bool
+		want         []models.IngestStatus
+		wantInserted int
+	}{
+		{
+			"new readings",
+			nil,
+			[]models.SensorData{reading(1, base), reading(1, base.Add(time.Minute)), reading(2, base)},
+			false,
+			[]models.IngestStatus{created, created, created},
+			3,
+		},
+		{
+			"repeated in the batch",
+			nil,
+			[]models.SensorData{reading(1, base), reading(1, base), reading(1, base.Add(time.Minute))},
+			false,
+			[]models.IngestStatus{created, duplicate, created},
+			2,
+		},
+		{
+			"same instant in another zone",
+			nil,
+			[]models.SensorData{reading(1, base), reading(1, base.In(kyiv))},
+			false,
+			[]models.IngestStatus{created, duplicate},
+			1,
+		},
+		{
+			"already stored",
+			[]models.SensorData{reading(1, base)},
+			[]models.SensorData{reading(1, base), reading(1, base.Add(time.Minute))},
+			false,
+			[]models.IngestStatus{duplicate, created},
+			1,
+		},
+		{
+			"dry run",
+			[]models.SensorData{reading(1, base)},
+			[]models.SensorData{reading(1, base), reading(1, base.Add(time.Minute)), reading(1, base.Add(time.Minute))},
+			true,
+			[]models.IngestStatus{duplicate, created, duplicate},
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeSensorDataRepository{stored: make(map[readingKey]bool)}
+			for _, reading := range tt.stored {
+				repository.stored[readingKey{reading.WaypointID, reading.Date.UTC()}] = true
+			}
+			s := &SensorDataService{sensorDataRepository: repository}
+
+			statuses, err := s.insertNew(context.Background(), tt.readings, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(statuses, tt.want) {
+				t.Errorf("statuses %v, want %v", statuses, tt.want)
+			}
+			if repository.inserted != tt.wantInserted {
+				t.Errorf("inserted %d readings, want %d", repository.inserted, tt.wantInserted)
+			}
+
+			for i, status := range statuses {
+				if stored := tt.readings[i].ID != 0; stored != (status == created && !tt.dryRun) {
+					t.Errorf("reading %d with status %s has ID %d", i, status, tt.readings[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestInsertNewReturnsTheErrorOfTheInsert(t *testing.T) {
+	failure := errors.New("connection lost")
+	s := &SensorDataService{sensorDataRepository: &fakeSensorDataRepository{err: failure}}
+
+	readings := []models.SensorData{{WaypointID: 1, Date: time.Now()}}
+	if _, err := s.insertNew(context.Background(), readings, false); !errors.Is(err, failure) {
+		t.Errorf("error %v, want %v", err, failure)
+	}
+}
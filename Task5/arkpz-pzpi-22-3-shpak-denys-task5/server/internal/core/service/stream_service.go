@@ -0,0 +1,139 @@
+package service // import "wayra/internal/core/service"
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"wayra/internal/core/domain/models"
+	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+)
+
+// StreamService is a service that publishes the ingested readings and the weather alerts they raise to the EventHub
+type StreamService struct {
+	eventHub           services.EventHub                // Hub the events are published to
+	waypointRepository port.Repository[models.Waypoint] // Repository for the waypoints of the readings
+	routeService       services.RouteService            // Service computing the weather alerts of the routes
+
+	mu         sync.Mutex      // Guards lastAlerts
+	lastAlerts map[uint]string // Types of the weather alerts last published for every route
+}
+
+// NewStreamService creates a new stream service
+// eventHub: Hub the events are published to
+// waypointRepository: Repository for the waypoints of the readings
+// routeService: Service computing the weather alerts of the routes
+// returns: a new stream service
+func NewStreamService(
+	eventHub services.EventHub,
+	waypointRepository port.Repository[models.Waypoint],
+	routeService services.RouteService,
+) *StreamService {
+	return &StreamService{
+		eventHub:           eventHub,
+		waypointRepository: waypointRepository,
+		routeService:       routeService,
+		lastAlerts:         make(map[uint]string),
+	}
+}
+
+// PublishReadings publishes the stored readings, and the weather alerts of their routes when the alerts changed
+// Nothing is looked up while nobody is subscribed. Failures are logged, the readings are stored anyway.
+// ctx: Context of the request
+// readings: Readings that were stored
+func (s *StreamService) PublishReadings(ctx context.Context, readings []models.SensorData) {
+	if len(readings) == 0 || !s.eventHub.HasSubscribers(0) {
+		return
+	}
+
+	readings = append([]models.SensorData(nil), readings...)
+	sort.SliceStable(readings, func(i, j int) bool { return readings[i].Date.Before(readings[j].Date) })
+
+	waypoints := make(map[uint]*models.Waypoint)
+	routes := []uint{}
+	events := make([]models.StreamEvent, 0, len(readings))
+	for _, reading := range readings {
+		waypoint, ok := waypoints[reading.WaypointID]
+		if !ok {
+			found, err := s.waypointRepository.GetByID(ctx, reading.WaypointID)
+			if err != nil {
+				slog.Error("publishing readings failed",
+					slog.Uint64("waypoint_id", uint64(reading.WaypointID)),
+					slog.String("error", err.Error()),
+				)
+			}
+
+			waypoint = found
+			waypoints[reading.WaypointID] = found
+			if found != nil && s.eventHub.HasSubscribers(found.Route.CompanyID) {
+				routes = append(routes, found.RouteID)
+			}
+		}
+
+		if waypoint == nil || !s.eventHub.HasSubscribers(waypoint.Route.CompanyID) {
+			continue
+		}
+
+		events = append(events, models.StreamEvent{
+			Type:       models.StreamEventSensorData,
+			CompanyID:  waypoint.Route.CompanyID,
+			RouteID:    waypoint.RouteID,
+			WaypointID: waypoint.ID,
+			Date:       reading.Date,
+			Data:       reading,
+		})
+	}
+
+	s.eventHub.Publish(events...)
+
+	for _, routeID := range routes {
+		s.publishAlerts(ctx, routeID)
+	}
+}
+
+// publishAlerts publishes the weather alerts of a route if their types differ from the ones last published
+// ctx: Context of the request
+// routeID: ID of the route
+func (s *StreamService) publishAlerts(ctx context.Context, routeID uint) {
+	route, err := s.routeService.GetByID(ctx, routeID)
+	if err != nil {
+		slog.Error("publishing weather alerts failed",
+			slog.Uint64("route_id", uint64(routeID)),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	// A route without usable readings has no alerts
+	alerts, err := s.routeService.GetWeatherAlert(ctx, *route)
+	if err != nil {
+		alerts = []models.WeatherAlert{}
+	}
+
+	types := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		types = append(types, alert.Type)
+	}
+	sort.Strings(types)
+	key := strings.Join(types, ",")
+
+	s.mu.Lock()
+	changed := s.lastAlerts[routeID] != key
+	s.lastAlerts[routeID] = key
+	s.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	s.eventHub.Publish(models.StreamEvent{
+		Type:      models.StreamEventWeatherAlert,
+		CompanyID: route.CompanyID,
+		RouteID:   route.ID,
+		Date:      time.Now().UTC(),
+		Data:      alerts,
+	})
+}
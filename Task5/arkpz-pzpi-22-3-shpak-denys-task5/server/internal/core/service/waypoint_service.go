@@ -1,20 +1,151 @@
 package service // import "wayra/internal/core/service"
 
 import (
+	"context"
+	"errors"
+	"time"
 	"wayra/internal/core/domain/models"
 	"wayra/internal/core/port"
+	"wayra/internal/core/port/services"
+
+	"gorm.io/gorm"
 )
 
 // WaypointService is a service that manages waypoints
 type WaypointService struct {
-	*GenericService[models.Waypoint] // Embedding the generic service
+	*GenericService[models.Waypoint]                                    // Embedding the generic service
+	statusEventRepository            port.WaypointStatusEventRepository // Repository for the status events
+	eventHub                         services.EventHub                  // Hub the status changes are published to
 }
 
 // NewWaypointService creates a new waypoint service
 // repo: the repository to use
+// statusEventRepository: the repository for the status events
+// eventHub: the hub the status changes are published to
 // returns: a new waypoint service
-func NewWaypointService(repo port.Repository[models.Waypoint]) *WaypointService {
+func NewWaypointService(
+	repo port.Repository[models.Waypoint],
+	statusEventRepository port.WaypointStatusEventRepository,
+	eventHub services.EventHub,
+) *WaypointService {
 	return &WaypointService{
-		GenericService: NewGenericService(repo),
+		GenericService:        NewGenericService(repo),
+		statusEventRepository: statusEventRepository,
+		eventHub:              eventHub,
+	}
+}
+
+// Create creates a new waypoint, making sure its device serial is not bound to another waypoint
+// ctx: context
+// waypoint: waypoint to create
+// returns: ErrDeviceSerialInUse if the serial is taken, error
+func (s *WaypointService) Create(ctx context.Context, waypoint *models.Waypoint) error {
+	return deviceSerialError(s.Repository.Add(ctx, waypoint))
+}
+
+// Update updates a waypoint, making sure its device serial is not bound to another waypoint
+// ctx: context
+// waypoint: waypoint to update
+// returns: ErrDeviceSerialInUse if the serial is taken, error
+func (s *WaypointService) Update(ctx context.Context, waypoint *models.Waypoint) error {
+	return deviceSerialError(s.Repository.Update(ctx, waypoint))
+}
+
+// GetByDeviceSerial returns the waypoint the device with the given serial is bound to
+// ctx: context
+// deviceSerial: serial number of the device
+// returns: the waypoint, ErrDeviceSerialNotFound or ErrDeviceSerialDuplicate
+func (s *WaypointService) GetByDeviceSerial(ctx context.Context, deviceSerial string) (*models.Waypoint, error) {
+	if deviceSerial == "" {
+		return nil, services.ErrDeviceSerialNotFound
+	}
+
+	waypoints, err := s.Repository.Where(ctx, "device_serial = ?", deviceSerial)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(waypoints) {
+	case 0:
+		return nil, services.ErrDeviceSerialNotFound
+	case 1:
+		return &waypoints[0], nil
+	default:
+		return nil, services.ErrDeviceSerialDuplicate
+	}
+}
+
+// GetWithDevicesByCompany returns the waypoints of the routes of the company that have a device bound
+// ctx: context
+// companyID: ID of the company
+// returns: the waypoints with their routes loaded, error
+func (s *WaypointService) GetWithDevicesByCompany(ctx context.Context, companyID uint) ([]models.Waypoint, error) {
+	return s.Repository.Where(ctx, "device_serial <> '' AND route_id IN (SELECT id FROM routes WHERE company_id = ?)", companyID)
+}
+
+// ReportStatus records a status event, makes its status the current status of the waypoint and publishes it
+// ctx: context
+// waypoint: waypoint the status is reported for, with its route, updated with the new status
+// event: event to record, its WaypointID and CreatedAt are filled in
+// returns: ErrInvalidWaypointStatus for unknown statuses, error
+func (s *WaypointService) ReportStatus(
+	ctx context.Context,
+	waypoint *models.Waypoint,
+	event *models.WaypointStatusEvent,
+) error {
+	if event.Status != models.WaypointStatusOK && event.Status != models.WaypointStatusAnomalyDetected {
+		return services.ErrInvalidWaypointStatus
+	}
+
+	now := time.Now().UTC()
+	event.WaypointID = waypoint.ID
+	event.CreatedAt = now
+
+	if err := s.statusEventRepository.Add(ctx, event); err != nil {
+		return err
+	}
+
+	// Only the status columns are written so the rest of the waypoint stays untouched
+	changes := &models.Waypoint{ID: waypoint.ID, Status: event.Status, StatusChangedAt: &now}
+	if err := s.Repository.Update(ctx, changes); err != nil {
+		return err
 	}
+
+	waypoint.Status = changes.Status
+	waypoint.StatusChangedAt = changes.StatusChangedAt
+
+	s.eventHub.Publish(models.StreamEvent{
+		Type:       models.StreamEventWaypointStatus,
+		CompanyID:  waypoint.Route.CompanyID,
+		RouteID:    waypoint.RouteID,
+		WaypointID: waypoint.ID,
+		Date:       now,
+		Data:       *event,
+	})
+	return nil
+}
+
+// GetStatusHistory returns the status events of the waypoint, newest first
+// ctx: context
+// waypointID: ID of the waypoint
+// limit: maximum number of events to return, 0 for all
+// returns: the events and an error
+func (s *WaypointService) GetStatusHistory(
+	ctx context.Context,
+	waypointID uint,
+	limit int,
+) ([]models.WaypointStatusEvent, error) {
+	return s.statusEventRepository.History(ctx, waypointID, limit)
+}
+
+// deviceSerialError maps the violation of the unique index on the device serials to ErrDeviceSerialInUse
+// The index makes two waypoints racing for the same serial fail, which a lookup before writing could not.
+// err: error of adding or updating a waypoint
+// returns: ErrDeviceSerialInUse if the serial is taken, err otherwise
+func deviceSerialError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return services.ErrDeviceSerialInUse
+	}
+
+	return err
 }
@@ -5,8 +5,12 @@ import (
 	"wayra/internal/adapter/config"
 	"wayra/internal/adapter/httpserver"
 	"wayra/internal/adapter/httpserver/handlers"
+	"wayra/internal/adapter/mqttclient"
+	"wayra/internal/adapter/notifier"
 	"wayra/internal/adapter/repository"
 	"wayra/internal/core/domain/models"
+	"wayra/internal/core/domain/utils/analysis"
+	"wayra/internal/core/domain/utils/netguard"
 	"wayra/internal/core/port"
 	"wayra/internal/core/service"
 
@@ -50,8 +54,11 @@ func BuildContainer() *dig.Container {
 	container.Provide(func(db *gorm.DB) port.Repository[models.Route] {
 		return repository.NewRepository[models.Route](db)
 	})
-	container.Provide(func(db *gorm.DB) port.Repository[models.SensorData] {
-		return repository.NewRepository[models.SensorData](db)
+	container.Provide(func(db *gorm.DB) port.SensorDataRepository {
+		return repository.NewSensorDataRepository(db)
+	})
+	container.Provide(func(repo port.SensorDataRepository) port.Repository[models.SensorData] {
+		return repo
 	})
 	container.Provide(func(db *gorm.DB) port.Repository[models.User] {
 		return repository.NewRepository[models.User](db)
@@ -62,6 +69,55 @@ func BuildContainer() *dig.Container {
 	container.Provide(func(db *gorm.DB) port.Repository[models.UserCompany] {
 		return repository.NewRepository[models.UserCompany](db)
 	})
+	container.Provide(func(db *gorm.DB) port.Repository[models.DeviceConfig] {
+		return repository.NewRepository[models.DeviceConfig](db)
+	})
+	container.Provide(func(db *gorm.DB) port.WaypointStatusEventRepository {
+		return repository.NewWaypointStatusEventRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.RouteConditionEventRepository {
+		return repository.NewRouteConditionEventRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.DeviceRepository {
+		return repository.NewDeviceRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.Repository[models.DeviceAssignment] {
+		return repository.NewRepository[models.DeviceAssignment](db)
+	})
+	container.Provide(func(db *gorm.DB) port.DeviceClockRepository {
+		return repository.NewDeviceClockRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.SensorDataRollupRepository {
+		return repository.NewSensorDataRollupRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.ConnectivityRepository {
+		return repository.NewConnectivityRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.Repository[models.DeviceCredential] {
+		return repository.NewRepository[models.DeviceCredential](db)
+	})
+	container.Provide(func(db *gorm.DB) port.CalibrationRepository {
+		return repository.NewCalibrationRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.AlertRuleRepository {
+		return repository.NewAlertRuleRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.AlertRepository {
+		return repository.NewAlertRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.NotificationChannelRepository {
+		return repository.NewNotificationChannelRepository(db)
+	})
+	container.Provide(func(db *gorm.DB) port.Repository[models.NotificationDelivery] {
+		return repository.NewRepository[models.NotificationDelivery](db)
+	})
+
+	// Notifiers
+	container.Provide(func(cfg *config.Config) *netguard.Guard {
+		return netguard.New(cfg.Notifications.AllowedWebhookHosts)
+	})
+	container.Provide(notifier.NewWebhookSender)
+	container.Provide(notifier.NewEmailSender)
 
 	// Services
 	container.Provide(func(repo port.Repository[models.Company]) *service.CompanyService {
@@ -80,19 +136,145 @@ func BuildContainer() *dig.Container {
 		routeRepo port.Repository[models.Route],
 		waypointRepo port.Repository[models.Waypoint],
 		deliveryRepo port.Repository[models.Delivery],
-		sensorDataRepo port.Repository[models.SensorData],
+		sensorDataRepo port.SensorDataRepository,
+		conditionEventRepo port.RouteConditionEventRepository,
+		rollupRepo port.SensorDataRollupRepository,
+		interpolationService *service.InterpolationService,
+		eventHub *service.EventHub,
+		alertRuleService *service.AlertRuleService,
+		cfg *config.Config,
 		//	productRepo port.Repository[models.Product],
-	) *service.RouteService {
+	) (*service.RouteService, error) {
+		features, err := analysis.ParseRegressionFeatures(cfg.Regression.Features)
+		if err != nil {
+			return nil, err
+		}
+
 		return service.NewRouteService(
 			routeRepo,
 			waypointRepo,
 			deliveryRepo,
 			sensorDataRepo,
+			conditionEventRepo,
+			rollupRepo,
+			interpolationService,
+			eventHub,
+			alertRuleService,
+			features,
 			//productRepo,
+		), nil
+	})
+	container.Provide(func(
+		repo port.SensorDataRepository,
+		assignmentRepo port.Repository[models.DeviceAssignment],
+		connectivityService *service.ConnectivityService,
+		clockRepo port.DeviceClockRepository,
+		qualityService *service.QualityService,
+		streamService *service.StreamService,
+		calibrationService *service.CalibrationService,
+	) *service.SensorDataService {
+		return service.NewSensorDataService(
+			repo,
+			assignmentRepo,
+			connectivityService,
+			clockRepo,
+			qualityService,
+			streamService,
+			calibrationService,
 		)
 	})
-	container.Provide(func(repo port.Repository[models.SensorData]) *service.SensorDataService {
-		return service.NewSensorDataService(repo)
+	container.Provide(func(
+		repo port.CalibrationRepository,
+		sensorDataRepo port.SensorDataRepository,
+		qualityService *service.QualityService,
+	) *service.CalibrationService {
+		return service.NewCalibrationService(repo, sensorDataRepo, qualityService)
+	})
+	container.Provide(func(repo port.AlertRuleRepository) *service.AlertRuleService {
+		return service.NewAlertRuleService(repo)
+	})
+	container.Provide(func(
+		repo port.AlertRepository,
+		routeService *service.RouteService,
+		eventHub *service.EventHub,
+		notificationService *service.NotificationService,
+		devicePublisher port.DevicePublisher,
+	) *service.AlertService {
+		return service.NewAlertService(repo, routeService, eventHub, notificationService, devicePublisher)
+	})
+	container.Provide(func(
+		repo port.NotificationChannelRepository,
+		deliveryRepo port.Repository[models.NotificationDelivery],
+		routeRepo port.Repository[models.Route],
+		webhookSender *notifier.WebhookSender,
+		emailSender *notifier.EmailSender,
+		guard *netguard.Guard,
+		cfg *config.Config,
+	) *service.NotificationService {
+		senders := map[string]port.NotificationSender{
+			models.NotificationChannelWebhook: webhookSender,
+		}
+		if cfg.Notifications.SMTP.Host != "" {
+			senders[models.NotificationChannelEmail] = emailSender
+		}
+
+		return service.NewNotificationService(repo, deliveryRepo, routeRepo, senders, models.NotificationRetryPolicy{
+			MaxAttempts:    cfg.Notifications.MaxAttempts,
+			InitialBackoff: cfg.Notifications.InitialBackoff,
+			MaxBackoff:     cfg.Notifications.MaxBackoff,
+			Timeout:        cfg.Notifications.Timeout,
+		}, guard)
+	})
+	container.Provide(func(cfg *config.Config) *service.EventHub {
+		return service.NewEventHub(cfg.Stream.BufferSize)
+	})
+	container.Provide(func(
+		eventHub *service.EventHub,
+		waypointRepo port.Repository[models.Waypoint],
+		routeService *service.RouteService,
+	) *service.StreamService {
+		return service.NewStreamService(eventHub, waypointRepo, routeService)
+	})
+	container.Provide(func(
+		sensorDataRepo port.SensorDataRepository,
+		waypointRepo port.Repository[models.Waypoint],
+		deviceConfigService *service.DeviceConfigService,
+	) (*service.QualityService, error) {
+		return service.NewQualityService(sensorDataRepo, waypointRepo, deviceConfigService, analysis.DefaultQualityConfig())
+	})
+	container.Provide(func(
+		sensorDataRepo port.SensorDataRepository,
+		routeRepo port.Repository[models.Route],
+	) (*service.InterpolationService, error) {
+		return service.NewInterpolationService(sensorDataRepo, routeRepo, analysis.DefaultInterpolationConfig())
+	})
+	container.Provide(func(
+		connectivityRepo port.ConnectivityRepository,
+		deviceConfigService *service.DeviceConfigService,
+		eventHub *service.EventHub,
+	) *service.ConnectivityService {
+		return service.NewConnectivityService(connectivityRepo, deviceConfigService, eventHub)
+	})
+	container.Provide(func(rollupRepo port.SensorDataRollupRepository, cfg *config.Config) *service.RetentionService {
+		companyPolicies := make(map[uint]models.RetentionPolicy, len(cfg.Retention.Companies))
+		for companyID, retention := range cfg.Retention.Companies {
+			companyPolicies[companyID] = models.RetentionPolicy{
+				RawAge:    retention.RawAge,
+				HourlyAge: retention.HourlyAge,
+			}
+		}
+
+		return service.NewRetentionService(
+			rollupRepo,
+			models.RetentionPolicy{RawAge: cfg.Retention.RawAge, HourlyAge: cfg.Retention.HourlyAge},
+			companyPolicies,
+		)
+	})
+	container.Provide(func(
+		repo port.DeviceRepository,
+		assignmentRepo port.Repository[models.DeviceAssignment],
+	) *service.DeviceService {
+		return service.NewDeviceService(repo, assignmentRepo)
 	})
 	container.Provide(func(repo port.Repository[models.User]) *service.UserService {
 		return service.NewUserService(repo)
@@ -100,12 +282,22 @@ func BuildContainer() *dig.Container {
 	container.Provide(func(us *service.UserService, cfg *config.Config) *service.AuthService {
 		return service.NewAuthService(us, cfg.AuthConfig.SecretKey, cfg.AuthConfig.TokenExpiry)
 	})
-	container.Provide(func(repo port.Repository[models.Waypoint]) *service.WaypointService {
-		return service.NewWaypointService(repo)
+	container.Provide(func(
+		repo port.Repository[models.Waypoint],
+		statusEventRepo port.WaypointStatusEventRepository,
+		eventHub *service.EventHub,
+	) *service.WaypointService {
+		return service.NewWaypointService(repo, statusEventRepo, eventHub)
 	})
 	container.Provide(func(repo port.Repository[models.UserCompany]) *service.UserCompanyService {
 		return service.NewUserCompanyService(repo)
 	})
+	container.Provide(func(repo port.Repository[models.DeviceConfig]) *service.DeviceConfigService {
+		return service.NewDeviceConfigService(repo)
+	})
+	container.Provide(func(repo port.Repository[models.DeviceCredential]) *service.DeviceAuthService {
+		return service.NewDeviceAuthService(repo)
+	})
 
 	// Handlers
 	container.Provide(func(authService *service.AuthService, cfg *config.Config) *handlers.AuthHandler {
@@ -122,28 +314,45 @@ func BuildContainer() *dig.Container {
 		companyService *service.CompanyService,
 		userCompanyService *service.UserCompanyService,
 		deliveryService *service.DeliveryService,
+		qualityService *service.QualityService,
 	) *handlers.RouteHandler {
 		return handlers.NewRoutesHandler(
 			routeService,
 			companyService,
 			userCompanyService,
 			deliveryService,
+			qualityService,
 		)
 	})
 	container.Provide(func(
 		sensorDataService *service.SensorDataService,
 		waypointService *service.WaypointService,
+		routeService *service.RouteService,
 		userCompanyService *service.UserCompanyService,
+		interpolationService *service.InterpolationService,
 	) *handlers.SensorDataHandler {
-		return handlers.NewSensorDataHandler(sensorDataService, waypointService, userCompanyService)
+		return handlers.NewSensorDataHandler(
+			sensorDataService,
+			waypointService,
+			routeService,
+			userCompanyService,
+			interpolationService,
+		)
 	})
 	container.Provide(func(
 		waypointService *service.WaypointService,
 		routeService *service.RouteService,
 		companyService *service.CompanyService,
 		userCompanyService *service.UserCompanyService,
+		connectivityService *service.ConnectivityService,
 	) *handlers.WaypointHandler {
-		return handlers.NewWaypointHandler(waypointService, routeService, companyService, userCompanyService)
+		return handlers.NewWaypointHandler(
+			waypointService,
+			routeService,
+			companyService,
+			userCompanyService,
+			connectivityService,
+		)
 	})
 	container.Provide(func(
 		deliveryService *service.DeliveryService,
@@ -171,6 +380,86 @@ func BuildContainer() *dig.Container {
 		return handlers.NewAdminHandler(cfg.DBPassword, userService, cfg.EncryptionKey)
 	})
 
+	container.Provide(func(
+		deviceConfigService *service.DeviceConfigService,
+		waypointService *service.WaypointService,
+		companyService *service.CompanyService,
+		userCompanyService *service.UserCompanyService,
+	) *handlers.DeviceConfigHandler {
+		return handlers.NewDeviceConfigHandler(deviceConfigService, waypointService, companyService, userCompanyService)
+	})
+	container.Provide(func(
+		deviceService *service.DeviceService,
+		waypointService *service.WaypointService,
+		companyService *service.CompanyService,
+		userCompanyService *service.UserCompanyService,
+	) *handlers.DeviceHandler {
+		return handlers.NewDeviceHandler(deviceService, waypointService, companyService, userCompanyService)
+	})
+	container.Provide(func(
+		deviceAuthService *service.DeviceAuthService,
+		waypointService *service.WaypointService,
+		userCompanyService *service.UserCompanyService,
+	) *handlers.DeviceAuthHandler {
+		return handlers.NewDeviceAuthHandler(deviceAuthService, waypointService, userCompanyService)
+	})
+	container.Provide(func(
+		cfg *config.Config,
+		eventHub *service.EventHub,
+		routeService *service.RouteService,
+		waypointService *service.WaypointService,
+		userCompanyService *service.UserCompanyService,
+	) *handlers.StreamHandler {
+		return handlers.NewStreamHandler(eventHub, routeService, waypointService, userCompanyService, cfg.Stream.Heartbeat)
+	})
+	container.Provide(func(
+		calibrationService *service.CalibrationService,
+		deviceService *service.DeviceService,
+		waypointService *service.WaypointService,
+		userCompanyService *service.UserCompanyService,
+	) *handlers.CalibrationHandler {
+		return handlers.NewCalibrationHandler(calibrationService, deviceService, waypointService, userCompanyService)
+	})
+	container.Provide(func(
+		alertRuleService *service.AlertRuleService,
+		companyService *service.CompanyService,
+		userCompanyService *service.UserCompanyService,
+	) *handlers.AlertRuleHandler {
+		return handlers.NewAlertRuleHandler(alertRuleService, companyService, userCompanyService)
+	})
+	container.Provide(func(
+		alertService *service.AlertService,
+		companyService *service.CompanyService,
+		routeService *service.RouteService,
+		userCompanyService *service.UserCompanyService,
+	) *handlers.AlertHandler {
+		return handlers.NewAlertHandler(alertService, companyService, routeService, userCompanyService)
+	})
+	container.Provide(func(
+		notificationService *service.NotificationService,
+		companyService *service.CompanyService,
+		userCompanyService *service.UserCompanyService,
+	) *handlers.NotificationChannelHandler {
+		return handlers.NewNotificationChannelHandler(notificationService, companyService, userCompanyService)
+	})
+
+	// MQTT
+	container.Provide(func(
+		log *slog.Logger,
+		cfg *config.Config,
+		sensorDataService *service.SensorDataService,
+		waypointService *service.WaypointService,
+		deviceConfigService *service.DeviceConfigService,
+	) *mqttclient.Client {
+		client := mqttclient.NewClient(log, cfg, sensorDataService, waypointService, deviceConfigService)
+		// The client resolves the configs with the config service, so the service gets the client afterwards
+		deviceConfigService.SetDevicePublisher(client)
+		return client
+	})
+	container.Provide(func(client *mqttclient.Client) port.DevicePublisher {
+		return client
+	})
+
 	// HTTP Server
 	container.Provide(func(
 		log *slog.Logger,
@@ -185,6 +474,15 @@ func BuildContainer() *dig.Container {
 		deliveryHandler *handlers.DeliveryHandler,
 		productHandler *handlers.ProductHandler,
 		adminHandler *handlers.AdminHandler,
+		deviceConfigHandler *handlers.DeviceConfigHandler,
+		deviceAuthService *service.DeviceAuthService,
+		deviceAuthHandler *handlers.DeviceAuthHandler,
+		deviceHandler *handlers.DeviceHandler,
+		streamHandler *handlers.StreamHandler,
+		calibrationHandler *handlers.CalibrationHandler,
+		alertRuleHandler *handlers.AlertRuleHandler,
+		alertHandler *handlers.AlertHandler,
+		notificationChannelHandler *handlers.NotificationChannelHandler,
 	) *gin.Engine {
 		return httpserver.NewRouter(
 			log,
@@ -199,6 +497,15 @@ func BuildContainer() *dig.Container {
 			deliveryHandler,
 			productHandler,
 			adminHandler,
+			deviceConfigHandler,
+			deviceAuthService,
+			deviceAuthHandler,
+			deviceHandler,
+			streamHandler,
+			calibrationHandler,
+			alertRuleHandler,
+			alertHandler,
+			notificationChannelHandler,
 		)
 	})
 
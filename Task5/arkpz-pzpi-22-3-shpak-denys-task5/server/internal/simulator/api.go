@@ -0,0 +1,90 @@
+package simulator // import "wayra/internal/simulator"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// apiError is a response of the server with an error status
+type apiError struct {
+	Status  int    // HTTP status of the response
+	Message string // error message returned by the server
+}
+
+// Error implements the error interface
+func (e *apiError) Error() string {
+	return fmt.Sprintf("server responded %d: %s", e.Status, e.Message)
+}
+
+// apiClient calls the wayra http server
+type apiClient struct {
+	baseURL    string       // address of the server, without trailing slash
+	httpClient *http.Client // client shared by every device
+}
+
+// newAPIClient creates a new apiClient
+// baseURL: address of the server
+// httpClient: client shared by every device
+// returns: a new apiClient
+func newAPIClient(baseURL string, httpClient *http.Client) *apiClient {
+	return &apiClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// do sends a request and decodes the JSON response
+// ctx: context of the request
+// method: HTTP method
+// path: path of the endpoint
+// authorization: value of the Authorization header, empty to send none
+// body: value encoded as the JSON body, nil to send none
+// out: value the response is decoded into, nil to discard it
+// returns: an *apiError when the server responds with an error status
+func (a *apiClient) do(ctx context.Context, method, path, authorization string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var errorBody struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errorBody)
+		return &apiError{Status: resp.StatusCode, Message: errorBody.Error}
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
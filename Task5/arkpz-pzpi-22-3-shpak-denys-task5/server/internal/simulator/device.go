@@ -0,0 +1,477 @@
+package simulator // import "wayra/internal/simulator"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+	utilsMath "wayra/internal/core/domain/utils/math"
+)
+
+// Statuses the firmware reports, with the details it sends
+const (
+	anomalyStatus     = "anomaly_detected"
+	anomalyDetails    = "Device CV exceeds normal values. Possible sensor malfunction."
+	badWeatherStatus  = "bad_weather_detected"
+	badWeatherDetails = "CV indicates abnormal weather conditions across multiple waypoints."
+)
+
+// Results of compareWithOtherWaypoints, as numbered by the firmware
+const (
+	noAnomaly     = 0
+	deviceIssue   = 1
+	badWeather    = 2
+	anomalyFactor = 1.5 // how much more the device may vary than the other waypoints
+	stableCV      = 0.1 // the other waypoints are stable below this coefficient of variation
+)
+
+// deviceWaypoint is the waypoint the server binds the device to
+type deviceWaypoint struct {
+	WaypointID uint   `json:"waypoint_id"`
+	RouteID    uint   `json:"route_id"`
+	Name       string `json:"name"`
+}
+
+// waypointDetails are the fields of the waypoint the device has to send back on every update,
+// the server replaces the waypoint with the request
+type waypointDetails struct {
+	ID           uint    `json:"id,omitempty"`
+	Name         string  `json:"name"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	DeviceSerial string  `json:"device_serial"`
+	RouteID      uint    `json:"route_id,omitempty"`
+	Status       string  `json:"status,omitempty"`
+	Details      string  `json:"details,omitempty"`
+}
+
+// deviceConfig is the config the server sends to the device
+type deviceConfig struct {
+	SendDataFrequency int  `json:"send_data_frequency"`
+	GetWeatherAlerts  bool `json:"get_weather_alerts"`
+}
+
+// sensorDataPayload is the reading the firmware posts to /sensor-data/
+type sensorDataPayload struct {
+	Date        string   `json:"date"`
+	Temperature *float64 `json:"temperature"`
+	Humidity    *float64 `json:"humidity"`
+	WindSpeed   *float64 `json:"wind_speed"`
+	Pressure    *float64 `json:"pressure"`
+	WaypointID  uint     `json:"waypoint_id"`
+}
+
+// routeReading is a reading returned by /routes/{route_id}/get-sensor-data
+type routeReading struct {
+	WaypointID   uint    `json:"waypoint_id"`
+	Temperature  float64 `json:"temperature"`
+	Humidity     float64 `json:"humidity"`
+	WindSpeed    float64 `json:"wind_speed"`
+	MeanPressure float64 `json:"mean_pressure"`
+}
+
+// device is a virtual device running the firmware protocol
+type device struct {
+	sim      *Simulator // simulation the device belongs to
+	serial   string     // serial number of the device
+	scenario Scenario   // weather measured by the device
+	rng      *rand.Rand // random source of the device
+	weather  *weather   // generator of the measurements
+
+	waypoint waypointDetails // waypoint the device is bound to
+	config   deviceConfig    // config fetched on start
+	buffer   []measurement   // samples analyzed against the other waypoints
+}
+
+// newDevice creates a new device
+// sim: simulation the device belongs to
+// serial: serial number of the device
+// scenario: weather measured by the device
+// rng: random source of the device
+// returns: a new device
+func newDevice(sim *Simulator, serial string, scenario Scenario, rng *rand.Rand) *device {
+	return &device{
+		sim:      sim,
+		serial:   serial,
+		scenario: scenario,
+		rng:      rng,
+		weather:  newWeather(scenario, rng, sim.cfg.Start),
+		// Defaults of the firmware, used until the config is fetched
+		config: deviceConfig{SendDataFrequency: 20, GetWeatherAlerts: true},
+	}
+}
+
+// run runs the setup and the main loop of the firmware
+// ctx: context that stops the device
+// returns: an error if the setup failed
+func (d *device) run(ctx context.Context) error {
+	if err := d.setup(ctx); err != nil {
+		return err
+	}
+	atomic.AddInt64(&d.sim.stats.Devices, 1)
+
+	log := d.logger()
+	log.Info("device started",
+		slog.String("scenario", string(d.scenario)),
+		slog.Int("send_data_frequency", d.config.SendDataFrequency),
+		slog.Bool("get_weather_alerts", d.config.GetWeatherAlerts),
+	)
+
+	sendInterval := time.Duration(d.config.SendDataFrequency) * time.Minute
+	now := d.sim.cfg.Start
+	lastSend := now
+	sent := 0
+
+	for d.sim.cfg.Readings == 0 || sent < d.sim.cfg.Readings {
+		if err := d.sleep(ctx); err != nil {
+			return err
+		}
+		now = now.Add(sampleInterval)
+
+		current, online := d.weather.sample(now)
+		if online {
+			d.remember(current)
+		}
+
+		if now.Sub(lastSend) < sendInterval {
+			continue
+		}
+		lastSend = now
+		sent++
+
+		if !online {
+			atomic.AddInt64(&d.sim.stats.Skipped, 1)
+			log.Debug("device silent, reading skipped", slog.Time("at", now))
+			continue
+		}
+
+		d.analyze(ctx)
+		d.send(ctx, now, current)
+	}
+
+	log.Info("device finished", slog.Int("readings", sent))
+	return nil
+}
+
+// setup finds the waypoint of the device, pushes its coordinates and fetches its config
+// ctx: context of the requests
+// returns: an error if the device can not work without the failed step
+func (d *device) setup(ctx context.Context) error {
+	latitude, longitude := d.coordinates()
+
+	var bound deviceWaypoint
+	err := d.sim.api.do(ctx, http.MethodGet, "/waypoints/?device_serial="+url.QueryEscape(d.serial),
+		d.deviceAuthorization(), nil, &bound)
+
+	var apiErr *apiError
+	switch {
+	case err == nil:
+		d.waypoint = waypointDetails{ID: bound.WaypointID, RouteID: bound.RouteID, Name: bound.Name, DeviceSerial: d.serial}
+		if err := d.loadWaypoint(ctx); err != nil {
+			return err
+		}
+		if d.sim.cfg.PushCoordinates {
+			d.pushCoordinates(ctx, latitude, longitude)
+		}
+	case errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound && d.sim.cfg.RouteID != 0:
+		if err := d.createWaypoint(ctx, latitude, longitude); err != nil {
+			return fmt.Errorf("create waypoint: %w", err)
+		}
+	default:
+		return fmt.Errorf("get waypoint by device serial: %w", err)
+	}
+
+	var config deviceConfig
+	path := fmt.Sprintf("/device-config/%d", d.waypoint.ID)
+	if err := d.sim.api.do(ctx, http.MethodGet, path, d.deviceAuthorization(), nil, &config); err != nil {
+		d.logger().Warn("failed to get config, using the firmware defaults", slog.String("error", err.Error()))
+		return nil
+	}
+
+	if config.SendDataFrequency > 0 {
+		d.config = config
+	}
+
+	return nil
+}
+
+// loadWaypoint loads the coordinates of the waypoint so the later updates keep them
+// ctx: context of the request
+// returns: an error if the waypoint could not be loaded
+func (d *device) loadWaypoint(ctx context.Context) error {
+	authorization := d.userAuthorization()
+	if authorization == "" {
+		return nil
+	}
+
+	var details waypointDetails
+	path := fmt.Sprintf("/waypoints/%d", d.waypoint.ID)
+	if err := d.sim.api.do(ctx, http.MethodGet, path, authorization, nil, &details); err != nil {
+		return fmt.Errorf("get waypoint: %w", err)
+	}
+
+	d.waypoint.Name = details.Name
+	d.waypoint.Latitude = details.Latitude
+	d.waypoint.Longitude = details.Longitude
+	return nil
+}
+
+// createWaypoint creates the waypoint of a device the server does not know yet
+// ctx: context of the request
+// latitude: latitude of the device
+// longitude: longitude of the device
+// returns: an error if the waypoint could not be created
+func (d *device) createWaypoint(ctx context.Context, latitude, longitude float64) error {
+	authorization := d.userAuthorization()
+	if authorization == "" {
+		return errors.New("a user token is required to create waypoints")
+	}
+
+	request := waypointDetails{
+		Name:         "Simulated " + d.serial,
+		Latitude:     latitude,
+		Longitude:    longitude,
+		DeviceSerial: d.serial,
+		RouteID:      d.sim.cfg.RouteID,
+	}
+
+	var created waypointDetails
+	if err := d.sim.api.do(ctx, http.MethodPost, "/waypoints/", authorization, request, &created); err != nil {
+		return err
+	}
+
+	d.waypoint = request
+	d.waypoint.ID = created.ID
+	d.logger().Info("waypoint created", slog.Uint64("route_id", uint64(d.sim.cfg.RouteID)))
+	return nil
+}
+
+// pushCoordinates sends the coordinates of the device to the server
+// ctx: context of the request
+// latitude: latitude of the device
+// longitude: longitude of the device
+func (d *device) pushCoordinates(ctx context.Context, latitude, longitude float64) {
+	authorization := d.deviceAuthorization()
+	if authorization == "" {
+		d.logger().Warn("no token, coordinates not pushed")
+		return
+	}
+
+	d.waypoint.Latitude = latitude
+	d.waypoint.Longitude = longitude
+
+	path := fmt.Sprintf("/waypoints/%d", d.waypoint.ID)
+	if err := d.sim.api.do(ctx, http.MethodPut, path, authorization, d.waypoint, nil); err != nil {
+		d.logger().Warn("failed to push coordinates", slog.String("error", err.Error()))
+	}
+}
+
+// coordinates returns random coordinates, drawn like the firmware does
+// returns: latitude and longitude
+func (d *device) coordinates() (float64, float64) {
+	latitude := float64(d.rng.Intn(180)-90) + float64(d.rng.Intn(100))/100
+	longitude := float64(d.rng.Intn(360)-180) + float64(d.rng.Intn(100))/100
+	return latitude, longitude
+}
+
+// remember adds the sample to the analysis buffer
+// The firmware keeps send_data_frequency minutes of samples.
+// current: sample to add
+func (d *device) remember(current measurement) {
+	size := d.config.SendDataFrequency * 60 / int(sampleInterval.Seconds())
+
+	d.buffer = append(d.buffer, current)
+	if len(d.buffer) > size {
+		d.buffer = d.buffer[len(d.buffer)-size:]
+	}
+}
+
+// send posts the reading to the server
+// ctx: context of the request
+// at: virtual time of the reading
+// current: measurement to send
+func (d *device) send(ctx context.Context, at time.Time, current measurement) {
+	payload := sensorDataPayload{
+		Date:        at.Add(d.sim.cfg.ClockOffset).UTC().Format("2006-01-02T15:04:05Z"),
+		Temperature: current.Temperature,
+		Humidity:    current.Humidity,
+		WindSpeed:   current.WindSpeed,
+		Pressure:    current.Pressure,
+		WaypointID:  d.waypoint.ID,
+	}
+
+	atomic.AddInt64(&d.sim.stats.Sent, 1)
+	err := d.sim.api.do(ctx, http.MethodPost, "/sensor-data/", d.deviceAuthorization(), payload, nil)
+	if err == nil {
+		atomic.AddInt64(&d.sim.stats.Accepted, 1)
+		return
+	}
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status < http.StatusInternalServerError {
+		atomic.AddInt64(&d.sim.stats.Rejected, 1)
+		d.logger().Debug("reading rejected", slog.String("date", payload.Date), slog.String("error", err.Error()))
+		return
+	}
+
+	atomic.AddInt64(&d.sim.stats.Failed, 1)
+	d.logger().Warn("failed to send reading", slog.String("error", err.Error()))
+}
+
+// analyze compares the buffered samples with the other waypoints of the route and reports the anomalies
+// ctx: context of the requests
+func (d *device) analyze(ctx context.Context) {
+	size := d.config.SendDataFrequency * 60 / int(sampleInterval.Seconds())
+	if !d.config.GetWeatherAlerts || len(d.buffer) < size {
+		return
+	}
+
+	authorization := d.deviceAuthorization()
+	if authorization == "" {
+		return
+	}
+
+	switch d.compareWithOtherWaypoints(ctx, authorization) {
+	case deviceIssue:
+		d.waypoint.Status = anomalyStatus
+		d.waypoint.Details = anomalyDetails
+		path := fmt.Sprintf("/waypoints/%d", d.waypoint.ID)
+		if err := d.sim.api.do(ctx, http.MethodPut, path, authorization, d.waypoint, nil); err != nil {
+			d.logger().Warn("failed to report anomaly", slog.String("error", err.Error()))
+		} else {
+			atomic.AddInt64(&d.sim.stats.AnomalyReports, 1)
+		}
+		d.waypoint.Status, d.waypoint.Details = "", ""
+	case badWeather:
+		path := fmt.Sprintf("/routes/%d", d.waypoint.RouteID)
+		request := map[string]string{"status": badWeatherStatus, "details": badWeatherDetails}
+		if err := d.sim.api.do(ctx, http.MethodPut, path, authorization, request, nil); err != nil {
+			d.logger().Warn("failed to report bad weather", slog.String("error", err.Error()))
+		} else {
+			atomic.AddInt64(&d.sim.stats.BadWeatherReports, 1)
+		}
+	}
+}
+
+// compareWithOtherWaypoints compares the variation of the samples with the variation of the other waypoints
+// ctx: context of the request
+// authorization: authorization of the request
+// returns: noAnomaly, deviceIssue when only this device varies, badWeather when the route varies as well
+func (d *device) compareWithOtherWaypoints(ctx context.Context, authorization string) int {
+	var readings []routeReading
+	path := fmt.Sprintf("/routes/%d/get-sensor-data", d.waypoint.RouteID)
+	if err := d.sim.api.do(ctx, http.MethodGet, path, authorization, nil, &readings); err != nil {
+		d.logger().Warn("failed to get route sensor data", slog.String("error", err.Error()))
+		return noAnomaly
+	}
+
+	var other [4][]float64
+	for _, reading := range readings {
+		if reading.WaypointID == d.waypoint.ID {
+			continue
+		}
+		other[0] = append(other[0], reading.Temperature)
+		other[1] = append(other[1], reading.Humidity)
+		other[2] = append(other[2], reading.WindSpeed)
+		other[3] = append(other[3], reading.MeanPressure)
+	}
+
+	if len(other[0]) == 0 {
+		return noAnomaly
+	}
+
+	var own [4][]float64
+	for _, sample := range d.buffer {
+		for i, value := range []*float64{sample.Temperature, sample.Humidity, sample.WindSpeed, sample.Pressure} {
+			if value != nil {
+				own[i] = append(own[i], *value)
+			}
+		}
+	}
+
+	anomaly, stable := false, true
+	for i := range own {
+		otherCV := coefficientOfVariation(other[i])
+		if coefficientOfVariation(own[i]) > otherCV*anomalyFactor {
+			anomaly = true
+		}
+		if otherCV >= stableCV {
+			stable = false
+		}
+	}
+
+	switch {
+	case !anomaly:
+		return noAnomaly
+	case stable:
+		return deviceIssue
+	default:
+		return badWeather
+	}
+}
+
+// sleep waits for the real time matching one sample interval
+// ctx: context that interrupts the wait
+// returns: the error of the context if it was canceled
+func (d *device) sleep(ctx context.Context) error {
+	if d.sim.cfg.Speed <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(time.Duration(float64(sampleInterval) / d.sim.cfg.Speed))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// deviceAuthorization returns the authorization for the routes devices may call
+// returns: the device token of the device, the user token if it has none
+func (d *device) deviceAuthorization() string {
+	if token, ok := d.sim.cfg.DeviceTokens[d.serial]; ok {
+		return "Device " + token
+	}
+
+	return d.userAuthorization()
+}
+
+// userAuthorization returns the authorization for the routes only users may call
+// returns: the user token, empty if none is configured
+func (d *device) userAuthorization() string {
+	if d.sim.cfg.UserToken == "" {
+		return ""
+	}
+
+	return "Bearer " + d.sim.cfg.UserToken
+}
+
+// logger returns the logger of the device
+// returns: the logger
+func (d *device) logger() *slog.Logger {
+	return slog.With(slog.String("serial", d.serial), slog.Uint64("waypoint_id", uint64(d.waypoint.ID)))
+}
+
+// coefficientOfVariation returns the standard deviation of the data divided by its mean
+// data: values to analyze
+// returns: the coefficient of variation, 0 for no data or a zero mean
+func coefficientOfVariation(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	mean := utilsMath.Mean(data)
+	if mean == 0 {
+		return 0
+	}
+
+	return utilsMath.StdDev(data) / mean
+}
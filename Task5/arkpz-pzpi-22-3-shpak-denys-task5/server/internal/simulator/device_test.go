@@ -0,0 +1,113 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// samples builds the buffered samples of a device from its temperatures, the other measurements steady
+func samples(temperatures ...float64) []measurement {
+	buffer := make([]measurement, 0, len(temperatures))
+	for _, temperature := range temperatures {
+		buffer = append(buffer, measurement{
+			Temperature: rounded(temperature),
+			Humidity:    rounded(50),
+			WindSpeed:   rounded(5),
+			Pressure:    rounded(1013),
+		})
+	}
+	return buffer
+}
+
+// otherReadings builds the readings of another waypoint of the route from its temperatures
+func otherReadings(waypointID uint, temperatures ...float64) []routeReading {
+	readings := make([]routeReading, 0, len(temperatures))
+	for _, temperature := range temperatures {
+		readings = append(readings, routeReading{
+			WaypointID:   waypointID,
+			Temperature:  temperature,
+			Humidity:     50,
+			WindSpeed:    5,
+			MeanPressure: 1013,
+		})
+	}
+	return readings
+}
+
+func TestCompareWithOtherWaypoints(t *testing.T) {
+	failedRead := measurement{}
+
+	tests := []struct {
+		name     string
+		buffer   []measurement
+		readings []routeReading
+		status   int
+		want     int
+	}{
+		{
+			"device varies like the route",
+			samples(10, 11, 12),
+			append(otherReadings(2, 10, 11, 12), otherReadings(1, 10, 30, 50)...),
+			http.StatusOK, noAnomaly,
+		},
+		{
+			"only the device varies",
+			samples(10, 20, 30),
+			otherReadings(2, 10, 10.1, 10.2),
+			http.StatusOK, deviceIssue,
+		},
+		{
+			"device varies more than an unstable route",
+			samples(5, 20, 35),
+			otherReadings(2, 10, 14, 18),
+			http.StatusOK, badWeather,
+		},
+		{
+			"failed reads are left out",
+			append(samples(10, 10.1), failedRead, failedRead),
+			otherReadings(2, 10, 10.1, 10.2),
+			http.StatusOK, noAnomaly,
+		},
+		{
+			"only the own readings on the route",
+			samples(10, 20, 30),
+			otherReadings(1, 10, 10.1, 10.2),
+			http.StatusOK, noAnomaly,
+		},
+		{
+			"route readings unavailable",
+			samples(10, 20, 30),
+			nil,
+			http.StatusInternalServerError, noAnomaly,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != "/routes/3/get-sensor-data" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_ = json.NewEncoder(w).Encode(tt.readings)
+			}))
+			defer server.Close()
+
+			sim := New(Config{ServerURL: server.URL, UserToken: "token", Timeout: time.Second})
+			d := newDevice(sim, "dev-1", ScenarioCalm, rand.New(rand.NewSource(1)))
+			d.waypoint = waypointDetails{ID: 1, RouteID: 3}
+			d.buffer = tt.buffer
+
+			if got := d.compareWithOtherWaypoints(context.Background(), d.userAuthorization()); got != tt.want {
+				t.Errorf("compareWithOtherWaypoints() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package simulator // import "wayra/internal/simulator"
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Scenario is the weather a virtual device measures
+type Scenario string
+
+// Scenarios of the virtual devices
+const (
+	ScenarioCalm        Scenario = "calm"         // mild weather with a day and night cycle
+	ScenarioStorm       Scenario = "storm"        // a storm building up over the first hours
+	ScenarioFreezing    Scenario = "freezing"     // temperatures below zero and high humidity
+	ScenarioSensorFault Scenario = "sensor_fault" // calm weather measured by a failing sensor
+)
+
+// stormBuildUp is how long a storm takes to reach its full strength
+const stormBuildUp = 2 * time.Hour
+
+// ParseScenarios parses a comma separated list of scenarios
+// value: list of scenarios, e.g. "calm,storm"
+// returns: the scenarios, assigned round-robin to the devices
+func ParseScenarios(value string) ([]Scenario, error) {
+	scenarios := []Scenario{}
+	for _, name := range strings.Split(value, ",") {
+		scenario := Scenario(strings.TrimSpace(name))
+		switch scenario {
+		case ScenarioCalm, ScenarioStorm, ScenarioFreezing, ScenarioSensorFault:
+			scenarios = append(scenarios, scenario)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown scenario %q, must be one of: calm, storm, freezing, sensor_fault", name)
+		}
+	}
+
+	if len(scenarios) == 0 {
+		return nil, fmt.Errorf("no scenario given")
+	}
+
+	return scenarios, nil
+}
+
+// measurement is one sample of the sensors, a nil value is a failed read
+type measurement struct {
+	Temperature *float64 // °C
+	Humidity    *float64 // %
+	WindSpeed   *float64 // m/s
+	Pressure    *float64 // hPa
+}
+
+// sensorFault is the way the sensor of a sensor_fault device is failing
+type sensorFault int
+
+// Faults of the sensor_fault scenario
+const (
+	faultNone   sensorFault = iota // the sensor works
+	faultStuck                     // the sensor repeats its last value
+	faultSpike                     // one measurement jumps to an absurd value
+	faultNoRead                    // the sensor returns no value, like a disconnected DHT22
+	faultSilent                    // the device stops sending
+)
+
+// weather generates the measurements of a virtual device
+type weather struct {
+	scenario Scenario   // scenario of the device
+	rng      *rand.Rand // random source of the device
+	started  time.Time  // virtual time the simulation started at
+
+	temperature float64 // mean temperature of the location
+	humidity    float64 // mean humidity of the location
+	windSpeed   float64 // mean wind speed of the location
+	pressure    float64 // current pressure, drifting slowly
+
+	fault      sensorFault // current fault of the sensor
+	faultUntil time.Time   // time the current fault ends
+	last       measurement // last sample, repeated by a stuck sensor
+}
+
+// newWeather creates the weather of a device
+// scenario: scenario of the device
+// rng: random source of the device
+// started: virtual time the simulation starts at
+// returns: the weather
+func newWeather(scenario Scenario, rng *rand.Rand, started time.Time) *weather {
+	w := &weather{
+		scenario:    scenario,
+		rng:         rng,
+		started:     started,
+		temperature: 5 + rng.Float64()*15,
+		humidity:    50 + rng.Float64()*20,
+		windSpeed:   2 + rng.Float64()*4,
+		pressure:    1008 + rng.Float64()*10,
+	}
+
+	if scenario == ScenarioFreezing {
+		w.temperature = -18 + rng.Float64()*10
+		w.humidity = 80 + rng.Float64()*10
+		w.pressure = 1022 + rng.Float64()*8
+	}
+
+	return w
+}
+
+// sample measures the weather at the given virtual time
+// at: virtual time of the sample
+// returns: the measurement and false if the device does not send anything
+func (w *weather) sample(at time.Time) (measurement, bool) {
+	hour := float64(at.Hour()) + float64(at.Minute())/60
+	// The day is the warmest at 15:00 and the coldest at 03:00
+	daily := math.Sin(2 * math.Pi * (hour - 9) / 24)
+
+	w.pressure += w.rng.NormFloat64() * 0.05
+
+	temperature := w.temperature + 4*daily + w.rng.NormFloat64()*0.2
+	humidity := w.humidity - 8*daily + w.rng.NormFloat64()
+	windSpeed := w.windSpeed + w.rng.NormFloat64()*0.5
+	pressure := w.pressure + w.rng.NormFloat64()*0.3
+
+	switch w.scenario {
+	case ScenarioStorm:
+		intensity := math.Min(1, at.Sub(w.started).Hours()/stormBuildUp.Hours())
+		temperature -= 6 * intensity
+		humidity += (96 - humidity) * intensity
+		windSpeed += 18*intensity + w.rng.Float64()*14*intensity
+		pressure -= 25 * intensity
+	case ScenarioFreezing:
+		temperature = w.temperature + 2*daily + w.rng.NormFloat64()*0.2
+		humidity = w.humidity + w.rng.NormFloat64()
+	}
+
+	current := measurement{
+		Temperature: rounded(temperature),
+		Humidity:    rounded(math.Max(0, math.Min(100, humidity))),
+		WindSpeed:   rounded(math.Max(0, windSpeed)),
+		Pressure:    rounded(pressure),
+	}
+
+	if w.scenario != ScenarioSensorFault {
+		return current, true
+	}
+
+	return w.applyFault(at, current)
+}
+
+// applyFault degrades the measurement according to the current fault of the sensor
+// Faults start about once every two virtual hours and last between 20 and 90 minutes.
+// at: virtual time of the sample
+// current: measurement of a working sensor
+// returns: the measurement and false if the device does not send anything
+func (w *weather) applyFault(at time.Time, current measurement) (measurement, bool) {
+	if w.fault != faultNone && !at.Before(w.faultUntil) {
+		w.fault = faultNone
+	}
+
+	if w.fault == faultNone && w.rng.Float64() < sampleInterval.Minutes()/120 {
+		w.fault = sensorFault(1 + w.rng.Intn(4))
+		w.faultUntil = at.Add(time.Duration(20+w.rng.Intn(70)) * time.Minute)
+	}
+
+	switch w.fault {
+	case faultStuck:
+		if w.last.Temperature != nil {
+			return w.last, true
+		}
+	case faultSpike:
+		spikes := []func(){
+			func() { current.Temperature = rounded(85 + w.rng.Float64()*40) },
+			func() { current.Humidity = rounded(0) },
+			func() { current.WindSpeed = rounded(60 + w.rng.Float64()*90) },
+			func() { current.Pressure = rounded(w.rng.Float64() * 200) },
+		}
+		spikes[w.rng.Intn(len(spikes))]()
+	case faultNoRead:
+		current = measurement{}
+	case faultSilent:
+		return measurement{}, false
+	}
+
+	w.last = current
+	return current, true
+}
+
+// rounded rounds the value to two decimals, as the firmware serializes its floats
+// value: value to round
+// returns: pointer to the rounded value
+func rounded(value float64) *float64 {
+	value = math.Round(value*100) / 100
+	return &value
+}
@@ -0,0 +1,109 @@
+package simulator
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sample is what a device measured at one sample interval
+type sample struct {
+	current measurement // measurement, compared by value by reflect.DeepEqual
+	sent    bool        // whether the device sent anything
+}
+
+// simulate samples the weather of a scenario every sample interval for the duration
+func simulate(scenario Scenario, seed int64, duration time.Duration) []sample {
+	start := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	w := newWeather(scenario, rand.New(rand.NewSource(seed)), start)
+
+	samples := []sample{}
+	for at := start; at.Before(start.Add(duration)); at = at.Add(sampleInterval) {
+		current, sent := w.sample(at)
+		samples = append(samples, sample{current: current, sent: sent})
+	}
+
+	return samples
+}
+
+func TestScenariosAreDeterministicForASeed(t *testing.T) {
+	scenarios := []Scenario{ScenarioCalm, ScenarioStorm, ScenarioFreezing, ScenarioSensorFault}
+
+	for _, scenario := range scenarios {
+		t.Run(string(scenario), func(t *testing.T) {
+			first := simulate(scenario, 42, 6*time.Hour)
+			second := simulate(scenario, 42, 6*time.Hour)
+			if !reflect.DeepEqual(first, second) {
+				t.Error("two runs with the same seed measured different weather")
+			}
+
+			if reflect.DeepEqual(first, simulate(scenario, 43, 6*time.Hour)) {
+				t.Error("runs with different seeds measured the same weather")
+			}
+		})
+	}
+}
+
+func TestScenariosMeasureTheirWeather(t *testing.T) {
+	tests := []struct {
+		scenario Scenario
+		check    func(first, last measurement) bool
+		want     string
+	}{
+		{ScenarioStorm, func(first, last measurement) bool {
+			return *last.WindSpeed > *first.WindSpeed+10 && *last.Pressure < *first.Pressure-15
+		}, "wind rising and pressure falling as the storm builds up"},
+		{ScenarioFreezing, func(first, last measurement) bool {
+			return *first.Temperature < 0 && *last.Temperature < 0 && *last.Humidity >= 75
+		}, "temperatures below zero and high humidity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scenario), func(t *testing.T) {
+			samples := simulate(tt.scenario, 7, stormBuildUp+time.Hour)
+			first, last := samples[0].current, samples[len(samples)-1].current
+			if !tt.check(first, last) {
+				t.Errorf("first sample %v °C %v m/s %v hPa, last %v °C %v m/s %v hPa, want %s",
+					*first.Temperature, *first.WindSpeed, *first.Pressure,
+					*last.Temperature, *last.WindSpeed, *last.Pressure, tt.want)
+			}
+		})
+	}
+}
+
+func TestSensorFaultsShowUp(t *testing.T) {
+	faulty := false
+	for _, s := range simulate(ScenarioSensorFault, 42, 24*time.Hour) {
+		if !s.sent || s.current.Temperature == nil || *s.current.Temperature > 60 || *s.current.WindSpeed > 50 {
+			faulty = true
+			break
+		}
+	}
+	if !faulty {
+		t.Error("a day of the sensor_fault scenario had no silent, failed or absurd sample")
+	}
+}
+
+func TestParseScenarios(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []Scenario
+		wantErr bool
+	}{
+		{"calm", []Scenario{ScenarioCalm}, false},
+		{"calm, storm,freezing,sensor_fault", []Scenario{ScenarioCalm, ScenarioStorm, ScenarioFreezing, ScenarioSensorFault}, false},
+		{"storm,,", []Scenario{ScenarioStorm}, false},
+		{"", nil, true},
+		{"calm,hail", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseScenarios(tt.value)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseScenarios(%q) = %v, %v, want %v and an error: %v", tt.value, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package simulator emulates the ESP32 firmware of the waypoint devices so the server
+// can be exercised without hardware. Every virtual device follows the protocol of the
+// firmware: it finds its waypoint by serial, pushes its coordinates, fetches its config,
+// samples its sensors every 10 seconds, sends a reading every send_data_frequency minutes
+// and compares its readings with the other waypoints of the route.
+package simulator // import "wayra/internal/simulator"
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sampleInterval is how often the firmware reads its sensors
+const sampleInterval = 10 * time.Second
+
+// Config is the configuration of a simulation
+type Config struct {
+	ServerURL       string            // address of the wayra server
+	UserToken       string            // JWT of a company manager, used for the routes devices can not call
+	DeviceTokens    map[string]string // device tokens by device serial
+	Devices         int               // number of virtual devices
+	SerialPrefix    string            // serial of the n-th device is the prefix followed by n
+	Scenarios       []Scenario        // scenarios assigned round-robin to the devices
+	Seed            int64             // seed making the weather and the faults reproducible
+	Start           time.Time         // virtual time the simulation starts at
+	Speed           float64           // virtual seconds per real second, 0 runs as fast as possible
+	Readings        int               // readings each device sends before stopping, 0 for no limit
+	RouteID         uint              // route the waypoints of unknown serials are created on, 0 to not create them
+	PushCoordinates bool              // whether the devices push random coordinates on start, like the firmware
+	ClockOffset     time.Duration     // offset added to the dates sent by the devices
+	Timeout         time.Duration     // timeout of a request to the server
+}
+
+// Stats counts what the devices did during a simulation
+type Stats struct {
+	Devices           int64 // devices that finished their setup
+	Sent              int64 // readings sent
+	Accepted          int64 // readings stored by the server
+	Rejected          int64 // readings refused by the server
+	Failed            int64 // requests that failed without a response
+	Skipped           int64 // readings a silent device did not send
+	AnomalyReports    int64 // anomaly_detected statuses reported
+	BadWeatherReports int64 // bad_weather_detected conditions reported
+}
+
+// String implements the fmt.Stringer interface
+func (s Stats) String() string {
+	return fmt.Sprintf(
+		"devices=%d sent=%d accepted=%d rejected=%d failed=%d skipped=%d anomaly_reports=%d bad_weather_reports=%d",
+		s.Devices, s.Sent, s.Accepted, s.Rejected, s.Failed, s.Skipped, s.AnomalyReports, s.BadWeatherReports,
+	)
+}
+
+// Simulator runs the virtual devices
+type Simulator struct {
+	cfg   Config     // configuration of the simulation
+	api   *apiClient // client of the server
+	stats Stats      // counters updated atomically by the devices
+}
+
+// New creates a new Simulator
+// cfg: configuration of the simulation
+// returns: a new Simulator
+func New(cfg Config) *Simulator {
+	return &Simulator{
+		cfg: cfg,
+		api: newAPIClient(cfg.ServerURL, &http.Client{Timeout: cfg.Timeout}),
+	}
+}
+
+// Run runs every device until it sent its readings or the context is canceled
+// ctx: context that stops the simulation
+// returns: the stats of the simulation
+func (s *Simulator) Run(ctx context.Context) Stats {
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.Devices; i++ {
+		serial := fmt.Sprintf("%s%d", s.cfg.SerialPrefix, i+1)
+		scenario := s.cfg.Scenarios[i%len(s.cfg.Scenarios)]
+		rng := rand.New(rand.NewSource(s.cfg.Seed + int64(i)))
+
+		d := newDevice(s, serial, scenario, rng)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.run(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("device stopped", slog.String("serial", d.serial), slog.String("error", err.Error()))
+			}
+		}()
+	}
+
+	wg.Wait()
+	return s.Stats()
+}
+
+// Stats returns the current stats of the simulation
+// returns: a snapshot of the stats
+func (s *Simulator) Stats() Stats {
+	return Stats{
+		Devices:           atomic.LoadInt64(&s.stats.Devices),
+		Sent:              atomic.LoadInt64(&s.stats.Sent),
+		Accepted:          atomic.LoadInt64(&s.stats.Accepted),
+		Rejected:          atomic.LoadInt64(&s.stats.Rejected),
+		Failed:            atomic.LoadInt64(&s.stats.Failed),
+		Skipped:           atomic.LoadInt64(&s.stats.Skipped),
+		AnomalyReports:    atomic.LoadInt64(&s.stats.AnomalyReports),
+		BadWeatherReports: atomic.LoadInt64(&s.stats.BadWeatherReports),
+	}
+}